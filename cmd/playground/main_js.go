@@ -0,0 +1,58 @@
+//go:build js && wasm
+
+// Command playground compares two SCIP indexes of a module in WebAssembly, for
+// an in-browser playground. Built with
+//
+//	GOOS=js GOARCH=wasm go build -o playground.wasm ./cmd/playground
+//
+// and loaded with wasm_exec.js from the Go distribution, it registers
+// compareIndexes(module, oldIndex, newIndex), which takes the indexes as
+// Uint8Arrays and returns the report as JSON, or an Error. Built with
+// GOOS=wasip1, it compares index files instead:
+//
+//	wasmtime --dir=. playground.wasm <module> old.scip new.scip [project.scip...]
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"go-upgrade-checker/pkg/upgradecheck"
+)
+
+func main() {
+	js.Global().Set("compareIndexes", js.FuncOf(compareIndexes))
+	select {}
+}
+
+// compareIndexes is the JavaScript binding of upgradecheck.CompareIndexes
+func compareIndexes(_ js.Value, args []js.Value) any {
+	if len(args) != 3 {
+		return jsError("compareIndexes(module, oldIndex, newIndex) takes 3 arguments")
+	}
+	report, err := upgradecheck.CompareIndexes(upgradecheck.IndexComparison{
+		Module: args[0].String(),
+		Old:    bytesOf(args[1]),
+		New:    bytesOf(args[2]),
+	})
+	if err != nil {
+		return jsError(err.Error())
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return jsError(err.Error())
+	}
+	return string(data)
+}
+
+// bytesOf copies a Uint8Array into Go memory
+func bytesOf(array js.Value) []byte {
+	data := make([]byte, array.Get("length").Int())
+	js.CopyBytesToGo(data, array)
+	return data
+}
+
+// jsError returns an Error with the message; panicking would stop the program
+func jsError(msg string) any {
+	return js.Global().Get("Error").New(msg)
+}
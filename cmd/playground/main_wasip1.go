@@ -0,0 +1,53 @@
+//go:build wasip1
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-upgrade-checker/pkg/upgradecheck"
+)
+
+func main() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "usage: playground <module> <old.scip> <new.scip> [project.scip...]")
+		os.Exit(2)
+	}
+	in := upgradecheck.IndexComparison{Module: os.Args[1]}
+	var err error
+	if in.Old, err = os.ReadFile(os.Args[2]); err != nil {
+		fail(err)
+	}
+	if in.New, err = os.ReadFile(os.Args[3]); err != nil {
+		fail(err)
+	}
+	for _, path := range os.Args[4:] {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fail(err)
+		}
+		if in.Projects == nil {
+			in.Projects = make(map[string][]byte)
+		}
+		in.Projects[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))] = data
+	}
+
+	report, err := upgradecheck.CompareIndexes(in)
+	if err != nil {
+		fail(err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println(string(data))
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(2)
+}
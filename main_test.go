@@ -1,3 +1,60 @@
 package main
 
-// TODO: add tests
\ No newline at end of file
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the command instead of the tests when the test binary is
+// started by runCommand
+func TestMain(m *testing.M) {
+	if os.Getenv("GO_UPGRADE_CHECKER_RUN_MAIN") == "1" {
+		os.Args = append([]string{"go-upgrade-checker"}, os.Args[1:]...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCommand runs the command with args and returns its combined output and
+// exit code
+func runCommand(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GO_UPGRADE_CHECKER_RUN_MAIN=1", "GOPROXY=off")
+	cmd.Dir = t.TempDir()
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("running the command: %v", err)
+	}
+	return string(out), 0
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{"version", []string{"version"}, 0, "go-upgrade-checker "},
+		{"unknown flag", []string{"--no-such-flag"}, 2, "flag provided but not defined"},
+		// GOPROXY=off leaves no way to find the latest version
+		{"no new version", []string{"--module=example.com/m", "--old-version=v1.0.0"}, 2, "pass --new-version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, code := runCommand(t, tt.args...)
+			if code != tt.wantCode || !strings.Contains(out, tt.wantOut) {
+				t.Errorf("go-upgrade-checker %s exited %d with\n%s\nwant exit %d mentioning %q", strings.Join(tt.args, " "), code, out, tt.wantCode, tt.wantOut)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// actionsMode is what --github-actions publishes besides the report: workflow
+// annotations at the usages of each finding and, optionally, a comment on the
+// pull request. It is nil unless enabled.
+var actionsMode *githubActions
+
+type githubActions struct {
+	// Comment posts the markdown report on the pull request the workflow runs
+	// for, updating the comment of an earlier run
+	Comment bool
+	// out is where workflow commands are written: stderr, which the runner
+	// reads them from as well, so they never mix into a report on stdout
+	out io.Writer
+}
+
+// maxCommentLength is GitHub's limit on the body of a comment
+const maxCommentLength = 65536
+
+// newGitHubActions enables the mode when running in GitHub Actions, returning
+// nil with a warning elsewhere
+func newGitHubActions(comment bool) *githubActions {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		log.Printf("Warning: --github-actions is ignored outside of GitHub Actions (GITHUB_ACTIONS is not true)")
+		return nil
+	}
+	return &githubActions{Comment: comment, out: os.Stderr}
+}
+
+// publish annotates the findings of the reports and comments on the pull
+// request
+func (a *githubActions) publish(reports ...*Report) error {
+	if a == nil {
+		return nil
+	}
+	writeWorkflowAnnotations(a.out, reports)
+	if !a.Comment {
+		return nil
+	}
+	repo, err := githubRepoFromEnv()
+	if err != nil {
+		return err
+	}
+	pr, ok := pullRequestNumber()
+	if !ok {
+		log.Printf("Warning: not commenting: the workflow doesn't run for a pull request")
+		return nil
+	}
+	url, created, err := repo.upsertComment(pr, commentMarker(reports), commentBody(reports))
+	if err != nil {
+		return fmt.Errorf("failed to comment on pull request #%d: %w", pr, err)
+	}
+	if created {
+		log.Printf("Commented on pull request #%d: %s", pr, url)
+	} else {
+		log.Printf("Updated the comment on pull request #%d: %s", pr, url)
+	}
+	return nil
+}
+
+// workflowCommand maps severities onto the annotation commands of Actions
+func workflowCommand(s Severity) string {
+	switch {
+	case s >= SeverityBreaking:
+		return "error"
+	case s == SeverityWarning:
+		return "warning"
+	default:
+		return "notice"
+	}
+}
+
+// writeWorkflowAnnotations writes an ::error, ::warning or ::notice workflow
+// command per usage of each finding, which Actions shows on the lines of the
+// pull request diff and the run summary. Findings without usages are
+// annotated without a location.
+func writeWorkflowAnnotations(w io.Writer, reports []*Report) {
+	for _, report := range reports {
+		for _, service := range report.Services {
+			root := service.dir
+			if root == "" {
+				root = service.Path
+			}
+			for _, f := range service.Findings {
+				title := fmt.Sprintf("%s@%s: %s %s", report.Module, report.NewVersion, f.Symbol, f.Kind)
+				message := f.Summary()
+				command := workflowCommand(f.Severity)
+				if f.Accepted {
+					// Accepted in the baseline; shown without failing the review
+					command = "notice"
+				}
+				if len(f.Notes) > 0 {
+					message += "\n" + strings.Join(f.Notes, "\n")
+				}
+				if len(f.Usages) == 0 {
+					fmt.Fprintf(w, "::%s title=%s::%s\n", command, escapeProperty(title), escapeData(message))
+					continue
+				}
+				for _, loc := range f.Usages {
+					props := "file=" + escapeProperty(repoRelative(filepath.Join(root, filepath.FromSlash(loc.Path))))
+					if loc.Line > 0 {
+						props += fmt.Sprintf(",line=%d", loc.Line)
+						if loc.Column > 0 {
+							props += fmt.Sprintf(",col=%d", loc.Column)
+						}
+					}
+					fmt.Fprintf(w, "::%s %s,title=%s::%s\n", command, props, escapeProperty(title), escapeData(message))
+				}
+			}
+		}
+	}
+}
+
+// escapeData escapes the message of a workflow command
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// pullRequestNumber returns the pull request the workflow runs for, read from
+// the event payload, or from refs/pull/<n>/merge
+func pullRequestNumber() (int, bool) {
+	if path := os.Getenv("GITHUB_EVENT_PATH"); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			var event struct {
+				Number      int `json:"number"`
+				PullRequest struct {
+					Number int `json:"number"`
+				} `json:"pull_request"`
+			}
+			if json.Unmarshal(data, &event) == nil {
+				if event.PullRequest.Number > 0 {
+					return event.PullRequest.Number, true
+				}
+				if event.Number > 0 {
+					return event.Number, true
+				}
+			}
+		}
+	}
+	ref, ok := strings.CutPrefix(os.Getenv("GITHUB_REF"), "refs/pull/")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(ref, "/merge"))
+	return n, err == nil
+}
+
+// commentMarker is a hidden line identifying the comment of the checked
+// modules, so reruns update it instead of adding another
+func commentMarker(reports []*Report) string {
+	var modules []string
+	for _, report := range reports {
+		modules = append(modules, report.Module)
+	}
+	return fmt.Sprintf("<!-- go-upgrade-checker: %s -->", strings.Join(modules, " "))
+}
+
+// commentBody renders the reports in markdown, cut to GitHub's limit
+func commentBody(reports []*Report) string {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, commentMarker(reports))
+	for _, report := range reports {
+		printMarkdown(&buf, report, false)
+	}
+	body := buf.String()
+	if len(body) > maxCommentLength {
+		const notice = "\n\n_The report was truncated; see the workflow run for all findings._\n"
+		body = body[:maxCommentLength-len(notice)] + notice
+	}
+	return body
+}
+
+// upsertComment updates the comment on an issue or pull request holding
+// marker, or creates one, returning its URL and whether it was created
+func (g *githubRepo) upsertComment(issue int, marker, body string) (string, bool, error) {
+	type comment struct {
+		ID      int64  `json:"id"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	}
+	for page := 1; ; page++ {
+		data, err := g.githubAPI(http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", g.Repository, issue, page), nil)
+		if err != nil {
+			return "", false, err
+		}
+		var comments []comment
+		if err := json.Unmarshal(data, &comments); err != nil {
+			return "", false, fmt.Errorf("failed to parse comments: %w", err)
+		}
+		for _, c := range comments {
+			if strings.HasPrefix(c.Body, marker) {
+				data, err := g.githubAPI(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", g.Repository, c.ID), map[string]string{"body": body})
+				if err != nil {
+					return "", false, err
+				}
+				var updated comment
+				if err := json.Unmarshal(data, &updated); err != nil {
+					return "", false, fmt.Errorf("failed to parse comment: %w", err)
+				}
+				return updated.HTMLURL, false, nil
+			}
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+
+	data, err := g.githubAPI(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", g.Repository, issue), map[string]string{"body": body})
+	if err != nil {
+		return "", false, err
+	}
+	var created comment
+	if err := json.Unmarshal(data, &created); err != nil {
+		return "", false, fmt.Errorf("failed to parse comment: %w", err)
+	}
+	return created.HTMLURL, true, nil
+}
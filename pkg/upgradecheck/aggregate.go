@@ -0,0 +1,102 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"strings"
+)
+
+// serviceReport holds the findings for one project analyzed in a run. Monorepos
+// pass several project paths, one per service, and get a section for each.
+type serviceReport struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Findings []Finding `json:"findings"`
+	// UsedSymbols are the module symbols the service uses, changed or not
+	UsedSymbols []string `json:"used_symbols,omitempty"`
+	// Targets is the per-target impact in Bazel workspaces (--bazel)
+	Targets []targetImpact `json:"targets,omitempty"`
+	// Build compares building a package against both versions (--build-impact)
+	Build *buildImpact `json:"build,omitempty"`
+	// Unanalyzed are the packages of the project scip-go couldn't index; their
+	// usages are missing from the findings
+	Unanalyzed []packageGap `json:"unanalyzed,omitempty"`
+
+	indexPath string
+	// dir holds the service's sources; it differs from Path when replaying a
+	// recorded session
+	dir string
+}
+
+// Verdict summarizes the service's findings as a single word for rollup tables
+func (s *serviceReport) Verdict() string {
+	if len(s.Findings) == 0 {
+		return "ok"
+	}
+	// Findings are sorted most severe first
+	return s.Findings[0].Severity.String()
+}
+
+// printReport renders the findings of every service in the requested view. A
+// single service is printed as-is; several services get one section each
+// followed by an overall rollup table.
+func printReport(services []*serviceReport, view string, byOwner bool) {
+	printView := func(findings []Finding, root string) {
+		if view == ViewSideBySide {
+			printSideBySide(findings, root)
+		} else {
+			printFindings(findings, root)
+		}
+	}
+	printFindingsIn := printView
+	if byOwner {
+		printFindingsIn = func(findings []Finding, root string) {
+			if len(findings) == 0 {
+				printView(findings, root)
+				return
+			}
+			owners, groups := findingsByOwner(findings)
+			for i, owner := range owners {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("Owner: %s\n", owner)
+				printView(groups[owner], root)
+			}
+		}
+	}
+
+	if len(services) == 1 {
+		printFindingsIn(services[0].Findings, services[0].Path)
+		printTargets(services[0].Targets)
+		printBuildImpact(services[0].Build)
+		return
+	}
+
+	for _, service := range services {
+		title := fmt.Sprintf("Service: %s (%s)", service.Name, service.Path)
+		fmt.Println(title)
+		fmt.Println(strings.Repeat("=", len(title)))
+		printFindingsIn(service.Findings, service.Path)
+		printTargets(service.Targets)
+		printBuildImpact(service.Build)
+		fmt.Println()
+	}
+
+	printRollup(services)
+}
+
+// printRollup writes a service × verdict table covering every analyzed service
+func printRollup(services []*serviceReport) {
+	nameWidth := len("SERVICE")
+	for _, service := range services {
+		if len(service.Name) > nameWidth {
+			nameWidth = len(service.Name)
+		}
+	}
+
+	fmt.Println("Rollup:")
+	fmt.Printf("  %-*s  %-8s  %s\n", nameWidth, "SERVICE", "VERDICT", "FINDINGS")
+	for _, service := range services {
+		fmt.Printf("  %-*s  %-8s  %d\n", nameWidth, service.Name, service.Verdict(), len(service.Findings))
+	}
+}
@@ -0,0 +1,103 @@
+package upgradecheck
+
+import (
+	"regexp"
+	"strings"
+)
+
+// unexportedAlias matches an exported alias of an unexported type of the same
+// package, e.g. "type Client = client"
+var unexportedAlias = regexp.MustCompile(`^type ([A-Z]\w*) = ([a-z_]\w*)$`)
+
+// unexportedAliases maps the unexported types that exported aliases stand for
+// to the alias names
+func unexportedAliases(symbols map[string][]string) map[string]string {
+	aliases := make(map[string]string)
+	for name, defs := range symbols {
+		for _, def := range defs {
+			m := unexportedAlias.FindStringSubmatch(normalizeDefinition(def))
+			if m != nil && m[1] == name {
+				aliases[m[2]] = name
+			}
+		}
+	}
+	return aliases
+}
+
+// aliasedKey returns the key an aliased unexported type, or one of its
+// members, is filed under once renamed to its alias: "client" becomes "Client"
+// and "client#Do" "Client#Do"
+func aliasedKey(key string, aliases map[string]string) (typeName, renamed string, ok bool) {
+	typeName, member, isMember := strings.Cut(key, "#")
+	alias, ok := aliases[typeName]
+	if !ok {
+		return "", "", false
+	}
+	if isMember {
+		return typeName, alias + "#" + member, true
+	}
+	return typeName, alias, true
+}
+
+// resolveAliases gives every exported alias of an unexported type the
+// definitions of that type and its members, with the type renamed to the
+// alias. Changes to the implementation type then surface under the name the
+// project actually uses, and a version replacing the alias by a real type of
+// the same shape compares equal.
+func resolveAliases(symbols map[string][]string, aliases map[string]string) {
+	// Renamed keys may come up again in the loop, but exported names are
+	// never aliased
+	for key := range symbols {
+		target, renamed, ok := aliasedKey(key, aliases)
+		if !ok {
+			continue
+		}
+		defs := symbols[key]
+		resolved := make([]string, 0, len(defs))
+		for _, def := range defs {
+			resolved = append(resolved, renameType(def, target, aliases[target]))
+		}
+		// The alias's own "type Client = client" gives way to the type
+		if renamed == aliases[target] {
+			symbols[renamed] = resolved
+		} else {
+			symbols[renamed] = append(symbols[renamed], resolved...)
+		}
+		delete(symbols, key)
+	}
+}
+
+// renameType replaces the type name in a type declaration or method receiver
+func renameType(def, from, to string) string {
+	switch {
+	case strings.HasPrefix(def, "type "+from):
+		return "type " + to + strings.TrimPrefix(def, "type "+from)
+	case strings.HasPrefix(def, "func ("):
+		end := matchingParen(def, len("func "))
+		if end < 0 {
+			return def
+		}
+		word := regexp.MustCompile(`\b` + regexp.QuoteMeta(from) + `\b`)
+		return word.ReplaceAllString(def[:end], to) + def[end:]
+	}
+	return def
+}
+
+// aliasUsages attributes the project's uses of an aliased unexported type,
+// such as calls of its methods, to the exported alias
+func aliasUsages(usedSymbols map[string][]string, usedIn usageSites, aliases map[string]string) {
+	for key := range usedSymbols {
+		if _, renamed, ok := aliasedKey(key, aliases); ok {
+			usedSymbols[renamed] = append(usedSymbols[renamed], usedSymbols[key]...)
+			delete(usedSymbols, key)
+		}
+	}
+	for key := range usedIn {
+		if _, renamed, ok := aliasedKey(key, aliases); ok {
+			for _, loc := range usedIn[key] {
+				usedIn.add(renamed, loc)
+			}
+			delete(usedIn, key)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package upgradecheck
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveAliases(t *testing.T) {
+	symbols := map[string][]string{
+		"Client":       {"type Client = client"},
+		"client":       {"type client struct { Timeout int }"},
+		"client#Do":    {"func (c *client) Do(req *Request) error"},
+		"client#Close": {"func (client) Close() error"},
+		"server":       {"type server struct{}"},
+		"server#Serve": {"func (s *server) Serve() error"},
+		"Handler":      {"type Handler = http.Handler"},
+		"NewClient":    {"func NewClient() *client"},
+	}
+	aliases := unexportedAliases(symbols)
+	if want := map[string]string{"client": "Client"}; !reflect.DeepEqual(aliases, want) {
+		t.Fatalf("unexportedAliases() = %v, want %v", aliases, want)
+	}
+
+	resolveAliases(symbols, aliases)
+	want := map[string][]string{
+		"Client":       {"type Client struct { Timeout int }"},
+		"Client#Do":    {"func (c *Client) Do(req *Request) error"},
+		"Client#Close": {"func (Client) Close() error"},
+		"server":       {"type server struct{}"},
+		"server#Serve": {"func (s *server) Serve() error"},
+		"Handler":      {"type Handler = http.Handler"},
+		"NewClient":    {"func NewClient() *client"},
+	}
+	if !reflect.DeepEqual(symbols, want) {
+		t.Errorf("resolveAliases() =\n%v\nwant\n%v", symbols, want)
+	}
+}
+
+func TestAliasUsages(t *testing.T) {
+	aliases := map[string]string{"client": "Client"}
+	used := map[string][]string{
+		"client":    {"Timeout"},
+		"Client":    {"Retries"},
+		"client#Do": nil,
+		"clients":   nil,
+	}
+	sites := usageSites{
+		"client":    {{Path: "a.go", Line: 1}},
+		"client#Do": {{Path: "a.go", Line: 2}},
+		"Client#Do": {{Path: "b.go", Line: 3}},
+	}
+	aliasUsages(used, sites, aliases)
+
+	wantUsed := map[string][]string{
+		"Client":    {"Retries", "Timeout"},
+		"Client#Do": nil,
+		"clients":   nil,
+	}
+	if !reflect.DeepEqual(used, wantUsed) {
+		t.Errorf("usedSymbols = %v, want %v", used, wantUsed)
+	}
+	wantSites := usageSites{
+		"Client":    {{Path: "a.go", Line: 1}},
+		"Client#Do": {{Path: "b.go", Line: 3}, {Path: "a.go", Line: 2}},
+	}
+	if !reflect.DeepEqual(sites, wantSites) {
+		t.Errorf("usedIn = %v, want %v", sites, wantSites)
+	}
+
+	packages := symbolPackages{
+		"client":    {"example.com/m/internal": true},
+		"client#Do": {"example.com/m/internal": true},
+		"Client":    {"example.com/m": true},
+	}
+	packages.rename(aliases)
+	wantPackages := symbolPackages{
+		"Client":    {"example.com/m": true, "example.com/m/internal": true},
+		"Client#Do": {"example.com/m/internal": true},
+	}
+	if !reflect.DeepEqual(packages, wantPackages) {
+		t.Errorf("rename() = %v, want %v", packages, wantPackages)
+	}
+}
+
+func TestRenameType(t *testing.T) {
+	tests := []struct {
+		def, want string
+	}{
+		{"type client struct{}", "type Client struct{}"},
+		{"type client[T any] struct{}", "type Client[T any] struct{}"},
+		{"func (c *client) Do(client *client) error", "func (c *Client) Do(client *client) error"},
+		{"func (c clientOptions) Apply()", "func (c clientOptions) Apply()"},
+		{"func newClient() *client", "func newClient() *client"},
+	}
+	for _, tt := range tests {
+		if got := renameType(tt.def, "client", "Client"); got != tt.want {
+			t.Errorf("renameType(%q) = %q, want %q", tt.def, got, tt.want)
+		}
+	}
+}
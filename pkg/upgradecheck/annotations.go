@@ -0,0 +1,112 @@
+package upgradecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// goModAnnotation attaches a finding to the go.mod line requiring the module,
+// the line a dependency bump changes, so review tools can comment the impact
+// right where the upgrade is made
+type goModAnnotation struct {
+	// Path is the go.mod relative to the repository root, slash separated
+	Path        string   `json:"path"`
+	Line        int      `json:"line"`
+	Severity    Severity `json:"severity"`
+	Symbol      string   `json:"symbol"`
+	Fingerprint string   `json:"fingerprint"`
+	Message     string   `json:"message"`
+}
+
+// goModAnnotations maps the findings of every service onto the require line of
+// modulePath in the service's go.mod. Services whose go.mod doesn't require
+// the module directly are skipped with a warning.
+func goModAnnotations(services []*serviceReport, modulePath string) ([]goModAnnotation, error) {
+	annotations := []goModAnnotation{}
+	for _, service := range services {
+		if len(service.Findings) == 0 {
+			continue
+		}
+		goModPath, line, err := requireLine(service.dir, modulePath)
+		if err != nil {
+			return nil, err
+		}
+		if line == 0 {
+			log.Printf("Warning: %s does not require %s directly; its findings are not annotated", service.Path, modulePath)
+			continue
+		}
+		for _, f := range service.Findings {
+			msg := f.Summary()
+			if len(f.Usages) > 0 {
+				msg += fmt.Sprintf(" (used in %d files across %d packages)", len(f.Files()), len(f.Packages()))
+			}
+			annotations = append(annotations, goModAnnotation{
+				Path:        repoRelative(goModPath),
+				Line:        line,
+				Severity:    f.Severity,
+				Symbol:      f.Symbol,
+				Fingerprint: f.Fingerprint(),
+				Message:     msg,
+			})
+		}
+	}
+	return annotations, nil
+}
+
+// requireLine returns the go.mod governing dir and the line of its require
+// directive for modulePath, or 0 when it has none
+func requireLine(dir, modulePath string) (string, int, error) {
+	goModPath, err := findGoMod(dir)
+	if err != nil || goModPath == "" {
+		return goModPath, 0, err
+	}
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return "", 0, err
+	}
+	file, err := modfile.ParseLax(goModPath, data, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to parse %s: %w", goModPath, err)
+	}
+	for _, req := range file.Require {
+		if req.Mod.Path == modulePath && req.Syntax != nil {
+			return goModPath, req.Syntax.Start.Line, nil
+		}
+	}
+	return goModPath, 0, nil
+}
+
+// repoRelative returns path relative to the root of the git repository
+// containing it, which is how review tools address files, or unchanged outside
+// of a repository
+func repoRelative(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			if rel, err := filepath.Rel(dir, abs); err == nil {
+				return filepath.ToSlash(rel)
+			}
+			return path
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+	}
+}
+
+// writeGoModAnnotations writes the annotations as a JSON array to path
+func writeGoModAnnotations(path string, annotations []goModAnnotation) error {
+	data, err := json.MarshalIndent(annotations, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,289 @@
+// Package upgradecheck reports how upgrading a Go module dependency affects
+// the projects using it: the symbols they use that the new version removes or
+// changes, and changes in behavior. It is the analysis behind the
+// go-upgrade-checker command, for tools that embed it instead of running the
+// binary. The command line itself is Main. Checks return their report in the
+// types of the report package, which mirror the JSON report and only ever gain
+// fields.
+package upgradecheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	reportapi "go-upgrade-checker/report"
+)
+
+// Options configures a check. The fields mirror the flags of the command line;
+// zero values select the same defaults, except where noted.
+type Options struct {
+	// ProjectPaths are the projects using the module, each reported as a
+	// service of its own. Defaults to the current directory.
+	ProjectPaths []string
+	// Module is the module path of the dependency
+	Module string
+	// OldVersion defaults to the version the projects' go.mod requires
+	OldVersion string
+	// NewVersion defaults to the latest release of the module
+	NewVersion string
+	// IncludePrerelease lets NewVersion default to a pre-release
+	IncludePrerelease bool
+	// AllowRetracted checks a retracted new version instead of failing
+	AllowRetracted bool
+
+	// ProjectIndexes are pre-built SCIP indexes of the projects, one per
+	// project of ProjectPaths, used instead of indexing them. OldIndex and
+	// NewIndex are pre-built indexes of the module versions.
+	ProjectIndexes []string
+	OldIndex       string
+	NewIndex       string
+
+	// OldRepoURL and NewRepoURL are the repositories to fetch versions from
+	// that the module proxy can't serve; they default to https://<module>.git
+	OldRepoURL string
+	NewRepoURL string
+	// VCS is the version control system of the repositories: "git", "hg" or
+	// "svn"; empty detects it
+	VCS string
+	// VCSHosts are the hosts, with path.Match wildcards, repositories may be
+	// cloned from; empty allows any host GOVCS does
+	VCSHosts []string
+	// Engine compares the module versions with scip-go indexes, "scip", the
+	// default, or with the export data the go toolchain compiles,
+	// "exportdata"
+	Engine string
+	// Backend builds indexes with scip-go, "scip", or with go/packages,
+	// "gopackages"; empty uses scip-go when it is on the PATH
+	Backend string
+	// CacheDir holds cached dependency indexes; empty disables caching
+	CacheDir string
+	// CacheMaxBytes bounds the cache before least recently used entries are
+	// evicted; 0 means unbounded
+	CacheMaxBytes int64
+	// CacheSignKey is the PEM file of an Ed25519 private key signing the
+	// cache entries stored; CacheVerifyKey that of the public key entries
+	// must be signed with to be used
+	CacheSignKey   string
+	CacheVerifyKey string
+
+	// Platforms index the projects for each goos/goarch[:tag1+tag2], e.g.
+	// "windows/amd64"
+	Platforms []string
+	// Packages limits indexing and reporting to these project packages, e.g.
+	// "./cmd/api/..."
+	Packages []string
+	// IgnoreDirs are directory names whose usages are ignored. nil ignores
+	// example, examples and testdata directories; an empty slice none.
+	IgnoreDirs []string
+	// MinConfidence drops findings rated below "exact", "high" or
+	// "heuristic", the default
+	MinConfidence string
+	// FollowReexports counts references to project declarations re-exporting
+	// a module symbol as usages of that symbol
+	FollowReexports bool
+	// Deep compares function bodies and variable values between versions;
+	// DeepThreshold is the percentage of changed lines that flags a function,
+	// 50 by default
+	Deep          bool
+	DeepThreshold int
+	// CommitHints notes the commits marked as breaking on related findings
+	CommitHints bool
+	// StableAPI also reports the used symbols the new version's api/*.txt
+	// stability files don't list
+	StableAPI bool
+
+	// CriticalFiles and CriticalPackages escalate findings used in that many
+	// files or more than that many packages to critical. Unlike the flags,
+	// 0 disables the escalation.
+	CriticalFiles    int
+	CriticalPackages int
+	// DeletingPackages are project packages scheduled for deletion; findings
+	// only used there are reported one severity lower
+	DeletingPackages []string
+	// ExcludePackages are project packages whose usages aren't reported
+	ExcludePackages []string
+	// IgnoreSymbols are symbols not to report, as reported or prefixed by the
+	// import path of their package, with path.Match wildcards
+	IgnoreSymbols []string
+	// MaxSeverity caps the severity of findings at "info", "warning",
+	// "breaking" or "critical"; empty leaves them as graded
+	MaxSeverity string
+
+	// Env sets variables in the environment of git, go and scip-go
+	Env map[string]string
+	// GitSSH are the hosts whose repositories git fetches over SSH instead
+	// of https
+	GitSSH []string
+	// GitTokens are access tokens by host that git sends when fetching from
+	// https://<host>
+	GitTokens map[string]string
+}
+
+// Checker runs checks. Checks share process-wide state, such as the
+// environment of subprocesses and the temporary resources to remove, so only
+// one runs at a time, whichever Checker starts it.
+type Checker struct {
+	// Log receives the warnings of a check and the diagnostics of its
+	// subprocesses; nil discards them. The standard logger writes to it while
+	// a check runs.
+	Log io.Writer
+}
+
+// checkMu serializes checks
+var checkMu sync.Mutex
+
+// Check checks upgrading opts.Module with a Checker discarding logs
+func Check(ctx context.Context, opts Options) (*reportapi.Report, error) {
+	var c Checker
+	return c.Check(ctx, opts)
+}
+
+// Check compares the two versions of opts.Module and returns the findings
+// for each project. Canceling ctx stops the subprocesses of the check and
+// returns its error. Temporary resources are removed before returning.
+func (c *Checker) Check(ctx context.Context, opts Options) (*reportapi.Report, error) {
+	if opts.Module == "" {
+		return nil, errors.New("no module to check")
+	}
+	checkOpts, err := opts.checkOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	checkMu.Lock()
+	defer checkMu.Unlock()
+
+	logOutput := io.Discard
+	if c.Log != nil {
+		logOutput = c.Log
+	}
+	prevLog := log.Writer()
+	log.SetOutput(logOutput)
+	defer log.SetOutput(prevLog)
+
+	prevContext, prevCancel := runContext, cancelRun
+	runContext, cancelRun = context.WithCancelCause(ctx)
+	defer func() {
+		cancelRun(nil)
+		runContext, cancelRun = prevContext, prevCancel
+	}()
+	// Cleanups run first, still with the environment of the check
+	prevEnv := toolEnv
+	defer func() { toolEnv = prevEnv }()
+	defer cleanups.run()
+
+	env, err := withGitAuth(buildToolEnv(os.Environ(), opts.Env), opts.GitSSH, opts.GitTokens)
+	if err != nil {
+		return nil, err
+	}
+	toolEnv = env
+
+	prevEngine := moduleEngine
+	if opts.Engine != "" {
+		moduleEngine = opts.Engine
+	}
+	defer func() { moduleEngine = prevEngine }()
+	prevBackend := indexBackend
+	if opts.Backend != "" {
+		indexBackend = opts.Backend
+	}
+	defer func() { indexBackend = prevBackend }()
+	prevPartial := partial
+	partial = &partialResults{}
+	defer func() { partial = prevPartial }()
+
+	report, err := runCheck(runContext, checkOpts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		return nil, err
+	}
+	return publicReport(report)
+}
+
+// checkOptions validates the options and converts them to those of runCheck
+func (o Options) checkOptions() (checkOptions, error) {
+	opts := checkOptions{
+		projectPath:       strings.Join(o.ProjectPaths, ","),
+		module:            o.Module,
+		oldVersion:        o.OldVersion,
+		newVersion:        o.NewVersion,
+		allowRetracted:    o.AllowRetracted,
+		includePrerelease: o.IncludePrerelease,
+		cacheDir:          o.CacheDir,
+		cacheMaxMB:        o.CacheMaxBytes >> 20,
+		cacheSignKey:      o.CacheSignKey,
+		cacheVerifyKey:    o.CacheVerifyKey,
+		oldRepoURL:        o.OldRepoURL,
+		newRepoURL:        o.NewRepoURL,
+		vcs:               o.VCS,
+		vcsHosts:          o.VCSHosts,
+		minConfidence:     ConfidenceHeuristic,
+		deep:              o.Deep,
+		deepThreshold:     o.DeepThreshold,
+		followReexports:   o.FollowReexports,
+		commitHints:       o.CommitHints,
+		stableAPI:         o.StableAPI,
+		projectIndexes:    strings.Join(o.ProjectIndexes, ","),
+		oldIndex:          o.OldIndex,
+		newIndex:          o.NewIndex,
+	}
+	if opts.projectPath == "" {
+		opts.projectPath = "."
+	}
+	if opts.deepThreshold == 0 {
+		opts.deepThreshold = 50
+	}
+	if o.MinConfidence != "" {
+		if err := opts.minConfidence.UnmarshalText([]byte(o.MinConfidence)); err != nil {
+			return opts, err
+		}
+	}
+	opts.policy = Policy{CriticalFiles: o.CriticalFiles, CriticalPackages: o.CriticalPackages}
+
+	ignoreDirs := defaultIgnoredDirs
+	if o.IgnoreDirs != nil {
+		ignoreDirs = strings.Join(o.IgnoreDirs, ",")
+	}
+	var err error
+	if opts.platforms, err = parsePlatforms(strings.Join(o.Platforms, ",")); err != nil {
+		return opts, err
+	}
+	if opts.scope, err = parsePackageScope(strings.Join(o.Packages, ",")); err != nil {
+		return opts, err
+	}
+	if opts.policy.Deleting, err = parsePackageScope(strings.Join(o.DeletingPackages, ",")); err != nil {
+		return opts, err
+	}
+	if opts.ignored, err = parseIgnoredDirs(ignoreDirs); err != nil {
+		return opts, fmt.Errorf("invalid IgnoreDirs: %w", err)
+	}
+	if opts.excluded, err = parsePackageScope(strings.Join(o.ExcludePackages, ",")); err != nil {
+		return opts, err
+	}
+	if opts.ignoredSymbols, err = parseSymbolPatterns(strings.Join(o.IgnoreSymbols, ",")); err != nil {
+		return opts, err
+	}
+	if o.Engine != "" {
+		if _, err := parseEngine(o.Engine); err != nil {
+			return opts, err
+		}
+	}
+	if _, err := parseBackend(o.Backend); err != nil {
+		return opts, err
+	}
+	if o.MaxSeverity != "" {
+		opts.policy.MaxSeverity = new(Severity)
+		if err := opts.policy.MaxSeverity.UnmarshalText([]byte(o.MaxSeverity)); err != nil {
+			return opts, fmt.Errorf("invalid MaxSeverity: %w", err)
+		}
+	}
+	return opts, nil
+}
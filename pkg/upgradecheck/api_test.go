@@ -0,0 +1,156 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCheckRejectsInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"no module", Options{}, "no module to check"},
+		{"confidence", Options{Module: "example.com/m", MinConfidence: "sure"}, "sure"},
+		{"engine", Options{Module: "example.com/m", Engine: "magic"}, "magic"},
+		{"backend", Options{Module: "example.com/m", Backend: "magic"}, "magic"},
+		{"max severity", Options{Module: "example.com/m", MaxSeverity: "fatal"}, "invalid MaxSeverity"},
+		{"platform", Options{Module: "example.com/m", Platforms: []string{"linux"}}, "linux"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Check(context.Background(), tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Check() = %v, %v; want an error mentioning %q", report, err, tt.want)
+			}
+		})
+	}
+}
+
+// writeTestIndex writes an index of document at path
+func writeTestIndex(t *testing.T, path string, document *scip.Document) {
+	t.Helper()
+	data, err := proto.Marshal(&scip.Index{Documents: []*scip.Document{document}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// completeCheck returns the options of a check that runs to the end from
+// pre-built indexes, reporting that Foo gains a parameter
+func completeCheck(t *testing.T) Options {
+	t.Helper()
+	project, indexes := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "go.mod"), []byte("module example.com/p\n\nrequire example.com/m v1.0.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	symbol := func(version string) string {
+		return "scip-go gomod example.com/m " + version + " `example.com/m`/Foo()."
+	}
+	definition := func(version, def string) *scip.Document {
+		return &scip.Document{RelativePath: "m.go", Symbols: []*scip.SymbolInformation{{Symbol: symbol(version), Documentation: []string{"```go\n" + def + "\n```"}}}}
+	}
+	opts := Options{
+		ProjectPaths:   []string{project},
+		Module:         "example.com/m",
+		OldVersion:     "v1.0.0",
+		NewVersion:     "v1.1.0",
+		ProjectIndexes: []string{filepath.Join(indexes, "project.scip")},
+		OldIndex:       filepath.Join(indexes, "old.scip"),
+		NewIndex:       filepath.Join(indexes, "new.scip"),
+	}
+	writeTestIndex(t, opts.ProjectIndexes[0], &scip.Document{RelativePath: "a.go", Occurrences: []*scip.Occurrence{{Symbol: symbol("v1.0.0"), Range: []int32{1, 1, 4}}}})
+	writeTestIndex(t, opts.OldIndex, definition("v1.0.0", "func Foo()"))
+	writeTestIndex(t, opts.NewIndex, definition("v1.1.0", "func Foo(x int)"))
+	return opts
+}
+
+func TestCheckerRestoresState(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		// The project has no go.mod to read the old version from, so the check
+		// fails after setting up the run
+		{"failed", Options{ProjectPaths: []string{t.TempDir()}, Module: "example.com/m"}, true},
+		{"complete", completeCheck(t), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prevEnv, prevEngine, prevBackend, prevContext := toolEnv, moduleEngine, indexBackend, runContext
+			prevLog := log.Writer()
+			prevPartial := partial
+
+			opts := tt.opts
+			opts.Engine = "exportdata"
+			opts.Backend = "gopackages"
+			opts.Env = map[string]string{"GOFLAGS": "-mod=mod"}
+			var logs bytes.Buffer
+			c := Checker{Log: &logs}
+			report, err := c.Check(context.Background(), opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, want an error: %v\n%s", err, tt.wantErr, logs.String())
+			}
+			if err == nil && (len(report.Services) != 1 || len(report.Services[0].Findings) != 1) {
+				t.Fatalf("Check() = %+v, want one finding", report)
+			}
+
+			if !slices.Equal(toolEnv, prevEnv) {
+				t.Errorf("toolEnv not restored: has GOFLAGS=%q", toolGetenv("GOFLAGS"))
+			}
+			if moduleEngine != prevEngine || indexBackend != prevBackend {
+				t.Errorf("engine and backend = %q, %q; want %q, %q", moduleEngine, indexBackend, prevEngine, prevBackend)
+			}
+			if runContext != prevContext {
+				t.Error("runContext not restored")
+			}
+			if log.Writer() != prevLog {
+				t.Error("log output not restored")
+			}
+			if partial != prevPartial || partial.report != nil {
+				t.Error("partial results not restored")
+			}
+		})
+	}
+}
+
+func TestCheckCanceled(t *testing.T) {
+	cause := errors.New("shutting down")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(cause)
+	_, err := Check(ctx, Options{ProjectPaths: []string{t.TempDir()}, Module: "example.com/m", OldVersion: "v1.0.0", NewVersion: "v1.1.0"})
+	if !errors.Is(err, cause) {
+		t.Fatalf("Check() error = %v, want %v", err, cause)
+	}
+}
+
+func TestCheckMaxSeverityKeepsClass(t *testing.T) {
+	opts := completeCheck(t)
+	opts.MaxSeverity = "info"
+	report, err := Check(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Services) != 1 || len(report.Services[0].Findings) != 1 {
+		t.Fatalf("Check() = %+v, want one finding", report)
+	}
+	// The capped finding still breaks the build
+	if f := report.Services[0].Findings[0]; f.Severity != "info" || f.Class != ClassBreaking {
+		t.Errorf("capped finding is %s in class %s, want info in class %s", f.Severity, f.Class, ClassBreaking)
+	}
+}
@@ -0,0 +1,281 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// privateModule reports whether modulePath is fetched without a module proxy,
+// as the go command would: GONOPROXY, or GOPRIVATE when unset, matches it, or
+// GOPROXY only lists direct and off. Such modules are resolved with the go
+// command and cloned with git, using their credentials: .netrc, git's
+// credential helpers and GIT_SSH_COMMAND.
+func privateModule(modulePath string) bool {
+	if goproxy := toolGetenv("GOPROXY"); goproxy != "" && !strings.Contains(goproxy, "://") {
+		// Only direct and off
+		return true
+	}
+	noProxy := toolGetenv("GONOPROXY")
+	if noProxy == "" {
+		noProxy = toolGetenv("GOPRIVATE")
+	}
+	return module.MatchPrefixPatterns(noProxy, modulePath)
+}
+
+// goListModule is the output of go list -m -json
+type goListModule struct {
+	Version  string
+	Versions []string
+	GoMod    string
+	Error    *struct{ Err string }
+}
+
+// directGet answers a module proxy request, e.g. "@v/list", for a private
+// module with the go command, which fetches it from its repository
+func directGet(modulePath, path string) ([]byte, error) {
+	args := []string{"list", "-m", "-json"}
+	var ext string
+	if path == "@v/list" {
+		args = append(args, "-versions", modulePath)
+	} else {
+		escaped, found := strings.CutPrefix(path, "@v/")
+		ext = filepath.Ext(escaped)
+		version, err := module.UnescapeVersion(strings.TrimSuffix(escaped, ext))
+		if !found || (ext != ".mod" && ext != ".info") || err != nil {
+			return nil, fmt.Errorf("unsupported request %s for private module %s", path, modulePath)
+		}
+		args = append(args, modulePath+"@"+version)
+	}
+
+	// Run outside any module, so no go.mod interferes with the query
+	cmd := command("go", args...)
+	cmd.Dir = os.TempDir()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve private module %s: %s", modulePath, strings.TrimSpace(stderr.String()))
+	}
+	var mod goListModule
+	if err := json.Unmarshal(out, &mod); err != nil {
+		return nil, fmt.Errorf("failed to parse go list output for %s: %w", modulePath, err)
+	}
+	if mod.Error != nil {
+		return nil, fmt.Errorf("failed to resolve private module %s: %s", modulePath, mod.Error.Err)
+	}
+
+	switch {
+	case ext == "":
+		return []byte(strings.Join(mod.Versions, "\n")), nil
+	case mod.GoMod == "":
+		return nil, fmt.Errorf("go list returned no go.mod for %s@%s", modulePath, mod.Version)
+	case ext == ".mod":
+		return os.ReadFile(mod.GoMod)
+	}
+	// The .info file is stored next to the .mod file in the module cache
+	if data, err := os.ReadFile(strings.TrimSuffix(mod.GoMod, ".mod") + ".info"); err == nil {
+		return data, nil
+	}
+	return json.Marshal(versionInfo{Version: mod.Version})
+}
+
+// proxyRequest sends a GET request to a module proxy, with the credentials of
+// .netrc for its host like the go command
+func proxyRequest(client *http.Client, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(runContext, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if login, password, ok := netrcCredentials(req.URL.Hostname()); ok {
+		req.SetBasicAuth(login, password)
+	}
+	return client.Do(req)
+}
+
+// netrcEntry is a machine or, without one, the default entry of a .netrc file
+type netrcEntry struct {
+	machine, login, password string
+}
+
+// netrcCredentials returns the login and password the .netrc file, or the one
+// NETRC names, has for host. Only a machine entry naming the host counts: the
+// default entry would send its credentials to any host a proxy or module
+// download redirects to.
+func netrcCredentials(host string) (login, password string, ok bool) {
+	path := toolGetenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", false
+		}
+		path = filepath.Join(home, ".netrc")
+		if runtime.GOOS == "windows" {
+			path = filepath.Join(home, "_netrc")
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false
+	}
+
+	var entries []netrcEntry
+	var current *netrcEntry
+	fields := strings.Fields(string(data))
+	// Macros run to the end of the file as far as splitting into fields goes
+	for i := 0; i < len(fields) && fields[i] != "macdef"; i++ {
+		switch fields[i] {
+		case "machine", "default":
+			entries = append(entries, netrcEntry{})
+			current = &entries[len(entries)-1]
+			if fields[i] == "machine" && i+1 < len(fields) {
+				i++
+				current.machine = fields[i]
+			}
+		case "login", "password", "account":
+			if current == nil || i+1 >= len(fields) {
+				continue
+			}
+			i++
+			switch fields[i-1] {
+			case "login":
+				current.login = fields[i]
+			case "password":
+				current.password = fields[i]
+			}
+		}
+	}
+
+	for _, e := range entries {
+		if e.machine != "" && e.machine == host {
+			return e.login, e.password, true
+		}
+	}
+	return "", "", false
+}
+
+// gitHosts is the value of --git-ssh: the hosts whose repositories are cloned
+// over SSH
+type gitHosts []string
+
+func (h *gitHosts) String() string {
+	return strings.Join(*h, ",")
+}
+
+func (h *gitHosts) Set(value string) error {
+	for _, host := range strings.Split(value, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if strings.ContainsAny(host, "/:@") {
+			return fmt.Errorf("invalid host %q: expected a host name such as github.com", host)
+		}
+		*h = append(*h, host)
+	}
+	return nil
+}
+
+// gitTokenFlag collects repeated --git-token HOST=ENVVAR flags, naming the
+// environment variable holding the access token of each host, so the token
+// never appears on the command line
+type gitTokenFlag map[string]string
+
+func (t gitTokenFlag) String() string {
+	var pairs []string
+	for host, name := range t {
+		pairs = append(pairs, host+"="+name)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (t gitTokenFlag) Set(value string) error {
+	host, name, ok := strings.Cut(value, "=")
+	if !ok || host == "" || name == "" {
+		return fmt.Errorf("expected HOST=ENVVAR, e.g. github.com=GITHUB_TOKEN, got %q", value)
+	}
+	t[host] = name
+	return nil
+}
+
+// tokens reads the access token of each host from its environment variable
+func (t gitTokenFlag) tokens() (map[string]string, error) {
+	tokens := make(map[string]string)
+	for host, name := range t {
+		token := os.Getenv(name)
+		if token == "" {
+			return nil, fmt.Errorf("--git-token %s=%s: %s is not set", host, name, name)
+		}
+		tokens[host] = token
+	}
+	return tokens, nil
+}
+
+// gitTokenUser is the user name sent along with access tokens; GitHub and
+// GitLab accept any name with a personal access token, and GitHub expects
+// this one with app installation tokens
+const gitTokenUser = "x-access-token"
+
+// withGitAuth adds git configuration to env, through GIT_CONFIG_COUNT after
+// any entries already set there, so that git fetches the repositories of
+// sshHosts over SSH and sends the access tokens to their https hosts. It
+// applies to clones as much as to the go command fetching private modules
+// with git, and leaves repository URLs, which key the cache, unchanged.
+func withGitAuth(env []string, sshHosts []string, tokens map[string]string) ([]string, error) {
+	if len(sshHosts) == 0 && len(tokens) == 0 {
+		return env, nil
+	}
+
+	count := 0
+	if value := getenvFrom(env, "GIT_CONFIG_COUNT"); value != "" {
+		var err error
+		if count, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid GIT_CONFIG_COUNT %q", value)
+		}
+	}
+	overrides := make(map[string]string)
+	add := func(key, value string) {
+		overrides[fmt.Sprintf("GIT_CONFIG_KEY_%d", count)] = key
+		overrides[fmt.Sprintf("GIT_CONFIG_VALUE_%d", count)] = value
+		count++
+	}
+	for _, host := range sshHosts {
+		add(fmt.Sprintf("url.ssh://git@%s/.insteadOf", host), fmt.Sprintf("https://%s/", host))
+	}
+	hosts := make([]string, 0, len(tokens))
+	for host := range tokens {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		u := url.URL{Scheme: "https", Host: host, Path: "/"}
+		credentials := base64.StdEncoding.EncodeToString([]byte(gitTokenUser + ":" + tokens[host]))
+		add("http."+u.String()+".extraHeader", "Authorization: Basic "+credentials)
+	}
+	overrides["GIT_CONFIG_COUNT"] = strconv.Itoa(count)
+
+	for _, kv := range env {
+		key, val, _ := strings.Cut(kv, "=")
+		if _, ok := overrides[key]; !ok {
+			overrides[key] = val
+		}
+	}
+	result := make([]string, 0, len(overrides))
+	for key, val := range overrides {
+		result = append(result, key+"="+val)
+	}
+	sort.Strings(result)
+	return result, nil
+}
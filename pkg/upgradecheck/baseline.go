@@ -0,0 +1,127 @@
+package upgradecheck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+)
+
+// baseline lists findings already triaged and accepted, so they stop failing
+// CI while new ones still do. It is written with --write-baseline and read
+// with --baseline.
+type baseline struct {
+	Findings []baselineEntry `json:"findings"`
+}
+
+// baselineEntry is an accepted finding, identified by its module and
+// fingerprint; the other fields are for reviewers of the file
+type baselineEntry struct {
+	Module      string   `json:"module"`
+	Fingerprint string   `json:"fingerprint"`
+	Symbol      string   `json:"symbol"`
+	Kind        string   `json:"kind"`
+	Severity    Severity `json:"severity"`
+}
+
+// readBaseline loads a baseline file
+func readBaseline(path string) (*baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var b baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+	return &b, nil
+}
+
+// accept marks the findings of the reports the baseline lists as accepted
+func (b *baseline) accept(reports ...*Report) {
+	accepted := make(map[[2]string]bool)
+	for _, e := range b.Findings {
+		accepted[[2]string{e.Module, e.Fingerprint}] = true
+	}
+	for _, report := range reports {
+		for _, service := range report.Services {
+			for i, f := range service.Findings {
+				if accepted[[2]string{report.Module, f.Fingerprint()}] {
+					service.Findings[i].Accepted = true
+				}
+			}
+		}
+	}
+}
+
+// saveBaseline writes the baseline of --write-baseline, if given
+func saveBaseline(path string, reports ...*Report) {
+	if path == "" {
+		return
+	}
+	if err := writeBaseline(path, reports...); err != nil {
+		fatalf("Failed to write the baseline: %v", err)
+	}
+	log.Printf("Wrote the baseline %s", path)
+}
+
+// writeBaseline writes the findings of the reports as accepted to path.
+// Entries of other modules already in the file are kept, so one baseline can
+// cover separate checks of several modules.
+func writeBaseline(path string, reports ...*Report) error {
+	checked := make(map[string]bool)
+	for _, report := range reports {
+		checked[report.Module] = true
+	}
+	var b baseline
+	if existing, err := readBaseline(path); err == nil {
+		for _, e := range existing.Findings {
+			if !checked[e.Module] {
+				b.Findings = append(b.Findings, e)
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	seen := make(map[[2]string]bool)
+	for _, report := range reports {
+		for _, service := range report.Services {
+			for _, f := range service.Findings {
+				key := [2]string{report.Module, f.Fingerprint()}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				b.Findings = append(b.Findings, baselineEntry{
+					Module:      report.Module,
+					Fingerprint: key[1],
+					Symbol:      f.Symbol,
+					Kind:        f.Kind,
+					Severity:    f.Severity,
+				})
+			}
+		}
+	}
+	sort.Slice(b.Findings, func(i, j int) bool {
+		if b.Findings[i].Module != b.Findings[j].Module {
+			return b.Findings[i].Module < b.Findings[j].Module
+		}
+		if b.Findings[i].Symbol != b.Findings[j].Symbol {
+			return b.Findings[i].Symbol < b.Findings[j].Symbol
+		}
+		return b.Findings[i].Fingerprint < b.Findings[j].Fingerprint
+	})
+	if b.Findings == nil {
+		b.Findings = []baselineEntry{}
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
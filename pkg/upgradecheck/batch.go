@@ -0,0 +1,274 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// batchReport is the result of --all: one report per direct dependency with a
+// newer version, and the dependencies that couldn't be checked
+type batchReport struct {
+	Modules []*Report      `json:"modules"`
+	Failed  []batchFailure `json:"failed,omitempty"`
+	// Interrupted says why the batch ended early, making this a partial
+	// report of the checks completed before
+	Interrupted string `json:"interrupted,omitempty"`
+}
+
+// batchFailure is a dependency whose check failed
+type batchFailure struct {
+	Module     string `json:"module"`
+	OldVersion string `json:"old_version"`
+	NewVersion string `json:"new_version,omitempty"`
+	Error      string `json:"error"`
+}
+
+// batchSkippedFlags are the flags --all sets itself, or that only make sense
+// for the consolidated run, when forwarding the others to each check
+var batchSkippedFlags = map[string]bool{
+	"all": true, "project-path": true, "module": true, "old-version": true, "new-version": true,
+	"format": true, "output-format": true, "view": true, "group-by-owner": true, "progress-format": true, "env": true,
+	"record": true, "replay": true, "annotations": true, "flamegraph": true, "write-baseline": true, "create-issues": true, "github-status": true, "fail-on": true,
+	"upgrade-set": true, "output-file": true, "github-actions": true, "github-comment": true, "base": true,
+}
+
+// batchArgs returns the flags set in fs to pass on to every check of a batch,
+// with the environment overrides
+func batchArgs(fs *flag.FlagSet, envOverrides envFlag) []string {
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		if !batchSkippedFlags[f.Name] {
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	for key, val := range envOverrides {
+		args = append(args, "--env", key+"="+val)
+	}
+	return args
+}
+
+// finishBatch renders a batch and exits with its status: an error when any
+// check failed, or findings at the threshold
+func finishBatch(batch *batchReport, format, view string, byOwner bool, threshold failOn) {
+	partial.finish()
+	if err := renderBatch(batch, format, view, byOwner); err != nil {
+		fatalf("%v", err)
+	}
+	if err := appendJobSummary(batch.Modules...); err != nil {
+		log.Printf("Warning: could not write the job summary: %v", err)
+	}
+	if err := actionsMode.publish(batch.Modules...); err != nil {
+		fatalf("%v", err)
+	}
+	switch {
+	case len(batch.Failed) > 0:
+		exit(ExitError)
+	case threshold.fails(batch.Modules...):
+		exit(ExitFindings)
+	}
+}
+
+// checkAll checks the upgrade of every direct dependency of the first project
+// to its latest version. Like readiness, each dependency is checked in a
+// separate run of the tool so one failing to clone or index doesn't end the
+// batch. args are passed on to every check.
+func checkAll(projectPath string, includePrerelease bool, args []string) (*batchReport, error) {
+	first := strings.TrimSpace(strings.Split(projectPath, ",")[0])
+	goModPath, err := findGoMod(first)
+	if err != nil {
+		return nil, err
+	}
+	if goModPath == "" {
+		return nil, fmt.Errorf("no go.mod found for %s", first)
+	}
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, err
+	}
+	file, err := modfile.ParseLax(goModPath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", goModPath, err)
+	}
+
+	batch := &batchReport{Modules: []*Report{}}
+	var upgrades []moduleUpgrade
+	for _, req := range file.Require {
+		// Indirect dependencies aren't imported by the project, so their
+		// upgrades can't break it directly
+		if req.Indirect {
+			continue
+		}
+		failed := batchFailure{Module: req.Mod.Path, OldVersion: req.Mod.Version}
+		old, err := pinnedVersion(first, req.Mod.Path)
+		if err == nil {
+			failed.OldVersion = old
+			failed.NewVersion, err = latestRelease(req.Mod.Path, includePrerelease)
+		}
+		if err != nil {
+			log.Printf("Warning: skipping %s: %v", req.Mod.Path, err)
+			failed.Error = err.Error()
+			batch.Failed = append(batch.Failed, failed)
+			continue
+		}
+		if semver.Compare(failed.NewVersion, old) <= 0 {
+			log.Printf("%s %s is up to date", req.Mod.Path, old)
+			continue
+		}
+		upgrades = append(upgrades, moduleUpgrade{Module: req.Mod.Path, OldVersion: old, NewVersion: failed.NewVersion})
+	}
+	checkUpgrades(batch, projectPath, upgrades, args)
+	return batch, nil
+}
+
+// moduleUpgrade is a dependency with a newer version to check
+type moduleUpgrade struct {
+	Module     string `json:"module"`
+	OldVersion string `json:"old_version"`
+	NewVersion string `json:"new_version"`
+	Indirect   bool   `json:"indirect,omitempty"`
+}
+
+// checkUpgrades checks each upgrade in a separate run of the tool, adding its
+// report or failure to batch. args are passed on to every check.
+//
+// The checks count as the partial results of the run: an interrupted batch
+// waits for the check in progress to stop, and reports the partial report it
+// writes.
+func checkUpgrades(batch *batchReport, projectPath string, upgrades []moduleUpgrade, args []string) {
+	partial.update(func() {
+		partial.batch = batch
+		partial.total = len(batch.Modules) + len(batch.Failed) + len(upgrades)
+	})
+	for _, upgrade := range upgrades {
+		partial.update(func() {
+			if runContext.Err() != nil {
+				return
+			}
+			log.Printf("Checking %s %s -> %s", upgrade.Module, upgrade.OldVersion, upgrade.NewVersion)
+			report, err := checkModule(append([]string{
+				"--project-path", projectPath,
+				"--module", upgrade.Module,
+				"--old-version", upgrade.OldVersion,
+				"--new-version", upgrade.NewVersion,
+			}, args...)...)
+			if err != nil {
+				log.Printf("Warning: check of %s failed: %v", upgrade.Module, err)
+				batch.Failed = append(batch.Failed, batchFailure{
+					Module:     upgrade.Module,
+					OldVersion: upgrade.OldVersion,
+					NewVersion: upgrade.NewVersion,
+					Error:      err.Error(),
+				})
+				return
+			}
+			batch.Modules = append(batch.Modules, report)
+		})
+	}
+}
+
+// checkModule runs each check of checkUpgrades, replaced by tests to stub out
+// the subprocess
+var checkModule = checkInSubprocess
+
+// checkInSubprocess runs the tool with args and returns its JSON report, the
+// partial one of an interrupted run. The error of a failed run is the last
+// line it logged.
+func checkInSubprocess(args ...string) (*Report, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	// Findings are read from the report, so only analysis errors fail the run
+	// Interrupting the batch stops the check, which then cleans up after itself
+	cmd := exec.CommandContext(runContext, self, append(append([]string{ModeCheck}, args...), "--format", FormatJSON, "--fail-on", "none")...)
+	terminateOnCancel(cmd)
+	cmd.WaitDelay = subprocessWaitDelay
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == ExitInterrupted {
+			var report Report
+			if json.Unmarshal(stdout.Bytes(), &report) == nil && report.Interrupted != "" {
+				return &report, nil
+			}
+		}
+		if msg := lastLine(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+
+	var report Report
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report: %w", err)
+	}
+	return &report, nil
+}
+
+// renderBatch writes the consolidated report, grouped by module, to stdout
+func renderBatch(batch *batchReport, format, view string, byOwner bool) error {
+	if format == FormatSARIF {
+		return printSARIF(batch.Modules...)
+	}
+	if format == FormatJSON {
+		data, err := json.MarshalIndent(batch, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if batch.Interrupted != "" {
+		if format == FormatMarkdown {
+			fmt.Printf("> **Partial report:** the checks were interrupted (%s); the remaining modules weren't checked.\n\n", batch.Interrupted)
+		} else {
+			fmt.Printf("\nPARTIAL REPORT: the checks were interrupted (%s); the remaining modules weren't checked.\n", batch.Interrupted)
+		}
+	}
+	for i, report := range batch.Modules {
+		if format == FormatMarkdown {
+			if i > 0 {
+				fmt.Println()
+			}
+			printMarkdown(os.Stdout, report, byOwner)
+			continue
+		}
+		fmt.Printf("\n=== %s %s -> %s ===\n", report.Module, report.OldVersion, report.NewVersion)
+		if err := renderReport(report, format, view, byOwner); err != nil {
+			return err
+		}
+	}
+	if len(batch.Modules) == 0 && len(batch.Failed) == 0 && batch.Interrupted == "" {
+		fmt.Println("All direct dependencies are up to date.")
+	}
+
+	if len(batch.Failed) > 0 {
+		if format == FormatMarkdown {
+			fmt.Print("\n# Failed checks\n\n")
+			fmt.Println("| Module | Current | Latest | Error |")
+			fmt.Println("| --- | --- | --- | --- |")
+			for _, f := range batch.Failed {
+				fmt.Printf("| %s | %s | %s | %s |\n", f.Module, f.OldVersion, f.NewVersion, markdownCell(f.Error))
+			}
+			return nil
+		}
+		fmt.Println("\nFailed checks:")
+		for _, f := range batch.Failed {
+			fmt.Printf("- %s %s: %s\n", f.Module, f.OldVersion, f.Error)
+		}
+	}
+	return nil
+}
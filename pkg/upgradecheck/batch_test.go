@@ -0,0 +1,77 @@
+package upgradecheck
+
+import (
+	"errors"
+	"flag"
+	"slices"
+	"testing"
+)
+
+func TestBatchArgs(t *testing.T) {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.String("module", "", "")
+	fs.String("format", "text", "")
+	fs.String("ignore-dirs", "", "")
+	fs.Bool("include-tests", false, "")
+	fs.Int("jobs", 4, "")
+	if err := fs.Parse([]string{"--module=example.com/m", "--format=json", "--ignore-dirs=vendor", "--include-tests"}); err != nil {
+		t.Fatal(err)
+	}
+	// Only flags set are passed on, and not the ones the batch sets itself
+	got := batchArgs(fs, envFlag{"GOFLAGS": "-mod=mod"})
+	want := []string{"--ignore-dirs=vendor", "--include-tests=true", "--env", "GOFLAGS=-mod=mod"}
+	if !slices.Equal(got, want) {
+		t.Errorf("batchArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckUpgrades(t *testing.T) {
+	var calls [][]string
+	prevCheck, prevPartial := checkModule, partial
+	t.Cleanup(func() { checkModule, partial = prevCheck, prevPartial })
+	partial = &partialResults{}
+	checkModule = func(args ...string) (*Report, error) {
+		calls = append(calls, args)
+		module := args[slices.Index(args, "--module")+1]
+		if module == "example.com/broken" {
+			return nil, errors.New("failed to index example.com/broken")
+		}
+		return &Report{Module: module, OldVersion: args[slices.Index(args, "--old-version")+1], NewVersion: args[slices.Index(args, "--new-version")+1]}, nil
+	}
+
+	// Results of earlier steps, like dependencies checkAll couldn't resolve,
+	// are kept
+	batch := &batchReport{Modules: []*Report{}, Failed: []batchFailure{{Module: "example.com/gone", OldVersion: "v1.0.0", Error: "not found"}}}
+	upgrades := []moduleUpgrade{
+		{Module: "example.com/a", OldVersion: "v1.0.0", NewVersion: "v1.2.0"},
+		{Module: "example.com/broken", OldVersion: "v0.1.0", NewVersion: "v0.2.0"},
+		{Module: "example.com/b", OldVersion: "v2.0.0", NewVersion: "v2.1.0"},
+	}
+	checkUpgrades(batch, "./p", upgrades, []string{"--include-tests=true"})
+
+	if len(calls) != len(upgrades) {
+		t.Fatalf("ran %d checks, want %d", len(calls), len(upgrades))
+	}
+	want := []string{"--project-path", "./p", "--module", "example.com/a", "--old-version", "v1.0.0", "--new-version", "v1.2.0", "--include-tests=true"}
+	if !slices.Equal(calls[0], want) {
+		t.Errorf("first check ran with %q, want %q", calls[0], want)
+	}
+
+	var modules []string
+	for _, report := range batch.Modules {
+		modules = append(modules, report.Module+"@"+report.NewVersion)
+	}
+	if want := []string{"example.com/a@v1.2.0", "example.com/b@v2.1.0"}; !slices.Equal(modules, want) {
+		t.Errorf("reports = %q, want %q", modules, want)
+	}
+	wantFailed := []batchFailure{
+		{Module: "example.com/gone", OldVersion: "v1.0.0", Error: "not found"},
+		{Module: "example.com/broken", OldVersion: "v0.1.0", NewVersion: "v0.2.0", Error: "failed to index example.com/broken"},
+	}
+	if !slices.Equal(batch.Failed, wantFailed) {
+		t.Errorf("failures = %+v, want %+v", batch.Failed, wantFailed)
+	}
+	if partial.batch != batch || partial.total != 4 {
+		t.Errorf("partial results = batch %p of %d, want %p of 4", partial.batch, partial.total, batch)
+	}
+}
@@ -0,0 +1,137 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// targetImpact is how one build target depending on the module is affected
+type targetImpact struct {
+	Label string `json:"label"`
+	// Verdict is "ok" or the severity of the worst finding used in the target
+	Verdict string `json:"verdict"`
+	// Symbols are the affected symbols the target's sources use
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// bazelRepoName returns the external repository name gazelle gives a module,
+// e.g. com_github_pkg_errors for github.com/pkg/errors
+func bazelRepoName(modulePath string) string {
+	components := strings.Split(strings.ToLower(modulePath), "/")
+	domain := strings.Split(components[0], ".")
+	for i, j := 0, len(domain)-1; i < j; i, j = i+1, j-1 {
+		domain[i], domain[j] = domain[j], domain[i]
+	}
+	name := strings.Join(append(domain, components[1:]...), ".")
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
+
+// bazel runs a bazel command in dir and returns its output lines
+func bazel(dir string, args ...string) ([]string, error) {
+	var out bytes.Buffer
+	cmd := command("bazel", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = subprocessStderr()
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("bazel %s failed: %w", args[0], err)
+	}
+	return strings.Fields(out.String()), nil
+}
+
+// labelPath converts a source file label like //pkg/sub:file.go into its path
+// relative to the workspace root. Labels of other repositories yield "".
+func labelPath(label string) string {
+	label = strings.TrimLeft(label, "@")
+	if !strings.HasPrefix(label, "//") {
+		return ""
+	}
+	pkg, name, ok := strings.Cut(strings.TrimPrefix(label, "//"), ":")
+	if !ok {
+		return ""
+	}
+	return filepath.ToSlash(filepath.Join(pkg, name))
+}
+
+// bazelTargetImpact asks Bazel which Go targets of the workspace containing
+// projectPath depend directly on the module's external repository, and grades
+// each one by the findings whose usages lie in its sources
+func bazelTargetImpact(projectPath, repoName string, findings []Finding) ([]targetImpact, error) {
+	info, err := bazel(projectPath, "info", "workspace")
+	if err != nil {
+		return nil, err
+	}
+	if len(info) == 0 {
+		return nil, fmt.Errorf("bazel info returned no workspace")
+	}
+	absProject, err := filepath.Abs(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	// Usages are relative to the project, labels to the workspace root
+	projectDir, err := filepath.Rel(info[0], absProject)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`kind("go_", rdeps(//..., @%s//..., 1))`, repoName)
+	labels, err := bazel(projectPath, "query", "--output=label", query)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []targetImpact
+	for _, label := range labels {
+		if labelPath(label) == "" {
+			continue
+		}
+		srcs, err := bazel(projectPath, "query", "--output=label", fmt.Sprintf("labels(srcs, %s)", label))
+		if err != nil {
+			return nil, err
+		}
+		files := make(map[string]bool)
+		for _, src := range srcs {
+			if path := labelPath(src); path != "" {
+				files[path] = true
+			}
+		}
+
+		target := targetImpact{Label: label, Verdict: "ok"}
+		for _, f := range findings {
+			for _, file := range f.Files() {
+				if files[filepath.ToSlash(filepath.Join(projectDir, file))] {
+					// Findings are sorted most severe first
+					if len(target.Symbols) == 0 {
+						target.Verdict = f.Severity.String()
+					}
+					target.Symbols = append(target.Symbols, f.Symbol)
+					break
+				}
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// printTargets writes the per-target impact table of a Bazel workspace
+func printTargets(targets []targetImpact) {
+	if len(targets) == 0 {
+		return
+	}
+	labelWidth := len("TARGET")
+	for _, t := range targets {
+		if len(t.Label) > labelWidth {
+			labelWidth = len(t.Label)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Bazel targets depending on the module:")
+	fmt.Printf("  %-*s  %-8s  %s\n", labelWidth, "TARGET", "VERDICT", "AFFECTED SYMBOLS")
+	for _, t := range targets {
+		fmt.Printf("  %-*s  %-8s  %s\n", labelWidth, t.Label, t.Verdict, strings.Join(t.Symbols, ", "))
+	}
+}
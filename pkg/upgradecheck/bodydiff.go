@@ -0,0 +1,331 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// versionSource reads the source files of one module version from its
+// extracted proxy zip, or straight from its repository without checking it out
+type versionSource struct {
+	// dir is the extracted module zip, if the version was downloaded
+	dir  string
+	repo *moduleRepo
+	rev  string
+	// subdir is the module's directory within the repository
+	subdir string
+
+	fset  *token.FileSet
+	files map[string]*ast.File
+}
+
+func newVersionSource(ctx context.Context, repo *moduleRepo, modulePath, version string) (*versionSource, error) {
+	if dir := repo.download(ctx, modulePath, version); dir != "" {
+		return &versionSource{dir: dir, fset: token.NewFileSet(), files: make(map[string]*ast.File)}, nil
+	}
+	if err := repo.clone(ctx); err != nil {
+		return nil, err
+	}
+	rev, subdir, err := moduleCheckout(repo, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return &versionSource{repo: repo, rev: rev, subdir: subdir, fset: token.NewFileSet(), files: make(map[string]*ast.File)}, nil
+}
+
+// file parses a file of the version, given relative to the module root
+func (s *versionSource) file(path string) (*ast.File, error) {
+	if file, ok := s.files[path]; ok {
+		return file, nil
+	}
+
+	src, err := s.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comments are dropped: reworded comments don't change behavior
+	file, err := parser.ParseFile(s.fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	s.files[path] = file
+	return file, nil
+}
+
+// readFile returns the contents of a file of the version, given relative to
+// the module root
+func (s *versionSource) readFile(path string) ([]byte, error) {
+	if s.dir != "" {
+		return os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(path)))
+	}
+	data, err := s.repo.VCS.show(s.repo.Dir, s.rev, pathpkg.Join(s.subdir, path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, s.rev, err)
+	}
+	return data, nil
+}
+
+// glob returns the files of the version matching a path.Match pattern,
+// relative to the module root
+func (s *versionSource) glob(pattern string) ([]string, error) {
+	if s.dir != "" {
+		matches, err := filepath.Glob(filepath.Join(s.dir, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, err
+		}
+		var paths []string
+		for _, match := range matches {
+			rel, err := filepath.Rel(s.dir, match)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return paths, nil
+	}
+	files, err := s.repo.VCS.files(s.repo.Dir, s.rev)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, file := range files {
+		if ok, _ := pathpkg.Match(pathpkg.Join(s.subdir, pattern), file); ok {
+			paths = append(paths, strings.TrimPrefix(file, s.subdir+"/"))
+		}
+	}
+	return paths, nil
+}
+
+// funcBody returns the gofmt-normalized body of the function or method named
+// by key ("Func" or "Type#Method") declared in the file at path
+func (s *versionSource) funcBody(path, key string) ([]string, bool, error) {
+	file, err := s.file(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	recv, name, isMethod := strings.Cut(key, "#")
+	if !isMethod {
+		name, recv = recv, ""
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || fn.Name.Name != name || receiverType(fn) != recv {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, s.fset, fn.Body); err != nil {
+			return nil, false, err
+		}
+		return strings.Split(buf.String(), "\n"), true, nil
+	}
+	return nil, false, nil
+}
+
+// receiverType returns the base type name of a method's receiver, or "" for
+// plain functions
+func receiverType(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// changedFraction returns the share of lines that differ between two versions
+// of a body: one minus the longest common subsequence over the longer version
+func changedFraction(oldLines, newLines []string) float64 {
+	if len(oldLines) == 0 && len(newLines) == 0 {
+		return 0
+	}
+	// lcs[j] holds the LCS length of the current oldLines prefix and newLines[:j]
+	lcs := make([]int, len(newLines)+1)
+	for _, oldLine := range oldLines {
+		prev := 0
+		for j, newLine := range newLines {
+			cur := lcs[j+1]
+			if oldLine == newLine {
+				lcs[j+1] = prev + 1
+			} else if lcs[j] > lcs[j+1] {
+				lcs[j+1] = lcs[j]
+			}
+			prev = cur
+		}
+	}
+	longest := len(oldLines)
+	if len(newLines) > longest {
+		longest = len(newLines)
+	}
+	return 1 - float64(lcs[len(newLines)])/float64(longest)
+}
+
+// bodyChangeFindings compares the bodies of the module functions and methods
+// the project uses between two versions and reports those rewritten by at
+// least threshold (0-1) as behavioral risks. Symbols that already have a
+// finding are skipped: their signature change is the bigger concern.
+func bodyChangeFindings(used usageSites, oldSrc, newSrc *versionSource, oldDefs, newDefs map[string]string, existing []Finding, threshold float64) ([]Finding, error) {
+	reported := make(map[string]bool)
+	for _, f := range existing {
+		reported[f.Symbol] = true
+	}
+
+	var keys []string
+	for key := range used {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var findings []Finding
+	for _, key := range keys {
+		oldPath, inOld := oldDefs[key]
+		newPath, inNew := newDefs[key]
+		if !inOld || !inNew || reported[key] || !strings.HasSuffix(oldPath, ".go") {
+			continue
+		}
+
+		oldBody, ok, err := oldSrc.funcBody(oldPath, key)
+		if err != nil {
+			return findings, err
+		}
+		if !ok {
+			continue
+		}
+		newBody, ok, err := newSrc.funcBody(newPath, key)
+		if err != nil {
+			return findings, err
+		}
+		if !ok {
+			continue
+		}
+
+		changed := changedFraction(oldBody, newBody)
+		if changed < threshold {
+			continue
+		}
+		name := strings.Replace(key, "#", ".", 1)
+		findings = append(findings, Finding{
+			Symbol:     key,
+			Kind:       ChangeBehavior,
+			Severity:   SeverityWarning,
+			Confidence: ConfidenceHeuristic,
+			Usages:     used[key],
+			Notes: []string{fmt.Sprintf("%s() changed %.0f%% of its body (%d -> %d lines); review whether it still behaves the way the project relies on",
+				name, changed*100, len(oldBody), len(newBody))},
+		})
+	}
+	return findings, nil
+}
+
+// varValue returns the gofmt-normalized initializer of the package-level
+// variable name declared in the file at path, on one line. Variables declared
+// without one, or initialized together from a multi-valued call, return false.
+func (s *versionSource) varValue(path, name string) (string, bool, error) {
+	file, err := s.file(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, ident := range vs.Names {
+				if ident.Name != name {
+					continue
+				}
+				if len(vs.Values) != len(vs.Names) {
+					return "", false, nil
+				}
+				var buf bytes.Buffer
+				if err := printer.Fprint(&buf, s.fset, vs.Values[i]); err != nil {
+					return "", false, err
+				}
+				return strings.Join(strings.Fields(buf.String()), " "), true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// varValueFindings compares the initializers of the package-level variables
+// of the module the project uses between two versions, such as a default
+// timeout or client, and reports changed ones as behavioral: the code still
+// compiles, but starts with another value unless the project sets its own.
+// Variables that already have a finding are skipped.
+func varValueFindings(used usageSites, oldSymbols map[string][]string, oldSrc, newSrc *versionSource, oldDefs, newDefs map[string]string, existing []Finding) ([]Finding, error) {
+	reported := make(map[string]bool)
+	for _, f := range existing {
+		reported[f.Symbol] = true
+	}
+
+	var keys []string
+	for key := range used {
+		defs := oldSymbols[key]
+		if len(defs) == 1 && strings.HasPrefix(normalizeDefinition(defs[0]), "var ") && !reported[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var findings []Finding
+	for _, key := range keys {
+		oldPath, inOld := oldDefs[key]
+		newPath, inNew := newDefs[key]
+		if !inOld || !inNew || !strings.HasSuffix(oldPath, ".go") {
+			continue
+		}
+		oldValue, ok, err := oldSrc.varValue(oldPath, key)
+		if err != nil {
+			return findings, err
+		}
+		if !ok {
+			continue
+		}
+		newValue, ok, err := newSrc.varValue(newPath, key)
+		if err != nil {
+			return findings, err
+		}
+		if !ok || newValue == oldValue {
+			continue
+		}
+		findings = append(findings, Finding{
+			Symbol:       key,
+			Kind:         ChangeBehavior,
+			Severity:     SeverityWarning,
+			Confidence:   ConfidenceHigh,
+			OldSignature: "var " + key + " = " + oldValue,
+			NewSignature: "var " + key + " = " + newValue,
+			Usages:       used[key],
+			Notes: []string{fmt.Sprintf("initial value of %s changed from %s to %s; code using it compiles unchanged but runs with the new value unless the project sets its own",
+				key, oldValue, newValue)},
+		})
+	}
+	return findings, nil
+}
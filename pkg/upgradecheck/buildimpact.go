@@ -0,0 +1,121 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// buildImpact compares building one project package against the old and the
+// new version of the module
+type buildImpact struct {
+	Package    string  `json:"package"`
+	OldSize    int64   `json:"old_size_bytes"`
+	NewSize    int64   `json:"new_size_bytes"`
+	OldSeconds float64 `json:"old_build_seconds"`
+	NewSeconds float64 `json:"new_build_seconds"`
+}
+
+// measureBuildImpact builds pkg of the project once per version and reports
+// the output size and build time of each. The project's go.mod is never
+// modified: every build gets a scratch copy of it passed with -modfile, plus an
+// empty build cache so both builds do the same amount of work.
+func measureBuildImpact(projectPath, pkg, modulePath, oldVersion, newVersion string) (*buildImpact, error) {
+	impact := &buildImpact{Package: pkg}
+
+	var err error
+	impact.OldSize, impact.OldSeconds, err = buildWithVersion(projectPath, pkg, modulePath, oldVersion)
+	if err != nil {
+		return nil, fmt.Errorf("building against %s: %w", oldVersion, err)
+	}
+	impact.NewSize, impact.NewSeconds, err = buildWithVersion(projectPath, pkg, modulePath, newVersion)
+	if err != nil {
+		return nil, fmt.Errorf("building against %s: %w", newVersion, err)
+	}
+	return impact, nil
+}
+
+func buildWithVersion(projectPath, pkg, modulePath, version string) (int64, float64, error) {
+	goModPath, err := findGoMod(projectPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	if goModPath == "" {
+		return 0, 0, fmt.Errorf("no go.mod found for %s", projectPath)
+	}
+
+	scratch, err := cleanups.tempDir("", "build-impact-*")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create scratch workspace: %w", err)
+	}
+	defer cleanups.remove(scratch)
+
+	modFile := filepath.Join(scratch, "go.mod")
+	if err := copyFile(goModPath, modFile); err != nil {
+		return 0, 0, err
+	}
+	goSum := filepath.Join(filepath.Dir(goModPath), "go.sum")
+	if _, err := os.Stat(goSum); err == nil {
+		if err := copyFile(goSum, filepath.Join(scratch, "go.sum")); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	env := []string{"GOCACHE=" + filepath.Join(scratch, "cache")}
+
+	get := command("go", "get", "-modfile="+modFile, modulePath+"@"+version)
+	get.Env = append(get.Env[:len(get.Env):len(get.Env)], env...)
+	get.Dir = projectPath
+	get.Stderr = subprocessStderr()
+	if err := get.Run(); err != nil {
+		return 0, 0, fmt.Errorf("go get failed: %w", err)
+	}
+
+	output := filepath.Join(scratch, "out")
+	build := command("go", "build", "-mod=mod", "-modfile="+modFile, "-o", output, pkg)
+	build.Env = append(build.Env[:len(build.Env):len(build.Env)], env...)
+	build.Dir = projectPath
+	build.Stderr = subprocessStderr()
+	start := time.Now()
+	if err := build.Run(); err != nil {
+		return 0, 0, fmt.Errorf("go build failed: %w", err)
+	}
+	elapsed := time.Since(start).Seconds()
+
+	info, err := os.Stat(output)
+	if err != nil {
+		return 0, 0, fmt.Errorf("build produced no output: %w", err)
+	}
+	return info.Size(), elapsed, nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o644)
+}
+
+// percentChange formats the relative change from old to new, e.g. "+12.5%"
+func percentChange(old, new float64) string {
+	if old == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", (new-old)/old*100)
+}
+
+// printBuildImpact writes the size and build time deltas of a build impact
+func printBuildImpact(impact *buildImpact) {
+	if impact == nil {
+		return
+	}
+	fmt.Println()
+	fmt.Printf("Build impact (%s):\n", impact.Package)
+	fmt.Printf("  binary size: %.1f MiB -> %.1f MiB (%s)\n",
+		float64(impact.OldSize)/(1<<20), float64(impact.NewSize)/(1<<20),
+		percentChange(float64(impact.OldSize), float64(impact.NewSize)))
+	fmt.Printf("  build time:  %.1fs -> %.1fs (%s)\n",
+		impact.OldSeconds, impact.NewSeconds, percentChange(impact.OldSeconds, impact.NewSeconds))
+}
@@ -0,0 +1,482 @@
+package upgradecheck
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const cacheMetaFile = "meta.json"
+
+// cacheLockFile guards the cache directory as a whole: lookups share it, while
+// storing, evicting and repairing entries take it exclusively. Per-key locks
+// live in the cacheKeyLocks directory, and the leases of entries in use in
+// cacheLeases. Dot-prefixed names are never entries.
+const (
+	cacheLockFile = ".lock"
+	cacheKeyLocks = ".locks"
+	cacheLeases   = ".leases"
+)
+
+// indexCache stores generated dependency indexes on disk so repeated runs don't
+// re-clone and re-index the same module versions. Each entry is a directory
+// named after the hash of its key, holding the cached files plus a meta.json
+// recording their checksums and when the entry was last used. Concurrent runs
+// may share the directory: writers are serialized with file locks.
+type indexCache struct {
+	Dir string
+	// MaxBytes bounds the total size of cached files; least recently used
+	// entries are evicted once it is exceeded. Zero means unbounded.
+	MaxBytes int64
+	// SignKey signs the entries stored; VerifyKey, when set, rejects entries
+	// not signed by its private key, so a shared cache can only feed indexes
+	// from trusted builders
+	SignKey   ed25519.PrivateKey
+	VerifyKey ed25519.PublicKey
+}
+
+// cacheMeta is the metadata stored alongside every cache entry
+type cacheMeta struct {
+	Key      string            `json:"key"`
+	Files    map[string]string `json:"files"` // file name -> sha256
+	Size     int64             `json:"size"`
+	Created  time.Time         `json:"created"`
+	LastUsed time.Time         `json:"last_used"`
+	// Provenance records how the index was made
+	Provenance *cacheProvenance `json:"provenance,omitempty"`
+	// Signature is the base64 Ed25519 signature of the entry by the key
+	// SignedBy identifies
+	Signature string `json:"signature,omitempty"`
+	SignedBy  string `json:"signed_by,omitempty"`
+}
+
+// defaultCacheDir returns the per-user cache location for the tool
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-upgrade-checker")
+}
+
+func (c *indexCache) entryDir(key string) string {
+	return filepath.Join(c.Dir, keyHash(key))
+}
+
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// lock takes the cache-wide lock, shared or exclusive
+func (c *indexCache) lock(exclusive bool) (func(), error) {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	unlock, _, err := lockFile(filepath.Join(c.Dir, cacheLockFile), exclusive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock cache: %w", err)
+	}
+	return unlock, nil
+}
+
+// LockKey takes the exclusive lock of one key, which whoever builds the entry
+// holds until it is stored so concurrent runs needing it wait for the result
+// instead of building it again. It reports whether another holder made it wait.
+func (c *indexCache) LockKey(key string) (unlock func(), waited bool, err error) {
+	dir := filepath.Join(c.Dir, cacheKeyLocks)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, false, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	unlock, waited, err = lockFile(filepath.Join(dir, keyHash(key)), true)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to lock cache entry: %w", err)
+	}
+	return unlock, waited, nil
+}
+
+// leaseFile is the file whose shared lock leases the entry in dir
+func (c *indexCache) leaseFile(dir string) string {
+	return filepath.Join(c.Dir, cacheLeases, filepath.Base(dir))
+}
+
+// Get returns the directory of a valid cache entry for key, leased until the
+// returned release function is called: eviction and cleaning leave leased
+// entries alone. Entries whose files fail checksum validation, or that aren't
+// signed by VerifyKey when set, are reported as a miss.
+func (c *indexCache) Get(key string) (dir string, release func(), ok bool) {
+	unlock, err := c.lock(false)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return "", nil, false
+	}
+	defer unlock()
+
+	dir = c.entryDir(key)
+	meta, err := readCacheMeta(dir)
+	if err != nil {
+		return "", nil, false
+	}
+	if err := verifyCacheEntry(dir, meta); err != nil {
+		// Removing it is left to the next Put, which holds the lock exclusively
+		log.Printf("Warning: discarding corrupted cache entry for %s: %v", key, err)
+		return "", nil, false
+	}
+	if c.VerifyKey != nil {
+		if err := verifyCacheSignature(meta, c.VerifyKey); err != nil {
+			log.Printf("Warning: ignoring untrusted cache entry for %s: %v", key, err)
+			return "", nil, false
+		}
+	}
+
+	// The lease is taken under the cache lock, so the entry can't be evicted
+	// in between
+	if err := os.MkdirAll(filepath.Join(c.Dir, cacheLeases), 0o755); err != nil {
+		log.Printf("Warning: failed to lease cache entry for %s: %v", key, err)
+		return "", nil, false
+	}
+	release, _, err = lockFile(c.leaseFile(dir), false)
+	if err != nil {
+		log.Printf("Warning: failed to lease cache entry for %s: %v", key, err)
+		return "", nil, false
+	}
+
+	meta.LastUsed = time.Now()
+	if err := writeCacheMeta(dir, meta); err != nil {
+		log.Printf("Warning: failed to update cache entry for %s: %v", key, err)
+	}
+	return dir, release, true
+}
+
+// removeUnleased removes the entry in dir unless it is leased, reporting
+// whether it did. The caller holds the cache lock exclusively.
+func (c *indexCache) removeUnleased(dir string) (bool, error) {
+	lease := c.leaseFile(dir)
+	unlock, ok, err := tryLockFile(lease)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil {
+		if !ok {
+			return false, nil
+		}
+		defer unlock()
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return false, err
+	}
+	// No Get can wait on the lease file while the cache lock is held
+	os.Remove(lease)
+	return true, nil
+}
+
+// Put writes the given files (name -> contents) into the cache under key, with
+// their provenance and signed with SignKey if set, evicting old entries if the
+// cache grows beyond its size limit, and returns the entry directory
+func (c *indexCache) Put(key string, files map[string]io.Reader, provenance *cacheProvenance) (string, error) {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	tmpDir, err := cleanups.tempDir(c.Dir, ".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer cleanups.remove(tmpDir)
+
+	dir := c.entryDir(key)
+
+	now := time.Now()
+	meta := &cacheMeta{Key: key, Files: make(map[string]string), Created: now, LastUsed: now, Provenance: provenance}
+	for name, r := range files {
+		sum, size, err := copyWithChecksum(r, filepath.Join(tmpDir, name))
+		if err != nil {
+			return "", fmt.Errorf("failed to cache %s: %w", name, err)
+		}
+		meta.Files[name] = sum
+		meta.Size += size
+	}
+	if c.SignKey != nil {
+		if err := signCacheMeta(meta, c.SignKey); err != nil {
+			return "", err
+		}
+	}
+	if err := writeCacheMeta(tmpDir, meta); err != nil {
+		return "", err
+	}
+
+	unlock, err := c.lock(true)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	// Swap the complete entry into place so readers never see a partial one.
+	// An entry another run has leased stays until it is released.
+	if _, err := os.Stat(dir); err == nil {
+		removed, err := c.removeUnleased(dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to replace cache entry: %w", err)
+		}
+		if !removed {
+			return "", fmt.Errorf("cache entry for %s is in use by another run", key)
+		}
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return "", fmt.Errorf("failed to store cache entry: %w", err)
+	}
+
+	if err := c.evict(dir); err != nil {
+		log.Printf("Warning: cache eviction failed: %v", err)
+	}
+	return dir, nil
+}
+
+// cacheEntry pairs an entry directory with its metadata
+type cacheEntry struct {
+	Dir  string
+	Meta *cacheMeta
+}
+
+// entries lists every entry in the cache. Directories without readable
+// metadata are returned with a nil Meta.
+func (c *indexCache) entries() ([]cacheEntry, error) {
+	dirEntries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cache dir: %w", err)
+	}
+
+	var entries []cacheEntry
+	for _, e := range dirEntries {
+		if !e.IsDir() || e.Name()[0] == '.' {
+			continue
+		}
+		dir := filepath.Join(c.Dir, e.Name())
+		meta, _ := readCacheMeta(dir)
+		entries = append(entries, cacheEntry{Dir: dir, Meta: meta})
+	}
+	return entries, nil
+}
+
+// evict removes least recently used entries until the cache fits in MaxBytes.
+// The entry in keep and entries leased by Get are never evicted.
+func (c *indexCache) evict(keep string) error {
+	if c.MaxBytes <= 0 {
+		return nil
+	}
+
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, e := range entries {
+		if e.Meta != nil {
+			total += e.Meta.Size
+		}
+	}
+
+	// Entries without metadata sort first so they are evicted before anything else
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Meta == nil || entries[j].Meta == nil {
+			return entries[i].Meta == nil && entries[j].Meta != nil
+		}
+		return entries[i].Meta.LastUsed.Before(entries[j].Meta.LastUsed)
+	})
+
+	for _, e := range entries {
+		if total <= c.MaxBytes {
+			break
+		}
+		if e.Dir == keep {
+			continue
+		}
+		removed, err := c.removeUnleased(e.Dir)
+		if err != nil {
+			return fmt.Errorf("failed to evict %s: %w", e.Dir, err)
+		}
+		if removed && e.Meta != nil {
+			total -= e.Meta.Size
+		}
+	}
+	return nil
+}
+
+// Verify checks every cache entry against its recorded checksums, and its
+// signature when VerifyKey is set, removing the corrupted or untrusted ones
+// when repair is set unless a run has leased them. It returns a description
+// per bad entry and how many entries it removed.
+func (c *indexCache) Verify(repair bool) ([]string, int, error) {
+	unlock, err := c.lock(repair)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var problems []string
+	var removed int
+	for _, e := range entries {
+		var verr error
+		name := filepath.Base(e.Dir)
+		if e.Meta == nil {
+			verr = fmt.Errorf("missing or unreadable %s", cacheMetaFile)
+		} else {
+			name = e.Meta.Key
+			verr = verifyCacheEntry(e.Dir, e.Meta)
+			if verr == nil && c.VerifyKey != nil {
+				verr = verifyCacheSignature(e.Meta, c.VerifyKey)
+			}
+		}
+		if verr == nil {
+			continue
+		}
+		problem := fmt.Sprintf("%s: %v", name, verr)
+		if repair {
+			ok, err := c.removeUnleased(e.Dir)
+			if err != nil {
+				return problems, removed, fmt.Errorf("failed to remove %s: %w", e.Dir, err)
+			}
+			if ok {
+				removed++
+			} else {
+				problem += " (in use by another run, not removed)"
+			}
+		}
+		problems = append(problems, problem)
+	}
+	return problems, removed, nil
+}
+
+// Clean removes the entries not used within olderThan, or all of them when it
+// is zero, along with any without metadata, but not those a run has leased.
+// It returns how many entries it removed and the size of their files.
+func (c *indexCache) Clean(olderThan time.Duration) (int, int64, error) {
+	unlock, err := c.lock(true)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var removed int
+	var freed int64
+	for _, e := range entries {
+		if e.Meta != nil && olderThan > 0 && time.Since(e.Meta.LastUsed) < olderThan {
+			continue
+		}
+		ok, err := c.removeUnleased(e.Dir)
+		if err != nil {
+			return removed, freed, fmt.Errorf("failed to remove %s: %w", e.Dir, err)
+		}
+		if !ok {
+			continue
+		}
+		removed++
+		if e.Meta != nil {
+			freed += e.Meta.Size
+		}
+	}
+	return removed, freed, nil
+}
+
+func verifyCacheEntry(dir string, meta *cacheMeta) error {
+	for name, want := range meta.Files {
+		got, _, err := fileChecksum(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+	}
+	return nil
+}
+
+func readCacheMeta(dir string) (*cacheMeta, error) {
+	data, err := os.ReadFile(filepath.Join(dir, cacheMetaFile))
+	if err != nil {
+		return nil, err
+	}
+	var meta cacheMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse cache metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+func writeCacheMeta(dir string, meta *cacheMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache metadata: %w", err)
+	}
+	// Readers sharing the cache update last-use times concurrently, so the
+	// file is replaced atomically rather than rewritten in place
+	tmp, err := os.CreateTemp(dir, ".meta-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, cacheMetaFile))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	return nil
+}
+
+func fileChecksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func copyWithChecksum(r io.Reader, dst string) (string, int64, error) {
+	out, err := os.Create(dst)
+	if err != nil {
+		return "", 0, err
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,140 @@
+package upgradecheck
+
+import (
+	"crypto/ed25519"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// putEntry stores an entry of one file holding contents under key
+func putEntry(t *testing.T, c *indexCache, key, contents string) string {
+	t.Helper()
+	dir, err := c.Put(key, map[string]io.Reader{"index.scip": strings.NewReader(contents)}, nil)
+	if err != nil {
+		t.Fatalf("Put(%q) error: %v", key, err)
+	}
+	return dir
+}
+
+func TestCacheGet(t *testing.T) {
+	signKey := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	otherSeed := make([]byte, ed25519.SeedSize)
+	otherSeed[0] = 1
+	otherKey := ed25519.NewKeyFromSeed(otherSeed)
+
+	tests := []struct {
+		name    string
+		sign    ed25519.PrivateKey
+		verify  ed25519.PublicKey
+		corrupt func(dir string) error
+		want    bool
+	}{
+		{name: "hit", want: true},
+		{
+			name: "modified file",
+			corrupt: func(dir string) error {
+				return os.WriteFile(filepath.Join(dir, "index.scip"), []byte("tampered"), 0o644)
+			},
+		},
+		{
+			name: "missing file",
+			corrupt: func(dir string) error {
+				return os.Remove(filepath.Join(dir, "index.scip"))
+			},
+		},
+		{
+			name: "missing metadata",
+			corrupt: func(dir string) error {
+				return os.Remove(filepath.Join(dir, cacheMetaFile))
+			},
+		},
+		{name: "signed", sign: signKey, verify: signKey.Public().(ed25519.PublicKey), want: true},
+		{name: "unsigned", verify: signKey.Public().(ed25519.PublicKey)},
+		{name: "signed by another key", sign: otherKey, verify: signKey.Public().(ed25519.PublicKey)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &indexCache{Dir: t.TempDir(), SignKey: tt.sign, VerifyKey: tt.verify}
+			dir := putEntry(t, c, "example.com/m@v1.0.0", "index")
+			if tt.corrupt != nil {
+				if err := tt.corrupt(dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, release, ok := c.Get("example.com/m@v1.0.0")
+			if ok != tt.want {
+				t.Fatalf("Get() ok = %v, want %v", ok, tt.want)
+			}
+			if !ok {
+				return
+			}
+			defer release()
+			if got != dir {
+				t.Errorf("Get() = %s, want %s", got, dir)
+			}
+			data, err := os.ReadFile(filepath.Join(got, "index.scip"))
+			if err != nil || string(data) != "index" {
+				t.Errorf("cached file = %q, %v; want %q", data, err, "index")
+			}
+		})
+	}
+}
+
+func TestCacheEvict(t *testing.T) {
+	c := &indexCache{Dir: t.TempDir(), MaxBytes: 10}
+	old := putEntry(t, c, "old", "12345")
+	used := putEntry(t, c, "used", "12345")
+
+	// Using the older entry makes the other one the least recently used
+	time.Sleep(10 * time.Millisecond)
+	_, release, ok := c.Get("old")
+	if !ok {
+		t.Fatal("Get(old) missed")
+	}
+	release()
+	putEntry(t, c, "new", "12345")
+
+	for _, tt := range []struct {
+		dir  string
+		want bool
+	}{
+		{old, true},
+		{used, false},
+		{c.entryDir("new"), true},
+	} {
+		if _, err := os.Stat(tt.dir); (err == nil) != tt.want {
+			t.Errorf("entry %s exists = %v, want %v", tt.dir, err == nil, tt.want)
+		}
+	}
+}
+
+func TestCacheVerifyAndClean(t *testing.T) {
+	c := &indexCache{Dir: t.TempDir()}
+	putEntry(t, c, "good", "index")
+	bad := putEntry(t, c, "bad", "index")
+	if err := os.WriteFile(filepath.Join(bad, "index.scip"), []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, removed, err := c.Verify(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], "bad: ") || removed != 1 {
+		t.Errorf("Verify() = %q, %d removed; want one problem with bad, removed", problems, removed)
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Errorf("corrupted entry not repaired: %v", err)
+	}
+
+	if removed, _, err := c.Clean(time.Hour); err != nil || removed != 0 {
+		t.Errorf("Clean(1h) = %d, %v; want 0 entries removed", removed, err)
+	}
+	if removed, freed, err := c.Clean(0); err != nil || removed != 1 || freed != int64(len("index")) {
+		t.Errorf("Clean(0) = %d, %d, %v; want 1 entry of 5 bytes removed", removed, freed, err)
+	}
+}
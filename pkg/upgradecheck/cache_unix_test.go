@@ -0,0 +1,127 @@
+//go:build unix
+
+package upgradecheck
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheLeases(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func(c *indexCache) error
+	}{
+		{"evict", func(c *indexCache) error {
+			putEntry(t, c, "new", "12345")
+			return nil
+		}},
+		{"clean", func(c *indexCache) error {
+			_, _, err := c.Clean(0)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &indexCache{Dir: t.TempDir(), MaxBytes: 10}
+			leased := putEntry(t, c, "leased", "12345")
+			released := putEntry(t, c, "released", "12345")
+			_, release, ok := c.Get("leased")
+			if !ok {
+				t.Fatal("Get(leased) missed")
+			}
+			_, releaseOther, ok := c.Get("released")
+			if !ok {
+				t.Fatal("Get(released) missed")
+			}
+			releaseOther()
+
+			if err := tt.remove(c); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(leased); err != nil {
+				t.Errorf("leased entry removed: %v", err)
+			}
+			if _, err := os.Stat(released); !os.IsNotExist(err) {
+				t.Errorf("released entry kept: %v", err)
+			}
+
+			// Once released, the entry goes like any other
+			release()
+			if _, _, err := c.Clean(0); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(leased); !os.IsNotExist(err) {
+				t.Errorf("entry kept after its lease ended: %v", err)
+			}
+		})
+	}
+}
+
+func TestCacheLeasedReplace(t *testing.T) {
+	c := &indexCache{Dir: t.TempDir()}
+	dir := putEntry(t, c, "leased", "index")
+	_, release, ok := c.Get("leased")
+	if !ok {
+		t.Fatal("Get(leased) missed")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.scip"), []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, removed, err := c.Verify(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0], "in use") || removed != 0 {
+		t.Errorf("Verify() = %q, %d removed; want the leased entry reported in use", problems, removed)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("leased entry removed by Verify(): %v", err)
+	}
+
+	if _, err := c.Put("leased", map[string]io.Reader{"index.scip": strings.NewReader("new")}, nil); err == nil {
+		t.Error("Put() replaced a leased entry")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "index.scip")); string(data) != "tampered" {
+		t.Errorf("leased entry holds %q after Put()", data)
+	}
+
+	release()
+	putEntry(t, c, "leased", "new")
+	if data, _ := os.ReadFile(filepath.Join(dir, "index.scip")); string(data) != "new" {
+		t.Errorf("released entry holds %q after Put(), want new", data)
+	}
+}
+
+func TestCacheLockKey(t *testing.T) {
+	c := &indexCache{Dir: t.TempDir()}
+	unlock, waited, err := c.LockKey("example.com/m@v1.0.0")
+	if err != nil || waited {
+		t.Fatalf("LockKey() = %v, %v; want the lock without waiting", waited, err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		unlock, waited, err := c.LockKey("example.com/m@v1.0.0")
+		if err != nil {
+			t.Error(err)
+		} else {
+			unlock()
+		}
+		done <- waited
+	}()
+	select {
+	case <-done:
+		t.Fatal("second LockKey() didn't wait for the first")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	if waited := <-done; !waited {
+		t.Error("second LockKey() reported it didn't wait")
+	}
+}
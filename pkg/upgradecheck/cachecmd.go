@@ -0,0 +1,83 @@
+package upgradecheck
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runCacheCommand implements the "cache" subcommand for maintaining the
+// persistent index cache
+func runCacheCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: go-upgrade-checker cache verify [--cache-dir dir] [--verify-key file] [--repair]")
+		fmt.Fprintln(os.Stderr, "       go-upgrade-checker cache clean [--cache-dir dir] [--older-than duration]")
+		fmt.Fprintln(os.Stderr, "       go-upgrade-checker cache keygen --out prefix")
+		exit(2)
+	}
+
+	switch args[0] {
+	case "verify":
+		fs := flag.NewFlagSet("cache verify", flag.ExitOnError)
+		cacheDir := fs.String("cache-dir", defaultCacheDir(), "Directory for cached dependency indexes")
+		verifyKey := fs.String("verify-key", "", "Also report entries not signed with the private key of this Ed25519 public key")
+		repair := fs.Bool("repair", false, "Remove corrupted entries")
+		fs.Parse(args[1:])
+
+		cache := &indexCache{Dir: *cacheDir}
+		if *verifyKey != "" {
+			var err error
+			if cache.VerifyKey, err = loadVerifyKey(*verifyKey); err != nil {
+				fatalf("%v", err)
+			}
+		}
+		problems, removed, err := cache.Verify(*repair)
+		if err != nil {
+			fatalf("Failed to verify cache: %v", err)
+		}
+		if len(problems) == 0 {
+			fmt.Println("Cache OK.")
+			return
+		}
+
+		for _, p := range problems {
+			fmt.Println("- " + p)
+		}
+		if *repair {
+			fmt.Printf("Removed %d corrupted or untrusted cache entries.\n", removed)
+			if removed < len(problems) {
+				fmt.Printf("%d entries are in use by another run. Run again once it finishes.\n", len(problems)-removed)
+				exit(1)
+			}
+			return
+		}
+		fmt.Printf("%d corrupted or untrusted cache entries found. Run with --repair to remove them.\n", len(problems))
+		exit(1)
+	case "clean":
+		fs := flag.NewFlagSet("cache clean", flag.ExitOnError)
+		cacheDir := fs.String("cache-dir", defaultCacheDir(), "Directory for cached dependency indexes")
+		olderThan := fs.Duration("older-than", 0, "Only remove entries not used for this long, e.g. 720h (0 removes all)")
+		fs.Parse(args[1:])
+
+		cache := &indexCache{Dir: *cacheDir}
+		removed, freed, err := cache.Clean(*olderThan)
+		if err != nil {
+			fatalf("Failed to clean cache: %v", err)
+		}
+		fmt.Printf("Removed %d cache entries, %.1f MiB.\n", removed, float64(freed)/(1<<20))
+	case "keygen":
+		fs := flag.NewFlagSet("cache keygen", flag.ExitOnError)
+		out := fs.String("out", "cache-signing", "Write the private key to <out>.key and the public key to <out>.pub")
+		fs.Parse(args[1:])
+
+		id, err := generateSigningKey(*out)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Printf("Wrote %s.key and %s.pub (key %s).\n", *out, *out, id)
+		fmt.Printf("Sign with --cache-sign-key %s.key and verify with --cache-verify-key %s.pub.\n", *out, *out)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown cache command %q\n", args[0])
+		exit(2)
+	}
+}
@@ -0,0 +1,211 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// arity describes how many arguments a function signature accepts
+type arity struct {
+	Params   int
+	Variadic bool
+}
+
+// signatureArity returns the arity of a function declaration, or false when the
+// definition isn't a function
+func signatureArity(sig string) (arity, bool) {
+	if !strings.HasPrefix(sig, "func") {
+		return arity{}, false
+	}
+	_, params, tail := splitSignature(sig)
+	if tail == "" {
+		return arity{}, false
+	}
+	a := arity{Params: len(params)}
+	if len(params) > 0 && strings.Contains(params[len(params)-1], "...") {
+		a.Variadic = true
+	}
+	return a, true
+}
+
+// accepts reports whether a call with n arguments compiles against the arity.
+// spread is set for calls passing a slice with "xs...".
+func (a arity) accepts(n int, spread bool) bool {
+	if spread {
+		return a.Variadic && n == a.Params
+	}
+	if a.Variadic {
+		return n >= a.Params-1
+	}
+	return n == a.Params
+}
+
+// callSite is a call expression in a project file together with the position
+// of the called name
+type callSite struct {
+	Name string
+	Line int
+	Col  int
+	Call *ast.CallExpr
+}
+
+// parseCallSites lists every call expression of a file by called name
+func parseCallSites(path string) ([]callSite, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var sites []callSite
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var ident *ast.Ident
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			ident = fun
+		case *ast.SelectorExpr:
+			ident = fun.Sel
+		case *ast.IndexExpr:
+			if id, ok := fun.X.(*ast.Ident); ok {
+				ident = id
+			} else if sel, ok := fun.X.(*ast.SelectorExpr); ok {
+				ident = sel.Sel
+			}
+		}
+		if ident != nil {
+			pos := fset.Position(ident.Pos())
+			sites = append(sites, callSite{Name: ident.Name, Line: pos.Line, Col: pos.Column, Call: call})
+		}
+		return true
+	})
+	return sites, nil
+}
+
+// findCall returns the call at loc, matching on the exact column first and on
+// the called name within the line otherwise
+func findCall(sites []callSite, loc Location, name string) *ast.CallExpr {
+	var byName *ast.CallExpr
+	matches := 0
+	for _, site := range sites {
+		if site.Line != loc.Line || site.Name != name {
+			continue
+		}
+		if site.Col == loc.Column {
+			return site.Call
+		}
+		byName = site.Call
+		matches++
+	}
+	if matches == 1 {
+		return byName
+	}
+	return nil
+}
+
+func containsLocation(locations []Location, loc Location) bool {
+	for _, existing := range locations {
+		if existing == loc {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCallSites narrows findings whose only change is the number of
+// parameters or their variadicity down to the usages that will actually stop
+// compiling. Calls whose argument count still fits the new signature are
+// dropped; if none remain the finding is downgraded to informational.
+// References that aren't calls (function values) are always kept, since the
+// function's type changed. Usages listed in skip are judged elsewhere (see
+// annotateDI); they are kept but not counted either way.
+func validateCallSites(projectPath string, findings []Finding, skip usageSites) {
+	parsed := make(map[string][]callSite)
+
+	for i := range findings {
+		f := &findings[i]
+		if f.Kind != ChangeChanged || len(f.Usages) == 0 {
+			continue
+		}
+		oldArity, ok1 := signatureArity(f.OldSignature)
+		newArity, ok2 := signatureArity(f.NewSignature)
+		if !ok1 || !ok2 || oldArity == newArity {
+			continue
+		}
+
+		name := f.Symbol
+		if j := strings.LastIndexAny(name, "#."); j >= 0 {
+			name = name[j+1:]
+		}
+
+		var broken, skipped []Location
+		// confirmed is set once a call is seen that won't compile
+		confirmed := false
+		for _, loc := range f.Usages {
+			if containsLocation(skip[name], loc) {
+				skipped = append(skipped, loc)
+				continue
+			}
+			sites, ok := parsed[loc.Path]
+			if !ok {
+				var err error
+				sites, err = parseCallSites(filepath.Join(projectPath, loc.Path))
+				if err != nil {
+					sites = nil
+				}
+				parsed[loc.Path] = sites
+			}
+
+			call := findCall(sites, loc, name)
+			if call == nil || loc.Line == 0 {
+				broken = append(broken, loc)
+				continue
+			}
+			spread := call.Ellipsis.IsValid()
+			if !newArity.accepts(len(call.Args), spread) {
+				broken = append(broken, loc)
+				confirmed = true
+				continue
+			}
+			// f(g()) may pass several values; don't claim it is safe
+			if len(call.Args) == 1 && newArity.Params > 1 {
+				if _, multi := call.Args[0].(*ast.CallExpr); multi {
+					broken = append(broken, loc)
+				}
+			}
+		}
+
+		total := len(f.Usages) - len(skipped)
+		if total == 0 {
+			continue
+		}
+		if len(broken) == 0 {
+			f.Severity = SeverityInfo
+			if newArity.Variadic && !oldArity.Variadic && newArity.Params == oldArity.Params+1 {
+				f.Notes = append(f.Notes, fmt.Sprintf("adds an optional variadic parameter; all %d usages remain valid", total))
+			} else {
+				f.Notes = append(f.Notes, fmt.Sprintf("all %d usages remain valid with the new parameter list", total))
+			}
+			continue
+		}
+
+		var where []string
+		for _, loc := range broken {
+			where = append(where, loc.String())
+		}
+		f.Notes = append(f.Notes, fmt.Sprintf("%d of %d usages will fail to compile: %s", len(broken), total, strings.Join(where, ", ")))
+		f.Usages = append(broken, skipped...)
+		if confirmed {
+			f.Confidence = ConfidenceExact
+		}
+	}
+
+	sortFindings(findings)
+}
@@ -0,0 +1,238 @@
+package upgradecheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// checkpointFile is the manifest of a checkpoint directory
+const checkpointFile = "checkpoint.json"
+
+// runContext is canceled when the --timeout time box runs out or the run is
+// interrupted, which kills the subprocesses started with command and aborts
+// downloads
+var runContext, cancelRun = context.WithCancelCause(context.Background())
+
+// errTimedOut is the cause runContext is canceled with by the time box
+var errTimedOut = errors.New("time box ran out")
+
+// runCheckpoint is the checkpoint of the current check, if time-boxed or resumed
+var runCheckpoint *checkpoint
+
+// checkpoint records the completed phases of a time-boxed check (--timeout):
+// the project and module indexes and the services already analyzed with their
+// findings. A later run with --resume continues from there, so a large check
+// can span several CI jobs. Every phase is saved as soon as it completes.
+type checkpoint struct {
+	Module     string   `json:"module"`
+	OldVersion string   `json:"old_version"`
+	NewVersion string   `json:"new_version"`
+	Projects   []string `json:"projects"`
+	// ProjectIndexes are the saved index files by project path
+	ProjectIndexes map[string]string `json:"project_indexes"`
+	// ModuleIndexes are the saved index files of the "old" and "new" version
+	ModuleIndexes map[string]string `json:"module_indexes"`
+	NewGoMod      []byte            `json:"new_go_mod,omitempty"`
+	// Analyzed are the reports of the services analyzed so far
+	Analyzed []*serviceReport `json:"analyzed"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// openCheckpoint starts a checkpoint in dir, or with resume loads the one saved
+// there, which must be of the same check
+func openCheckpoint(dir string, resume bool, module, oldVersion, newVersion string, projects []string) (*checkpoint, error) {
+	if resume {
+		data, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+		var cp checkpoint
+		if err := json.Unmarshal(data, &cp); err != nil {
+			return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+		}
+		if cp.Module != module || cp.OldVersion != oldVersion || cp.NewVersion != newVersion || !slices.Equal(cp.Projects, projects) {
+			return nil, fmt.Errorf("checkpoint in %s is of %s %s -> %s for %v, not this check", dir, cp.Module, cp.OldVersion, cp.NewVersion, cp.Projects)
+		}
+		cp.dir = dir
+		log.Printf("Resuming from checkpoint %s: %d of %d projects indexed, %d of 2 module versions indexed, %d projects analyzed",
+			dir, len(cp.ProjectIndexes), len(projects), len(cp.ModuleIndexes), len(cp.Analyzed))
+		return &cp, nil
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to clear checkpoint dir: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint dir: %w", err)
+	}
+	cp := &checkpoint{
+		Module:         module,
+		OldVersion:     oldVersion,
+		NewVersion:     newVersion,
+		Projects:       projects,
+		ProjectIndexes: make(map[string]string),
+		ModuleIndexes:  make(map[string]string),
+		dir:            dir,
+	}
+	return cp, cp.write()
+}
+
+// timeBox cancels runContext once timeout has elapsed
+func timeBox(timeout time.Duration) {
+	time.AfterFunc(timeout, func() { cancelRun(errTimedOut) })
+}
+
+// timedOut reports whether the time box has run out
+func timedOut() bool {
+	return errors.Is(context.Cause(runContext), errTimedOut)
+}
+
+// exitIncomplete ends a run whose time box ran out with ExitIncomplete
+func (c *checkpoint) exitIncomplete() {
+	if c == nil {
+		log.Printf("Timed out before the analysis completed")
+	} else {
+		// Wait for a phase being saved, so the checkpoint stays complete
+		c.mu.Lock()
+		log.Printf("Timed out before the analysis completed; the completed phases are saved in %s, rerun with --resume to continue", c.dir)
+	}
+	exit(ExitIncomplete)
+}
+
+// checkDeadline exits with ExitIncomplete once the time box has run out. It is
+// called between phases.
+func (c *checkpoint) checkDeadline() {
+	if timedOut() {
+		c.exitIncomplete()
+	}
+}
+
+// projectIndex returns the saved index of a project, or "" when not indexed yet
+func (c *checkpoint) projectIndex(projectPath string) string {
+	if c == nil || c.ProjectIndexes[projectPath] == "" {
+		return ""
+	}
+	return filepath.Join(c.dir, c.ProjectIndexes[projectPath])
+}
+
+// saveProjectIndex saves the index of a project
+func (c *checkpoint) saveProjectIndex(projectPath, indexPath string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := fmt.Sprintf("project-%d.scip", slices.Index(c.Projects, projectPath))
+	if err := copyIndex(indexPath, filepath.Join(c.dir, name)); err != nil {
+		return err
+	}
+	c.ProjectIndexes[projectPath] = name
+	return c.write()
+}
+
+// moduleIndex returns the saved index of the "old" or "new" module version, or
+// "" when not indexed yet
+func (c *checkpoint) moduleIndex(which string) string {
+	if c == nil || c.ModuleIndexes[which] == "" {
+		return ""
+	}
+	return filepath.Join(c.dir, c.ModuleIndexes[which])
+}
+
+// saveModuleIndex saves the index of the "old" or "new" module version, along
+// with the go.mod of the new one
+func (c *checkpoint) saveModuleIndex(which, indexPath string, goMod []byte) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := which + ".scip"
+	if err := copyIndex(indexPath, filepath.Join(c.dir, name)); err != nil {
+		return err
+	}
+	c.ModuleIndexes[which] = name
+	if which == "new" {
+		c.NewGoMod = goMod
+	}
+	return c.write()
+}
+
+// analyzed returns the saved report of an analyzed service, or nil
+func (c *checkpoint) analyzed(projectPath string) *serviceReport {
+	if c == nil {
+		return nil
+	}
+	for _, s := range c.Analyzed {
+		if s.Path == projectPath {
+			return s
+		}
+	}
+	return nil
+}
+
+// saveAnalyzed saves the report of an analyzed service
+func (c *checkpoint) saveAnalyzed(service *serviceReport) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Analyzed = append(c.Analyzed, service)
+	return c.write()
+}
+
+// Remove deletes the checkpoint of a completed check
+func (c *checkpoint) Remove() {
+	if c != nil {
+		os.RemoveAll(c.dir)
+	}
+}
+
+func (c *checkpoint) write() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	// Replaced atomically, so a run killed mid-write leaves the previous one
+	tmp := filepath.Join(c.dir, checkpointFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, checkpointFile)); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// copyIndex copies the index at indexPath, on disk or in memory, to dst
+func copyIndex(indexPath, dst string) error {
+	index, err := openIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	_, err = io.Copy(out, index)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	return nil
+}
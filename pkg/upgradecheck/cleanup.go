@@ -0,0 +1,132 @@
+package upgradecheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// cleanupManager tears down the temporary resources of a run: clones, module
+// downloads, generated indexes, extracted sessions and scratch directories.
+// They are registered by path when created, and removed by the code done
+// with them or, at the latest, when the run ends: returning from main, fatalf
+// and the other exits, or SIGINT and SIGTERM, none of which run deferred calls
+// but the first. Subprocesses are tied to runContext by command(), so ending
+// the run stops them too.
+type cleanupManager struct {
+	mu    sync.Mutex
+	paths map[string]bool
+	// order lists the registered paths oldest first, so teardown removes
+	// nested resources before those containing them
+	order []string
+}
+
+// cleanups is the cleanup manager of the run
+var cleanups = &cleanupManager{paths: make(map[string]bool)}
+
+// tempDir creates a temporary directory in dir, the default temp directory if
+// empty, registered for teardown
+func (m *cleanupManager) tempDir(dir, pattern string) (string, error) {
+	path, err := os.MkdirTemp(dir, pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	m.register(path)
+	return path, nil
+}
+
+// tempFile creates a temporary file in the default temp directory, registered
+// for teardown
+func (m *cleanupManager) tempFile(pattern string) (*os.File, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	m.register(f.Name())
+	return f, nil
+}
+
+// register adds path to the resources removed at teardown
+func (m *cleanupManager) register(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.paths[path] {
+		m.paths[path] = true
+		m.order = append(m.order, path)
+	}
+}
+
+// remove removes a registered temporary file or directory now. Paths that
+// weren't registered are left alone, so a path derived from an empty or
+// unexpected value, such as the directory of a cached index, is never
+// deleted.
+func (m *cleanupManager) remove(path string) {
+	m.mu.Lock()
+	registered := m.paths[path]
+	delete(m.paths, path)
+	m.mu.Unlock()
+
+	if registered {
+		os.RemoveAll(path)
+	}
+}
+
+// run removes every registered resource still present, newest first, and
+// the indexes the run decoded
+func (m *cleanupManager) run() {
+	m.mu.Lock()
+	order := m.order
+	m.order = nil
+	m.mu.Unlock()
+
+	for i := len(order) - 1; i >= 0; i-- {
+		m.remove(order[i])
+	}
+	releaseIndexes()
+}
+
+// exit tears down the run and exits with code. Use it instead of os.Exit.
+func exit(code int) {
+	flushTelemetry()
+	cleanups.run()
+	os.Exit(code)
+}
+
+// subprocessKillGrace is how long an interrupted run waits for its canceled
+// subprocesses to be killed before exiting
+const subprocessKillGrace = 200 * time.Millisecond
+
+// errInterrupted is the cause runContext is canceled with by a signal
+var errInterrupted = errors.New("interrupted")
+
+// interrupted reports whether a signal is ending the run
+func interrupted() bool {
+	return errors.Is(context.Cause(runContext), errInterrupted)
+}
+
+// handleSignals ends the run on SIGINT and SIGTERM: subprocesses are stopped,
+// the results so far written as a partial report and temporary resources
+// removed before exiting with ExitInterrupted
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %s, cleaning up", sig)
+		cause := fmt.Sprintf("received %s", sig)
+		cancelRun(fmt.Errorf("%w: %s", errInterrupted, cause))
+		// Subprocesses run in process groups of their own, out of reach of
+		// the terminal's signals; give their watchers a moment to kill them
+		time.Sleep(subprocessKillGrace)
+		if partial.flush(cause) {
+			log.Printf("Wrote a partial report of the results so far")
+		}
+		exit(ExitInterrupted)
+	}()
+}
@@ -0,0 +1,179 @@
+package upgradecheck
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// codeownersLocations are where GitHub and GitLab look for CODEOWNERS, relative
+// to the repository root, in order of precedence
+var codeownersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+// unowned groups findings no CODEOWNERS rule covers
+const unowned = "(unowned)"
+
+type codeownersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// codeowners maps project files to the teams owning them
+type codeowners struct {
+	rules []codeownersRule
+	// projectDir is the project's directory relative to the repository root
+	projectDir string
+}
+
+// loadCodeowners reads the CODEOWNERS file of the repository containing
+// projectPath. It returns nil when there is none.
+func loadCodeowners(projectPath string) (*codeowners, error) {
+	abs, err := filepath.Abs(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		for _, loc := range codeownersLocations {
+			data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(loc)))
+			if err != nil {
+				continue
+			}
+			rel, err := filepath.Rel(dir, abs)
+			if err != nil {
+				return nil, err
+			}
+			c, err := parseCodeowners(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", loc, err)
+			}
+			c.projectDir = filepath.ToSlash(rel)
+			return c, nil
+		}
+		// CODEOWNERS lives at the repository root; don't look past it
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil || filepath.Dir(dir) == dir {
+			return nil, nil
+		}
+	}
+}
+
+func parseCodeowners(data []byte) (*codeowners, error) {
+	c := &codeowners{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := strings.Fields(line)
+		// GitLab section headers like [Docs] carry no pattern
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "[") || strings.HasPrefix(fields[0], "^[") {
+			continue
+		}
+		re, err := codeownersPattern(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		c.rules = append(c.rules, codeownersRule{pattern: re, owners: fields[1:]})
+	}
+	return c, scanner.Err()
+}
+
+// codeownersPattern compiles a gitignore-style CODEOWNERS pattern. Patterns
+// with a leading or inner slash are anchored at the repository root, others
+// match at any depth; a pattern matching a directory covers everything in it.
+func codeownersPattern(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+
+	var re strings.Builder
+	if anchored {
+		re.WriteString("^")
+	} else {
+		re.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			re.WriteString("[^/]*")
+		case pattern[i] == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString("(?:/.*)?$")
+	return regexp.Compile(re.String())
+}
+
+// owners returns the owners of a file given relative to the project. As on
+// GitHub, the last matching rule wins.
+func (c *codeowners) owners(file string) []string {
+	if c == nil {
+		return nil
+	}
+	p := path.Join(c.projectDir, file)
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if c.rules[i].pattern.MatchString(p) {
+			return c.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// annotateOwners records on each finding the owners of the files using it
+func annotateOwners(findings []Finding, c *codeowners) {
+	if c == nil {
+		return
+	}
+	for i := range findings {
+		seen := make(map[string]bool)
+		for _, file := range findings[i].Files() {
+			for _, owner := range c.owners(file) {
+				if !seen[owner] {
+					seen[owner] = true
+					findings[i].Owners = append(findings[i].Owners, owner)
+				}
+			}
+		}
+	}
+}
+
+// findingsByOwner groups findings by owner, owners sorted and findings without
+// any last. A finding owned by several teams appears under each of them.
+func findingsByOwner(findings []Finding) ([]string, map[string][]Finding) {
+	groups := make(map[string][]Finding)
+	for _, f := range findings {
+		if len(f.Owners) == 0 {
+			groups[unowned] = append(groups[unowned], f)
+			continue
+		}
+		for _, owner := range f.Owners {
+			groups[owner] = append(groups[owner], f)
+		}
+	}
+
+	var owners []string
+	for owner := range groups {
+		if owner != unowned {
+			owners = append(owners, owner)
+		}
+	}
+	sort.Strings(owners)
+	if _, ok := groups[unowned]; ok {
+		owners = append(owners, unowned)
+	}
+	return owners, groups
+}
@@ -0,0 +1,170 @@
+package upgradecheck
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+)
+
+// Check modes of Main, the subcommands sharing the flags of a check
+const (
+	ModeCheck    = "check"
+	ModeCheckAll = "check-all"
+	ModeDiff     = "diff"
+)
+
+// commands lists the subcommands for usage messages. A command line without
+// one runs check, which keeps the flags of releases before subcommands working.
+var commands = []struct{ name, summary string }{
+	{ModeCheck, "Check the upgrade of one module (the default)"},
+	{ModeCheckAll, "Check every direct dependency against its latest version"},
+	{ModeDiff, "Check the upgrades a go.mod change makes"},
+	{"outdated", "List the dependencies with updates and check them"},
+	{"readiness", "Report the upgrade of every direct dependency to its latest release, e.g. nightly"},
+	{"removal-impact", "List every symbol and call site tying the project to a module"},
+	{"verify-migration", "List the references to removed symbols left after applying an upgrade"},
+	{"report", "Work with reports saved with --format=json"},
+	{"index", "Write the SCIP index of the project or of a module version"},
+	{"cache", "Verify, clean or sign the index cache"},
+	{"doctor", "Check the tool's prerequisites"},
+	{"update", "Replace the binary with the latest or pinned release"},
+	{"version", "Print the version of the tool and of scip-go"},
+}
+
+// modeUsage is the synopsis and description of each check mode
+var modeUsage = map[string][2]string{
+	ModeCheck:    {"[check] [flags]", "Checks how upgrading --module from --old-version to --new-version affects the project."},
+	ModeCheckAll: {"check-all [flags]", "Checks every direct dependency of the project against its latest version and prints one report grouped by module."},
+	ModeDiff:     {"diff [flags] [old/go.mod new/go.mod]", "Checks the upgrades between two go.mod files, by default those of --base and the working tree."},
+}
+
+// printCommands writes the list of subcommands to w
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-18s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run go-upgrade-checker <command> -h for the flags of a command.")
+}
+
+// modeFlagUsage prints the usage of a check mode with its flags, followed by
+// the other commands for check, the default
+func modeFlagUsage(mode string) func() {
+	return func() {
+		w := flag.CommandLine.Output()
+		usage := modeUsage[mode]
+		fmt.Fprintf(w, "usage: go-upgrade-checker %s\n\n%s\n\nFlags:\n", usage[0], usage[1])
+		flag.PrintDefaults()
+		if mode == ModeCheck {
+			fmt.Fprintln(w)
+			printCommands(w)
+		}
+	}
+}
+
+// checkerVersion returns the version the binary was built from, "(devel)" for
+// builds outside of go install
+func checkerVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
+
+// runVersion implements the "version" subcommand. The scip-go version is
+// printed too, since indexes and cache entries depend on it.
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	fs.Parse(args)
+
+	fmt.Printf("go-upgrade-checker %s\n", checkerVersion())
+	fmt.Printf("scip-go %s\n", scipGoVersion())
+}
+
+// runIndexCommand implements the "index" subcommand, which writes the SCIP
+// index a check would use: the project's, or that of a module version, taken
+// from and stored in the cache like during checks
+func runIndexCommand(args []string) {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	projectPath := fs.String("project-path", ".", "Path to the Go project to index")
+	packageList := fs.String("packages", "", "Only index these project packages, e.g. ./cmd/api/...,./internal/billing/...")
+	platformList := fs.String("platforms", "", "Index the project for each goos/goarch[:tag1+tag2] in this comma separated list and merge the results")
+	module := fs.String("module", "", "Index this module at --version instead of the project")
+	version := fs.String("version", "", "Version of --module to index")
+	repoURL := fs.String("repo-url", "", "Repository to fetch --module from when the module proxy can't serve it (defaults to https://<module>.git)")
+	vcsName := fs.String("vcs", VCSAuto, "Version control system of --repo-url: auto, git, hg or svn")
+	cacheDir := fs.String("cache-dir", defaultCacheDir(), "Directory for cached dependency indexes (empty disables caching)")
+	var vcsHosts gitHosts
+	fs.Var(&vcsHosts, "vcs-hosts", "Comma separated hosts, with path.Match wildcards, that --module may be cloned from (empty allows any)")
+	engine := fs.String("engine", EngineSCIP, "Index --module with scip-go (scip) or from the export data the go toolchain compiles (exportdata)")
+	backend := fs.String("backend", "", "Build the index with scip-go (scip) or go/packages (gopackages); empty uses scip-go when it is on the PATH")
+	out := fs.String("out", "index.scip", "Write the index to this file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-upgrade-checker index [--project-path dir | --module path --version v] [--out file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	toolEnv = buildToolEnv(os.Environ(), nil)
+	var err error
+	if moduleEngine, err = parseEngine(*engine); err != nil {
+		fatalf("%v", err)
+	}
+	if indexBackend, err = parseBackend(*backend); err != nil {
+		fatalf("%v", err)
+	}
+
+	var indexPath string
+	if *module != "" {
+		if *version == "" {
+			fatalf("--module needs --version")
+		}
+		var cache *indexCache
+		if *cacheDir != "" {
+			cache = &indexCache{Dir: *cacheDir}
+		}
+		if *repoURL == "" {
+			*repoURL = defaultRepoURL(*module)
+		}
+		repo := &moduleRepo{URL: *repoURL, AllowedHosts: vcsHosts}
+		defer repo.Close()
+		if *vcsName != VCSAuto {
+			if repo.VCS, err = newVCS(*vcsName); err != nil {
+				fatalf("%v", err)
+			}
+		}
+		var cleanup func()
+		indexPath, _, cleanup, err = indexModuleVersion(context.Background(), cache, repo, *module, *version)
+		if err == nil {
+			defer cleanup()
+		}
+	} else {
+		var scope packageScope
+		var platforms []platform
+		scope, err = parsePackageScope(*packageList)
+		if err == nil {
+			platforms, err = parsePlatforms(*platformList)
+		}
+		if err == nil {
+			indexPath, err = generateProjectIndex(*projectPath, platforms, scope)
+		}
+		if err == nil {
+			defer releaseIndex(indexPath)
+		}
+	}
+	if err == nil {
+		err = copyIndex(indexPath, *out)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	for _, gap := range gapsOf(indexPath) {
+		fmt.Printf("Not indexed: %s: %s\n", gap.Package, gap.Error)
+	}
+	fmt.Printf("Wrote %s.\n", *out)
+}
@@ -0,0 +1,173 @@
+package upgradecheck
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// breakingSubject matches the subject of a conventional commit marked as
+// breaking, e.g. "feat(client)!: drop the context parameter of Do"
+var breakingSubject = regexp.MustCompile(`^\w+(\([^)]*\))?!:\s*(.+)$`)
+
+// breakingFooter matches the "BREAKING CHANGE:" footer of a conventional commit
+var breakingFooter = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:\s*(.+)$`)
+
+// maxCommitHints bounds the commits noted on one finding
+const maxCommitHints = 3
+
+// breakingCommit is a commit between the two versions that its author marked
+// as breaking
+type breakingCommit struct {
+	Hash string
+	// Description is the BREAKING CHANGE footer, or else the subject without
+	// its type
+	Description string
+	Message     string
+	// Files are the files the commit changed, relative to the module root
+	Files []string
+	URL   string
+}
+
+// ref is how notes refer to the commit: its URL where the host is known,
+// otherwise its abbreviated hash
+func (c breakingCommit) ref() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	return c.Hash[:min(len(c.Hash), 12)]
+}
+
+// breakingCommits returns the commits between two versions of the module in a
+// clone of its repository marked as breaking by conventional-commit markers:
+// a "type!:" subject or a "BREAKING CHANGE:" footer. Only commits changing the
+// module's directory count, since a repository may host several modules.
+func breakingCommits(ctx context.Context, repo *moduleRepo, oldModule, oldVersion, newModule, newVersion string) ([]breakingCommit, error) {
+	if err := repo.clone(ctx); err != nil {
+		return nil, err
+	}
+	oldRev, _, err := moduleCheckout(repo, oldModule, oldVersion)
+	if err != nil {
+		return nil, err
+	}
+	newRev, subdir, err := moduleCheckout(repo, newModule, newVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := repo.VCS.log(repo.Dir, oldRev, newRev, subdir)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []breakingCommit
+	for _, c := range log {
+		description := breakingDescription(c.Message)
+		if description == "" {
+			continue
+		}
+		commits = append(commits, breakingCommit{Hash: c.Hash, Description: description, Message: c.Message, Files: c.Files, URL: commitURL(repo.Remote, c.Hash)})
+	}
+	return commits, nil
+}
+
+// breakingDescription returns what a commit message says breaks, or "" when it
+// isn't marked as breaking
+func breakingDescription(message string) string {
+	if m := breakingFooter.FindStringSubmatch(message); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	if m := breakingSubject.FindStringSubmatch(strings.TrimSpace(subject)); m != nil {
+		return strings.TrimSpace(m[2])
+	}
+	return ""
+}
+
+// commitURL links a commit on the code hosts whose URL layout is known
+func commitURL(repoURL, hash string) string {
+	repo := strings.TrimSuffix(strings.TrimPrefix(repoURL, "https://"), ".git")
+	host, _, _ := strings.Cut(repo, "/")
+	switch {
+	case repo == repoURL || !repoRootHosts[host]:
+		return ""
+	case host == "bitbucket.org":
+		return "https://" + repo + "/commits/" + hash
+	default:
+		return "https://" + repo + "/commit/" + hash
+	}
+}
+
+// annotateCommits notes on each finding the breaking commits related to it:
+// those whose message mentions the symbol, or failing that, those changing
+// the file declaring it. definedIn maps symbols to the file declaring them in
+// the old version, as returned by definingDocuments.
+func annotateCommits(findings []Finding, commits []breakingCommit, definedIn map[string]string) {
+	if len(commits) == 0 {
+		return
+	}
+	for i := range findings {
+		f := &findings[i]
+		related := commitsMentioning(commits, f.Symbol)
+		if len(related) == 0 {
+			related = commitsChanging(commits, declaringFile(f.Symbol, definedIn))
+		}
+		for j, c := range related {
+			if j == maxCommitHints {
+				f.Notes = append(f.Notes, fmt.Sprintf("and %d more breaking commits", len(related)-j))
+				break
+			}
+			f.Notes = append(f.Notes, fmt.Sprintf("marked breaking in %s: %s", c.ref(), c.Description))
+		}
+	}
+}
+
+// commitsMentioning returns the commits whose message names the symbol, e.g.
+// "Client.Do", or its last element as a word of its own, e.g. "Do"
+func commitsMentioning(commits []breakingCommit, symbol string) []breakingCommit {
+	name := symbol
+	if i := strings.LastIndex(symbol, "."); i >= 0 {
+		name = symbol[i+1:]
+	}
+	mention := regexp.MustCompile(`\b(` + regexp.QuoteMeta(symbol) + `|` + regexp.QuoteMeta(name) + `)\b`)
+
+	var related []breakingCommit
+	for _, c := range commits {
+		if mention.MatchString(c.Message) {
+			related = append(related, c)
+		}
+	}
+	return related
+}
+
+// commitsChanging returns the commits changing file
+func commitsChanging(commits []breakingCommit, file string) []breakingCommit {
+	if file == "" {
+		return nil
+	}
+	var related []breakingCommit
+	for _, c := range commits {
+		for _, changed := range c.Files {
+			if changed == file || strings.HasSuffix(changed, "/"+file) || strings.HasSuffix(file, "/"+changed) {
+				related = append(related, c)
+				break
+			}
+		}
+	}
+	return related
+}
+
+// declaringFile returns the file declaring a finding's symbol, such as
+// "Client.Do", falling back to the file declaring its type
+func declaringFile(symbol string, definedIn map[string]string) string {
+	if file, ok := definedIn[strings.Replace(symbol, ".", "#", 1)]; ok {
+		return path.Clean(file)
+	}
+	typeName, _, _ := strings.Cut(symbol, ".")
+	if file, ok := definedIn[typeName]; ok {
+		return path.Clean(file)
+	}
+	return ""
+}
@@ -0,0 +1,216 @@
+package upgradecheck
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	reportapi "go-upgrade-checker/report"
+)
+
+// The comparison core works on indexes alone: it neither runs processes nor
+// reads the project sources, so it also runs where neither is available, such
+// as WebAssembly. Analyses needing the sources or the toolchain stay in
+// runCheck.
+
+// moduleIndexes holds what the analysis reads from the indexes of the two
+// module versions
+type moduleIndexes struct {
+	oldSymbols, newSymbols map[string][]string
+	oldDocs, newDocs       map[string]string
+	// definedIn maps symbols of the old version to their defining documents
+	definedIn                    map[string]string
+	oldImplements, newImplements map[string]map[string]string
+	// oldReads and newReads are the variables the module's own code reads
+	oldReads, newReads map[string]bool
+	// packages are the packages defining the symbols of either version, which
+	// qualify the names findings are reported under
+	packages symbolPackages
+}
+
+// loadModuleIndexes reads the indexes of the old and new module versions.
+// Only the symbol definitions are required; the rest degrades to warnings.
+func loadModuleIndexes(oldPath, newPath string) (*moduleIndexes, error) {
+	var m moduleIndexes
+	var err error
+	if m.newSymbols, err = getAvailableSymbols(newPath); err != nil {
+		return nil, fmt.Errorf("failed to find used symbols: %w", err)
+	}
+	if m.oldSymbols, err = getAvailableSymbols(oldPath); err != nil {
+		return nil, fmt.Errorf("failed to find used symbols: %w", err)
+	}
+
+	if m.oldDocs, err = symbolDocs(oldPath); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if m.newDocs, err = symbolDocs(newPath); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if m.definedIn, err = definingDocuments(oldPath); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if m.oldImplements, err = implementedInterfaces(oldPath); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if m.newImplements, err = implementedInterfaces(newPath); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if m.oldReads, err = readVariables(oldPath); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if m.newReads, err = readVariables(newPath); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	m.packages = make(symbolPackages)
+	for _, path := range []string{oldPath, newPath} {
+		index, err := loadIndex(path)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		for key, pkgs := range definedPackages(index) {
+			if m.packages[key] == nil {
+				m.packages[key] = make(map[string]bool)
+			}
+			for pkg := range pkgs {
+				m.packages[key][pkg] = true
+			}
+		}
+	}
+	return &m, nil
+}
+
+// symbolFindings returns the module symbols the project at indexPath uses and
+// the findings for those the new version removes or changes. extra adds usages
+// the index doesn't record.
+func (m *moduleIndexes) symbolFindings(indexPath, oldModuleIndexPath, module string, extra usageSites, ignored ignoredDirs) (map[string][]string, []Finding, error) {
+	usedSymbols, usage, confidence, err := findUsedSymbols(indexPath, oldModuleIndexPath, module, extra, ignored)
+	if err != nil {
+		return nil, nil, err
+	}
+	added, removed := findChangedSymbols(usedSymbols, m.newSymbols)
+	return usedSymbols, buildFindings(usedSymbols, added, removed, usage, confidence), nil
+}
+
+// memberFindings returns the findings about the members the project at
+// indexPath uses whose documented defaults, deprecation, documented panics or
+// implemented interfaces change, and about project types implementing module
+// interfaces. It also returns the used members for the analyses of the
+// sources.
+func (m *moduleIndexes) memberFindings(indexPath, module string, ignored ignoredDirs) (usageSites, []Finding) {
+	members, err := usedMembers(indexPath, module, ignored)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	findings := defaultsFindings(members, m.oldDocs, m.newDocs)
+	findings = append(findings, deprecationFindings(members, m.oldDocs, m.newDocs)...)
+	findings = append(findings, errorHandlingFindings(members, m.oldDocs, m.newDocs)...)
+	interfaceRefs, err := interfaceReferences(indexPath, ignored)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	findings = append(findings, implementsFindings(members, m.oldImplements, m.newImplements, m.newSymbols, interfaceRefs)...)
+	implementers, projectTypes, err := moduleImplementers(indexPath, module, ignored)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	} else {
+		findings = append(findings, implementerFindings(implementers, projectTypes, m.oldSymbols, m.newSymbols)...)
+	}
+	return members, findings
+}
+
+// IndexComparison is the input of CompareIndexes: encoded SCIP indexes, e.g.
+// as written by scip-go
+type IndexComparison struct {
+	// Module is the module path of the dependency
+	Module string
+	// OldVersion and NewVersion only label the report
+	OldVersion string
+	NewVersion string
+	// Old and New index the two versions of the module
+	Old []byte
+	New []byte
+	// Projects index the projects using the module by service name. Without
+	// any, every symbol of the old version counts as used, which reports the
+	// changes to the module's whole API.
+	Projects map[string][]byte
+	// MinConfidence drops findings rated below "exact", "high" or
+	// "heuristic", the default
+	MinConfidence string
+}
+
+// CompareIndexes compares two indexed versions of a module without building
+// or reading anything else. It is the part of Check that runs anywhere,
+// including WebAssembly; the findings that need the project sources or the
+// module's repository, such as call site validation or --deep, are missing.
+func CompareIndexes(in IndexComparison) (*reportapi.Report, error) {
+	if in.Module == "" {
+		return nil, errors.New("no module to compare")
+	}
+	minConfidence := ConfidenceHeuristic
+	if in.MinConfidence != "" {
+		if err := minConfidence.UnmarshalText([]byte(in.MinConfidence)); err != nil {
+			return nil, err
+		}
+	}
+
+	oldPath, newPath := storeIndex(in.Old, nil), storeIndex(in.New, nil)
+	defer releaseIndex(oldPath)
+	defer releaseIndex(newPath)
+	indexes, err := loadModuleIndexes(oldPath, newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Module: in.Module, OldVersion: in.OldVersion, NewVersion: in.NewVersion}
+	if len(in.Projects) == 0 {
+		findings := apiFindings(indexes)
+		annotateGenerated(findings, indexes.definedIn)
+		classifyFindings(findings)
+		canonicalFindings(findings, indexes.packages)
+		report.Services = append(report.Services, &serviceReport{Name: in.Module, Findings: findings})
+		return publicReport(report)
+	}
+
+	names := make([]string, 0, len(in.Projects))
+	for name := range in.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		service := &serviceReport{Name: name, indexPath: storeIndex(in.Projects[name], nil)}
+		defer releaseIndex(service.indexPath)
+
+		usedSymbols, findings, err := indexes.symbolFindings(service.indexPath, oldPath, in.Module, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find used symbols in %s: %w", name, err)
+		}
+		for sym := range usedSymbols {
+			service.UsedSymbols = append(service.UsedSymbols, sym)
+		}
+		service.UsedSymbols = canonicalSymbols(service.UsedSymbols, indexes.packages)
+		_, memberFindings := indexes.memberFindings(service.indexPath, in.Module, nil)
+		service.Findings = append(findings, memberFindings...)
+		annotateGenerated(service.Findings, indexes.definedIn)
+		classifyFindings(service.Findings)
+		Policy{}.Apply(service.Findings)
+		service.Findings = filterConfidence(service.Findings, minConfidence)
+		canonicalFindings(service.Findings, indexes.packages)
+		report.Services = append(report.Services, service)
+	}
+	return publicReport(report)
+}
+
+// apiFindings reports every removed or changed symbol of the old version, as
+// if a project used them all
+func apiFindings(m *moduleIndexes) []Finding {
+	added, removed := findChangedSymbols(m.oldSymbols, m.newSymbols)
+	confidence := make(map[string]Confidence)
+	for _, changed := range []map[string]string{added, removed} {
+		for sym := range changed {
+			confidence[sym] = ConfidenceExact
+		}
+	}
+	return buildFindings(m.oldSymbols, added, removed, nil, confidence)
+}
@@ -0,0 +1,202 @@
+package upgradecheck
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFiles are the names of the project configuration file, looked up in
+// the project and its parents up to the repository root
+var configFiles = []string{".upgradecheck.yaml", ".upgradecheck.yml"}
+
+// fileConfig is a policy file teams commit instead of passing the same flags in
+// every CI job. Flags given on the command line take precedence.
+type fileConfig struct {
+	Format   string `yaml:"format"`
+	CacheDir string `yaml:"cache-dir"`
+	Proxy    string `yaml:"proxy"`
+	// GOVCS is the policy of the version control systems repositories may be
+	// fetched with, in the syntax of the environment variable
+	GOVCS           string   `yaml:"govcs"`
+	VCSHosts        []string `yaml:"vcs-hosts"`
+	ExcludePackages []string `yaml:"exclude-packages"`
+	IgnoreSymbols   []string `yaml:"ignore-symbols"`
+	// Severity caps the severity of the findings of modules, by module path
+	// or path.Match pattern, e.g. "github.com/legacy/*": warning
+	Severity map[string]Severity `yaml:"severity"`
+	// UpdateVersion pins the release the update command installs: a version
+	// such as v1.4.2, or v1.4 or v1 for the latest release of a minor or
+	// major version
+	UpdateVersion string `yaml:"update-version"`
+	// Flags sets any other flag of the command by name
+	Flags map[string]string `yaml:"flags"`
+}
+
+// findConfig returns the configuration file of the project at projectPath, in
+// it or a parent directory up to the repository root, or "" if it has none
+func findConfig(projectPath string) (string, error) {
+	dir, err := filepath.Abs(projectPath)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range configFiles {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return filepath.Join(dir, name), nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil || parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadConfig reads the configuration file at path
+func loadConfig(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var cfg fileConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// severityFor returns the severity cap of module and whether it has one. An
+// exact module path wins over patterns, and longer patterns over shorter ones.
+func (c *fileConfig) severityFor(module string) (Severity, bool) {
+	if c == nil || module == "" {
+		return 0, false
+	}
+	if sev, ok := c.Severity[module]; ok {
+		return sev, true
+	}
+	patterns := make([]string, 0, len(c.Severity))
+	for pattern := range c.Severity {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, module); ok {
+			return c.Severity[pattern], true
+		}
+	}
+	return 0, false
+}
+
+// apply sets the flags of fs the configuration covers that weren't given on
+// the command line, after which the command proceeds as if they were, and
+// passes the proxy and GOVCS on to env unless already set. Severity caps are left to
+// the check of each module.
+func (c *fileConfig) apply(fs *flag.FlagSet, env envFlag) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	values := make(map[string]string)
+	for name, value := range c.Flags {
+		values[name] = value
+	}
+	if c.Format != "" {
+		values["format"] = c.Format
+	}
+	if c.CacheDir != "" {
+		values["cache-dir"] = c.CacheDir
+	}
+	if len(c.ExcludePackages) > 0 {
+		values["exclude-packages"] = strings.Join(c.ExcludePackages, ",")
+	}
+	if len(c.VCSHosts) > 0 {
+		values["vcs-hosts"] = strings.Join(c.VCSHosts, ",")
+	}
+	if len(c.IgnoreSymbols) > 0 {
+		values["ignore-symbols"] = strings.Join(c.IgnoreSymbols, ",")
+	}
+	// --format has an alias; giving either overrides the configured format
+	if given["output-format"] {
+		given["format"] = true
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if given[name] {
+			continue
+		}
+		// Flags of other commands, such as --base, are left to them
+		if fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("invalid %s in config: %w", name, err)
+		}
+	}
+
+	if _, ok := env["GOPROXY"]; c.Proxy != "" && !ok {
+		env["GOPROXY"] = c.Proxy
+	}
+	if _, ok := env["GOVCS"]; c.GOVCS != "" && !ok {
+		env["GOVCS"] = c.GOVCS
+	}
+	return nil
+}
+
+// parseSymbolPatterns parses a comma separated list of symbols to ignore
+func parseSymbolPatterns(list string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid symbol pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// ignoreSymbols drops the findings whose symbol matches one of the path.Match
+// patterns, given either as reported ("api.Client.Do") or with the import
+// path of the package ("github.com/org/sdk/api.Client.Do")
+func ignoreSymbols(findings []Finding, patterns []string) []Finding {
+	if len(patterns) == 0 {
+		return findings
+	}
+	var kept []Finding
+	for _, f := range findings {
+		names := []string{f.Symbol}
+		if f.Package != "" {
+			names = append(names, f.Package+"."+f.Name())
+		}
+		if !matchesAny(patterns, names) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+func matchesAny(patterns, names []string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package upgradecheck
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// ChangeConfig marks findings on configuration the project hands the module
+// that the new version no longer reads: a flag variable the project sets or
+// an environment variable it defines. They break deployments rather than
+// builds, as the setting is silently ignored.
+const ChangeConfig = "config"
+
+// flagVar matches the definition of a boolean or string package variable, the
+// usual shape of feature flags and behavior switches, e.g. "var EnableRetries
+// bool"
+var flagVar = regexp.MustCompile(`^var ([A-Z]\w*) (bool|string)$`)
+
+// envName matches environment variable names in configuration files
+var envName = regexp.MustCompile(`\b[A-Z][A-Z0-9_]*[A-Z0-9]\b`)
+
+// goSetenv matches environment variables set from Go code, e.g. in tests or
+// before initializing the module
+var goSetenv = regexp.MustCompile(`Setenv\("([A-Za-z_][A-Za-z0-9_]*)"`)
+
+// readVariables returns the package-level variables of the module in the
+// index that its own code reads, i.e. references other than the definition
+func readVariables(indexPath string) (map[string]bool, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	read := make(map[string]bool)
+	for _, doc := range index.Documents {
+		for _, occ := range doc.Occurrences {
+			if occ.SymbolRoles&int32(scip.SymbolRole_Definition) != 0 {
+				continue
+			}
+			if val, typ := extractSymbolsFromOccurrence(occ.Symbol); typ == "constant or variable" {
+				read[val] = true
+			}
+		}
+	}
+	return read, nil
+}
+
+// assignedVars returns the module variables the project sets: assigns to, or
+// hands the address of to a function, as flag.BoolVar(&dep.Verbose, ...) does
+func assignedVars(projectPath, moduleName string) (usageSites, error) {
+	assigned := make(usageSites)
+
+	err := walkModuleImports(projectPath, moduleName, func(file *ast.File, imports moduleImports, pos func(token.Pos) Location) {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				for _, lhs := range n.Lhs {
+					if name, ok := imports.moduleRef(lhs); ok {
+						assigned.add(name, pos(lhs.Pos()))
+					}
+				}
+			case *ast.UnaryExpr:
+				if n.Op == token.AND {
+					if name, ok := imports.moduleRef(n.X); ok {
+						assigned.add(name, pos(n.X.Pos()))
+					}
+				}
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return assigned, nil
+}
+
+// flagFindings reports the boolean and string variables the project sets that
+// the old version reads and the new one still declares but no longer reads:
+// the flag is kept for compatibility, but setting it has no effect anymore.
+// Removed and retyped variables already have findings of their own.
+func flagFindings(assigned usageSites, m *moduleIndexes) []Finding {
+	var names []string
+	for name := range assigned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var findings []Finding
+	for _, name := range names {
+		oldDefs, newDefs := m.oldSymbols[name], m.newSymbols[name]
+		if len(oldDefs) != 1 || len(newDefs) != 1 || !flagVar.MatchString(normalizeDefinition(oldDefs[0])) {
+			continue
+		}
+		if normalizeDefinition(oldDefs[0]) != normalizeDefinition(newDefs[0]) || !m.oldReads[name] || m.newReads[name] {
+			continue
+		}
+		findings = append(findings, Finding{
+			Symbol:       name,
+			Kind:         ChangeConfig,
+			Severity:     SeverityWarning,
+			Confidence:   ConfidenceHeuristic,
+			OldSignature: oldDefs[0],
+			NewSignature: newDefs[0],
+			Usages:       assigned[name],
+			Notes:        []string{fmt.Sprintf("the new version still declares %s but no longer reads it; the value the project sets has no effect", name)},
+		})
+	}
+	return findings
+}
+
+// envReads returns the environment variables the Go files of a version read
+// through os.Getenv or os.LookupEnv with a literal name, with the file reading
+// each first
+func envReads(src *versionSource, paths []string) (map[string]string, error) {
+	reads := make(map[string]string)
+	for _, path := range paths {
+		file, err := src.file(path)
+		if err != nil {
+			return reads, err
+		}
+		osName := ""
+		for _, imp := range file.Imports {
+			if imp.Path.Value != `"os"` {
+				continue
+			}
+			osName = "os"
+			if imp.Name != nil {
+				osName = imp.Name.Name
+			}
+		}
+		if osName == "" {
+			continue
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (sel.Sel.Name != "Getenv" && sel.Sel.Name != "LookupEnv") {
+				return true
+			}
+			if x, ok := sel.X.(*ast.Ident); !ok || x.Name != osName {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			if name, err := strconv.Unquote(lit.Value); err == nil && name != "" {
+				if _, seen := reads[name]; !seen {
+					reads[name] = path
+				}
+			}
+			return true
+		})
+	}
+	return reads, nil
+}
+
+// sourceFiles returns the non-test Go files among the documents symbols are
+// defined in, in order
+func sourceFiles(definedIn map[string]string) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, path := range definedIn {
+		if strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// isConfigFile reports whether a project file likely sets environment
+// variables of a deployment: Dockerfiles, compose and Kubernetes manifests,
+// .env files, shell scripts and the like
+func isConfigFile(name string) bool {
+	switch {
+	case strings.HasPrefix(name, "Dockerfile"), strings.HasPrefix(name, ".env"), name == "Makefile", name == "Procfile":
+		return true
+	}
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml", ".env", ".sh", ".toml", ".json", ".ini", ".conf", ".properties", ".tf", ".tfvars":
+		return true
+	}
+	return false
+}
+
+// envSettings returns where the project sets the given environment variables:
+// mentions in its configuration files and Setenv calls in its Go code
+func envSettings(projectPath string, names map[string]bool, ignored ignoredDirs) (usageSites, error) {
+	settings := make(usageSites)
+	if len(names) == 0 {
+		return settings, nil
+	}
+
+	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != projectPath && (name == "vendor" || name == "node_modules" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		isGo := strings.HasSuffix(name, ".go")
+		if !isGo && !isConfigFile(name) {
+			return nil
+		}
+		rel, err := filepath.Rel(projectPath, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if ignored.ignores(rel) {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			text := scanner.Text()
+			if isGo {
+				for _, m := range goSetenv.FindAllStringSubmatchIndex(text, -1) {
+					if env := text[m[2]:m[3]]; names[env] {
+						settings.add(env, Location{Path: rel, Line: line, Column: m[2] + 1})
+					}
+				}
+				continue
+			}
+			for _, m := range envName.FindAllStringIndex(text, -1) {
+				if env := text[m[0]:m[1]]; names[env] {
+					settings.add(env, Location{Path: rel, Line: line, Column: m[0] + 1})
+				}
+			}
+		}
+		// Lines too long to scan are minified data rather than configuration
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan project configuration: %w", err)
+	}
+	return settings, nil
+}
+
+// envFindings reports the environment variables the old version reads and the
+// new one doesn't that the project sets. Variables the new version starts
+// reading under the same prefix are suggested as renames.
+func envFindings(oldReads, newReads map[string]string, settings usageSites) []Finding {
+	var names []string
+	for name := range settings {
+		if _, ok := newReads[name]; !ok {
+			if _, ok := oldReads[name]; ok {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var findings []Finding
+	for _, name := range names {
+		note := fmt.Sprintf("the old version reads environment variable %s (in %s) and the new version doesn't; the setting is silently ignored", name, oldReads[name])
+		prefix, _, _ := strings.Cut(name, "_")
+		var added []string
+		for env := range newReads {
+			if _, ok := oldReads[env]; !ok && strings.HasPrefix(env, prefix+"_") {
+				added = append(added, env)
+			}
+		}
+		sort.Strings(added)
+		if len(added) > 0 {
+			note += fmt.Sprintf("; it newly reads %s", strings.Join(added, ", "))
+		}
+		findings = append(findings, Finding{
+			Symbol:       "$" + name,
+			Kind:         ChangeConfig,
+			Severity:     SeverityWarning,
+			Confidence:   ConfidenceHeuristic,
+			OldSignature: fmt.Sprintf("os.Getenv(%q)", name),
+			Usages:       settings[name],
+			Notes:        []string{note},
+		})
+	}
+	return findings
+}
@@ -0,0 +1,150 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// constantValue splits the definition of a constant, such as "const Timeout
+// untyped int = 30" or "const StatusActive Status = 1", into its type and
+// resolved value
+func constantValue(def string) (typeName, value string, ok bool) {
+	rest, ok := strings.CutPrefix(normalizeDefinition(def), "const ")
+	if !ok {
+		return "", "", false
+	}
+	_, rest, ok = strings.Cut(rest, " ")
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, " = ")
+}
+
+// enumConstant splits the definition of a constant of a named type, such as
+// "const StatusActive Status = 1", into its type and resolved value. Untyped
+// constants and those of predeclared types aren't enum values.
+func enumConstant(def string) (typeName, value string, ok bool) {
+	typeName, value, ok = constantValue(def)
+	if !ok || typeName == "" || strings.Contains(typeName, " ") {
+		return "", "", false
+	}
+	if r := []rune(typeName)[0]; !unicode.IsUpper(r) {
+		return "", "", false
+	}
+	return typeName, value, true
+}
+
+// serializedTypes returns the module types the project declares tagged struct
+// fields of, e.g. a dep.Status field tagged json:"status", with those fields.
+// Values of such types are likely persisted or sent over the wire.
+func serializedTypes(projectPath, moduleName string) (usageSites, error) {
+	serialized := make(usageSites)
+
+	err := walkModuleImports(projectPath, moduleName, func(file *ast.File, imports moduleImports, pos func(token.Pos) Location) {
+		ast.Inspect(file, func(n ast.Node) bool {
+			st, ok := n.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			for _, field := range st.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+				typ := field.Type
+				// []Status and map[string]Status are stored the same way
+				for {
+					if arr, ok := typ.(*ast.ArrayType); ok {
+						typ = arr.Elt
+					} else if m, ok := typ.(*ast.MapType); ok {
+						typ = m.Value
+					} else {
+						break
+					}
+				}
+				if name, ok := imports.moduleRef(typ); ok {
+					serialized.add(name, pos(field.Pos()))
+				}
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return serialized, nil
+}
+
+// annotateConstValues reclassifies findings on constants whose value changed
+// as behavioral changes: the code still compiles, but runs with another value,
+// such as a default timeout or limit. Enum constants typically shift because a
+// value was inserted into or removed from an iota sequence, so values stored
+// or sent by the old version now mean another constant; those are critical
+// when the project declares tagged struct fields of the enum type. Constants
+// whose type changed stay breaking, with a note on the types.
+func annotateConstValues(findings []Finding, newSymbols map[string][]string, serialized usageSites) {
+	// Which constant each value of an enum type means in the new version
+	meaning := make(map[string]string)
+	for name, defs := range newSymbols {
+		if len(defs) == 0 {
+			continue
+		}
+		if typeName, value, ok := enumConstant(defs[0]); ok {
+			meaning[typeName+"="+value] = name
+		}
+	}
+
+	for i := range findings {
+		f := &findings[i]
+		if f.Kind != ChangeChanged {
+			continue
+		}
+		oldType, oldValue, ok := constantValue(f.OldSignature)
+		if !ok {
+			continue
+		}
+		newType, newValue, ok := constantValue(f.NewSignature)
+		switch {
+		case !ok:
+			continue
+		case newType != oldType:
+			f.Notes = append(f.Notes, fmt.Sprintf("type of %s changed from %s to %s", f.Symbol, oldType, newType))
+			continue
+		case newValue == oldValue:
+			continue
+		}
+
+		f.Kind = ChangeBehavior
+		note := fmt.Sprintf("value of %s changed from %s to %s", f.Symbol, oldValue, newValue)
+		if _, _, isEnum := enumConstant(f.OldSignature); !isEnum {
+			f.Severity = SeverityWarning
+			f.Notes = append(f.Notes, note+"; code using it compiles unchanged but runs with the new value")
+			continue
+		}
+		if other := meaning[oldType+"="+oldValue]; other != "" && other != f.Symbol {
+			note += fmt.Sprintf("; %s now means %s", oldValue, other)
+		}
+		f.Notes = append(f.Notes, note)
+
+		if fields := serialized[oldType]; len(fields) > 0 {
+			f.Severity = SeverityCritical
+			var where []string
+			for _, loc := range fields {
+				where = append(where, loc.String())
+			}
+			sort.Strings(where)
+			f.Notes = append(f.Notes, fmt.Sprintf(
+				"%s is persisted or sent over the wire by tagged struct fields at %s; stored and transmitted values will be read as a different constant",
+				oldType, strings.Join(where, ", ")))
+		} else {
+			f.Severity = SeverityWarning
+			f.Notes = append(f.Notes, fmt.Sprintf(
+				"compiled code stays consistent, but %s values stored, transmitted or written as literals by the old version now mean a different constant",
+				oldType))
+		}
+	}
+}
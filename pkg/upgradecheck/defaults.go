@@ -0,0 +1,148 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ChangeBehavior marks findings that compile fine but may change how the
+// dependency behaves for the project
+const ChangeBehavior = "behavior"
+
+var (
+	// optionsTypeName matches the configuration structs libraries commonly expose
+	optionsTypeName = regexp.MustCompile(`(Options|Opts|Config|Configuration|Settings|Params)$`)
+	// defaultsSymbolName matches constructors and exported defaults
+	defaultsSymbolName = regexp.MustCompile(`^(New|Default)[A-Z0-9_]?`)
+	// defaultsWording finds documentation describing default or zero-value semantics
+	defaultsWording = regexp.MustCompile(`(?i)\b(default|defaults|zero|unset|empty|nil|required|must be set|if not set)\b`)
+	// requiredWording finds documentation of fields that must be provided
+	requiredWording = regexp.MustCompile(`(?i)\b(required|must be set|must be provided|must not be (empty|nil|zero))\b`)
+)
+
+// symbolDocs maps each symbol of the index, keyed like extractSymbolsFromOccurrence
+// (e.g. "Options#Timeout" for a field), to its doc comment
+func symbolDocs(indexPath string) (map[string]string, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make(map[string]string)
+	for _, doc := range index.Documents {
+		for _, sym := range doc.Symbols {
+			val, _ := extractSymbolsFromOccurrence(sym.Symbol)
+			if val == "" {
+				continue
+			}
+			// The first entry is the declaration, the rest is its doc comment
+			var comment string
+			if len(sym.Documentation) > 1 {
+				comment = strings.TrimSpace(strings.Join(sym.Documentation[1:], "\n"))
+			}
+			docs[val] = comment
+		}
+	}
+	return docs, nil
+}
+
+// usedMembers returns the module symbols the project references, keyed like
+// extractSymbolsFromOccurrence so struct fields keep their type ("Options#Timeout"),
+// outside of ignored directories
+func usedMembers(indexPath, moduleName string, ignored ignoredDirs) (usageSites, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(usageSites)
+	for _, doc := range index.Documents {
+		if ignored.ignores(doc.RelativePath) {
+			continue
+		}
+		for _, occ := range doc.Occurrences {
+			if !inModule(occ.Symbol, moduleName) {
+				continue
+			}
+			if val, _ := extractSymbolsFromOccurrence(occ.Symbol); val != "" {
+				used.add(strings.TrimSuffix(val, "#"), occurrenceLocation(doc.RelativePath, occ))
+			}
+		}
+	}
+	return used, nil
+}
+
+// defaultsFindings reports behavioral risks around configuration: documented
+// default or zero-value semantics that changed on Options/Config fields the
+// project sets, new fields documented as required on Options/Config structs the
+// project uses, and changed documentation of constructors and Default*
+// symbols the project relies on
+func defaultsFindings(used usageSites, oldDocs, newDocs map[string]string) []Finding {
+	var findings []Finding
+
+	usedTypes := make(map[string]bool)
+	for key := range used {
+		typeName, member, isMember := strings.Cut(key, "#")
+		if optionsTypeName.MatchString(typeName) {
+			usedTypes[typeName] = true
+		}
+
+		oldDoc, inOld := oldDocs[key]
+		newDoc, inNew := newDocs[key]
+		if !inOld || !inNew || normalizeDefinition(oldDoc) == normalizeDefinition(newDoc) {
+			continue
+		}
+		if !defaultsWording.MatchString(oldDoc) && !defaultsWording.MatchString(newDoc) {
+			continue
+		}
+
+		var note string
+		switch {
+		case isMember && optionsTypeName.MatchString(typeName):
+			note = fmt.Sprintf("the documented default or zero-value meaning of %s.%s, which the project sets, changed", typeName, member)
+		case !isMember && defaultsSymbolName.MatchString(key):
+			note = fmt.Sprintf("the documented defaults of %s, which the project uses, changed", key)
+		default:
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Symbol:       strings.Replace(key, "#", ".", 1),
+			Kind:         ChangeBehavior,
+			Severity:     SeverityWarning,
+			Confidence:   ConfidenceHeuristic,
+			OldSignature: oldDoc,
+			NewSignature: newDoc,
+			Usages:       used[key],
+			Notes:        []string{note},
+		})
+	}
+
+	var newKeys []string
+	for key := range newDocs {
+		newKeys = append(newKeys, key)
+	}
+	sort.Strings(newKeys)
+	for _, key := range newKeys {
+		typeName, member, isMember := strings.Cut(key, "#")
+		if !isMember || !usedTypes[typeName] {
+			continue
+		}
+		if _, existed := oldDocs[key]; existed || !requiredWording.MatchString(newDocs[key]) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Symbol:       typeName + "." + member,
+			Kind:         ChangeBehavior,
+			Severity:     SeverityWarning,
+			Confidence:   ConfidenceHeuristic,
+			NewSignature: newDocs[key],
+			Usages:       used[typeName],
+			Notes:        []string{fmt.Sprintf("new field %s.%s is documented as required; existing %s values won't set it", typeName, member, typeName)},
+		})
+	}
+
+	return findings
+}
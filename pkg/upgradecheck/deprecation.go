@@ -0,0 +1,70 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// ChangeDeprecated marks findings for symbols the new version deprecates
+const ChangeDeprecated = "deprecated"
+
+// moduleDeprecation returns the "// Deprecated:" message of the module declared
+// by the given go.mod contents, or an empty string when it is not deprecated or
+// has no go.mod at all
+func moduleDeprecation(goMod []byte) (string, error) {
+	if goMod == nil {
+		return "", nil
+	}
+
+	file, err := modfile.ParseLax("go.mod", goMod, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse go.mod: %w", err)
+	}
+	if file.Module == nil {
+		return "", nil
+	}
+
+	return file.Module.Deprecated, nil
+}
+
+// deprecationNotice returns the paragraph of a doc comment starting with
+// "Deprecated:", the convention tools like gopls and staticcheck recognize
+func deprecationNotice(doc string) string {
+	for _, paragraph := range strings.Split(doc, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if strings.HasPrefix(paragraph, "Deprecated:") {
+			return strings.Join(strings.Fields(paragraph), " ")
+		}
+	}
+	return ""
+}
+
+// deprecationFindings reports the symbols the project uses that are deprecated
+// in the new version but weren't in the old one
+func deprecationFindings(used usageSites, oldDocs, newDocs map[string]string) []Finding {
+	var keys []string
+	for key := range used {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var findings []Finding
+	for _, key := range keys {
+		notice := deprecationNotice(newDocs[key])
+		if notice == "" || deprecationNotice(oldDocs[key]) != "" {
+			continue
+		}
+		findings = append(findings, Finding{
+			Symbol:     strings.Replace(key, "#", ".", 1),
+			Kind:       ChangeDeprecated,
+			Severity:   SeverityWarning,
+			Confidence: ConfidenceExact,
+			Usages:     used[key],
+			Notes:      []string{notice},
+		})
+	}
+	return findings
+}
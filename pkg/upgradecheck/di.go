@@ -0,0 +1,176 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diFrameworks maps the import paths of supported dependency-injection
+// frameworks to their name
+var diFrameworks = map[string]string{
+	"github.com/google/wire": "wire",
+	"go.uber.org/fx":         "fx",
+	"go.uber.org/dig":        "dig",
+}
+
+// diFunctions are the package-level functions of each framework that take
+// providers; dig takes them through methods of its containers instead
+var diFunctions = map[string]map[string]bool{
+	"wire": {"NewSet": true, "Build": true},
+	"fx":   {"Provide": true, "Invoke": true, "Decorate": true},
+}
+
+// digMethods are the container and scope methods of dig that take providers
+var digMethods = map[string]bool{"Provide": true, "Invoke": true, "Decorate": true}
+
+// diProvider is a module constructor handed to a dependency-injection framework
+type diProvider struct {
+	Framework string
+	Location  Location
+}
+
+// diProviders returns the module functions the project passes to wire provider
+// sets, fx.Provide/Invoke/Decorate or dig containers, keyed by name. The
+// frameworks resolve these by their signature at generate time or when the
+// application starts, so signature changes break there rather than at a call
+// site the compiler checks.
+func diProviders(projectPath, moduleName string) (map[string][]diProvider, error) {
+	providers := make(map[string][]diProvider)
+
+	err := walkModuleImports(projectPath, moduleName, func(file *ast.File, imports moduleImports, pos func(token.Pos) Location) {
+		frameworks := make(map[string]string) // import name -> framework
+		usesDig := false
+		for _, imp := range file.Imports {
+			importPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			framework, ok := diFrameworks[importPath]
+			if !ok {
+				continue
+			}
+			if framework == "dig" {
+				usesDig = true
+			}
+			name := path.Base(importPath)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			frameworks[name] = framework
+		}
+		if len(frameworks) == 0 {
+			return
+		}
+
+		// collect records module functions among the arguments of a DI call,
+		// looking into helper calls such as fx.Annotate but not function literals
+		var collect func(framework string, expr ast.Expr)
+		collect = func(framework string, expr ast.Expr) {
+			switch e := expr.(type) {
+			case *ast.CallExpr:
+				for _, arg := range e.Args {
+					collect(framework, arg)
+				}
+			case *ast.FuncLit:
+			default:
+				if name, ok := imports.moduleRef(e); ok {
+					// Point at the name itself, like the indexer's occurrences
+					at := e.Pos()
+					if sel, ok := e.(*ast.SelectorExpr); ok {
+						at = sel.Sel.Pos()
+					}
+					providers[name] = append(providers[name], diProvider{Framework: framework, Location: pos(at)})
+				}
+			}
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			framework := ""
+			if x, ok := sel.X.(*ast.Ident); ok && diFunctions[frameworks[x.Name]][sel.Sel.Name] {
+				framework = frameworks[x.Name]
+			} else if usesDig && digMethods[sel.Sel.Name] {
+				framework = "dig"
+			}
+			if framework == "" {
+				return true
+			}
+			for _, arg := range call.Args {
+				collect(framework, arg)
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return providers, nil
+}
+
+// diLocations returns the locations of all providers, for callers that must
+// not treat them as ordinary call sites
+func diLocations(providers map[string][]diProvider) usageSites {
+	sites := make(usageSites)
+	for name, ps := range providers {
+		for _, p := range ps {
+			sites.add(name, p.Location)
+		}
+	}
+	return sites
+}
+
+// annotateDI notes on changed or removed functions the project hands to a
+// dependency-injection framework where and how that breaks, and makes sure
+// such findings stay at least breaking even when every ordinary call site
+// still compiles
+func annotateDI(findings []Finding, providers map[string][]diProvider) {
+	for i := range findings {
+		f := &findings[i]
+		ps := providers[f.Symbol]
+		if len(ps) == 0 || (f.Kind != ChangeChanged && f.Kind != ChangeResults && f.Kind != ChangeRemoved) {
+			continue
+		}
+
+		byFramework := make(map[string][]string)
+		for _, p := range ps {
+			byFramework[p.Framework] = append(byFramework[p.Framework], p.Location.String())
+			if !containsLocation(f.Usages, p.Location) {
+				f.Usages = append(f.Usages, p.Location)
+			}
+		}
+		var frameworks []string
+		for framework := range byFramework {
+			frameworks = append(frameworks, framework)
+		}
+		sort.Strings(frameworks)
+
+		for _, framework := range frameworks {
+			where := strings.Join(byFramework[framework], ", ")
+			var note string
+			switch framework {
+			case "wire":
+				note = fmt.Sprintf("used as a wire provider in %s; regenerating wire_gen.go will fail or produce different wiring", where)
+			default:
+				note = fmt.Sprintf("passed to %s in %s; %s resolves it by signature when the application starts, so this breaks at runtime rather than at compile time", framework, where, framework)
+			}
+			f.Notes = append(f.Notes, note)
+		}
+		if f.Severity < SeverityBreaking {
+			f.Severity = SeverityBreaking
+		}
+	}
+	sortFindings(findings)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package upgradecheck
+
+import "errors"
+
+// freeDiskSpace is not implemented on this platform
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, errors.New("not supported on this platform")
+}
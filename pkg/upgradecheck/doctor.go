@@ -0,0 +1,194 @@
+package upgradecheck
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+)
+
+// minScipGoVersion is the oldest scip-go release known to produce indexes
+// this tool can read
+const minScipGoVersion = "v0.1.0"
+
+// minFreeDiskBytes is the free space below which doctor warns; clones and
+// indexes of large dependencies easily take hundreds of megabytes
+const minFreeDiskBytes = 1 << 30
+
+var versionPattern = regexp.MustCompile(`v?\d+\.\d+\.\d+`)
+
+// checkResult is the outcome of a single doctor check
+type checkResult struct {
+	Name   string
+	Status string // "ok", "warn" or "fail"
+	Detail string
+	Fix    string
+}
+
+// runDoctor implements the "doctor" subcommand, which verifies the tool's
+// prerequisites and suggests fixes for anything missing
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	cacheDir := fs.String("cache-dir", defaultCacheDir(), "Directory for cached dependency indexes")
+	fs.Parse(args)
+
+	results := []checkResult{
+		checkGit(),
+		checkGo(),
+		checkScipGo(),
+		checkProxy(),
+		checkCacheDir(*cacheDir),
+		checkDiskSpace(os.TempDir()),
+	}
+
+	failed := false
+	for _, r := range results {
+		fmt.Printf("[%-4s] %s: %s\n", r.Status, r.Name, r.Detail)
+		if r.Fix != "" && r.Status != "ok" {
+			fmt.Printf("       fix: %s\n", r.Fix)
+		}
+		if r.Status == "fail" {
+			failed = true
+		}
+	}
+
+	if failed {
+		fmt.Println("\nSome prerequisites are missing; fix the failed checks above and re-run doctor.")
+		exit(1)
+	}
+	fmt.Println("\nAll prerequisites are in place.")
+}
+
+// scipGoVersion returns the version of the scip-go on the PATH, or "unknown"
+// when it can't be determined. It runs scip-go at most once per run.
+var scipGoVersion = sync.OnceValue(func() string {
+	out, err := toolVersion("scip-go", "--version")
+	if err != nil {
+		return "unknown"
+	}
+	version := versionPattern.FindString(out)
+	if version == "" {
+		return "unknown"
+	}
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version
+})
+
+// toolVersion runs "name args..." and returns its trimmed output
+func toolVersion(name string, args ...string) (string, error) {
+	out, err := command(name, args...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+func checkGit() checkResult {
+	r := checkResult{Name: "git", Fix: "install git and make sure it is on your PATH"}
+	if _, err := exec.LookPath("git"); err != nil {
+		r.Status, r.Detail = "fail", "git not found in PATH"
+		return r
+	}
+	out, err := toolVersion("git", "--version")
+	if err != nil {
+		r.Status, r.Detail = "fail", fmt.Sprintf("git --version failed: %v", err)
+		return r
+	}
+	r.Status, r.Detail = "ok", out
+	return r
+}
+
+func checkGo() checkResult {
+	r := checkResult{Name: "go", Fix: "install the Go toolchain (https://go.dev/dl/); scip-go needs it to load packages"}
+	if _, err := exec.LookPath("go"); err != nil {
+		r.Status, r.Detail = "fail", "go not found in PATH"
+		return r
+	}
+	out, err := toolVersion("go", "version")
+	if err != nil {
+		r.Status, r.Detail = "fail", fmt.Sprintf("go version failed: %v", err)
+		return r
+	}
+	r.Status, r.Detail = "ok", out
+	return r
+}
+
+func checkScipGo() checkResult {
+	r := checkResult{Name: "scip-go", Fix: "go install github.com/sourcegraph/scip-go/cmd/scip-go@latest (see https://github.com/sourcegraph/scip-go#installation)"}
+	if _, err := exec.LookPath("scip-go"); err != nil {
+		r.Status, r.Detail = "warn", "scip-go not found in PATH; indexes are built with go/packages instead"
+		return r
+	}
+	out, err := toolVersion("scip-go", "--version")
+	if err != nil {
+		r.Status, r.Detail = "fail", fmt.Sprintf("scip-go --version failed: %v", err)
+		return r
+	}
+
+	version := versionPattern.FindString(out)
+	if version == "" {
+		r.Status, r.Detail = "warn", fmt.Sprintf("could not determine scip-go version from %q", out)
+		return r
+	}
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if semver.Compare(version, minScipGoVersion) < 0 {
+		r.Status, r.Detail = "fail", fmt.Sprintf("scip-go %s is older than the minimum supported %s", version, minScipGoVersion)
+		return r
+	}
+	r.Status, r.Detail = "ok", "scip-go "+version
+	return r
+}
+
+func checkProxy() checkResult {
+	r := checkResult{Name: "module proxy", Fix: "check network access to the proxy, or point GOPROXY at a reachable proxy"}
+	if _, err := proxyGet("golang.org/x/mod", "@v/list"); err != nil {
+		// Only retraction checks need the proxy, so this isn't fatal
+		r.Status, r.Detail = "warn", fmt.Sprintf("%s unreachable: %v", proxyURL(), err)
+		return r
+	}
+	r.Status, r.Detail = "ok", proxyURL()+" reachable"
+	return r
+}
+
+func checkCacheDir(dir string) checkResult {
+	r := checkResult{Name: "cache dir", Fix: "pass a writable --cache-dir, or an empty one to disable caching"}
+	if dir == "" {
+		r.Status, r.Detail = "ok", "caching disabled"
+		return r
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		r.Status, r.Detail = "fail", fmt.Sprintf("cannot create %s: %v", dir, err)
+		return r
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		r.Status, r.Detail = "fail", fmt.Sprintf("%s is not writable: %v", dir, err)
+		return r
+	}
+	f.Close()
+	os.Remove(f.Name())
+	r.Status, r.Detail = "ok", dir+" is writable"
+	return r
+}
+
+func checkDiskSpace(dir string) checkResult {
+	r := checkResult{Name: "disk space", Fix: fmt.Sprintf("free up space in %s or point TMPDIR elsewhere", dir)}
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		r.Status, r.Detail = "warn", fmt.Sprintf("could not determine free space in %s: %v", dir, err)
+		return r
+	}
+	detail := fmt.Sprintf("%d MiB free in %s", free>>20, dir)
+	if free < minFreeDiskBytes {
+		r.Status, r.Detail = "warn", detail
+		return r
+	}
+	r.Status, r.Detail = "ok", detail
+	return r
+}
@@ -0,0 +1,121 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// inheritedEnvPrefixes selects the variables passed on to git, scip-go and go
+// subprocesses. Everything that influences module resolution, authentication
+// or proxies is inherited so results match what the project's real builds see.
+var inheritedEnvPrefixes = []string{
+	"GO", "CGO_", "GIT_", "SSH_",
+}
+
+// inheritedEnvNames are individual variables passed on to subprocesses
+var inheritedEnvNames = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "TMPDIR", "TEMP", "TMP",
+	"XDG_CACHE_HOME", "XDG_CONFIG_HOME", "SYSTEMROOT", "LOCALAPPDATA", "APPDATA", "USERPROFILE",
+	"CC", "CXX", "PKG_CONFIG_PATH",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "ALL_PROXY",
+	"http_proxy", "https_proxy", "no_proxy", "all_proxy",
+	"SSL_CERT_FILE", "SSL_CERT_DIR", "NETRC",
+}
+
+// toolEnv is the environment every subprocess runs with; see buildToolEnv
+var toolEnv = buildToolEnv(os.Environ(), nil)
+
+// envFlag collects repeated --env KEY=VALUE flags
+type envFlag map[string]string
+
+func (e envFlag) String() string {
+	var pairs []string
+	for k, v := range e {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (e envFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", value)
+	}
+	e[key] = val
+	return nil
+}
+
+// buildToolEnv filters environ down to the inherited variables and applies the
+// overrides on top. Git is told never to prompt for credentials, since there is
+// no terminal to answer it in CI.
+func buildToolEnv(environ []string, overrides map[string]string) []string {
+	vars := make(map[string]string)
+	for _, kv := range environ {
+		key, val, ok := strings.Cut(kv, "=")
+		if ok && inheritEnv(key) {
+			vars[key] = val
+		}
+	}
+	if _, ok := vars["GIT_TERMINAL_PROMPT"]; !ok {
+		vars["GIT_TERMINAL_PROMPT"] = "0"
+	}
+	for key, val := range overrides {
+		vars[key] = val
+	}
+
+	env := make([]string, 0, len(vars))
+	for key, val := range vars {
+		env = append(env, key+"="+val)
+	}
+	sort.Strings(env)
+	return env
+}
+
+func inheritEnv(key string) bool {
+	for _, name := range inheritedEnvNames {
+		if key == name {
+			return true
+		}
+	}
+	for _, prefix := range inheritedEnvPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolGetenv looks a variable up in the subprocess environment
+func toolGetenv(key string) string {
+	return getenvFrom(toolEnv, key)
+}
+
+// getenvFrom looks a variable up in env
+func getenvFrom(env []string, key string) string {
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// subprocessWaitDelay bounds how long a canceled subprocess may keep its output
+// open, e.g. through a child that outlived it, before waiting for it gives up
+const subprocessWaitDelay = 10 * time.Second
+
+// command prepares a subprocess running with the controlled tool environment,
+// killed along with the processes it started when the --timeout time box runs
+// out or the run is interrupted
+func command(name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(runContext, name, args...)
+	cmd.Env = toolEnv
+	killProcessGroup(cmd)
+	cmd.WaitDelay = subprocessWaitDelay
+	return cmd
+}
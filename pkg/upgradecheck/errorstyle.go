@@ -0,0 +1,136 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ChangeResults marks findings for functions that gain results, such as an
+// error: unlike most parameter changes, every call site has to be edited
+const ChangeResults = "results"
+
+// ChangeErrorHandling marks findings for functions whose documentation starts
+// describing panics the old version didn't
+const ChangeErrorHandling = "error-handling"
+
+var (
+	// panicSentence matches a sentence of a doc comment mentioning panics
+	panicSentence = regexp.MustCompile(`(?i)[^.]*\bpanic(s|ked|king)?\b[^.]*\.?`)
+	// noPanicWording finds sentences saying that something doesn't panic, e.g.
+	// "returns an error instead of panicking"
+	noPanicWording = regexp.MustCompile(`(?i)(no longer|never|not|n't|instead of|rather than|without)\s+(\w+\s+)?panic`)
+)
+
+// signatureResults returns the canonical result types of a function or method
+// declaration and its declaration without them, or false when the definition
+// isn't a function
+func signatureResults(def string) ([]string, string, bool) {
+	def = normalizeDefinition(def)
+	if !strings.HasPrefix(def, "func") {
+		return nil, "", false
+	}
+	// Drop the receiver, which interface methods write as "(Reader).Read"
+	if strings.HasPrefix(def, "func (") {
+		if end := matchingParen(def, len("func ")); end >= 0 {
+			def = "func " + strings.TrimLeft(def[end+1:], " .")
+		}
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+def, parser.SkipObjectResolution)
+	if err != nil || len(file.Decls) != 1 {
+		return nil, "", false
+	}
+	fn, ok := file.Decls[0].(*ast.FuncDecl)
+	if !ok {
+		return nil, "", false
+	}
+	renameTypeParams(fn)
+	var results []string
+	if fn.Type.Results != nil {
+		for _, field := range unnamedFields(fn.Type.Results).List {
+			results = append(results, printCanonical(field.Type))
+		}
+	}
+	fn.Type.Results = nil
+	return results, printCanonical(fn), true
+}
+
+// returnsError reports whether the last result is an error, as Go convention
+// has it
+func returnsError(results []string) bool {
+	return len(results) > 0 && results[len(results)-1] == "error"
+}
+
+// classifyResultChanges sets the kind of changed functions that only gain
+// results to ChangeResults and notes on changed functions when they start or
+// stop returning an error
+func classifyResultChanges(findings []Finding) {
+	for i := range findings {
+		f := &findings[i]
+		if f.Kind != ChangeChanged {
+			continue
+		}
+		oldResults, oldRest, ok1 := signatureResults(f.OldSignature)
+		newResults, newRest, ok2 := signatureResults(f.NewSignature)
+		if !ok1 || !ok2 {
+			continue
+		}
+		if oldRest == newRest && len(newResults) > len(oldResults) {
+			f.Kind = ChangeResults
+		}
+		switch hadError, hasError := returnsError(oldResults), returnsError(newResults); {
+		case hasError && !hadError:
+			f.Notes = append(f.Notes, "now returns an error; every call site has to receive and handle it")
+		case hadError && !hasError:
+			f.Notes = append(f.Notes, "no longer returns an error; every call site has to drop its error handling, and failures may now panic or go unreported")
+		case len(newResults) > len(oldResults):
+			f.Notes = append(f.Notes, fmt.Sprintf("returns %d results instead of %d; every call site has to receive them", len(newResults), len(oldResults)))
+		}
+	}
+}
+
+// documentedPanic returns the first sentence of a doc comment saying when the
+// symbol panics, or "" when there is none
+func documentedPanic(doc string) string {
+	for _, sentence := range panicSentence.FindAllString(doc, -1) {
+		if !noPanicWording.MatchString(sentence) {
+			return strings.Join(strings.Fields(sentence), " ")
+		}
+	}
+	return ""
+}
+
+// errorHandlingFindings reports the symbols the project uses whose new
+// documentation describes panics the old one didn't, such as a function
+// turning invalid input from an error into a panic. These compile unchanged.
+func errorHandlingFindings(used usageSites, oldDocs, newDocs map[string]string) []Finding {
+	var keys []string
+	for key := range used {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var findings []Finding
+	for _, key := range keys {
+		oldDoc, existed := oldDocs[key]
+		sentence := documentedPanic(newDocs[key])
+		if !existed || sentence == "" || documentedPanic(oldDoc) != "" {
+			continue
+		}
+		findings = append(findings, Finding{
+			Symbol:       strings.Replace(key, "#", ".", 1),
+			Kind:         ChangeErrorHandling,
+			Severity:     SeverityWarning,
+			Confidence:   ConfidenceHeuristic,
+			OldSignature: oldDoc,
+			NewSignature: newDocs[key],
+			Usages:       used[key],
+			Notes:        []string{"now documented to panic: " + sentence},
+		})
+	}
+	return findings
+}
@@ -0,0 +1,81 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// exclusion describes an exclude directive of the project's go.mod covering
+// the version to upgrade to, which the go command would therefore never select
+type exclusion struct {
+	GoMod   string
+	Version string
+	// Suggested is the next higher version that is not excluded, if any
+	Suggested string
+}
+
+// findGoMod returns the go.mod governing dir: the first one found in dir or
+// one of its parents
+func findGoMod(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, "go.mod")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// checkExcluded reports whether the go.mod of the project at projectPath
+// excludes modulePath@version. It returns nil when the version is not excluded
+// or the project has no go.mod. includePrerelease allows suggesting
+// pre-releases instead (see suggestable).
+func checkExcluded(projectPath, modulePath, version string, includePrerelease bool) (*exclusion, error) {
+	goModPath, err := findGoMod(projectPath)
+	if err != nil || goModPath == "" {
+		return nil, err
+	}
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", goModPath, err)
+	}
+	// Exclude directives only apply to the main module, so ParseLax drops them
+	file, err := modfile.Parse(goModPath, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", goModPath, err)
+	}
+
+	excluded := make(map[string]bool)
+	for _, e := range file.Exclude {
+		if e.Mod.Path == modulePath {
+			excluded[e.Mod.Version] = true
+		}
+	}
+	if !excluded[version] {
+		return nil, nil
+	}
+
+	result := &exclusion{GoMod: goModPath, Version: version}
+	if versions, err := listVersions(modulePath); err == nil {
+		semver.Sort(versions)
+		for _, v := range suggestable(versions, version, includePrerelease) {
+			if semver.Compare(v, version) > 0 && !excluded[v] {
+				result.Suggested = v
+				break
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package upgradecheck
+
+import "fmt"
+
+// Exit codes of a check, so it can be used as a CI gate
+const (
+	// ExitOK means no finding reached the --fail-on severity
+	ExitOK = 0
+	// ExitFindings means findings at or above the --fail-on severity were reported
+	ExitFindings = 1
+	// ExitError means the analysis itself failed
+	ExitError = 2
+	// ExitIncomplete means the --timeout time box ran out; the completed
+	// phases were saved for --resume
+	ExitIncomplete = 3
+	// ExitInterrupted means the run was interrupted by SIGINT or SIGTERM; the
+	// results so far were written as a partial report
+	ExitInterrupted = 4
+)
+
+// failOn is the value of --fail-on: the lowest severity of findings that make
+// a check exit with ExitFindings, or none to only fail on analysis errors
+type failOn struct {
+	severity Severity
+	never    bool
+}
+
+func (f *failOn) String() string {
+	if f.never {
+		return "none"
+	}
+	return f.severity.String()
+}
+
+func (f *failOn) Set(value string) error {
+	if value == "none" {
+		*f = failOn{never: true}
+		return nil
+	}
+	var s Severity
+	if err := s.UnmarshalText([]byte(value)); err != nil {
+		return fmt.Errorf("%w: must be info, warning, breaking, critical or none", err)
+	}
+	*f = failOn{severity: s}
+	return nil
+}
+
+// fails reports whether any finding of the reports reaches the threshold
+func (f *failOn) fails(reports ...*Report) bool {
+	if f.never {
+		return false
+	}
+	for _, report := range reports {
+		for _, service := range report.Services {
+			for _, finding := range service.Findings {
+				if finding.Severity >= f.severity && !finding.Accepted {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// fatalf logs an analysis error and exits with ExitError, exporting the spans
+// of the failed run and removing its temporary resources first. Errors after
+// the time box ran out come from the subprocesses it killed and end the run as
+// incomplete instead, and those after an interrupt are left to the signal
+// handler ending the run. Other cancellations fail the run like any error.
+func fatalf(format string, args ...any) {
+	if timedOut() {
+		runCheckpoint.exitIncomplete()
+	}
+	if interrupted() {
+		select {}
+	}
+	logError(format, args...)
+	exit(ExitError)
+}
@@ -0,0 +1,341 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// Engines comparing the versions of a module. Projects are always indexed
+// with scip-go, whose occurrences locate their usages.
+const (
+	EngineSCIP       = "scip"
+	EngineExportData = "exportdata"
+)
+
+// moduleEngine is the engine module versions are indexed with
+var moduleEngine = EngineSCIP
+
+// parseEngine validates the name of an engine
+func parseEngine(name string) (string, error) {
+	switch name {
+	case EngineSCIP, EngineExportData:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown engine %q: use %s or %s", name, EngineSCIP, EngineExportData)
+}
+
+// moduleIndexer names the engine and version indexing module versions, which
+// cache keys and provenance record since indexes of other engines and versions
+// may record symbols differently
+func moduleIndexer() string {
+	if moduleEngine == EngineExportData {
+		return "exportdata " + goToolchainVersion()
+	}
+	if useGoPackages() {
+		return "gopackages " + goToolchainVersion()
+	}
+	return "scip-go " + scipGoVersion()
+}
+
+// goToolchainVersion returns the version of the go command on the PATH, or
+// "unknown". It runs go at most once per run.
+var goToolchainVersion = sync.OnceValue(func() string {
+	out, err := toolVersion("go", "env", "GOVERSION")
+	if err != nil || out == "" {
+		return "unknown"
+	}
+	return out
+})
+
+// exportDataIndex indexes the packages of the module at moduleDir from the
+// export data the go toolchain compiles for them instead of running scip-go.
+// This is much faster, but the index only declares the symbols: there are no
+// occurrences, doc comments or implementation relationships, so it suits
+// signature-level comparisons only. Packages that don't compile are left out
+// and recorded as gaps.
+func exportDataIndex(moduleDir, modulePath string, env []string) (string, error) {
+	var out bytes.Buffer
+	cmd := command("go", "list", "-e", "-export", "-deps", "-json=ImportPath,Dir,Export,DepOnly,Error", "./...")
+	cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], env...)
+	cmd.Dir = moduleDir
+	cmd.Stdout = &out
+	cmd.Stderr = subprocessStderr()
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("go list failed: %w", err)
+	}
+
+	type listedPackage struct {
+		ImportPath string
+		Dir        string
+		Export     string
+		DepOnly    bool
+		Error      *struct{ Err string }
+	}
+	exports := make(map[string]string)
+	var packages []listedPackage
+	var gaps []packageGap
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var pkg listedPackage
+		if err := decoder.Decode(&pkg); err != nil {
+			return "", fmt.Errorf("failed to parse go list output: %w", err)
+		}
+		if pkg.Export != "" {
+			exports[pkg.ImportPath] = pkg.Export
+		}
+		if pkg.DepOnly {
+			continue
+		}
+		switch {
+		case pkg.Error != nil:
+			gaps = append(gaps, packageGap{Package: pkg.ImportPath, Error: firstLine(pkg.Error.Err)})
+		case pkg.Export == "":
+			gaps = append(gaps, packageGap{Package: pkg.ImportPath, Error: "no export data"})
+		default:
+			packages = append(packages, pkg)
+		}
+	}
+	if len(packages) == 0 {
+		if len(gaps) > 0 {
+			return "", fmt.Errorf("no package of %s compiles: %s: %s", modulePath, gaps[0].Package, gaps[0].Error)
+		}
+		return "", fmt.Errorf("no packages found in %s", moduleDir)
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := exports[path]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(file)
+	})
+
+	docs := make(map[string]*scip.Document)
+	for _, listed := range packages {
+		pkg, err := imp.Import(listed.ImportPath)
+		if err != nil {
+			gaps = append(gaps, packageGap{Package: listed.ImportPath, Error: firstLine(err.Error())})
+			continue
+		}
+		dir, err := filepath.Rel(moduleDir, listed.Dir)
+		if err != nil {
+			dir = "."
+		}
+		for _, sym := range exportedSymbols(pkg, modulePath) {
+			// Compilers may record trimmed file names, so only the base name
+			// is taken from the export data
+			name := path.Join(filepath.ToSlash(dir), filepath.Base(fset.Position(sym.pos).Filename))
+			doc, ok := docs[name]
+			if !ok {
+				doc = &scip.Document{Language: "go", RelativePath: name}
+				docs[name] = doc
+			}
+			doc.Symbols = append(doc.Symbols, &scip.SymbolInformation{
+				Symbol:        sym.symbol,
+				Documentation: []string{"```go\n" + sym.def + "\n```"},
+			})
+		}
+	}
+
+	index := &scip.Index{Metadata: &scip.Metadata{
+		ToolInfo:    &scip.ToolInfo{Name: "go-upgrade-checker exportdata", Version: goToolchainVersion()},
+		ProjectRoot: "file://" + filepath.ToSlash(moduleDir),
+	}}
+	for _, doc := range docs {
+		index.Documents = append(index.Documents, doc)
+	}
+	sort.Slice(index.Documents, func(i, j int) bool { return index.Documents[i].RelativePath < index.Documents[j].RelativePath })
+
+	indexPath, err := saveIndex(index)
+	if err != nil {
+		return "", err
+	}
+	recordGaps(indexPath, gaps)
+	return indexPath, nil
+}
+
+// exportedSymbol is a declaration of a package, with its scip-go symbol and
+// definition as scip-go documents it
+type exportedSymbol struct {
+	symbol, def string
+	pos         token.Pos
+}
+
+// exportedSymbols lists the package-level declarations of pkg and the
+// exported members of its types, named like scip-go names them. Unexported
+// types are included for their exported aliases.
+func exportedSymbols(pkg *types.Package, modulePath string) []exportedSymbol {
+	prefix := fmt.Sprintf("scip-go gomod %s . %s/", modulePath, descriptorName(pkg.Path()))
+	typeString := packageTypeString(pkg)
+
+	var symbols []exportedSymbol
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if _, isType := obj.(*types.TypeName); !obj.Exported() && !isType {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Func:
+			symbols = append(symbols, exportedSymbol{prefix + name + "().", objectDefinition(obj, typeString), obj.Pos()})
+		case *types.Const, *types.Var:
+			symbols = append(symbols, exportedSymbol{prefix + name + ".", objectDefinition(obj, typeString), obj.Pos()})
+		case *types.TypeName:
+			symbols = append(symbols, exportedSymbol{prefix + name + "#", objectDefinition(obj, typeString), obj.Pos()})
+			for _, member := range typeMembersOf(obj) {
+				if !member.obj.Exported() {
+					continue
+				}
+				symbols = append(symbols, exportedSymbol{prefix + name + "#" + member.descriptor(), member.definition(typeString), member.obj.Pos()})
+			}
+		}
+	}
+	return symbols
+}
+
+// packageTypeString formats types as code in pkg refers to them: its own
+// types unqualified, others by package name
+func packageTypeString(pkg *types.Package) func(types.Type) string {
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+	return func(t types.Type) string { return types.TypeString(t, qualifier) }
+}
+
+// descriptorName escapes a name for a SCIP descriptor: names with characters
+// other than letters, digits, "_", "+", "-" and "$" are put in backticks
+func descriptorName(name string) string {
+	if name != "" && !strings.ContainsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_+-$", r)
+	}) {
+		return name
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// objectDefinition formats the declaration of a package-level object or a
+// method like scip-go documents it, e.g. "func (c *Client) Close() error",
+// "const Timeout untyped int = 30" or "type Client struct"
+func objectDefinition(obj types.Object, typeString func(types.Type) string) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		// Signatures print without their receiver
+		params := strings.TrimPrefix(typeString(sig), "func")
+		recv := sig.Recv()
+		switch {
+		case recv == nil:
+			return "func " + obj.Name() + params
+		case types.IsInterface(recv.Type()):
+			return fmt.Sprintf("func (%s).%s%s", typeString(recv.Type()), obj.Name(), params)
+		case recv.Name() != "" && recv.Name() != "_":
+			return fmt.Sprintf("func (%s %s) %s%s", recv.Name(), typeString(recv.Type()), obj.Name(), params)
+		}
+		return fmt.Sprintf("func (%s) %s%s", typeString(recv.Type()), obj.Name(), params)
+	case *types.Const:
+		return fmt.Sprintf("const %s %s = %s", obj.Name(), typeString(obj.Type()), obj.Val().ExactString())
+	case *types.Var:
+		if obj.IsField() {
+			return "struct field " + obj.Name() + " " + typeString(obj.Type())
+		}
+		return fmt.Sprintf("var %s %s", obj.Name(), typeString(obj.Type()))
+	case *types.TypeName:
+		if alias, ok := obj.Type().(*types.Alias); ok {
+			return "type " + obj.Name() + " = " + typeString(alias.Rhs())
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			return "type " + obj.Name() + " " + typeString(obj.Type())
+		}
+		var params []string
+		for i := range named.TypeParams().Len() {
+			param := named.TypeParams().At(i)
+			params = append(params, param.Obj().Name()+" "+typeString(param.Constraint()))
+		}
+		decl := "type " + obj.Name()
+		if len(params) > 0 {
+			decl += "[" + strings.Join(params, ", ") + "]"
+		}
+		return decl + " " + underlyingKind(named.Underlying(), typeString)
+	}
+	return ""
+}
+
+// typeMember is a field, interface method or method a type declares
+type typeMember struct {
+	obj types.Object
+	// tag is the tag of a struct field
+	tag string
+}
+
+// descriptor returns the SCIP descriptor of the member within its type:
+// methods end in "().", while fields and interface methods are terms
+func (m typeMember) descriptor() string {
+	if fn, ok := m.obj.(*types.Func); ok && !types.IsInterface(fn.Type().(*types.Signature).Recv().Type()) {
+		return m.obj.Name() + "()."
+	}
+	return m.obj.Name() + "."
+}
+
+func (m typeMember) definition(typeString func(types.Type) string) string {
+	def := objectDefinition(m.obj, typeString)
+	if m.tag != "" {
+		def += " `" + m.tag + "`"
+	}
+	return def
+}
+
+// typeMembersOf returns the fields, explicit interface methods and methods a
+// defined type declares; aliases have none of their own
+func typeMembersOf(obj *types.TypeName) []typeMember {
+	named, ok := obj.Type().(*types.Named)
+	if !ok || obj.IsAlias() {
+		return nil
+	}
+	var members []typeMember
+	switch u := named.Underlying().(type) {
+	case *types.Struct:
+		for i := range u.NumFields() {
+			members = append(members, typeMember{obj: u.Field(i), tag: u.Tag(i)})
+		}
+	case *types.Interface:
+		for i := range u.NumExplicitMethods() {
+			members = append(members, typeMember{obj: u.ExplicitMethod(i)})
+		}
+	}
+	for i := range named.NumMethods() {
+		members = append(members, typeMember{obj: named.Method(i)})
+	}
+	return members
+}
+
+// underlyingKind describes the underlying type of a type declaration like
+// scip-go: "struct" and "interface" without their members, which have
+// symbols of their own, other types in full
+func underlyingKind(t types.Type, typeString func(types.Type) string) string {
+	switch t.(type) {
+	case *types.Struct:
+		return "struct"
+	case *types.Interface:
+		return "interface"
+	}
+	return typeString(t)
+}
@@ -0,0 +1,89 @@
+package upgradecheck
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// reexports finds the package-level declarations of the project that re-export
+// a module symbol, as internal facade packages do: type Client = dep.Client,
+// var NewClient = dep.NewClient or const Timeout = dep.Timeout. They are keyed
+// by the position of the declared name and map to the re-exported name.
+func reexports(projectPath, moduleName string) (map[Location]string, error) {
+	found := make(map[Location]string)
+	err := walkModuleImports(projectPath, moduleName, func(file *ast.File, imports moduleImports, pos func(token.Pos) Location) {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					// Only aliases re-export; a defined type is a new type
+					if spec.Assign.IsValid() && spec.Name.IsExported() {
+						if target, ok := imports.moduleRef(spec.Type); ok {
+							found[pos(spec.Name.Pos())] = target
+						}
+					}
+				case *ast.ValueSpec:
+					if len(spec.Values) != len(spec.Names) {
+						continue
+					}
+					for i, name := range spec.Names {
+						if !name.IsExported() {
+							continue
+						}
+						if target, ok := imports.moduleRef(spec.Values[i]); ok {
+							found[pos(name.Pos())] = target
+						}
+					}
+				}
+			}
+		}
+	})
+	return found, err
+}
+
+// reexportUsages follows one level of re-export: references to the project's
+// re-exporting declarations count as usages of the module symbols they
+// re-export, so a module consumed through an internal facade isn't reported
+// as barely used. Facades re-exporting other facades aren't followed.
+func reexportUsages(projectPath, indexPath, moduleName string) (usageSites, error) {
+	found, err := reexports(projectPath, moduleName)
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The indexer's symbols of the re-exporting declarations, found by position
+	targets := make(map[string]string)
+	for _, doc := range index.Documents {
+		for _, occ := range doc.Occurrences {
+			if occ.SymbolRoles&int32(scip.SymbolRole_Definition) == 0 {
+				continue
+			}
+			if target, ok := found[occurrenceLocation(doc.RelativePath, occ)]; ok {
+				targets[occ.Symbol] = target
+			}
+		}
+	}
+
+	usage := make(usageSites)
+	for _, doc := range index.Documents {
+		for _, occ := range doc.Occurrences {
+			if occ.SymbolRoles&int32(scip.SymbolRole_Definition) != 0 {
+				continue
+			}
+			if target, ok := targets[occ.Symbol]; ok {
+				usage.add(target, occurrenceLocation(doc.RelativePath, occ))
+			}
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package upgradecheck
+
+// lockFile is not implemented on this platform: concurrent runs sharing a
+// cache directory are not coordinated
+func lockFile(path string, exclusive bool) (unlock func(), waited bool, err error) {
+	return func() {}, false, nil
+}
+
+// tryLockFile always succeeds on this platform, see lockFile
+func tryLockFile(path string) (unlock func(), ok bool, err error) {
+	return func() {}, true, nil
+}
@@ -0,0 +1,527 @@
+package upgradecheck
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Severity ranks how disruptive a finding is expected to be for the project
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityBreaking
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityBreaking:
+		return "breaking"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// MarshalText encodes the severity by name so saved reports stay readable
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for candidate := SeverityInfo; candidate <= SeverityCritical; candidate++ {
+		if candidate.String() == string(text) {
+			*s = candidate
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Confidence rates how certain the symbol matching behind a finding is
+type Confidence int
+
+const (
+	// ConfidenceHeuristic findings rest on name or documentation heuristics
+	ConfidenceHeuristic Confidence = iota
+	// ConfidenceHigh findings match the symbol exactly, but the project's use of
+	// the changed part is inferred, e.g. from using the type a member belongs to
+	ConfidenceHigh
+	// ConfidenceExact findings match a symbol the project's index references
+	ConfidenceExact
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceHeuristic:
+		return "heuristic"
+	case ConfidenceHigh:
+		return "high"
+	case ConfidenceExact:
+		return "exact"
+	default:
+		return fmt.Sprintf("confidence(%d)", int(c))
+	}
+}
+
+func (c Confidence) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Confidence) UnmarshalText(text []byte) error {
+	for candidate := ConfidenceHeuristic; candidate <= ConfidenceExact; candidate++ {
+		if candidate.String() == string(text) {
+			*c = candidate
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown confidence %q: must be exact, high or heuristic", text)
+}
+
+// Change kinds reported for a used symbol
+const (
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+	ChangeAdded   = "added"
+)
+
+// Change classes group findings by what they mean for the project, in the
+// order reports list them
+const (
+	// ClassBreaking changes stop the project from compiling
+	ClassBreaking = "breaking"
+	// ClassConfiguration changes compile but break deployments, e.g. an
+	// environment variable the module no longer reads
+	ClassConfiguration = "configuration"
+	// ClassBehavioral changes compile but may change how the module behaves
+	ClassBehavioral = "behavioral"
+	// ClassDeprecated symbols still work but are marked for removal
+	ClassDeprecated = "deprecated"
+	// ClassUnstable symbols are outside the documented stable API of the
+	// dependency and may change in any release
+	ClassUnstable = "unstable"
+	// ClassCompatible changes need no action, e.g. an added struct field
+	ClassCompatible = "compatible"
+)
+
+var changeClasses = []string{ClassBreaking, ClassConfiguration, ClassBehavioral, ClassDeprecated, ClassUnstable, ClassCompatible}
+
+// Finding describes a single change to a dependency symbol used by the project
+type Finding struct {
+	// Symbol is the Go name of the symbol, e.g. "api.Client.Do"
+	Symbol string `json:"symbol"`
+	// Package is the import path of the package defining the symbol, if known
+	Package  string   `json:"package,omitempty"`
+	Kind     string   `json:"kind"`
+	Class    string   `json:"class,omitempty"`
+	Severity Severity `json:"severity"`
+	// Confidence rates the symbol matching the finding relies on
+	Confidence   Confidence `json:"confidence"`
+	OldSignature string     `json:"old_signature,omitempty"`
+	NewSignature string     `json:"new_signature,omitempty"`
+	// Usages are the places in the project referencing the symbol
+	Usages []Location `json:"usages,omitempty"`
+	// Notes carry extra context about how the project depends on the symbol
+	Notes []string `json:"notes,omitempty"`
+	// Owners are the CODEOWNERS owners of the files using the symbol
+	Owners []string `json:"owners,omitempty"`
+	// Accepted findings are listed in the --baseline; they are reported but
+	// don't fail the check
+	Accepted bool `json:"accepted,omitempty"`
+}
+
+// Fingerprint identifies the change a finding describes independently of how it
+// was graded or where the project uses the symbol, so the same finding can be
+// recognized across runs
+func (f Finding) Fingerprint() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{f.Symbol, f.Kind, f.OldSignature, f.NewSignature}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Files returns the distinct project documents referencing the symbol
+func (f Finding) Files() []string {
+	return locationFiles(f.Usages)
+}
+
+// Packages returns the distinct project package directories referencing the symbol
+func (f Finding) Packages() []string {
+	seen := make(map[string]bool)
+	var pkgs []string
+	for _, file := range f.Files() {
+		dir := filepath.Dir(file)
+		if !seen[dir] {
+			seen[dir] = true
+			pkgs = append(pkgs, dir)
+		}
+	}
+	return pkgs
+}
+
+// Location is a position in a project document. Line and Column are 1-based;
+// both are zero when only the document is known.
+type Location struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+func (l Location) String() string {
+	switch {
+	case l.Line == 0:
+		return l.Path
+	case l.Column == 0:
+		return fmt.Sprintf("%s:%d", l.Path, l.Line)
+	}
+	return fmt.Sprintf("%s:%d:%d", l.Path, l.Line, l.Column)
+}
+
+// locationFiles returns the distinct documents of the locations, in order
+func locationFiles(locations []Location) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, loc := range locations {
+		if !seen[loc.Path] {
+			seen[loc.Path] = true
+			files = append(files, loc.Path)
+		}
+	}
+	return files
+}
+
+// usageSites maps a dependency symbol to the places in the project referencing it
+type usageSites map[string][]Location
+
+func (u usageSites) add(symbol string, loc Location) {
+	for _, existing := range u[symbol] {
+		if existing == loc {
+			return
+		}
+	}
+	u[symbol] = append(u[symbol], loc)
+}
+
+// buildFindings turns the added/removed maps from findChangedSymbols into findings,
+// one per changed symbol, sorted by severity and then symbol name. confidence
+// rates how each used symbol was matched (see findUsedSymbols).
+func buildFindings(usedSymbols map[string][]string, added, removed map[string]string, usage usageSites, confidence map[string]Confidence) []Finding {
+	symbols := make(map[string]bool)
+	for sym := range added {
+		symbols[sym] = true
+	}
+	for sym := range removed {
+		symbols[sym] = true
+	}
+
+	var findings []Finding
+	for sym := range symbols {
+		f := Finding{
+			Symbol:   sym,
+			Kind:     ChangeChanged,
+			Severity: SeverityBreaking,
+			Usages:   usage[sym],
+		}
+		f.Confidence = confidence[sym]
+		oldSig, wasRemoved := removed[sym]
+		newSig, wasAdded := added[sym]
+
+		// Member findings ("Type.member") carry the usages of the member itself
+		// when the project accesses it. Others inherit the usages of their type,
+		// which doesn't prove the project uses that member: a field it never
+		// accesses only matters to unkeyed composite literals of the type.
+		// Without any usages, when comparing the API alone, members stand on
+		// their own.
+		if parent, member, ok := strings.Cut(sym, "."); ok && usage != nil {
+			if len(f.Usages) > 0 {
+				f.Confidence = confidence[parent]
+			} else {
+				f.Usages = usage[parent]
+				f.Confidence = min(confidence[parent], ConfidenceHigh)
+				if wasRemoved && oldSig != "removed" && isFieldDefinition(oldSig) {
+					f.Severity = SeverityInfo
+					f.Notes = append(f.Notes, fmt.Sprintf("the project doesn't access %s.%s; only unkeyed composite literals of %s are affected", parent, member, parent))
+				}
+			}
+		}
+
+		switch {
+		case oldSig == "removed":
+			f.Kind = ChangeRemoved
+			if defs := usedSymbols[sym]; len(defs) > 0 {
+				f.OldSignature = defs[0]
+			}
+		case !wasAdded:
+			f.Kind = ChangeRemoved
+			f.OldSignature = oldSig
+		case !wasRemoved:
+			f.Kind = ChangeAdded
+			f.NewSignature = newSig
+			if isFieldDefinition(newSig) {
+				f.Severity = SeverityInfo
+				f.Notes = append(f.Notes, "new struct field; only unkeyed composite literals of the type stop compiling")
+			}
+		default:
+			f.OldSignature = oldSig
+			f.NewSignature = newSig
+		}
+		findings = append(findings, f)
+	}
+
+	annotateRenamedFields(findings)
+	classifyResultChanges(findings)
+	sortFindings(findings)
+	return findings
+}
+
+// annotateRenamedFields notes on removed struct fields the field added to the
+// same struct with the same type, if there is exactly one: most likely the
+// field was renamed
+func annotateRenamedFields(findings []Finding) {
+	added := make(map[string][]string)
+	for _, f := range findings {
+		parent, member, ok := strings.Cut(f.Symbol, ".")
+		if ok && f.Kind == ChangeAdded && isFieldDefinition(f.NewSignature) {
+			key := parent + " " + fieldType(f.NewSignature)
+			added[key] = append(added[key], member)
+		}
+	}
+	for i := range findings {
+		f := &findings[i]
+		parent, _, ok := strings.Cut(f.Symbol, ".")
+		if !ok || f.Kind != ChangeRemoved || !isFieldDefinition(f.OldSignature) {
+			continue
+		}
+		if candidates := added[parent+" "+fieldType(f.OldSignature)]; len(candidates) == 1 {
+			f.Notes = append(f.Notes, fmt.Sprintf("probably renamed to %s.%s, which has the same type", parent, candidates[0]))
+		}
+	}
+}
+
+// annotateAssertions notes on findings for types the project uses in type
+// assertions or type switches where those happen, since such code either stops
+// compiling or silently stops matching after the change
+func annotateAssertions(findings []Finding, asserted usageSites) {
+	for i := range findings {
+		// "Type#Method" and "Type.member" findings relate to the asserted type too
+		name := findings[i].Symbol
+		if j := strings.IndexAny(name, "#."); j >= 0 {
+			name = name[:j]
+		}
+		files := locationFiles(asserted[name])
+		if len(files) == 0 {
+			continue
+		}
+		findings[i].Notes = append(findings[i].Notes, fmt.Sprintf(
+			"%s is used in type assertions or type switches in %s; they will fail to compile or stop matching at runtime",
+			name, strings.Join(files, ", ")))
+	}
+}
+
+// filterConfidence returns the findings rated at least threshold
+func filterConfidence(findings []Finding, threshold Confidence) []Finding {
+	var kept []Finding
+	for _, f := range findings {
+		if f.Confidence >= threshold {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+func sortFindings(findings []Finding) {
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity > findings[j].Severity
+		}
+		return findings[i].Symbol < findings[j].Symbol
+	})
+}
+
+// Summary describes the change in one line, e.g. "Client.Do: old -> new"
+func (f Finding) Summary() string {
+	oldSig, newSig := f.OldSignature, f.NewSignature
+	switch f.Kind {
+	case ChangeRemoved:
+		newSig = "removed"
+	case ChangeAdded:
+		oldSig = "added"
+	case ChangeBehavior:
+		// Behavioral findings compare documentation; the notes explain them
+		return f.Symbol + ": possible behavior change"
+	case ChangeImplements:
+		return fmt.Sprintf("%s: no longer implements %s", f.Symbol, f.OldSignature)
+	case ChangeDeprecated:
+		return f.Symbol + ": deprecated"
+	case ChangeConfig:
+		return f.Symbol + ": no longer read"
+	case ChangeErrorHandling:
+		return f.Symbol + ": now documented to panic"
+	case ChangeUnstableAPI:
+		return f.Symbol + ": not part of the stable API"
+	case ChangeImplementer:
+		if oldSig == "" {
+			oldSig = "added"
+		}
+	}
+	return fmt.Sprintf("%s: %s -> %s", f.Symbol, oldSig, newSig)
+}
+
+// classify returns the class of a finding from its kind and final severity:
+// findings downgraded to info, e.g. parameter list changes all calls still
+// fit, are compatible
+func classify(f Finding) string {
+	switch {
+	case f.Kind == ChangeConfig:
+		return ClassConfiguration
+	case f.Kind == ChangeBehavior, f.Kind == ChangeErrorHandling:
+		return ClassBehavioral
+	case f.Kind == ChangeDeprecated:
+		return ClassDeprecated
+	case f.Kind == ChangeUnstableAPI:
+		return ClassUnstable
+	case f.Severity == SeverityInfo:
+		return ClassCompatible
+	default:
+		return ClassBreaking
+	}
+}
+
+// classifyFindings sets the class of findings that don't have one yet, such
+// as those of reports saved before classes were recorded
+func classifyFindings(findings []Finding) {
+	for i := range findings {
+		if findings[i].Class == "" {
+			findings[i].Class = classify(findings[i])
+		}
+	}
+}
+
+// findingsByClass groups findings by class, returning the classes present in
+// report order
+func findingsByClass(findings []Finding) ([]string, map[string][]Finding) {
+	groups := make(map[string][]Finding)
+	for _, f := range findings {
+		class := f.Class
+		if class == "" {
+			class = classify(f)
+		}
+		groups[class] = append(groups[class], f)
+	}
+	var classes []string
+	for _, class := range changeClasses {
+		if len(groups[class]) > 0 {
+			classes = append(classes, class)
+		}
+	}
+	return classes, groups
+}
+
+// classTitle is the heading findings of a class are listed under
+func classTitle(class string) string {
+	switch class {
+	case ClassBreaking:
+		return "Breaking changes"
+	case ClassConfiguration:
+		return "Configuration changes"
+	case ClassBehavioral:
+		return "Behavioral changes"
+	case ClassDeprecated:
+		return "Deprecations"
+	case ClassUnstable:
+		return "Usages outside the stable API"
+	default:
+		return "Compatible changes"
+	}
+}
+
+// printFindings writes the text report, grouped by class, followed by a
+// per-severity summary. Usages of breaking findings are located under root,
+// the project path.
+func printFindings(findings []Finding, root string) {
+	if len(findings) == 0 {
+		fmt.Println("No breaking changes detected.")
+		return
+	}
+
+	classes, groups := findingsByClass(findings)
+	for i, class := range classes {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(classTitle(class) + ":")
+		for _, f := range groups[class] {
+			fmt.Printf("- [%s] %s", f.grade(), f.Summary())
+			if len(f.Usages) > 0 {
+				fmt.Printf(" (used in %d files across %d packages)", len(f.Files()), len(f.Packages()))
+			}
+			fmt.Println()
+			for _, note := range f.Notes {
+				fmt.Println("    note: " + note)
+			}
+			if len(f.Owners) > 0 {
+				fmt.Println("    owners: " + strings.Join(f.Owners, ", "))
+			}
+			printUsages(f, root)
+		}
+	}
+
+	fmt.Println()
+	printSummary(findings)
+}
+
+// grade returns the severity and confidence of the finding for text reports,
+// marking accepted ones
+func (f Finding) grade() string {
+	grade := fmt.Sprintf("%s, %s", f.Severity, f.Confidence)
+	if f.Accepted {
+		grade += ", accepted"
+	}
+	return grade
+}
+
+// printUsages lists every usage of a breaking finding as path:line:col under
+// root, which editors and terminals jump to. Other findings only count their
+// usages.
+func printUsages(f Finding, root string) {
+	if f.Severity < SeverityBreaking {
+		return
+	}
+	for _, loc := range f.Usages {
+		loc.Path = filepath.Join(root, filepath.FromSlash(loc.Path))
+		fmt.Println("    at " + loc.String())
+	}
+}
+
+// printSummary writes the number of findings per severity, most severe first
+func printSummary(findings []Finding) {
+	counts := make(map[Severity]int)
+	accepted := 0
+	for _, f := range findings {
+		counts[f.Severity]++
+		if f.Accepted {
+			accepted++
+		}
+	}
+	fmt.Print("Summary:")
+	sep := " "
+	for s := SeverityCritical; s >= SeverityInfo; s-- {
+		if counts[s] > 0 {
+			fmt.Printf("%s%d %s", sep, counts[s], s)
+			sep = ", "
+		}
+	}
+	if accepted > 0 {
+		fmt.Printf(" (%d accepted in the baseline)", accepted)
+	}
+	fmt.Println()
+}
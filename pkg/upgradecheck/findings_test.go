@@ -0,0 +1,60 @@
+package upgradecheck
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		kind string
+		// want is the class at each severity, from info to critical
+		want [4]string
+	}{
+		{ChangeRemoved, [4]string{ClassCompatible, ClassBreaking, ClassBreaking, ClassBreaking}},
+		{ChangeChanged, [4]string{ClassCompatible, ClassBreaking, ClassBreaking, ClassBreaking}},
+		{ChangeAdded, [4]string{ClassCompatible, ClassBreaking, ClassBreaking, ClassBreaking}},
+		{ChangeResults, [4]string{ClassCompatible, ClassBreaking, ClassBreaking, ClassBreaking}},
+		{ChangeImplements, [4]string{ClassCompatible, ClassBreaking, ClassBreaking, ClassBreaking}},
+		{ChangeImplementer, [4]string{ClassCompatible, ClassBreaking, ClassBreaking, ClassBreaking}},
+		{ChangeConfig, [4]string{ClassConfiguration, ClassConfiguration, ClassConfiguration, ClassConfiguration}},
+		{ChangeBehavior, [4]string{ClassBehavioral, ClassBehavioral, ClassBehavioral, ClassBehavioral}},
+		{ChangeErrorHandling, [4]string{ClassBehavioral, ClassBehavioral, ClassBehavioral, ClassBehavioral}},
+		{ChangeDeprecated, [4]string{ClassDeprecated, ClassDeprecated, ClassDeprecated, ClassDeprecated}},
+		{ChangeUnstableAPI, [4]string{ClassUnstable, ClassUnstable, ClassUnstable, ClassUnstable}},
+	}
+	for _, tt := range tests {
+		for severity := SeverityInfo; severity <= SeverityCritical; severity++ {
+			if got := classify(Finding{Kind: tt.kind, Severity: severity}); got != tt.want[severity] {
+				t.Errorf("classify(%s at %s) = %s, want %s", tt.kind, severity, got, tt.want[severity])
+			}
+		}
+	}
+}
+
+func TestClassifyFindings(t *testing.T) {
+	findings := []Finding{
+		{Symbol: "A", Kind: ChangeRemoved, Severity: SeverityBreaking},
+		{Symbol: "B", Kind: ChangeChanged, Severity: SeverityInfo},
+		// Classes already set, as in saved reports, are kept
+		{Symbol: "C", Kind: ChangeChanged, Severity: SeverityInfo, Class: ClassBreaking},
+		{Symbol: "D", Kind: ChangeDeprecated, Severity: SeverityWarning},
+		{Symbol: "E", Kind: ChangeConfig, Severity: SeverityBreaking},
+	}
+	classifyFindings(findings)
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Class)
+	}
+	if want := []string{ClassBreaking, ClassCompatible, ClassBreaking, ClassDeprecated, ClassConfiguration}; !slices.Equal(got, want) {
+		t.Errorf("classes = %q, want %q", got, want)
+	}
+
+	classes, groups := findingsByClass(findings)
+	if want := []string{ClassBreaking, ClassConfiguration, ClassDeprecated, ClassCompatible}; !slices.Equal(classes, want) {
+		t.Errorf("findingsByClass() classes = %q, want %q", classes, want)
+	}
+	if breaking := groups[ClassBreaking]; len(breaking) != 2 || breaking[0].Symbol != "A" || breaking[1].Symbol != "C" {
+		t.Errorf("breaking findings = %+v, want A and C", breaking)
+	}
+}
@@ -0,0 +1,240 @@
+package upgradecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// flameNode is a frame of the flamegraph: the report, a service, a directory
+// of the project, a file or a symbol used there. Its weight is the number of
+// affected usages below it.
+type flameNode struct {
+	name     string
+	weight   int
+	children map[string]*flameNode
+}
+
+func (n *flameNode) child(name string) *flameNode {
+	if n.children == nil {
+		n.children = make(map[string]*flameNode)
+	}
+	c, ok := n.children[name]
+	if !ok {
+		c = &flameNode{name: name}
+		n.children[name] = c
+	}
+	return c
+}
+
+// sortedChildren returns the children ordered by name, like flamegraph.pl,
+// so the layout doesn't change between runs
+func (n *flameNode) sortedChildren() []*flameNode {
+	children := make([]*flameNode, 0, len(n.children))
+	for _, c := range n.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+	return children
+}
+
+// usageStack returns the frames below the service for a usage: the
+// directories of its file, the file and the symbol
+func usageStack(loc Location, symbol string) []string {
+	var frames []string
+	if dir := path.Dir(loc.Path); dir != "." {
+		frames = strings.Split(dir, "/")
+	}
+	return append(frames, path.Base(loc.Path), symbol)
+}
+
+// flameTree weights the project packages of the report by the usages of
+// findings needing attention, i.e. of at least warning severity, so the
+// widest frames are where migration effort lands. Services get a frame of
+// their own when there are several.
+func flameTree(report *Report) *flameNode {
+	root := &flameNode{name: fmt.Sprintf("%s %s → %s", report.Module, report.OldVersion, report.NewVersion)}
+	for _, service := range report.Services {
+		base := root
+		if len(report.Services) > 1 {
+			base = root.child(service.Name)
+		}
+		for _, f := range service.Findings {
+			if f.Severity < SeverityWarning {
+				continue
+			}
+			for _, loc := range f.Usages {
+				root.weight++
+				node := base
+				if node != root {
+					node.weight++
+				}
+				for _, frame := range usageStack(loc, f.Symbol) {
+					node = node.child(frame)
+					node.weight++
+				}
+			}
+		}
+	}
+	return root
+}
+
+// writeFlamegraph writes the flamegraph of the report's affected usages to
+// path: speedscope JSON when it ends in .json, SVG otherwise
+func writeFlamegraph(path string, report *Report) error {
+	root := flameTree(report)
+	var data []byte
+	if strings.HasSuffix(path, ".json") {
+		var err error
+		if data, err = speedscopeProfile(root); err != nil {
+			return err
+		}
+	} else {
+		data = flamegraphSVG(root)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// speedscopeProfile encodes the tree as a sampled profile in the file format
+// of https://www.speedscope.app, one sample per leaf weighted by its usages
+func speedscopeProfile(root *flameNode) ([]byte, error) {
+	type frame struct {
+		Name string `json:"name"`
+	}
+	type profile struct {
+		Type       string  `json:"type"`
+		Name       string  `json:"name"`
+		Unit       string  `json:"unit"`
+		StartValue int     `json:"startValue"`
+		EndValue   int     `json:"endValue"`
+		Samples    [][]int `json:"samples"`
+		Weights    []int   `json:"weights"`
+	}
+
+	var frames []frame
+	frameIDs := make(map[string]int)
+	p := profile{Type: "sampled", Name: root.name, Unit: "none", EndValue: root.weight, Samples: [][]int{}, Weights: []int{}}
+	var walk func(n *flameNode, stack []int)
+	walk = func(n *flameNode, stack []int) {
+		id, ok := frameIDs[n.name]
+		if !ok {
+			id = len(frames)
+			frameIDs[n.name] = id
+			frames = append(frames, frame{n.name})
+		}
+		stack = append(stack[:len(stack):len(stack)], id)
+		if len(n.children) == 0 {
+			p.Samples = append(p.Samples, stack)
+			p.Weights = append(p.Weights, n.weight)
+			return
+		}
+		for _, c := range n.sortedChildren() {
+			walk(c, stack)
+		}
+	}
+	if root.weight > 0 {
+		walk(root, nil)
+	}
+
+	return json.MarshalIndent(struct {
+		Schema   string `json:"$schema"`
+		Name     string `json:"name"`
+		Exporter string `json:"exporter"`
+		Shared   struct {
+			Frames []frame `json:"frames"`
+		} `json:"shared"`
+		Profiles []profile `json:"profiles"`
+	}{
+		Schema:   "https://www.speedscope.app/file-format-schema.json",
+		Name:     root.name,
+		Exporter: "go-upgrade-check",
+		Shared: struct {
+			Frames []frame `json:"frames"`
+		}{frames},
+		Profiles: []profile{p},
+	}, "", "  ")
+}
+
+// Layout of the SVG flamegraph, in pixels
+const (
+	flameWidth     = 1200
+	flameRowHeight = 18
+	flameMargin    = 10
+	flameCharWidth = 7
+)
+
+// flamegraphSVG draws the tree as an icicle chart, the report on top and each
+// frame as wide as its share of the affected usages. Hovering a frame shows
+// its usage count.
+func flamegraphSVG(root *flameNode) []byte {
+	depth := 0
+	var measure func(n *flameNode, d int)
+	measure = func(n *flameNode, d int) {
+		if d > depth {
+			depth = d
+		}
+		for _, c := range n.children {
+			measure(c, d+1)
+		}
+	}
+	measure(root, 1)
+
+	height := 2*flameMargin + 24 + depth*flameRowHeight
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" standalone="no"?>
+<svg version="1.1" xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Verdana, sans-serif" font-size="12">
+<rect width="100%%" height="100%%" fill="#ffffff"/>
+<text x="%d" y="%d" font-size="16" text-anchor="middle">Affected usages: %s</text>
+`, flameWidth, height, flameWidth, height, flameWidth/2, flameMargin+14, html.EscapeString(root.name))
+
+	if root.weight == 0 {
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">No usages affected by the upgrade.</text>\n", flameWidth/2, flameMargin+24+flameRowHeight)
+	} else {
+		scale := float64(flameWidth-2*flameMargin) / float64(root.weight)
+		var draw func(n *flameNode, x float64, d int)
+		draw = func(n *flameNode, x float64, d int) {
+			w := float64(n.weight) * scale
+			y := flameMargin + 24 + d*flameRowHeight
+			fmt.Fprintf(&b, "<g><title>%s (%d usages, %.1f%%)</title><rect x=\"%.1f\" y=\"%d\" width=\"%.1f\" height=\"%d\" fill=\"%s\" stroke=\"#ffffff\" stroke-width=\"0.5\"/>",
+				html.EscapeString(n.name), n.weight, 100*float64(n.weight)/float64(root.weight), x, y, w, flameRowHeight-1, flameColor(n.name))
+			if label := fitLabel(n.name, w); label != "" {
+				fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%d\">%s</text>", x+3, y+flameRowHeight-5, html.EscapeString(label))
+			}
+			b.WriteString("</g>\n")
+			for _, c := range n.sortedChildren() {
+				draw(c, x, d+1)
+				x += float64(c.weight) * scale
+			}
+		}
+		draw(root, flameMargin, 0)
+	}
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// fitLabel shortens name to fit a frame of width pixels, or returns "" when
+// not even a few characters fit
+func fitLabel(name string, width float64) string {
+	fit := int((width - 6) / flameCharWidth)
+	runes := []rune(name)
+	switch {
+	case fit < 3:
+		return ""
+	case len(runes) <= fit:
+		return name
+	}
+	return string(runes[:fit-2]) + ".."
+}
+
+// flameColor picks a warm color for a frame, stable across runs for a name
+func flameColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	v := h.Sum32()
+	return fmt.Sprintf("rgb(%d,%d,%d)", 205+v%50, (v>>8)%230, (v>>16)%55)
+}
@@ -0,0 +1,101 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// generatorPatterns recognizes generated files by their conventional names.
+// Order matters: more specific suffixes come first.
+var generatorPatterns = []struct {
+	suffix    string
+	generator string
+	source    string // extension of the generator input replacing suffix, if known
+}{
+	{"_grpc.pb.go", "protoc-gen-go-grpc", ".proto"},
+	{".pb.gw.go", "grpc-gateway", ".proto"},
+	{".pb.validate.go", "protoc-gen-validate", ".proto"},
+	{".twirp.go", "twirp", ".proto"},
+	{".pb.go", "protoc-gen-go", ".proto"},
+	{"_string.go", "stringer", ""},
+	{"_easyjson.go", "easyjson", ""},
+	{"_mock.go", "mockgen", ""},
+	{"wire_gen.go", "wire", ""},
+	{"_gen.go", "go generate", ""},
+}
+
+// generatedBy returns the generator that conventionally produces the file at
+// path, and its input file when it can be derived from the name
+func generatedBy(file string) (generator, source string, ok bool) {
+	base := path.Base(file)
+	if strings.HasPrefix(base, "zz_generated") {
+		return "go generate", "", true
+	}
+	for _, p := range generatorPatterns {
+		if strings.HasSuffix(base, p.suffix) {
+			if p.source != "" {
+				source = strings.TrimSuffix(file, p.suffix) + p.source
+			}
+			return p.generator, source, true
+		}
+	}
+	return "", "", false
+}
+
+// definingDocuments maps every symbol of the index, keyed like
+// extractSymbolsFromOccurrence and additionally by bare type name, to the
+// document declaring it
+func definingDocuments(indexPath string) (map[string]string, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make(map[string]string)
+	for _, doc := range index.Documents {
+		for _, sym := range doc.Symbols {
+			val, _ := extractSymbolsFromOccurrence(sym.Symbol)
+			if val == "" {
+				continue
+			}
+			docs[strings.TrimSuffix(val, "#")] = doc.RelativePath
+			if typeName, _, ok := strings.Cut(val, "#"); ok {
+				if _, seen := docs[typeName]; !seen {
+					docs[typeName] = doc.RelativePath
+				}
+			}
+		}
+	}
+	return docs, nil
+}
+
+// annotateGenerated notes on findings whose symbol is declared in a generated
+// file which generator produced it; the right migration is then usually to
+// regenerate from the updated definitions rather than edit call sites
+func annotateGenerated(findings []Finding, definedIn map[string]string) {
+	for i := range findings {
+		name := findings[i].Symbol
+		file, ok := definedIn[name]
+		if !ok {
+			base := name
+			if j := strings.IndexAny(base, "#."); j >= 0 {
+				base = base[:j]
+			}
+			file, ok = definedIn[base]
+		}
+		if !ok {
+			continue
+		}
+
+		generator, source, ok := generatedBy(file)
+		if !ok {
+			continue
+		}
+		note := fmt.Sprintf("declared in generated file %s (%s)", file, generator)
+		if source != "" {
+			note += fmt.Sprintf("; consider regenerating your stubs against the new %s instead of editing call sites", source)
+		}
+		findings[i].Notes = append(findings[i].Notes, note)
+	}
+}
@@ -0,0 +1,446 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// typeParam is one type parameter of a generic function or type, with its
+// constraint printed canonically
+type typeParam struct {
+	Name       string
+	Constraint string
+}
+
+func (p typeParam) String() string {
+	return p.Name + " " + p.Constraint
+}
+
+// formatTypeParams prints a type parameter list, e.g. "[K comparable, V any]"
+func formatTypeParams(params []typeParam) string {
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p.String()
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// parseDeclaration parses a function or type declaration from an index. Type
+// declarations without a body, such as "type List[T any] struct", are
+// completed with an empty one.
+func parseDeclaration(def string) (ast.Decl, bool) {
+	def = normalizeDefinition(def)
+	if !strings.HasPrefix(def, "func ") && !strings.HasPrefix(def, "type ") {
+		return nil, false
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+def, parser.SkipObjectResolution)
+	if err != nil && (strings.HasSuffix(def, " struct") || strings.HasSuffix(def, " interface")) {
+		file, err = parser.ParseFile(token.NewFileSet(), "", "package p\n"+def+"{}", parser.SkipObjectResolution)
+	}
+	if err != nil || len(file.Decls) != 1 {
+		return nil, false
+	}
+	return file.Decls[0], true
+}
+
+// typeParamList returns the type parameter list of a declaration: that of a
+// generic function or type, or the parameters of a method's generic receiver
+// type, such as T of "func (l *List[T]) Push(v T)"
+func typeParamList(decl ast.Decl) []*ast.Ident {
+	var names []*ast.Ident
+	fields := func(list *ast.FieldList) {
+		if list != nil {
+			for _, field := range list.List {
+				names = append(names, field.Names...)
+			}
+		}
+	}
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		fields(d.Type.TypeParams)
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			recv := d.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			var indices []ast.Expr
+			switch r := recv.(type) {
+			case *ast.IndexExpr:
+				indices = []ast.Expr{r.Index}
+			case *ast.IndexListExpr:
+				indices = r.Indices
+			}
+			for _, index := range indices {
+				if ident, ok := index.(*ast.Ident); ok && ident.Name != "_" {
+					names = append(names, ident)
+				}
+			}
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok {
+				fields(ts.TypeParams)
+			}
+		}
+	}
+	return names
+}
+
+// declTypeParams returns the type parameters a generic function or type
+// declares, with their constraints. Methods can't declare any of their own.
+func declTypeParams(decl ast.Decl) []typeParam {
+	var list *ast.FieldList
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		list = d.Type.TypeParams
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok {
+				list = ts.TypeParams
+			}
+		}
+	}
+	if list == nil {
+		return nil
+	}
+	var params []typeParam
+	for _, field := range list.List {
+		constraint := printCanonical(field.Type)
+		for _, name := range field.Names {
+			params = append(params, typeParam{Name: name.Name, Constraint: constraint})
+		}
+	}
+	return params
+}
+
+// renameTypeParams renames the type parameters of a declaration by position,
+// so that renaming T to E between versions doesn't count as a change. The names
+// chosen can't clash with exported identifiers.
+func renameTypeParams(decl ast.Decl) {
+	renamed := make(map[string]string)
+	for i, ident := range typeParamList(decl) {
+		renamed[ident.Name] = fmt.Sprintf("_T%d", i+1)
+	}
+	if len(renamed) == 0 {
+		return
+	}
+	var rename func(n ast.Node) bool
+	rename = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			// pkg.T names a type of another package, never a type parameter
+			ast.Inspect(n.X, rename)
+			return false
+		case *ast.Ident:
+			if name, ok := renamed[n.Name]; ok {
+				n.Name = name
+			}
+		}
+		return true
+	}
+	ast.Inspect(decl, rename)
+}
+
+// unify matches the type expression of the new version of a parameter, which
+// may mention type parameters, against that of the old version, recording in
+// bound the type each type parameter stands for. It reports false when the
+// expressions differ in more than type parameters, or a type parameter would
+// stand for two types.
+func unify(newType, oldType ast.Expr, params map[string]bool, bound map[string]string) bool {
+	if ident, ok := newType.(*ast.Ident); ok && params[ident.Name] {
+		typ := printCanonical(oldType)
+		if prev, ok := bound[ident.Name]; ok {
+			return prev == typ
+		}
+		bound[ident.Name] = typ
+		return true
+	}
+
+	switch n := newType.(type) {
+	case *ast.StarExpr:
+		if o, ok := oldType.(*ast.StarExpr); ok {
+			return unify(n.X, o.X, params, bound)
+		}
+	case *ast.Ellipsis:
+		if o, ok := oldType.(*ast.Ellipsis); ok {
+			return unify(n.Elt, o.Elt, params, bound)
+		}
+	case *ast.ArrayType:
+		if o, ok := oldType.(*ast.ArrayType); ok && (n.Len == nil) == (o.Len == nil) {
+			if n.Len != nil && printCanonical(n.Len) != printCanonical(o.Len) {
+				return false
+			}
+			return unify(n.Elt, o.Elt, params, bound)
+		}
+	case *ast.MapType:
+		if o, ok := oldType.(*ast.MapType); ok {
+			return unify(n.Key, o.Key, params, bound) && unify(n.Value, o.Value, params, bound)
+		}
+	case *ast.ChanType:
+		if o, ok := oldType.(*ast.ChanType); ok && n.Dir == o.Dir {
+			return unify(n.Value, o.Value, params, bound)
+		}
+	case *ast.FuncType:
+		if o, ok := oldType.(*ast.FuncType); ok {
+			return unifyFields(n.Params, o.Params, params, bound) && unifyFields(n.Results, o.Results, params, bound)
+		}
+	case *ast.IndexExpr:
+		if o, ok := oldType.(*ast.IndexExpr); ok {
+			return unify(n.X, o.X, params, bound) && unify(n.Index, o.Index, params, bound)
+		}
+	}
+	return printCanonical(newType) == printCanonical(oldType)
+}
+
+// unifyFields unifies two parameter or result lists type by type
+func unifyFields(newList, oldList *ast.FieldList, params map[string]bool, bound map[string]string) bool {
+	newTypes, oldTypes := fieldTypes(newList), fieldTypes(oldList)
+	if len(newTypes) != len(oldTypes) {
+		return false
+	}
+	for i := range newTypes {
+		if !unify(newTypes[i], oldTypes[i], params, bound) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldTypes returns the type of each parameter of a list, once per name
+func fieldTypes(list *ast.FieldList) []ast.Expr {
+	if list == nil {
+		return nil
+	}
+	var types []ast.Expr
+	for _, field := range list.List {
+		for range max(len(field.Names), 1) {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+// constraintTerms splits a constraint into its union terms, e.g. "~int |
+// string" into ~int and string. any and interface{} accept every type.
+// Constraints with methods aren't split.
+func constraintTerms(constraint string) (terms map[string]bool, universe, ok bool) {
+	switch constraint {
+	case "any", "interface{}":
+		return nil, true, true
+	}
+	if strings.Contains(constraint, "{") {
+		return nil, false, false
+	}
+	terms = make(map[string]bool)
+	for _, term := range strings.Split(constraint, "|") {
+		terms[strings.TrimSpace(term)] = true
+	}
+	return terms, false, true
+}
+
+// termCovered reports whether the union terms accept every type of term: ~T
+// covers T
+func termCovered(term string, terms map[string]bool) bool {
+	return terms[term] || (!strings.HasPrefix(term, "~") && terms["~"+term])
+}
+
+// Relations between the old and the new constraint of a type parameter
+const (
+	constraintLooser  = "loosened"
+	constraintTighter = "tightened"
+	constraintChanged = "changed"
+)
+
+// compareConstraints tells whether the new constraint accepts every type the
+// old one did, the other way around, or neither can be told
+func compareConstraints(oldConstraint, newConstraint string) string {
+	oldTerms, oldUniverse, ok1 := constraintTerms(oldConstraint)
+	newTerms, newUniverse, ok2 := constraintTerms(newConstraint)
+	switch {
+	case !ok1 || !ok2:
+		return constraintChanged
+	case newUniverse:
+		return constraintLooser
+	case oldUniverse:
+		return constraintTighter
+	}
+	looser, tighter := true, true
+	for term := range oldTerms {
+		looser = looser && termCovered(term, newTerms)
+	}
+	for term := range newTerms {
+		tighter = tighter && termCovered(term, oldTerms)
+	}
+	switch {
+	case looser:
+		return constraintLooser
+	case tighter:
+		return constraintTighter
+	default:
+		return constraintChanged
+	}
+}
+
+// satisfies reports whether typ is known to satisfy constraint
+func satisfies(typ, constraint string) bool {
+	if constraint == "comparable" {
+		return !strings.HasPrefix(typ, "[]") && !strings.HasPrefix(typ, "map[") && !strings.HasPrefix(typ, "func(")
+	}
+	terms, universe, ok := constraintTerms(constraint)
+	return ok && (universe || termCovered(typ, terms))
+}
+
+// annotateGenerics classifies changed functions and types whose type
+// parameters changed. A function that became generic stays compatible for
+// calls when its type parameters are inferred from the old parameter types,
+// e.g. interface{} turned into T any, so it is downgraded to informational
+// when every usage is such a call; a type that became generic breaks every
+// reference. Loosened constraints are compatible, tightened ones breaking.
+func annotateGenerics(projectPath string, findings []Finding) {
+	parsed := make(map[string][]callSite)
+
+	for i := range findings {
+		f := &findings[i]
+		if f.Kind != ChangeChanged {
+			continue
+		}
+		oldDecl, ok1 := parseDeclaration(f.OldSignature)
+		newDecl, ok2 := parseDeclaration(f.NewSignature)
+		if !ok1 || !ok2 {
+			continue
+		}
+		oldParams, newParams := declTypeParams(oldDecl), declTypeParams(newDecl)
+		if len(oldParams) == 0 && len(newParams) == 0 {
+			continue
+		}
+
+		name := f.Symbol
+		if j := strings.LastIndexAny(name, "#."); j >= 0 {
+			name = name[j+1:]
+		}
+		oldFn, oldIsFunc := oldDecl.(*ast.FuncDecl)
+		newFn, newIsFunc := newDecl.(*ast.FuncDecl)
+
+		switch {
+		case len(oldParams) == 0 && oldIsFunc && newIsFunc:
+			annotateGenericFunc(projectPath, f, name, oldFn, newFn, newParams, parsed)
+		case len(oldParams) == 0:
+			f.Notes = append(f.Notes, fmt.Sprintf("%s became generic with type parameters %s; every reference must now instantiate it", name, formatTypeParams(newParams)))
+		case len(newParams) == 0:
+			f.Notes = append(f.Notes, fmt.Sprintf("%s is no longer generic; instantiations such as %s[...] stop compiling", name, name))
+		case len(oldParams) != len(newParams):
+			f.Notes = append(f.Notes, fmt.Sprintf("type parameters of %s changed from %s to %s; explicit instantiations stop compiling", name, formatTypeParams(oldParams), formatTypeParams(newParams)))
+		default:
+			annotateConstraints(f, oldDecl, newDecl, oldParams, newParams)
+		}
+	}
+
+	sortFindings(findings)
+}
+
+// annotateGenericFunc handles a function that gained type parameters: calls
+// keep compiling when each type parameter is inferred from a parameter whose
+// old type satisfies its constraint, while references to the function as a
+// value need an explicit instantiation
+func annotateGenericFunc(projectPath string, f *Finding, name string, oldFn, newFn *ast.FuncDecl, newParams []typeParam, parsed map[string][]callSite) {
+	generic := fmt.Sprintf("%s became generic with type parameters %s", name, formatTypeParams(newParams))
+	params := make(map[string]bool)
+	for _, p := range newParams {
+		params[p.Name] = true
+	}
+	bound := make(map[string]string)
+	if !unifyFields(newFn.Type.Params, oldFn.Type.Params, params, bound) {
+		f.Notes = append(f.Notes, generic+" and its parameters changed too")
+		return
+	}
+	// Results don't take part in inference
+	inferred := make(map[string]string, len(bound))
+	for p, typ := range bound {
+		inferred[p] = typ
+	}
+	if !unifyFields(newFn.Type.Results, oldFn.Type.Results, params, bound) {
+		f.Notes = append(f.Notes, generic+" and its results changed too")
+		return
+	}
+
+	var names, args []string
+	for _, p := range newParams {
+		typ, ok := inferred[p.Name]
+		if !ok {
+			f.Notes = append(f.Notes, fmt.Sprintf("%s; %s can't be inferred from the arguments, so every call must instantiate it explicitly", generic, p.Name))
+			return
+		}
+		if !satisfies(typ, p.Constraint) {
+			f.Notes = append(f.Notes, fmt.Sprintf("%s; %s = %s as inferred from existing calls doesn't provably satisfy %s", generic, p.Name, typ, p.Constraint))
+			return
+		}
+		names = append(names, p.Name)
+		args = append(args, typ)
+	}
+
+	var values []Location
+	for _, loc := range f.Usages {
+		sites, ok := parsed[loc.Path]
+		if !ok {
+			var err error
+			sites, err = parseCallSites(filepath.Join(projectPath, loc.Path))
+			if err != nil {
+				sites = nil
+			}
+			parsed[loc.Path] = sites
+		}
+		if loc.Line == 0 || findCall(sites, loc, name) == nil {
+			values = append(values, loc)
+		}
+	}
+	if len(values) == 0 {
+		f.Severity = SeverityInfo
+		f.Notes = append(f.Notes, fmt.Sprintf("%s; calls infer %s from their arguments, so all %d usages remain valid", generic, strings.Join(names, ", "), len(f.Usages)))
+		return
+	}
+	f.Notes = append(f.Notes, fmt.Sprintf("%s; calls still compile by inferring %s from their arguments, but %d references use %s as a function value and need an explicit instantiation such as %s[%s]",
+		generic, strings.Join(names, ", "), len(values), name, name, strings.Join(args, ", ")))
+	f.Usages = values
+}
+
+// annotateConstraints handles generic declarations whose type parameters
+// kept their number: when only constraints changed and each was loosened, the
+// change is compatible
+func annotateConstraints(f *Finding, oldDecl, newDecl ast.Decl, oldParams, newParams []typeParam) {
+	// Constraints may mention other type parameters, e.g. S ~[]E
+	renameTypeParams(oldDecl)
+	renameTypeParams(newDecl)
+	oldRenamed, newRenamed := declTypeParams(oldDecl), declTypeParams(newDecl)
+
+	compatible := true
+	for i := range oldRenamed {
+		if oldRenamed[i].Constraint == newRenamed[i].Constraint {
+			continue
+		}
+		relation := compareConstraints(oldRenamed[i].Constraint, newRenamed[i].Constraint)
+		compatible = compatible && relation == constraintLooser
+		f.Notes = append(f.Notes, fmt.Sprintf("constraint of %s %s from %s to %s", newParams[i].Name, relation, oldParams[i].Constraint, newParams[i].Constraint))
+	}
+
+	// The rest of the declaration must be unchanged for loosening to suffice
+	for _, decl := range []ast.Decl{oldDecl, newDecl} {
+		var list *ast.FieldList
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			list = d.Type.TypeParams
+		case *ast.GenDecl:
+			list = d.Specs[0].(*ast.TypeSpec).TypeParams
+		}
+		for _, field := range list.List {
+			field.Type = ast.NewIdent("any")
+		}
+	}
+	if compatible && printCanonical(oldDecl) == printCanonical(newDecl) {
+		f.Severity = SeverityInfo
+		f.Notes = append(f.Notes, "existing instantiations still satisfy the loosened constraints")
+	}
+}
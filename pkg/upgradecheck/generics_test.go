@@ -0,0 +1,122 @@
+package upgradecheck
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnnotateGenerics(t *testing.T) {
+	tests := []struct {
+		name         string
+		old, new     string
+		wantSeverity Severity
+		// wantNote is part of the only note expected, empty for none
+		wantNote string
+	}{
+		{
+			name:         "union widened",
+			old:          "func Sum[T int | int64](s []T) T",
+			new:          "func Sum[T int | int64 | float64](s []T) T",
+			wantSeverity: SeverityInfo,
+			wantNote:     "constraint of T loosened from int | int64 to int | int64 | float64",
+		},
+		{
+			name:         "union narrowed",
+			old:          "func Sum[T int | int64 | float64](s []T) T",
+			new:          "func Sum[T int | int64](s []T) T",
+			wantSeverity: SeverityBreaking,
+			wantNote:     "constraint of T tightened from int | int64 | float64 to int | int64",
+		},
+		{
+			name:         "approximation widened",
+			old:          "func Sum[T int](s []T) T",
+			new:          "func Sum[T ~int](s []T) T",
+			wantSeverity: SeverityInfo,
+			wantNote:     "constraint of T loosened",
+		},
+		{
+			name:         "widened with other changes",
+			old:          "func Sum[T int](s []T) T",
+			new:          "func Sum[T int | int64](s []T, start T) T",
+			wantSeverity: SeverityBreaking,
+			wantNote:     "constraint of T loosened",
+		},
+		{
+			name:         "type parameter added",
+			old:          "func Map[T any](s []T, f func(T) T) []T",
+			new:          "func Map[T, U any](s []T, f func(T) U) []U",
+			wantSeverity: SeverityBreaking,
+			wantNote:     "type parameters of F changed from [T any] to [T any, U any]",
+		},
+		{
+			name:         "became generic, inferred",
+			old:          "func Print(v interface{})",
+			new:          "func Print[T any](v T)",
+			wantSeverity: SeverityInfo,
+			wantNote:     "calls infer T from their arguments",
+		},
+		{
+			name:         "became generic, not inferable",
+			old:          "func Parse(s string) any",
+			new:          "func Parse[T any](s string) T",
+			wantSeverity: SeverityBreaking,
+			wantNote:     "T can't be inferred from the arguments",
+		},
+		{
+			name:         "inferred type outside the constraint",
+			old:          "func Abs(v float64) float64",
+			new:          "func Abs[T ~int | ~int64](v T) T",
+			wantSeverity: SeverityBreaking,
+			wantNote:     "T = float64 as inferred from existing calls doesn't provably satisfy ~int | ~int64",
+		},
+		{
+			name:         "type became generic",
+			old:          "type List struct",
+			new:          "type List[T any] struct",
+			wantSeverity: SeverityBreaking,
+			wantNote:     "every reference must now instantiate it",
+		},
+		{
+			name:         "constraint unchanged",
+			old:          "func Max[T cmp.Ordered](a, b T) T",
+			new:          "func Max[T cmp.Ordered](a, b, c T) T",
+			wantSeverity: SeverityBreaking,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := []Finding{{Symbol: "m.F", Kind: ChangeChanged, Severity: SeverityBreaking, OldSignature: tt.old, NewSignature: tt.new}}
+			annotateGenerics(t.TempDir(), findings)
+			f := findings[0]
+			if f.Severity != tt.wantSeverity {
+				t.Errorf("severity = %s, want %s (notes %q)", f.Severity, tt.wantSeverity, f.Notes)
+			}
+			switch {
+			case tt.wantNote == "" && len(f.Notes) > 0:
+				t.Errorf("notes = %q, want none", f.Notes)
+			case tt.wantNote != "" && (len(f.Notes) == 0 || !strings.Contains(strings.Join(f.Notes, "\n"), tt.wantNote)):
+				t.Errorf("notes = %q, want one mentioning %q", f.Notes, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestCompareConstraints(t *testing.T) {
+	tests := []struct {
+		old, new, want string
+	}{
+		{"int | string", "int | string | float64", constraintLooser},
+		{"int | string", "string", constraintTighter},
+		{"int", "~int", constraintLooser},
+		{"~int", "int", constraintTighter},
+		{"int | string", "int | float64", constraintChanged},
+		{"int", "any", constraintLooser},
+		{"any", "comparable", constraintTighter},
+		{"fmt.Stringer", "interface{ String() string }", constraintChanged},
+	}
+	for _, tt := range tests {
+		if got := compareConstraints(tt.old, tt.new); got != tt.want {
+			t.Errorf("compareConstraints(%q, %q) = %s, want %s", tt.old, tt.new, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var githubClient = &http.Client{Timeout: 30 * time.Second}
+
+// githubRepo identifies the repository and commit results are published for,
+// as provided by the GitHub Actions environment
+type githubRepo struct {
+	APIURL     string
+	Repository string // owner/name
+	SHA        string
+	Token      string
+	RunURL     string
+}
+
+// githubRepoFromEnv reads the repository, commit and token from the standard
+// GitHub Actions variables
+func githubRepoFromEnv() (*githubRepo, error) {
+	repo := &githubRepo{
+		APIURL:     os.Getenv("GITHUB_API_URL"),
+		Repository: os.Getenv("GITHUB_REPOSITORY"),
+		SHA:        os.Getenv("GITHUB_SHA"),
+		Token:      os.Getenv("GITHUB_TOKEN"),
+	}
+	if repo.APIURL == "" {
+		repo.APIURL = "https://api.github.com"
+	}
+	if repo.Repository == "" || repo.SHA == "" || repo.Token == "" {
+		return nil, fmt.Errorf("GITHUB_REPOSITORY, GITHUB_SHA and GITHUB_TOKEN must be set")
+	}
+	if server, runID := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_RUN_ID"); server != "" && runID != "" {
+		repo.RunURL = fmt.Sprintf("%s/%s/actions/runs/%s", server, repo.Repository, runID)
+	}
+	return repo, nil
+}
+
+// githubAPI sends an authenticated JSON request to the GitHub REST API
+func (g *githubRepo) githubAPI(method, path string, body any) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(g.APIURL, "/")+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := githubClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("GitHub API %s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// commitStatus is one status published on the analyzed commit
+type commitStatus struct {
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// severityStatuses builds one commit status per severity class: "<prefix>/breaking"
+// fails on breaking or critical findings, and "<prefix>/risky" fails on warnings
+// such as behavioral changes. Branch protection can require only the former.
+func severityStatuses(prefix, module, newVersion string, services []*serviceReport) []commitStatus {
+	var breaking, risky int
+	for _, service := range services {
+		for _, f := range service.Findings {
+			switch {
+			case f.Accepted:
+				// Triaged in the baseline
+			case f.Severity >= SeverityBreaking:
+				breaking++
+			case f.Severity == SeverityWarning:
+				risky++
+			}
+		}
+	}
+
+	status := func(name string, count int, noun string) commitStatus {
+		s := commitStatus{
+			State:       "success",
+			Context:     prefix + "/" + name,
+			Description: fmt.Sprintf("No %s in %s@%s", noun, module, newVersion),
+		}
+		if count > 0 {
+			s.State = "failure"
+			s.Description = fmt.Sprintf("%d %s in %s@%s", count, noun, module, newVersion)
+		}
+		// GitHub rejects descriptions longer than 140 characters
+		if len(s.Description) > 140 {
+			s.Description = s.Description[:137] + "..."
+		}
+		return s
+	}
+
+	return []commitStatus{
+		status("breaking", breaking, "breaking changes"),
+		status("risky", risky, "risky changes"),
+	}
+}
+
+// publishStatuses posts the statuses on the commit
+func (g *githubRepo) publishStatuses(statuses []commitStatus) error {
+	for _, s := range statuses {
+		s.TargetURL = g.RunURL
+		if _, err := g.githubAPI(http.MethodPost, fmt.Sprintf("/repos/%s/statuses/%s", g.Repository, g.SHA), s); err != nil {
+			return fmt.Errorf("failed to publish %s status: %w", s.Context, err)
+		}
+	}
+	return nil
+}
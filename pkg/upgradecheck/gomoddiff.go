@@ -0,0 +1,162 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// goModDiff is what the "diff" subcommand compares: the go.mod files before
+// and after a dependency bump. Without files, the go.mod of the project is
+// compared against its version at the merge base of the branch with base.
+type goModDiff struct {
+	OldGoMod, NewGoMod string
+	Base               string
+}
+
+// defaultDiffBase is the branch a pull request merges into in CI, or else the
+// default branch of origin
+func defaultDiffBase() string {
+	if ref := os.Getenv("GITHUB_BASE_REF"); ref != "" {
+		return "origin/" + ref
+	}
+	if ref := os.Getenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME"); ref != "" {
+		return "origin/" + ref
+	}
+	return "origin/HEAD"
+}
+
+// diffUpgrades returns the upgrades of the direct dependencies between two
+// go.mod files. A module moving to a new major version, whose path changes,
+// is one upgrade from the old path. Added and removed modules, indirect
+// dependencies and modules the new go.mod replaces are skipped.
+func diffUpgrades(oldData, newData []byte) ([]moduleUpgrade, error) {
+	oldFile, err := modfile.ParseLax("old/go.mod", oldData, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the old go.mod: %w", err)
+	}
+	newFile, err := modfile.ParseLax("new/go.mod", newData, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the new go.mod: %w", err)
+	}
+
+	replaced := make(map[string]bool)
+	for _, r := range newFile.Replace {
+		replaced[r.Old.Path] = true
+	}
+	required := func(f *modfile.File) map[string]*modfile.Require {
+		reqs := make(map[string]*modfile.Require)
+		for _, req := range f.Require {
+			reqs[req.Mod.Path] = req
+		}
+		return reqs
+	}
+	oldReqs, newReqs := required(oldFile), required(newFile)
+
+	// New major versions are matched to the old ones by the path without
+	// the major version suffix
+	added := make(map[string]*modfile.Require)
+	for path, req := range newReqs {
+		if _, ok := oldReqs[path]; !ok {
+			prefix, _, _ := module.SplitPathVersion(path)
+			added[prefix] = req
+		}
+	}
+
+	var upgrades []moduleUpgrade
+	for path, old := range oldReqs {
+		updated, ok := newReqs[path]
+		if !ok {
+			prefix, _, _ := module.SplitPathVersion(path)
+			if updated, ok = added[prefix]; !ok {
+				log.Printf("%s was removed, skipping it", path)
+				continue
+			}
+		}
+		if updated.Mod.Version == old.Mod.Version {
+			continue
+		}
+		switch {
+		case updated.Indirect:
+			log.Printf("Skipping %s %s -> %s: indirect dependencies aren't imported by the project", path, old.Mod.Version, updated.Mod.Version)
+			continue
+		case replaced[updated.Mod.Path]:
+			log.Printf("Skipping %s: the new go.mod replaces it", updated.Mod.Path)
+			continue
+		case semver.Compare(updated.Mod.Version, old.Mod.Version) < 0:
+			log.Printf("%s is downgraded from %s to %s", path, old.Mod.Version, updated.Mod.Version)
+		}
+		upgrades = append(upgrades, moduleUpgrade{Module: path, OldVersion: old.Mod.Version, NewVersion: updated.Mod.Version})
+	}
+	sort.Slice(upgrades, func(i, j int) bool { return upgrades[i].Module < upgrades[j].Module })
+	return upgrades, nil
+}
+
+// read returns the contents of the old and new go.mod, and the project to
+// check: the directory of the new go.mod unless projectPath is given
+func (d goModDiff) read(projectPath string) (oldData, newData []byte, project string, err error) {
+	if d.OldGoMod != "" {
+		if oldData, err = os.ReadFile(d.OldGoMod); err != nil {
+			return nil, nil, "", fmt.Errorf("failed to read the old go.mod: %w", err)
+		}
+		if newData, err = os.ReadFile(d.NewGoMod); err != nil {
+			return nil, nil, "", fmt.Errorf("failed to read the new go.mod: %w", err)
+		}
+		if projectPath == "" {
+			projectPath = filepath.Dir(d.NewGoMod)
+		}
+		return oldData, newData, projectPath, nil
+	}
+
+	if projectPath == "" {
+		projectPath = "."
+	}
+	first := strings.TrimSpace(strings.Split(projectPath, ",")[0])
+	goModPath, err := findGoMod(first)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if goModPath == "" {
+		return nil, nil, "", fmt.Errorf("no go.mod found for %s", first)
+	}
+	if newData, err = os.ReadFile(goModPath); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	dir := filepath.Dir(goModPath)
+	base, err := git(dir, "merge-base", d.Base, "HEAD")
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to find the merge base with %s (fetch it or pass --base): %w", d.Base, err)
+	}
+	rev := strings.TrimSpace(string(base))
+	if oldData, err = git(dir, "show", rev+":./go.mod"); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to read go.mod at %s: %w", rev, err)
+	}
+	log.Printf("Comparing %s with its version at %.12s, the merge base with %s", goModPath, rev, d.Base)
+	return oldData, newData, projectPath, nil
+}
+
+// checkGoModDiff checks every upgrade between the two versions of go.mod, each
+// in a separate run of the tool like --all. args are passed on to every check.
+func checkGoModDiff(d goModDiff, projectPath string, args []string) (*batchReport, error) {
+	oldData, newData, project, err := d.read(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	upgrades, err := diffUpgrades(oldData, newData)
+	if err != nil {
+		return nil, err
+	}
+	if len(upgrades) == 0 {
+		log.Printf("go.mod changes the version of no direct dependency")
+	}
+	batch := &batchReport{Modules: []*Report{}}
+	checkUpgrades(batch, project, upgrades, args)
+	return batch, nil
+}
@@ -0,0 +1,436 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+	"golang.org/x/tools/go/packages"
+)
+
+// Backends building the SCIP indexes of projects and module versions
+const (
+	BackendSCIP       = "scip"
+	BackendGoPackages = "gopackages"
+)
+
+// indexBackend is the backend of --backend. Empty uses scip-go when it is on
+// the PATH and go/packages otherwise.
+var indexBackend string
+
+// parseBackend validates the name of a backend
+func parseBackend(name string) (string, error) {
+	switch name {
+	case "", BackendSCIP, BackendGoPackages:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown backend %q: use %s or %s", name, BackendSCIP, BackendGoPackages)
+}
+
+var fallbackNotice sync.Once
+
+// useGoPackages reports whether indexes are built with go/packages rather
+// than scip-go
+func useGoPackages() bool {
+	switch indexBackend {
+	case BackendSCIP:
+		return false
+	case BackendGoPackages:
+		return true
+	}
+	if _, err := exec.LookPath("scip-go"); err == nil {
+		return false
+	}
+	fallbackNotice.Do(func() {
+		log.Printf("scip-go isn't on the PATH; indexing with go/packages instead (--backend %s)", BackendGoPackages)
+	})
+	return true
+}
+
+// packagesIndex builds the SCIP index of the packages matching patterns in dir
+// with go/packages and go/types, as scip-go would: the declarations of the
+// packages with their documentation and implemented interfaces, and the
+// occurrences of every package-level symbol and member in their files.
+// Packages that don't type-check are left out and recorded as gaps.
+func packagesIndex(dir string, env []string, patterns []string) (string, error) {
+	return indexPackages(dir, env, patterns, false)
+}
+
+// indexPackages is packagesIndex, also indexing the packages that don't
+// type-check as far as go/types resolves them when keepIllTyped is set. They
+// are still recorded as gaps.
+func indexPackages(dir string, env []string, patterns []string, keepIllTyped bool) (string, error) {
+	// Dependencies are type-checked from source like scip-go does, rather
+	// than read from export data, whose format follows the toolchain
+	pkgs, err := packages.Load(&packages.Config{
+		Context: runContext,
+		Dir:     dir,
+		Env:     append(toolEnv[:len(toolEnv):len(toolEnv)], env...),
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports | packages.NeedDeps |
+			packages.NeedModule | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+	}, patterns...)
+	if err != nil {
+		return "", fmt.Errorf("failed to load packages: %w", err)
+	}
+
+	x := &packagesIndexer{
+		dir:     dir,
+		modules: make(map[string]*packages.Module),
+		owners:  make(map[*types.Var]*types.TypeName),
+		scanned: make(map[*types.Package]bool),
+		docs:    make(map[string]*scip.Document),
+	}
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		if p.Module != nil {
+			x.modules[p.PkgPath] = p.Module
+		}
+	})
+
+	var gaps []packageGap
+	var loaded []*packages.Package
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			gaps = append(gaps, packageGap{Package: pkg.PkgPath, Error: firstLine(pkg.Errors[0].Msg)})
+			if !keepIllTyped || pkg.Types == nil || pkg.TypesInfo == nil {
+				continue
+			}
+		}
+		loaded = append(loaded, pkg)
+	}
+	if len(loaded) == 0 {
+		if len(gaps) > 0 {
+			return "", fmt.Errorf("no package in %s type-checks: %s: %s", dir, gaps[0].Package, gaps[0].Error)
+		}
+		return "", fmt.Errorf("no packages found in %s", dir)
+	}
+
+	interfaces := knownInterfaces(loaded)
+	for _, pkg := range loaded {
+		x.indexPackage(pkg, interfaces)
+	}
+
+	index := &scip.Index{Metadata: &scip.Metadata{
+		ToolInfo:    &scip.ToolInfo{Name: "go-upgrade-checker gopackages", Version: goToolchainVersion()},
+		ProjectRoot: "file://" + filepath.ToSlash(dir),
+	}}
+	for _, doc := range x.docs {
+		sort.Slice(doc.Occurrences, func(i, j int) bool {
+			a, b := doc.Occurrences[i].Range, doc.Occurrences[j].Range
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			return a[1] < b[1]
+		})
+		index.Documents = append(index.Documents, doc)
+	}
+	sort.Slice(index.Documents, func(i, j int) bool { return index.Documents[i].RelativePath < index.Documents[j].RelativePath })
+	index.ExternalSymbols = x.external
+
+	indexPath, err := saveIndex(index)
+	if err != nil {
+		return "", err
+	}
+	recordGaps(indexPath, gaps)
+	return indexPath, nil
+}
+
+// packagesIndexer turns type-checked packages into SCIP documents
+type packagesIndexer struct {
+	dir     string
+	modules map[string]*packages.Module
+	// owners maps fields to the struct types declaring them, for the
+	// packages scanned so far
+	owners   map[*types.Var]*types.TypeName
+	scanned  map[*types.Package]bool
+	docs     map[string]*scip.Document
+	external []*scip.SymbolInformation
+	// referenced are the symbols of other packages already in external
+	referenced map[string]bool
+}
+
+// document returns the document of the file at pos, or nil for files outside
+// the indexed directory, such as cgo output
+func (x *packagesIndexer) document(fset *token.FileSet, pos token.Pos) *scip.Document {
+	rel, err := filepath.Rel(x.dir, fset.Position(pos).Filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+	doc, ok := x.docs[rel]
+	if !ok {
+		doc = &scip.Document{Language: "go", RelativePath: rel}
+		x.docs[rel] = doc
+	}
+	return doc
+}
+
+// prefix returns the scheme, package and namespace of the symbols of pkg. The
+// standard library is named like scip-go names it.
+func (x *packagesIndexer) prefix(pkg *types.Package) string {
+	module, version := "github.com/golang/go/src", goToolchainVersion()
+	if m := x.modules[pkg.Path()]; m != nil {
+		module, version = m.Path, m.Version
+	}
+	if version == "" {
+		version = "."
+	}
+	return fmt.Sprintf("scip-go gomod %s %s %s/", module, version, descriptorName(pkg.Path()))
+}
+
+// symbol returns the scip-go symbol of a package-level object or of a member
+// of a package-level type, or "" for local and builtin objects
+func (x *packagesIndexer) symbol(obj types.Object) string {
+	if obj == nil || obj.Pkg() == nil {
+		return ""
+	}
+	packageLevel := obj.Parent() == obj.Pkg().Scope()
+	switch obj := obj.(type) {
+	case *types.Func:
+		obj = obj.Origin()
+		recv := obj.Type().(*types.Signature).Recv()
+		if recv == nil {
+			if !packageLevel {
+				return ""
+			}
+			return x.prefix(obj.Pkg()) + descriptorName(obj.Name()) + "()."
+		}
+		owner := receiverTypeName(recv.Type())
+		if owner == nil || owner.Parent() != owner.Pkg().Scope() {
+			return ""
+		}
+		return x.prefix(owner.Pkg()) + descriptorName(owner.Name()) + "#" + typeMember{obj: obj}.descriptor()
+	case *types.Var:
+		if obj.IsField() {
+			owner := x.fieldOwner(obj.Origin())
+			if owner == nil {
+				return ""
+			}
+			return x.prefix(owner.Pkg()) + descriptorName(owner.Name()) + "#" + descriptorName(obj.Name()) + "."
+		}
+		if packageLevel {
+			return x.prefix(obj.Pkg()) + descriptorName(obj.Name()) + "."
+		}
+	case *types.Const:
+		if packageLevel {
+			return x.prefix(obj.Pkg()) + descriptorName(obj.Name()) + "."
+		}
+	case *types.TypeName:
+		if packageLevel {
+			return x.prefix(obj.Pkg()) + descriptorName(obj.Name()) + "#"
+		}
+	}
+	return ""
+}
+
+// receiverTypeName returns the defined type of a method receiver
+func receiverTypeName(t types.Type) *types.TypeName {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Origin().Obj()
+	}
+	return nil
+}
+
+// fieldOwner returns the package-level struct type declaring a field, or nil
+// for fields of anonymous structs
+func (x *packagesIndexer) fieldOwner(field *types.Var) *types.TypeName {
+	pkg := field.Pkg()
+	if !x.scanned[pkg] {
+		x.scanned[pkg] = true
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			if tn, ok := scope.Lookup(name).(*types.TypeName); ok {
+				for _, m := range typeMembersOf(tn) {
+					if v, ok := m.obj.(*types.Var); ok {
+						x.owners[v] = tn
+					}
+				}
+			}
+		}
+	}
+	return x.owners[field]
+}
+
+// indexPackage adds the declarations and occurrences of a package
+func (x *packagesIndexer) indexPackage(pkg *packages.Package, interfaces []*types.TypeName) {
+	typeString := packageTypeString(pkg.Types)
+	comments := docComments(pkg.Syntax, pkg.TypesInfo)
+	declare := func(obj types.Object, def string, rels []*scip.Relationship) {
+		symbol := x.symbol(obj)
+		doc := x.document(pkg.Fset, obj.Pos())
+		if symbol == "" || doc == nil {
+			return
+		}
+		documentation := []string{"```go\n" + def + "\n```"}
+		if comment := comments[obj]; comment != "" {
+			documentation = append(documentation, comment)
+		}
+		doc.Symbols = append(doc.Symbols, &scip.SymbolInformation{Symbol: symbol, Documentation: documentation, Relationships: rels})
+	}
+
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		declare(obj, objectDefinition(obj, typeString), x.implementations(obj, interfaces))
+		if tn, ok := obj.(*types.TypeName); ok {
+			for _, m := range typeMembersOf(tn) {
+				declare(m.obj, m.definition(typeString), nil)
+			}
+		}
+	}
+
+	occurrence := func(ident *ast.Ident, obj types.Object, roles scip.SymbolRole) {
+		symbol := x.symbol(obj)
+		doc := x.document(pkg.Fset, ident.Pos())
+		if symbol == "" || doc == nil {
+			return
+		}
+		pos := pkg.Fset.Position(ident.Pos())
+		doc.Occurrences = append(doc.Occurrences, &scip.Occurrence{
+			Range:       []int32{int32(pos.Line - 1), int32(pos.Column - 1), int32(pos.Column - 1 + len(ident.Name))},
+			Symbol:      symbol,
+			SymbolRoles: int32(roles),
+		})
+		if obj.Pkg() != pkg.Types {
+			x.reference(obj, symbol)
+		}
+	}
+	for ident, obj := range pkg.TypesInfo.Defs {
+		occurrence(ident, obj, scip.SymbolRole_Definition)
+	}
+	for ident, obj := range pkg.TypesInfo.Uses {
+		occurrence(ident, obj, scip.SymbolRole_UnspecifiedSymbolRole)
+	}
+}
+
+// reference records a symbol of another package among the external symbols,
+// as scip-go does for the symbols a project references
+func (x *packagesIndexer) reference(obj types.Object, symbol string) {
+	if x.referenced == nil {
+		x.referenced = make(map[string]bool)
+	}
+	if x.referenced[symbol] {
+		return
+	}
+	x.referenced[symbol] = true
+	def := objectDefinition(obj, packageTypeString(obj.Pkg()))
+	if v, ok := obj.(*types.Var); ok && v.IsField() {
+		def = "struct field " + v.Name() + " " + packageTypeString(obj.Pkg())(v.Type())
+	}
+	x.external = append(x.external, &scip.SymbolInformation{Symbol: symbol, Documentation: []string{"```go\n" + def + "\n```"}})
+}
+
+// implementations returns the is_implementation relationships of a defined,
+// non-interface type to the known interfaces it or its pointer implements
+func (x *packagesIndexer) implementations(obj types.Object, interfaces []*types.TypeName) []*scip.Relationship {
+	tn, ok := obj.(*types.TypeName)
+	if !ok || tn.IsAlias() || types.IsInterface(tn.Type()) {
+		return nil
+	}
+	if named, ok := tn.Type().(*types.Named); !ok || named.TypeParams().Len() > 0 {
+		return nil
+	}
+	var rels []*scip.Relationship
+	for _, iface := range interfaces {
+		it := iface.Type().Underlying().(*types.Interface)
+		if types.Implements(tn.Type(), it) || types.Implements(types.NewPointer(tn.Type()), it) {
+			if symbol := x.symbol(iface); symbol != "" {
+				rels = append(rels, &scip.Relationship{Symbol: symbol, IsImplementation: true})
+			}
+		}
+	}
+	return rels
+}
+
+// knownInterfaces returns the non-empty, non-generic interfaces declared by
+// the packages and the packages they import, which their types are checked
+// against
+func knownInterfaces(pkgs []*packages.Package) []*types.TypeName {
+	seen := make(map[*types.Package]bool)
+	var interfaces []*types.TypeName
+	add := func(pkg *types.Package) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 {
+				continue
+			}
+			if it, ok := named.Underlying().(*types.Interface); ok && it.NumMethods() > 0 {
+				interfaces = append(interfaces, tn)
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		add(pkg.Types)
+		for _, imported := range pkg.Types.Imports() {
+			add(imported)
+		}
+	}
+	return interfaces
+}
+
+// docComments returns the doc comments of the declarations, fields and
+// interface methods in files. Declarations of a group without their own
+// comment take the group's when it declares only them.
+func docComments(files []*ast.File, info *types.Info) map[types.Object]string {
+	comments := make(map[types.Object]string)
+	add := func(ident *ast.Ident, groups ...*ast.CommentGroup) {
+		obj := info.Defs[ident]
+		if obj == nil {
+			return
+		}
+		for _, g := range groups {
+			if text := strings.TrimSpace(g.Text()); text != "" {
+				comments[obj] = text
+				return
+			}
+		}
+	}
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				add(n.Name, n.Doc)
+			case *ast.GenDecl:
+				var group *ast.CommentGroup
+				if len(n.Specs) == 1 {
+					group = n.Doc
+				}
+				for _, spec := range n.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						add(spec.Name, spec.Doc, group)
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							add(name, spec.Doc, group)
+						}
+					}
+				}
+			case *ast.Field:
+				for _, name := range n.Names {
+					add(name, n.Doc)
+				}
+			}
+			return true
+		})
+	}
+	return comments
+}
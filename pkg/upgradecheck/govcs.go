@@ -0,0 +1,113 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// defaultGOVCS is the go command's policy when GOVCS has no matching rule:
+// only git and hg for public modules, any system for private ones
+const defaultGOVCS = "public:git|hg,private:all"
+
+// vcsSystem returns the name of a backend, as GOVCS lists it
+func vcsSystem(v vcs) string {
+	switch v.(type) {
+	case hgVCS:
+		return VCSMercurial
+	case svnVCS:
+		return VCSSubversion
+	}
+	return VCSGit
+}
+
+// govcsAllows reports whether the GOVCS policy allows fetching importPath
+// with system, like the go command checks before running it: the first rule
+// whose pattern matches the path, "public", or "private" for paths GOPRIVATE
+// matches, lists the allowed systems separated by "|", or "all" or "off".
+// Paths no rule matches fall back to defaultGOVCS.
+func govcsAllows(govcs, importPath, system string) (bool, error) {
+	private := module.MatchPrefixPatterns(toolGetenv("GOPRIVATE"), importPath)
+	for _, rules := range []string{govcs, defaultGOVCS} {
+		for _, rule := range strings.Split(rules, ",") {
+			rule = strings.TrimSpace(rule)
+			if rule == "" {
+				continue
+			}
+			pattern, systems, ok := strings.Cut(rule, ":")
+			if !ok || pattern == "" || systems == "" {
+				return false, fmt.Errorf("malformed GOVCS entry %q", rule)
+			}
+			switch pattern {
+			case "public":
+				if private {
+					continue
+				}
+			case "private":
+				if !private {
+					continue
+				}
+			default:
+				if !module.MatchPrefixPatterns(pattern, importPath) {
+					continue
+				}
+			}
+			for _, allowed := range strings.Split(systems, "|") {
+				if allowed == "all" || allowed == system {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// remoteHost returns the host of a repository URL, including scp-like ones
+// such as git@github.com:org/repo.git
+func remoteHost(remote string) string {
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	host, _, _ := strings.Cut(remote, ":")
+	if _, after, ok := strings.Cut(host, "@"); ok {
+		host = after
+	}
+	return host
+}
+
+// checkOrigin refuses to fetch a repository that GOVCS or the allowed hosts
+// rule out, so the tool complies with the same source origin policies as the
+// go command. The repository must be resolved.
+func (r *moduleRepo) checkOrigin() error {
+	system := vcsSystem(r.VCS)
+	// Repositories not cloned from https://<import path>.git, such as svn://
+	// URLs, are matched by host and path
+	importPath := r.Root
+	if u, err := url.Parse(r.Remote); importPath == "" && err == nil && u.Host != "" {
+		importPath = strings.TrimSuffix(u.Hostname()+u.Path, ".git")
+	} else if importPath == "" {
+		importPath = remoteHost(r.Remote)
+	}
+	allowed, err := govcsAllows(toolGetenv("GOVCS"), importPath, system)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("GOVCS disallows using %s for %s; see 'go help vcs'", system, importPath)
+	}
+
+	if len(r.AllowedHosts) == 0 {
+		return nil
+	}
+	host := remoteHost(r.Remote)
+	for _, pattern := range r.AllowedHosts {
+		if ok, _ := path.Match(pattern, host); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("refusing to clone %s: %s is not an allowed VCS host (%s)", r.Remote, host, strings.Join(r.AllowedHosts, ", "))
+}
@@ -0,0 +1,89 @@
+package upgradecheck
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// hgVCS reads Mercurial repositories
+type hgVCS struct{}
+
+func hg(dir string, args ...string) ([]byte, error) {
+	return runVCS("hg", dir, args...)
+}
+
+func (hgVCS) clone(url, dir string) error {
+	cmd := command("hg", "clone", "--noupdate", "--", url, dir)
+	return runLogged(cmd)
+}
+
+func (hgVCS) head() string { return "default" }
+
+func (hgVCS) tag(dir, name string) (string, bool) {
+	_, err := hg(dir, "identify", "--id", "--rev", name)
+	return name, err == nil
+}
+
+func (hgVCS) commit(id string) string { return id }
+
+func (hgVCS) checkout(dir, rev string) error {
+	cmd := command("hg", "update", "--clean", "--rev", rev)
+	cmd.Dir = dir
+	return runLogged(cmd)
+}
+
+func (hgVCS) commitID(dir, rev string) (string, error) {
+	out, err := hg(dir, "log", "--rev", rev, "--template", "{node}")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (hgVCS) files(dir, rev string) ([]string, error) {
+	out, err := hg(dir, "files", "--rev", rev)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		files = append(files, filepath.ToSlash(file))
+	}
+	return files, nil
+}
+
+func (hgVCS) show(dir, rev, path string) ([]byte, error) {
+	return hg(dir, "cat", "--rev", rev, "path:"+path)
+}
+
+func (hgVCS) log(dir, oldRev, newRev, subdir string) ([]vcsCommit, error) {
+	args := []string{"log",
+		"--rev", "sort(only(" + revsetString(newRev) + ", " + revsetString(oldRev) + "), rev)",
+		"--template", `\x1e{node}\x00{desc}\x00{join(files, "\n")}\n`,
+	}
+	if subdir != "" {
+		args = append(args, "--include", "path:"+subdir)
+	}
+	out, err := hg(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := parseLogRecords(out)
+	// Files are listed relative to the repository root, including those of
+	// commits that also change other directories
+	for i := range commits {
+		var files []string
+		for _, file := range commits[i].Files {
+			if subdir == "" {
+				files = append(files, file)
+			} else if rel, ok := strings.CutPrefix(file, subdir+"/"); ok {
+				files = append(files, rel)
+			}
+		}
+		commits[i].Files = files
+	}
+	return commits, nil
+}
+
+// revsetString quotes a revision for use in a revset
+func revsetString(rev string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(rev) + "'"
+}
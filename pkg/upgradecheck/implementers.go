@@ -0,0 +1,189 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// ChangeImplementer marks findings for methods of a module interface that a
+// project type implements, where the interface gained or changed the method
+const ChangeImplementer = "implementer"
+
+// implementer is a project type that implements an interface of the module
+type implementer struct {
+	// Type is the project type as Go code names it, e.g. "store.Cache"
+	Type string
+	// Interface is the module interface, keyed like getAvailableSymbols
+	Interface string
+	// InterfaceName is the interface as Go code names it, e.g. "cache.Cache"
+	InterfaceName string
+	// symbol is the scip-go symbol of the type, the prefix of its members'
+	symbol string
+}
+
+// projectTypes holds what the project index declares about the project's own
+// types: where symbols are defined, and the methods and fields of each type by
+// type symbol. Methods and fields may be declared in other files than their
+// type.
+type projectTypes struct {
+	definitions map[string]Location
+	methods     map[string]map[string]string
+	fields      map[string][]string
+}
+
+// method returns the definition and location of a method of the type, if the
+// type declares it
+func (p *projectTypes) method(impl implementer, name string) (string, Location, bool) {
+	def, ok := p.methods[impl.symbol][name]
+	return def, p.definitions[impl.symbol+name+"()."], ok
+}
+
+// moduleImplementers returns the project types implementing an interface of
+// the module, according to the is_implementation relationships scip-go
+// records when type-checking the project, outside of ignored directories
+func moduleImplementers(indexPath, module string, ignored ignoredDirs) ([]implementer, *projectTypes, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	types := &projectTypes{
+		definitions: make(map[string]Location),
+		methods:     make(map[string]map[string]string),
+		fields:      make(map[string][]string),
+	}
+	var implementers []implementer
+	for _, doc := range index.Documents {
+		if ignored.ignores(doc.RelativePath) {
+			continue
+		}
+		for _, occ := range doc.Occurrences {
+			if occ.SymbolRoles&int32(scip.SymbolRole_Definition) != 0 {
+				types.definitions[occ.Symbol] = occurrenceLocation(doc.RelativePath, occ)
+			}
+		}
+		for _, sym := range doc.Symbols {
+			if inModule(sym.Symbol, module) {
+				continue
+			}
+			val, typ := extractSymbolsFromOccurrence(sym.Symbol)
+			_, member, isMember := strings.Cut(val, "#")
+			switch {
+			case typ == "function" && isMember:
+				typeSymbol := sym.Symbol[:strings.LastIndex(sym.Symbol, "#")+1]
+				if types.methods[typeSymbol] == nil {
+					types.methods[typeSymbol] = make(map[string]string)
+				}
+				var def string
+				if len(sym.Documentation) > 0 {
+					def = extractSymbolDefinition(sym.Documentation[0])
+				}
+				types.methods[typeSymbol][member] = def
+			case typ == "type" && member != "":
+				typeSymbol := sym.Symbol[:strings.LastIndex(sym.Symbol, "#")+1]
+				types.fields[typeSymbol] = append(types.fields[typeSymbol], member)
+			case typ == "type" && isMember:
+				for _, rel := range sym.Relationships {
+					if !rel.IsImplementation || !inModule(rel.Symbol, module) {
+						continue
+					}
+					iface, _ := extractSymbolsFromOccurrence(rel.Symbol)
+					implementers = append(implementers, implementer{
+						Type:          interfaceName(sym.Symbol),
+						Interface:     strings.TrimSuffix(iface, "#"),
+						InterfaceName: interfaceName(rel.Symbol),
+						symbol:        sym.Symbol,
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(implementers, func(i, j int) bool {
+		if implementers[i].Type != implementers[j].Type {
+			return implementers[i].Type < implementers[j].Type
+		}
+		return implementers[i].Interface < implementers[j].Interface
+	})
+	return implementers, types, nil
+}
+
+// interfaceMethods returns the method definitions of an interface among the
+// member definitions of getAvailableSymbols
+func interfaceMethods(defs []string) []string {
+	var methods []string
+	for _, def := range defs {
+		if strings.HasPrefix(normalizeDefinition(def), "func ") {
+			methods = append(methods, def)
+		}
+	}
+	return methods
+}
+
+// promotes reports whether one of the type's fields is a module type that has
+// the method in the new version, such as an embedded UnimplementedFooServer,
+// so the method is promoted to the type
+func promotes(fields []string, method string, newSymbols map[string][]string) bool {
+	for _, field := range fields {
+		for _, def := range newSymbols[field] {
+			if strings.HasPrefix(normalizeDefinition(def), "func ") && memberName(def) == method {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// implementerFindings reports methods that module interfaces implemented by
+// project types gained or changed: the project type no longer satisfies the
+// interface, so passing it where the interface is expected stops compiling.
+// Methods the type already has with the new signature, or gets from an
+// embedded module type, are skipped, as are removed methods, which
+// implementations keep satisfying.
+func implementerFindings(implementers []implementer, types *projectTypes, oldSymbols, newSymbols map[string][]string) []Finding {
+	var findings []Finding
+	for _, impl := range implementers {
+		newDefs, ok := newSymbols[impl.Interface]
+		if !ok {
+			// Removed interfaces are reported where the project names them
+			continue
+		}
+		for _, change := range diffMembers(interfaceMethods(oldSymbols[impl.Interface]), interfaceMethods(newDefs)) {
+			if change.New == "" {
+				continue
+			}
+			def, site, has := types.method(impl, change.Name)
+			if has && methodSignature(def) == methodSignature(change.New) {
+				continue
+			}
+			if !has && promotes(types.fields[impl.symbol], change.Name, newSymbols) {
+				continue
+			}
+
+			f := Finding{
+				Symbol:       impl.Interface + "." + change.Name,
+				Kind:         ChangeImplementer,
+				Severity:     SeverityBreaking,
+				Confidence:   ConfidenceHigh,
+				OldSignature: change.Old,
+				NewSignature: change.New,
+				Usages:       []Location{types.definitions[impl.symbol]},
+			}
+			if change.Old == "" {
+				f.Notes = []string{fmt.Sprintf("%s implements %s, which gains method %s; %s must add it",
+					impl.Type, impl.InterfaceName, change.Name, impl.Type)}
+			} else {
+				f.Notes = []string{fmt.Sprintf("%s implements %s, whose method %s changes signature; %s.%s must be updated to match",
+					impl.Type, impl.InterfaceName, change.Name, impl.Type, change.Name)}
+				if has && site.Path != "" {
+					f.Usages = append(f.Usages, site)
+				}
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
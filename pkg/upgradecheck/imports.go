@@ -0,0 +1,217 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// importsModule reports whether an import path belongs to the module
+func importsModule(importPath, moduleName string) bool {
+	return importPath == moduleName || strings.HasPrefix(importPath, moduleName+"/")
+}
+
+// defaultImportName guesses the package name of an import path without an
+// explicit name: the last element, skipping a major version suffix
+func defaultImportName(importPath string) string {
+	name := path.Base(importPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
+
+// moduleImports describes how one project file imports the module
+type moduleImports struct {
+	// Names are the identifiers the file refers to the module's packages by
+	Names map[string]bool
+	// Aliased is set when at least one import renames the package
+	Aliased bool
+	// Dot is set when the module is dot-imported
+	Dot bool
+}
+
+// moduleRef returns the name of the module identifier expr refers to, if any:
+// pkg.Name through an imported name, or a bare Name through a dot import.
+// Pointer and generic instantiation wrappers are looked through.
+func (m moduleImports) moduleRef(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return m.moduleRef(e.X)
+	case *ast.IndexExpr:
+		return m.moduleRef(e.X)
+	case *ast.IndexListExpr:
+		return m.moduleRef(e.X)
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok && m.Names[x.Name] {
+			return e.Sel.Name, true
+		}
+	case *ast.Ident:
+		if m.Dot && e.IsExported() {
+			return e.Name, true
+		}
+	}
+	return "", false
+}
+
+// walkModuleImports parses every Go file of the project that imports the module
+// and calls fn for it. pos converts node positions of the file into Locations
+// relative to the project root.
+func walkModuleImports(projectPath, moduleName string, fn func(file *ast.File, imports moduleImports, pos func(token.Pos) Location)) error {
+	fset := token.NewFileSet()
+	// Packages of nested modules share the import path prefix but belong to
+	// a module of their own
+	nested := nestedModules(projectPath, moduleName)
+	inNested := func(importPath string) bool {
+		for _, m := range nested {
+			if importsModule(importPath, m) {
+				return true
+			}
+		}
+		return false
+	}
+
+	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != projectPath && (name == "vendor" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			// Files that don't parse can't have been indexed either
+			return nil
+		}
+
+		imports := moduleImports{Names: make(map[string]bool)}
+		for _, imp := range file.Imports {
+			importPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || !importsModule(importPath, moduleName) || inNested(importPath) {
+				continue
+			}
+			if imp.Name == nil {
+				imports.Names[defaultImportName(importPath)] = true
+				continue
+			}
+			switch imp.Name.Name {
+			case "_":
+			case ".":
+				imports.Dot = true
+			default:
+				imports.Names[imp.Name.Name] = true
+				imports.Aliased = true
+			}
+		}
+		if len(imports.Names) == 0 && !imports.Dot {
+			return nil
+		}
+
+		rel, err := filepath.Rel(projectPath, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		fn(file, imports, func(p token.Pos) Location {
+			position := fset.Position(p)
+			return Location{Path: rel, Line: position.Line, Column: position.Column}
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan project imports: %w", err)
+	}
+	return nil
+}
+
+// aliasedUsages scans the project's Go files that import the module under an
+// alias or as a dot import and returns the identifiers they reference through
+// those imports, keyed by bare symbol name like extractSymbolsFromOccurrence.
+// The indexer doesn't always attribute such references to the dependency, so
+// these are merged into the SCIP occurrences. Dot imports yield every exported
+// identifier of the file; callers must match them against known symbols.
+func aliasedUsages(projectPath, moduleName string) (usageSites, error) {
+	usage := make(usageSites)
+
+	err := walkModuleImports(projectPath, moduleName, func(file *ast.File, imports moduleImports, pos func(token.Pos) Location) {
+		if !imports.Aliased && !imports.Dot {
+			return
+		}
+
+		var inspect func(n ast.Node) bool
+		inspect = func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				if name, ok := imports.moduleRef(n); ok {
+					usage.add(name, pos(n.Sel.Pos()))
+					return false
+				}
+				// The selected name belongs to whatever X is, never to a dot import
+				ast.Inspect(n.X, inspect)
+				return false
+			case *ast.Ident:
+				if name, ok := imports.moduleRef(n); ok {
+					usage.add(name, pos(n.Pos()))
+				}
+			}
+			return true
+		}
+		ast.Inspect(file, inspect)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// assertedTypes returns the module types the project names in type assertions
+// and type switch cases, with the files doing so. Such types matter even when
+// none of their methods are called: removing them breaks compilation, and
+// replacing them makes assertions silently stop matching at runtime.
+func assertedTypes(projectPath, moduleName string) (usageSites, error) {
+	asserted := make(usageSites)
+
+	err := walkModuleImports(projectPath, moduleName, func(file *ast.File, imports moduleImports, pos func(token.Pos) Location) {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.TypeAssertExpr:
+				// x.(type) in a type switch has no Type; its cases are handled below
+				if n.Type != nil {
+					if name, ok := imports.moduleRef(n.Type); ok {
+						asserted.add(name, pos(n.Type.Pos()))
+					}
+				}
+			case *ast.TypeSwitchStmt:
+				for _, stmt := range n.Body.List {
+					for _, expr := range stmt.(*ast.CaseClause).List {
+						if name, ok := imports.moduleRef(expr); ok {
+							asserted.add(name, pos(expr.Pos()))
+						}
+					}
+				}
+			}
+			return true
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asserted, nil
+}
@@ -0,0 +1,49 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// incompatibleSuffix marks versions of major version 2 or higher published
+// before the module had a go.mod, e.g. v2.3.0+incompatible
+const incompatibleSuffix = "+incompatible"
+
+func isIncompatible(version string) bool {
+	return strings.HasSuffix(version, incompatibleSuffix)
+}
+
+// upgradedModulePath returns the module path the new version is published
+// under. Crossing a major version, or moving off a +incompatible version to a
+// release that has a go.mod, changes the /vN suffix of the path.
+func upgradedModulePath(modulePath, oldVersion, newVersion string) string {
+	return modulePathAt(modulePathAt(modulePath, oldVersion), newVersion)
+}
+
+// synthesizeGoMod writes a minimal go.mod into moduleDir when the checked out
+// version predates modules, as +incompatible and other GOPATH-era versions do,
+// so scip-go can load its packages. The go command treats such versions the
+// same way. It reports whether a go.mod was written and returns a function
+// removing it, and a go.sum created by scip-go, which must run before the next checkout.
+func synthesizeGoMod(moduleDir, modulePath string) (bool, func(), error) {
+	goModPath := filepath.Join(moduleDir, "go.mod")
+	if _, err := os.Stat(goModPath); err == nil {
+		return false, func() {}, nil
+	}
+
+	goSumPath := filepath.Join(moduleDir, "go.sum")
+	_, err := os.Stat(goSumPath)
+	hadGoSum := err == nil
+
+	if err := os.WriteFile(goModPath, []byte(fmt.Sprintf("module %s\n", modulePath)), 0o644); err != nil {
+		return false, nil, fmt.Errorf("failed to write go.mod for %s: %w", modulePath, err)
+	}
+	return true, func() {
+		os.Remove(goModPath)
+		if !hadGoSum {
+			os.Remove(goSumPath)
+		}
+	}, nil
+}
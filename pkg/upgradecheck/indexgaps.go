@@ -0,0 +1,276 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// packageGap is a package scip-go couldn't index, e.g. for a build error in
+// an example or a missing C toolchain. The rest is indexed without it, so
+// findings about its usages are missing from the report.
+type packageGap struct {
+	Package string `json:"package"`
+	// Version is the module version the package belongs to; it is empty for
+	// packages of the project
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error"`
+}
+
+// indexGaps holds the packages left out of each generated or cached index,
+// by index path
+var indexGaps = struct {
+	sync.Mutex
+	byIndex map[string][]packageGap
+}{byIndex: make(map[string][]packageGap)}
+
+// gapsFile is the cache entry file holding the gaps of a cached index
+const gapsFile = "gaps.json"
+
+func recordGaps(indexPath string, gaps []packageGap) {
+	if len(gaps) == 0 {
+		return
+	}
+	indexGaps.Lock()
+	defer indexGaps.Unlock()
+	indexGaps.byIndex[indexPath] = append(indexGaps.byIndex[indexPath], gaps...)
+}
+
+// gapsOf returns the packages left out of an index
+func gapsOf(indexPath string) []packageGap {
+	indexGaps.Lock()
+	defer indexGaps.Unlock()
+	return indexGaps.byIndex[indexPath]
+}
+
+// withVersion returns a copy of gaps attributed to a module version
+func withVersion(gaps []packageGap, version string) []packageGap {
+	var versioned []packageGap
+	for _, gap := range gaps {
+		gap.Version = version
+		versioned = append(versioned, gap)
+	}
+	return versioned
+}
+
+// runScipGoLoadable runs scip-go in dir over patterns. When it fails, the
+// packages that can't be type-checked are left out and the others indexed,
+// with the left-out packages recorded as gaps of the index. flags come before
+// the patterns. Without scip-go, or with --backend gopackages, go/packages
+// builds the index instead.
+func runScipGoLoadable(dir string, env []string, stderr io.Writer, flags []string, patterns ...string) (string, error) {
+	if useGoPackages() {
+		listed := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			listed[i] = pattern
+			if pattern == dir {
+				listed[i] = "./..."
+			}
+		}
+		return packagesIndex(dir, env, listed)
+	}
+	indexPath, err := runScipGo(dir, env, stderr, append(flags, patterns...)...)
+	if err == nil || errors.Is(err, exec.ErrNotFound) || runContext.Err() != nil {
+		return indexPath, err
+	}
+
+	// Projects are indexed by passing their directory, standing for all of
+	// their packages
+	listed := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		listed[i] = pattern
+		if pattern == dir {
+			listed[i] = "./..."
+		}
+	}
+	loadable, gaps, listErr := brokenPackages(dir, env, listed)
+	if listErr != nil {
+		log.Printf("Warning: could not list the packages scip-go failed on: %v", listErr)
+		return "", err
+	}
+	if len(gaps) == 0 || len(loadable) == 0 {
+		return "", err
+	}
+	var names []string
+	for _, gap := range gaps {
+		names = append(names, gap.Package)
+	}
+	log.Printf("Warning: scip-go failed in %s (%v); indexing it without %d packages that don't build: %s", dir, err, len(gaps), strings.Join(names, ", "))
+	indexPath, err = runScipGo(dir, env, stderr, append(flags, loadable...)...)
+	if err != nil {
+		return "", err
+	}
+	recordGaps(indexPath, gaps)
+	return indexPath, nil
+}
+
+// brokenPackages lists the packages matching patterns with go list and splits
+// them into those scip-go can type-check and those it can't: packages with
+// errors, packages importing one, and cgo packages without a C compiler
+func brokenPackages(dir string, env, patterns []string) ([]string, []packageGap, error) {
+	var out bytes.Buffer
+	cmd := command("go", append([]string{"list", "-e", "-json=ImportPath,CgoFiles,Error,DepsErrors"}, patterns...)...)
+	cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], env...)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = subprocessStderr()
+	if err := cmd.Run(); err != nil {
+		return nil, nil, fmt.Errorf("go list failed: %w", err)
+	}
+
+	type packageError struct{ Err string }
+	noCompiler := cCompilerMissing(dir, env)
+	var loadable []string
+	var gaps []packageGap
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var pkg struct {
+			ImportPath string
+			CgoFiles   []string
+			Error      *packageError
+			DepsErrors []*packageError
+		}
+		if err := decoder.Decode(&pkg); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse go list output: %w", err)
+		}
+		switch {
+		case pkg.Error != nil:
+			gaps = append(gaps, packageGap{Package: pkg.ImportPath, Error: firstLine(pkg.Error.Err)})
+		case len(pkg.DepsErrors) > 0:
+			gaps = append(gaps, packageGap{Package: pkg.ImportPath, Error: "imports a package that doesn't build: " + firstLine(pkg.DepsErrors[0].Err)})
+		case len(pkg.CgoFiles) > 0 && noCompiler != "":
+			gaps = append(gaps, packageGap{Package: pkg.ImportPath, Error: noCompiler})
+		default:
+			loadable = append(loadable, pkg.ImportPath)
+		}
+	}
+	return loadable, gaps, nil
+}
+
+// cCompilerMissing says why cgo packages can't be built in dir, or returns ""
+// when they can
+func cCompilerMissing(dir string, env []string) string {
+	var out bytes.Buffer
+	cmd := command("go", "env", "CGO_ENABLED", "CC")
+	cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], env...)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) < 2 || lines[0] != "1" {
+		return ""
+	}
+	cc := strings.Fields(lines[1])
+	if len(cc) == 0 {
+		return ""
+	}
+	if _, err := exec.LookPath(cc[0]); err != nil {
+		return fmt.Sprintf("uses cgo, but the C compiler %s isn't installed", cc[0])
+	}
+	return ""
+}
+
+// firstLine returns the first line of a multi-line error message
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// writeGaps stores the gaps of an index in a cache entry's files
+func writeGaps(files map[string]io.Reader, indexPath string) error {
+	gaps := gapsOf(indexPath)
+	if len(gaps) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(gaps)
+	if err != nil {
+		return err
+	}
+	files[gapsFile] = bytes.NewReader(data)
+	return nil
+}
+
+// readGaps records the gaps stored in a cache entry for its index
+func readGaps(dir, indexPath string) error {
+	data, err := os.ReadFile(filepath.Join(dir, gapsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var gaps []packageGap
+	if err := json.Unmarshal(data, &gaps); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", gapsFile, err)
+	}
+	recordGaps(indexPath, gaps)
+	return nil
+}
+
+// sortGaps orders gaps by version and package
+func sortGaps(gaps []packageGap) {
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].Version != gaps[j].Version {
+			return gaps[i].Version < gaps[j].Version
+		}
+		return gaps[i].Package < gaps[j].Package
+	})
+}
+
+// reportGaps returns the gaps of a report: the packages of the module
+// versions, then those of each project, prefixed by the service when there
+// are several
+func reportGaps(report *Report) []string {
+	var lines []string
+	for _, gap := range report.Unanalyzed {
+		lines = append(lines, fmt.Sprintf("%s@%s %s: %s", report.Module, gap.Version, gap.Package, gap.Error))
+	}
+	for _, service := range report.Services {
+		for _, gap := range service.Unanalyzed {
+			line := fmt.Sprintf("%s: %s", gap.Package, gap.Error)
+			if len(report.Services) > 1 {
+				line = service.Name + ": " + line
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// printGapsText writes the packages left out of the analysis, if any
+func printGapsText(w io.Writer, report *Report) {
+	gaps := reportGaps(report)
+	if len(gaps) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "INCOMPLETE: these packages couldn't be indexed and aren't covered by the verdict:")
+	for _, gap := range gaps {
+		fmt.Fprintln(w, "  - "+gap)
+	}
+	fmt.Fprintln(w)
+}
+
+// printGapsMarkdown writes the packages left out of the analysis, if any
+func printGapsMarkdown(w io.Writer, report *Report) {
+	gaps := reportGaps(report)
+	if len(gaps) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "> **Incomplete:** these packages couldn't be indexed and aren't covered by the verdict:")
+	fmt.Fprintln(w, ">")
+	for _, gap := range gaps {
+		fmt.Fprintln(w, "> - "+markdownCell(gap))
+	}
+	fmt.Fprintln(w)
+}
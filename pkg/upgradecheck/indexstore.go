@@ -0,0 +1,212 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+	"google.golang.org/protobuf/proto"
+)
+
+// memoryIndexPrefix marks index paths that live in the in-memory store rather
+// than on disk
+const memoryIndexPrefix = "memory:"
+
+// inMemoryIndexes makes scip-go stream generated indexes through a pipe into
+// memory instead of writing them to temp directories
+var inMemoryIndexes bool
+
+// storedIndex is an index kept in memory, in encoded and decoded form
+type storedIndex struct {
+	data  []byte
+	index *scip.Index
+	// modTime and size identify the contents an index file had when read
+	modTime time.Time
+	size    int64
+}
+
+// indexStore holds in-memory indexes and every index decoded so far, so each
+// index file is read and unmarshalled at most once per run. A file rewritten
+// since is read again, and releaseIndexes empties the store when the run
+// ends.
+var indexStore = struct {
+	sync.Mutex
+	indexes map[string]*storedIndex
+	next    int
+}{indexes: make(map[string]*storedIndex)}
+
+// loadIndex returns the decoded index at path, which is either a file or an
+// in-memory index
+func loadIndex(path string) (*scip.Index, error) {
+	indexStore.Lock()
+	defer indexStore.Unlock()
+
+	stored, ok := indexStore.indexes[path]
+	if strings.HasPrefix(path, memoryIndexPrefix) {
+		if !ok {
+			return nil, fmt.Errorf("in-memory index %s has been released", path)
+		}
+	} else {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read index file '%s': %w", path, err)
+		}
+		if !ok || !stored.modTime.Equal(info.ModTime()) || stored.size != info.Size() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read index file '%s': %w", path, err)
+			}
+			stored = &storedIndex{data: data, modTime: info.ModTime(), size: info.Size()}
+			indexStore.indexes[path] = stored
+		}
+	}
+
+	if stored.index == nil {
+		var index scip.Index
+		if err := proto.Unmarshal(stored.data, &index); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal index '%s': %w", path, err)
+		}
+		stored.index = &index
+		// Only in-memory indexes need their encoded form later on
+		if !strings.HasPrefix(path, memoryIndexPrefix) {
+			stored.data = nil
+		}
+	}
+	return stored.index, nil
+}
+
+// openIndex returns a reader over the encoded index at path
+func openIndex(path string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(path, memoryIndexPrefix) {
+		return os.Open(path)
+	}
+	indexStore.Lock()
+	stored, ok := indexStore.indexes[path]
+	indexStore.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("in-memory index %s has been released", path)
+	}
+	return io.NopCloser(bytes.NewReader(stored.data)), nil
+}
+
+// storeIndex keeps an index in memory and returns its path. index may be nil,
+// in which case it is decoded from data on first use.
+func storeIndex(data []byte, index *scip.Index) string {
+	indexStore.Lock()
+	defer indexStore.Unlock()
+
+	indexStore.next++
+	path := fmt.Sprintf("%s%d", memoryIndexPrefix, indexStore.next)
+	indexStore.indexes[path] = &storedIndex{data: data, index: index}
+	return path
+}
+
+// saveIndex persists an index built by the tool itself, in memory or in a new
+// temp directory depending on the mode, and returns its path
+func saveIndex(index *scip.Index) (string, error) {
+	data, err := proto.Marshal(index)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal index: %w", err)
+	}
+	if inMemoryIndexes {
+		return storeIndex(data, index), nil
+	}
+
+	outputDir, err := cleanups.tempDir("", "scip-index-*")
+	if err != nil {
+		return "", err
+	}
+	outputPath := filepath.Join(outputDir, "index.scip")
+	if err := os.WriteFile(outputPath, data, 0o644); err != nil {
+		cleanups.remove(outputDir)
+		return "", fmt.Errorf("failed to write index: %w", err)
+	}
+	return outputPath, nil
+}
+
+// releaseIndex frees a generated index: in-memory indexes are dropped and
+// on-disk ones have their temp directory removed
+func releaseIndex(path string) {
+	if path == "" {
+		return
+	}
+	indexStore.Lock()
+	delete(indexStore.indexes, path)
+	indexStore.Unlock()
+
+	if !strings.HasPrefix(path, memoryIndexPrefix) {
+		cleanups.remove(filepath.Dir(path))
+	}
+}
+
+// releaseIndexes drops every index of the store, including cached and
+// pre-built ones, which nothing releases individually
+func releaseIndexes() {
+	indexStore.Lock()
+	defer indexStore.Unlock()
+	clear(indexStore.indexes)
+}
+
+// runScipGo runs scip-go in dir with the given arguments plus an --output flag
+// and returns the path of the generated index. In memory mode the index is
+// streamed through a pipe passed as file descriptor 3, so nothing is written
+// to disk; platforms without /dev/fd fall back to a temp directory.
+func runScipGo(dir string, env []string, stderr io.Writer, args ...string) (string, error) {
+	if inMemoryIndexes && runtime.GOOS != "windows" {
+		return runScipGoPiped(dir, env, stderr, args...)
+	}
+
+	outputDir, err := cleanups.tempDir("", "scip-index-*")
+	if err != nil {
+		return "", err
+	}
+	outputPath := filepath.Join(outputDir, "index.scip")
+
+	cmd := command("scip-go", append([]string{"--output", outputPath}, args...)...)
+	cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], env...)
+	cmd.Dir = dir
+	cmd.Stderr = stderr
+	if err := subprocessError(cmd.Run(), stderr); err != nil {
+		cleanups.remove(outputDir)
+		return "", fmt.Errorf("failed to run scip-go: %w", err)
+	}
+
+	return outputPath, nil
+}
+
+func runScipGoPiped(dir string, env []string, stderr io.Writer, args ...string) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer r.Close()
+
+	cmd := command("scip-go", append([]string{"--output", "/dev/fd/3"}, args...)...)
+	cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], env...)
+	cmd.Dir = dir
+	cmd.Stderr = stderr
+	cmd.ExtraFiles = []*os.File{w}
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return "", fmt.Errorf("failed to run scip-go: %w", err)
+	}
+	// Only the child may hold the write end, so reading ends when it exits
+	w.Close()
+
+	data, readErr := io.ReadAll(r)
+	if err := subprocessError(cmd.Wait(), stderr); err != nil {
+		return "", fmt.Errorf("failed to run scip-go: %w", err)
+	}
+	if readErr != nil {
+		return "", fmt.Errorf("failed to read index from scip-go: %w", readErr)
+	}
+
+	return storeIndex(data, nil), nil
+}
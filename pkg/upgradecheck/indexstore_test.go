@@ -0,0 +1,76 @@
+package upgradecheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+	"google.golang.org/protobuf/proto"
+)
+
+// writeIndex writes an index of one document at path
+func writeIndex(t *testing.T, path, document string, modTime time.Time) {
+	t.Helper()
+	data, err := proto.Marshal(&scip.Index{Documents: []*scip.Document{{RelativePath: document}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	defer releaseIndexes()
+	path := filepath.Join(t.TempDir(), "index.scip")
+	modTime := time.Now().Add(-time.Hour)
+
+	documents := func() string {
+		t.Helper()
+		index, err := loadIndex(path)
+		if err != nil {
+			t.Fatalf("loadIndex() error: %v", err)
+		}
+		return index.Documents[0].RelativePath
+	}
+
+	writeIndex(t, path, "a.go", modTime)
+	if got := documents(); got != "a.go" {
+		t.Fatalf("loadIndex() read %s, want a.go", got)
+	}
+	first, _ := loadIndex(path)
+	if second, _ := loadIndex(path); second != first {
+		t.Error("loadIndex() decoded an unchanged file again")
+	}
+
+	// A rewritten file is read again, even when its size is the same
+	writeIndex(t, path, "b.go", modTime.Add(time.Minute))
+	if got := documents(); got != "b.go" {
+		t.Errorf("loadIndex() after rewriting = %s, want b.go", got)
+	}
+	os.Remove(path)
+	if _, err := loadIndex(path); err == nil {
+		t.Error("loadIndex() of a removed file succeeded")
+	}
+
+	memory := storeIndex(nil, &scip.Index{Documents: []*scip.Document{{RelativePath: "c.go"}}})
+	if index, err := loadIndex(memory); err != nil || index.Documents[0].RelativePath != "c.go" {
+		t.Errorf("loadIndex(%s) = %v, %v", memory, index, err)
+	}
+
+	releaseIndexes()
+	if _, err := loadIndex(memory); err == nil {
+		t.Errorf("loadIndex(%s) succeeded after releaseIndexes()", memory)
+	}
+	indexStore.Lock()
+	n := len(indexStore.indexes)
+	indexStore.Unlock()
+	if n != 0 {
+		t.Errorf("%d indexes left after releaseIndexes()", n)
+	}
+}
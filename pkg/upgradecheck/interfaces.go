@@ -0,0 +1,145 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeImplements marks findings for module types that stopped implementing an
+// interface; OldSignature names the interface
+const ChangeImplements = "implements"
+
+// interfaceKey identifies a scip-go symbol independently of the version of its
+// module, so references from the project match the module index
+func interfaceKey(symbol string) string {
+	fields := strings.SplitN(symbol, " ", 5)
+	if len(fields) < 5 {
+		return symbol
+	}
+	return fields[2] + " " + fields[4]
+}
+
+// interfaceName renders an interface symbol the way Go code names it, e.g.
+// "io.WriterTo" for "scip-go gomod std . `io`/WriterTo#"
+func interfaceName(symbol string) string {
+	name, ok := parseSymbolName(symbol)
+	if !ok || name.Type == "" {
+		return symbol
+	}
+	if name.Package == "" {
+		return name.Type
+	}
+	return defaultImportName(name.Package) + "." + name.Type
+}
+
+// implementedInterfaces returns the interfaces each module type implements
+// according to the is_implementation relationships scip-go records, keyed by
+// type name and then interfaceKey, with the interface symbols as values
+func implementedInterfaces(indexPath string) (map[string]map[string]string, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	implemented := make(map[string]map[string]string)
+	for _, doc := range index.Documents {
+		for _, sym := range doc.Symbols {
+			val, typ := extractSymbolsFromOccurrence(sym.Symbol)
+			if typ != "type" || !strings.HasSuffix(val, "#") || strings.Count(val, "#") != 1 {
+				continue
+			}
+			name := strings.TrimSuffix(val, "#")
+			for _, rel := range sym.Relationships {
+				if !rel.IsImplementation {
+					continue
+				}
+				if implemented[name] == nil {
+					implemented[name] = make(map[string]string)
+				}
+				implemented[name][interfaceKey(rel.Symbol)] = rel.Symbol
+			}
+		}
+	}
+	return implemented, nil
+}
+
+// interfaceReferences returns where the project refers to each interface,
+// keyed by interfaceKey, outside of ignored directories
+func interfaceReferences(indexPath string, ignored ignoredDirs) (usageSites, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(usageSites)
+	for _, doc := range index.Documents {
+		if ignored.ignores(doc.RelativePath) {
+			continue
+		}
+		for _, occ := range doc.Occurrences {
+			if strings.HasSuffix(occ.Symbol, "#") {
+				refs.add(interfaceKey(occ.Symbol), occurrenceLocation(doc.RelativePath, occ))
+			}
+		}
+	}
+	return refs, nil
+}
+
+// implementsFindings reports module types the project uses that implement an
+// interface in the old version but not in the new one. Code receiving such a
+// value as that interface stops compiling, or, behind a type assertion like
+// io.Copy's check for io.WriterTo, silently takes another path. Without type
+// information the tool can't see every place a value is passed as an
+// interface, so the finding is breaking only where the project names the
+// interface itself. Types missing from the new version are left to their
+// removal finding.
+func implementsFindings(used usageSites, oldImpl, newImpl map[string]map[string]string, newSymbols map[string][]string, refs usageSites) []Finding {
+	var types []string
+	for name := range used {
+		if len(oldImpl[name]) > 0 {
+			types = append(types, name)
+		}
+	}
+	sort.Strings(types)
+
+	var findings []Finding
+	for _, name := range types {
+		if _, ok := newSymbols[name]; !ok {
+			continue
+		}
+		var lost []string
+		for key := range oldImpl[name] {
+			if _, ok := newImpl[name][key]; !ok {
+				lost = append(lost, key)
+			}
+		}
+		sort.Strings(lost)
+
+		for _, key := range lost {
+			iface := interfaceName(oldImpl[name][key])
+			f := Finding{
+				Symbol:       name,
+				Kind:         ChangeImplements,
+				Severity:     SeverityWarning,
+				Confidence:   ConfidenceHeuristic,
+				OldSignature: iface,
+				Usages:       used[name],
+				Notes: []string{fmt.Sprintf("%s no longer implements %s; check whether the project passes it where %s is expected",
+					name, iface, iface)},
+			}
+			if sites := refs[key]; len(sites) > 0 {
+				f.Severity = SeverityBreaking
+				f.Confidence = ConfidenceHigh
+				var where []string
+				for _, loc := range sites {
+					where = append(where, loc.String())
+				}
+				f.Notes = []string{fmt.Sprintf("%s no longer implements %s, which the project refers to at %s",
+					name, iface, strings.Join(where, ", "))}
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,79 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// partialResults are the results of the run so far: the services of a check
+// analyzed, or the checks of a batch completed. An interrupted run writes them
+// as a partial report instead of losing them.
+type partialResults struct {
+	mu sync.Mutex
+	// report is the check in progress, holding the services analyzed so far,
+	// and batch the batch in progress; total counts the services or checks
+	// expected
+	report *Report
+	batch  *batchReport
+	total  int
+	// done is set once the run writes its complete report
+	done bool
+
+	// format, view and byOwner render the partial report; without a format,
+	// as in checks through the API, nothing is written
+	format  string
+	view    string
+	byOwner bool
+}
+
+// partial holds the results of the run
+var partial = &partialResults{}
+
+// output sets how a partial report is written
+func (p *partialResults) output(format, view string, byOwner bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.format, p.view, p.byOwner = format, view, byOwner
+}
+
+// update runs f, which records results, so a partial report never sees them
+// half-recorded. A report written meanwhile waits for f to return.
+func (p *partialResults) update(f func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f()
+}
+
+// finish marks the results complete, as the run is writing its own report
+func (p *partialResults) finish() {
+	p.update(func() { p.done = true })
+}
+
+// flush writes the results so far as a partial report marked with why the run
+// was interrupted. It reports whether there was anything to write.
+func (p *partialResults) flush(cause string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done || p.format == "" {
+		return false
+	}
+
+	var err error
+	switch {
+	case p.batch != nil:
+		checked := len(p.batch.Modules) + len(p.batch.Failed)
+		p.batch.Interrupted = fmt.Sprintf("%s after checking %d of %d modules", cause, checked, p.total)
+		err = renderBatch(p.batch, p.format, p.view, p.byOwner)
+	case p.report != nil:
+		p.report.Interrupted = fmt.Sprintf("%s after analyzing %d of %d projects", cause, len(p.report.Services), p.total)
+		err = renderReport(p.report, p.format, p.view, p.byOwner)
+	default:
+		return false
+	}
+	if err != nil {
+		log.Printf("Warning: could not write the partial report: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,428 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Issue tracker backends understood by --create-issues
+const (
+	TrackerGitHub = "github"
+	TrackerGitLab = "gitlab"
+	TrackerJira   = "jira"
+)
+
+var trackerClient = &http.Client{Timeout: 30 * time.Second}
+
+// trackingIssue is the issue opened for a breaking upgrade
+type trackingIssue struct {
+	Title  string
+	Body   string
+	Labels []string
+	// Assignees are the individual owners of the affected code; teams can't
+	// be assigned and are mentioned in the body instead
+	Assignees []string
+}
+
+// issueTracker is an issue tracker tracking issues are filed in
+type issueTracker interface {
+	// findIssue returns the ID of the open issue titled title, or "" if none
+	findIssue(title string) (string, error)
+	createIssue(issue trackingIssue) (string, error)
+	updateIssue(id string, issue trackingIssue) error
+	// issueURL returns the web address of an issue for the log
+	issueURL(id string) string
+}
+
+// newIssueTracker configures a backend from the environment of the CI system
+// it belongs to
+func newIssueTracker(backend string) (issueTracker, error) {
+	switch backend {
+	case TrackerGitHub:
+		t := &githubRepo{
+			APIURL:     os.Getenv("GITHUB_API_URL"),
+			Repository: os.Getenv("GITHUB_REPOSITORY"),
+			Token:      os.Getenv("GITHUB_TOKEN"),
+		}
+		if t.APIURL == "" {
+			t.APIURL = "https://api.github.com"
+		}
+		if t.Repository == "" || t.Token == "" {
+			return nil, fmt.Errorf("GITHUB_REPOSITORY and GITHUB_TOKEN must be set")
+		}
+		return t, nil
+	case TrackerGitLab:
+		t := &gitlabProject{
+			APIURL:  os.Getenv("CI_API_V4_URL"),
+			Project: os.Getenv("CI_PROJECT_ID"),
+			Token:   os.Getenv("GITLAB_TOKEN"),
+			WebURL:  os.Getenv("CI_PROJECT_URL"),
+		}
+		if t.APIURL == "" {
+			t.APIURL = "https://gitlab.com/api/v4"
+		}
+		if t.Project == "" || t.Token == "" {
+			return nil, fmt.Errorf("CI_PROJECT_ID and GITLAB_TOKEN must be set")
+		}
+		return t, nil
+	case TrackerJira:
+		t := &jiraProject{
+			URL:     strings.TrimSuffix(os.Getenv("JIRA_URL"), "/"),
+			User:    os.Getenv("JIRA_USER"),
+			Token:   os.Getenv("JIRA_TOKEN"),
+			Project: os.Getenv("JIRA_PROJECT"),
+		}
+		if t.URL == "" || t.User == "" || t.Token == "" || t.Project == "" {
+			return nil, fmt.Errorf("JIRA_URL, JIRA_USER, JIRA_TOKEN and JIRA_PROJECT must be set")
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown issue tracker %q: must be %s, %s or %s", backend, TrackerGitHub, TrackerGitLab, TrackerJira)
+}
+
+// breakingIssue builds the tracking issue for a report, or returns nil when the
+// upgrade has no breaking findings
+func breakingIssue(report *Report, labels []string) *trackingIssue {
+	breaking := false
+	var owners []string
+	seen := make(map[string]bool)
+	for _, service := range report.Services {
+		for _, f := range service.Findings {
+			if f.Severity < SeverityBreaking || f.Accepted {
+				continue
+			}
+			breaking = true
+			for _, owner := range f.Owners {
+				if !seen[owner] {
+					seen[owner] = true
+					owners = append(owners, owner)
+				}
+			}
+		}
+	}
+	if !breaking {
+		return nil
+	}
+
+	issue := &trackingIssue{
+		Title:  fmt.Sprintf("Upgrade %s to %s", report.Module, report.NewVersion),
+		Labels: labels,
+	}
+	var body bytes.Buffer
+	printMarkdown(&body, report, len(owners) > 0)
+	var teams []string
+	for _, owner := range owners {
+		// Teams are @org/team, email owners can't be mapped to accounts
+		if strings.Contains(owner, "/") || !strings.HasPrefix(owner, "@") {
+			teams = append(teams, owner)
+			continue
+		}
+		issue.Assignees = append(issue.Assignees, strings.TrimPrefix(owner, "@"))
+	}
+	if len(teams) > 0 {
+		fmt.Fprintf(&body, "\ncc %s\n", strings.Join(teams, " "))
+	}
+	issue.Body = body.String()
+	return issue
+}
+
+// fileTrackingIssue opens the issue, or updates the open one with the same
+// title so re-running the check keeps a single issue per upgrade current
+func fileTrackingIssue(tracker issueTracker, issue trackingIssue) (string, bool, error) {
+	id, err := tracker.findIssue(issue.Title)
+	if err != nil {
+		return "", false, err
+	}
+	if id != "" {
+		return tracker.issueURL(id), false, tracker.updateIssue(id, issue)
+	}
+	id, err = tracker.createIssue(issue)
+	if err != nil {
+		return "", false, err
+	}
+	return tracker.issueURL(id), true, nil
+}
+
+func (g *githubRepo) findIssue(title string) (string, error) {
+	// Search matches words, so the exact title is checked below
+	q := fmt.Sprintf("repo:%s is:issue is:open in:title %q", g.Repository, title)
+	data, err := g.githubAPI(http.MethodGet, "/search/issues?"+url.Values{"q": {q}}.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Items []struct {
+			Number int    `json:"number"`
+			Title  string `json:"title"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to parse GitHub issues: %w", err)
+	}
+	for _, issue := range result.Items {
+		if issue.Title == title {
+			return fmt.Sprint(issue.Number), nil
+		}
+	}
+	return "", nil
+}
+
+func (g *githubRepo) createIssue(issue trackingIssue) (string, error) {
+	fields := map[string]any{"title": issue.Title, "body": issue.Body, "labels": issue.Labels}
+	if len(issue.Assignees) > 0 {
+		fields["assignees"] = issue.Assignees
+	}
+	data, err := g.githubAPI(http.MethodPost, fmt.Sprintf("/repos/%s/issues", g.Repository), fields)
+	if err != nil {
+		return "", err
+	}
+	var created struct {
+		Number int `json:"number"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil {
+		return "", fmt.Errorf("failed to parse created GitHub issue: %w", err)
+	}
+	return fmt.Sprint(created.Number), nil
+}
+
+func (g *githubRepo) updateIssue(id string, issue trackingIssue) error {
+	// Assignees are only ever added, so whoever picked the issue up stays
+	fields := map[string]any{"body": issue.Body, "labels": issue.Labels}
+	if _, err := g.githubAPI(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/%s", g.Repository, id), fields); err != nil {
+		return err
+	}
+	if len(issue.Assignees) > 0 {
+		path := fmt.Sprintf("/repos/%s/issues/%s/assignees", g.Repository, id)
+		if _, err := g.githubAPI(http.MethodPost, path, map[string]any{"assignees": issue.Assignees}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *githubRepo) issueURL(id string) string {
+	server := os.Getenv("GITHUB_SERVER_URL")
+	if server == "" {
+		server = "https://github.com"
+	}
+	return fmt.Sprintf("%s/%s/issues/%s", server, g.Repository, id)
+}
+
+// gitlabProject is the GitLab project tracking issues are filed in, as
+// provided by the GitLab CI environment
+type gitlabProject struct {
+	APIURL  string
+	Project string // numeric ID or path like group/project
+	Token   string
+	WebURL  string
+}
+
+func (g *gitlabProject) api(method, path string, body, out any) error {
+	return trackerRequest(method, strings.TrimSuffix(g.APIURL, "/")+"/projects/"+url.PathEscape(g.Project)+path,
+		map[string]string{"PRIVATE-TOKEN": g.Token}, body, out)
+}
+
+func (g *gitlabProject) findIssue(title string) (string, error) {
+	var issues []struct {
+		IID   int    `json:"iid"`
+		Title string `json:"title"`
+	}
+	query := url.Values{"state": {"opened"}, "search": {title}, "in": {"title"}}
+	if err := g.api(http.MethodGet, "/issues?"+query.Encode(), nil, &issues); err != nil {
+		return "", err
+	}
+	for _, issue := range issues {
+		if issue.Title == title {
+			return fmt.Sprint(issue.IID), nil
+		}
+	}
+	return "", nil
+}
+
+// assigneeIDs resolves usernames to the user IDs GitLab assigns by; unknown
+// users are skipped
+func (g *gitlabProject) assigneeIDs(usernames []string) []int {
+	var ids []int
+	for _, username := range usernames {
+		var users []struct {
+			ID int `json:"id"`
+		}
+		err := trackerRequest(http.MethodGet, strings.TrimSuffix(g.APIURL, "/")+"/users?username="+url.QueryEscape(username),
+			map[string]string{"PRIVATE-TOKEN": g.Token}, nil, &users)
+		if err == nil && len(users) > 0 {
+			ids = append(ids, users[0].ID)
+		}
+	}
+	return ids
+}
+
+func (g *gitlabProject) createIssue(issue trackingIssue) (string, error) {
+	var created struct {
+		IID int `json:"iid"`
+	}
+	fields := map[string]any{"title": issue.Title, "description": issue.Body, "labels": strings.Join(issue.Labels, ",")}
+	if ids := g.assigneeIDs(issue.Assignees); len(ids) > 0 {
+		fields["assignee_ids"] = ids
+	}
+	err := g.api(http.MethodPost, "/issues", fields, &created)
+	return fmt.Sprint(created.IID), err
+}
+
+// updateIssue leaves the assignees alone: replacing them would unassign
+// whoever picked the issue up
+func (g *gitlabProject) updateIssue(id string, issue trackingIssue) error {
+	return g.api(http.MethodPut, "/issues/"+id, map[string]any{
+		"description": issue.Body,
+		"labels":      strings.Join(issue.Labels, ","),
+	}, nil)
+}
+
+func (g *gitlabProject) issueURL(id string) string {
+	if g.WebURL == "" {
+		return "#" + id
+	}
+	return g.WebURL + "/-/issues/" + id
+}
+
+// jiraProject is the Jira project tracking issues are filed in as tasks
+type jiraProject struct {
+	URL     string
+	User    string
+	Token   string
+	Project string
+}
+
+func (j *jiraProject) api(method, path string, body, out any) error {
+	auth := base64.StdEncoding.EncodeToString([]byte(j.User + ":" + j.Token))
+	return trackerRequest(method, j.URL+"/rest/api/2"+path, map[string]string{"Authorization": "Basic " + auth}, body, out)
+}
+
+func (j *jiraProject) findIssue(title string) (string, error) {
+	jql := fmt.Sprintf("project = %q AND summary ~ %q AND statusCategory != Done", j.Project, "\""+title+"\"")
+	var result struct {
+		Issues []struct {
+			Key    string `json:"key"`
+			Fields struct {
+				Summary string `json:"summary"`
+			} `json:"fields"`
+		} `json:"issues"`
+	}
+	if err := j.api(http.MethodGet, "/search?"+url.Values{"jql": {jql}, "fields": {"summary"}}.Encode(), nil, &result); err != nil {
+		return "", err
+	}
+	for _, issue := range result.Issues {
+		if issue.Fields.Summary == title {
+			return issue.Key, nil
+		}
+	}
+	return "", nil
+}
+
+// jiraLabels replaces spaces, which Jira labels can't contain
+func jiraLabels(labels []string) []string {
+	var out []string
+	for _, label := range labels {
+		out = append(out, strings.ReplaceAll(label, " ", "-"))
+	}
+	return out
+}
+
+func (j *jiraProject) createIssue(issue trackingIssue) (string, error) {
+	var created struct {
+		Key string `json:"key"`
+	}
+	// Jira assigns by account ID, which usernames don't map to; the owners
+	// are listed in the description
+	err := j.api(http.MethodPost, "/issue", map[string]any{
+		"fields": map[string]any{
+			"project":     map[string]string{"key": j.Project},
+			"issuetype":   map[string]string{"name": "Task"},
+			"summary":     issue.Title,
+			"description": jiraDescription(issue),
+			"labels":      jiraLabels(issue.Labels),
+		},
+	}, &created)
+	return created.Key, err
+}
+
+func (j *jiraProject) updateIssue(id string, issue trackingIssue) error {
+	return j.api(http.MethodPut, "/issue/"+id, map[string]any{
+		"fields": map[string]any{
+			"description": jiraDescription(issue),
+			"labels":      jiraLabels(issue.Labels),
+		},
+	}, nil)
+}
+
+func (j *jiraProject) issueURL(id string) string {
+	return j.URL + "/browse/" + id
+}
+
+func jiraDescription(issue trackingIssue) string {
+	if len(issue.Assignees) == 0 {
+		return issue.Body
+	}
+	return issue.Body + "\nOwners: " + strings.Join(issue.Assignees, ", ") + "\n"
+}
+
+// trackerRequest sends a JSON request to an issue tracker API and decodes the
+// response into out, if given
+func trackerRequest(method, endpoint string, headers map[string]string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := trackerClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, endpoint, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s returned %s: %s", method, endpoint, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to parse response of %s %s: %w", method, endpoint, err)
+		}
+	}
+	return nil
+}
+
+// parseLabels splits a comma separated label list
+func parseLabels(list string) []string {
+	var labels []string
+	for _, label := range strings.Split(list, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,105 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strings"
+)
+
+// appendJobSummary adds the reports to the job summary of the GitHub Actions
+// step, shown on the workflow run page. It does nothing outside of Actions.
+func appendJobSummary(reports ...*Report) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, report := range reports {
+		writeJobSummary(&buf, report)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeJobSummary renders one report for the job summary: severity counts, a
+// mermaid chart of the findings per project package and the findings with
+// their usages in collapsible sections
+func writeJobSummary(buf *bytes.Buffer, report *Report) {
+	fmt.Fprintf(buf, "## Upgrade check: %s %s → %s\n\n", report.Module, report.OldVersion, report.NewVersion)
+	if report.Deprecated != "" {
+		fmt.Fprintf(buf, "> **Deprecated:** %s\n\n", report.Deprecated)
+	}
+
+	var findings []Finding
+	byPackage := make(map[string]int)
+	for _, service := range report.Services {
+		for _, f := range service.Findings {
+			findings = append(findings, f)
+			for _, pkg := range f.Packages() {
+				if len(report.Services) > 1 {
+					pkg = service.Name + ": " + pkg
+				}
+				byPackage[pkg]++
+			}
+		}
+	}
+	if len(findings) == 0 {
+		buf.WriteString("No breaking changes detected.\n\n")
+		return
+	}
+
+	counts := make(map[Severity]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	buf.WriteString("| Severity | Findings |\n| --- | --- |\n")
+	for s := SeverityCritical; s >= SeverityInfo; s-- {
+		if counts[s] > 0 {
+			fmt.Fprintf(buf, "| %s | %d |\n", s, counts[s])
+		}
+	}
+	buf.WriteString("\n")
+
+	if len(byPackage) > 0 {
+		pkgs := make([]string, 0, len(byPackage))
+		for pkg := range byPackage {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Slice(pkgs, func(i, j int) bool {
+			if byPackage[pkgs[i]] != byPackage[pkgs[j]] {
+				return byPackage[pkgs[i]] > byPackage[pkgs[j]]
+			}
+			return pkgs[i] < pkgs[j]
+		})
+		buf.WriteString("```mermaid\npie title Findings by package\n")
+		for _, pkg := range pkgs {
+			// Mermaid labels can't contain double quotes
+			fmt.Fprintf(buf, "    %q : %d\n", strings.ReplaceAll(pkg, `"`, "'"), byPackage[pkg])
+		}
+		buf.WriteString("```\n\n")
+	}
+
+	for _, f := range findings {
+		fmt.Fprintf(buf, "<details><summary>[%s] %s</summary>\n\n", f.Severity, html.EscapeString(f.Summary()))
+		for _, note := range f.Notes {
+			fmt.Fprintf(buf, "- note: %s\n", markdownCell(note))
+		}
+		if len(f.Owners) > 0 {
+			fmt.Fprintf(buf, "- owners: %s\n", markdownCell(strings.Join(f.Owners, ", ")))
+		}
+		for _, loc := range f.Usages {
+			fmt.Fprintf(buf, "- `%s`\n", loc)
+		}
+		buf.WriteString("\n</details>\n\n")
+	}
+}
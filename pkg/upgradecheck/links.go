@@ -0,0 +1,88 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sourceURL is the base URL of links to the project's files in markdown
+// reports, e.g. https://github.com/org/repo/blob/main (--source-url). When
+// empty, links point at the commit checked by GitHub Actions or GitLab CI.
+var sourceURL string
+
+// maxMarkdownUsages caps the usages linked per finding, so comments on large
+// projects stay readable
+const maxMarkdownUsages = 10
+
+// sourceBaseURL returns the base URL files are linked under, or "" when there
+// is none and paths are shown as code
+func sourceBaseURL() string {
+	if sourceURL != "" {
+		return strings.TrimSuffix(sourceURL, "/")
+	}
+	if repo, sha := os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_SHA"); repo != "" && sha != "" {
+		server := os.Getenv("GITHUB_SERVER_URL")
+		if server == "" {
+			server = "https://github.com"
+		}
+		return fmt.Sprintf("%s/%s/blob/%s", server, repo, sha)
+	}
+	if project, sha := os.Getenv("CI_PROJECT_URL"), os.Getenv("CI_COMMIT_SHA"); project != "" && sha != "" {
+		return fmt.Sprintf("%s/-/blob/%s", project, sha)
+	}
+	return ""
+}
+
+// markdownLocation formats a usage in the project at root as a link to its
+// line, with the path relative to the repository, or as code without a base
+// URL
+func markdownLocation(base, root string, loc Location) string {
+	path := repoRelative(filepath.Join(root, filepath.FromSlash(loc.Path)))
+	text := path
+	if loc.Line > 0 {
+		text = fmt.Sprintf("%s:%d", path, loc.Line)
+	}
+	if base == "" {
+		return markdownCode(text)
+	}
+	link := base + "/" + path
+	if loc.Line > 0 {
+		link += fmt.Sprintf("#L%d", loc.Line)
+	}
+	return fmt.Sprintf("[%s](%s)", markdownCell(text), link)
+}
+
+// printMarkdownUsages lists where the project uses the symbols of findings,
+// collapsed so the tables stay the focus of the comment
+func printMarkdownUsages(w io.Writer, root string, findings []Finding) {
+	var lines []string
+	base := sourceBaseURL()
+	for _, f := range findings {
+		if len(f.Usages) == 0 {
+			continue
+		}
+		var links []string
+		for i, loc := range f.Usages {
+			if i == maxMarkdownUsages {
+				links = append(links, fmt.Sprintf("and %d more", len(f.Usages)-i))
+				break
+			}
+			links = append(links, markdownLocation(base, root, loc))
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", markdownCode(f.Symbol), strings.Join(links, ", ")))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "<details><summary>Usages</summary>")
+	fmt.Fprintln(w)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "</details>")
+	fmt.Fprintln(w)
+}
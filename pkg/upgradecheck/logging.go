@@ -0,0 +1,177 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log formats of --log-format
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// logLevel is the lowest level logged: debug with --verbose, which adds the
+// output of subprocesses and phase timings, error with --quiet
+var logLevel = new(slog.LevelVar)
+
+// leveledLogs is set once Main routes the log through slog. Until then, and
+// when embedded, the log package is used as is.
+var leveledLogs bool
+
+// setupLogging makes slog, and the log package through it, write the records
+// of at least logLevel to w, as JSON lines or as lines like the log package
+// writes, with or without timestamps
+func setupLogging(w io.Writer, format string, timestamps bool) {
+	var h slog.Handler = &textHandler{w: w, timestamps: timestamps}
+	if format == LogJSON {
+		h = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: logLevel})
+	}
+	slog.SetDefault(slog.New(warningHandler{h}))
+	leveledLogs = true
+}
+
+// parseLogFormat validates --log-format
+func parseLogFormat(format string) error {
+	if format != LogText && format != LogJSON {
+		return fmt.Errorf("unknown log format %q: must be %s or %s", format, LogText, LogJSON)
+	}
+	return nil
+}
+
+// warningHandler raises the records of log.Printf("Warning: ...") calls,
+// which slog receives at info level, to warnings
+type warningHandler struct {
+	slog.Handler
+}
+
+func (h warningHandler) Handle(ctx context.Context, r slog.Record) error {
+	if msg, ok := strings.CutPrefix(r.Message, "Warning: "); ok && r.Level == slog.LevelInfo {
+		warning := slog.NewRecord(r.Time, slog.LevelWarn, msg, r.PC)
+		r.Attrs(func(a slog.Attr) bool {
+			warning.AddAttrs(a)
+			return true
+		})
+		r = warning
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h warningHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return warningHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h warningHandler) WithGroup(name string) slog.Handler {
+	return warningHandler{h.Handler.WithGroup(name)}
+}
+
+// textHandler writes records like the log package did before logging was
+// leveled, so text logs read the same. Attributes are left to JSON logs.
+type textHandler struct {
+	mu         sync.Mutex
+	w          io.Writer
+	timestamps bool
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b bytes.Buffer
+	if h.timestamps {
+		b.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	}
+	if r.Level == slog.LevelWarn {
+		b.WriteString("Warning: ")
+	}
+	b.WriteString(strings.TrimSuffix(r.Message, "\n"))
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(b.Bytes())
+	return err
+}
+
+func (h *textHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *textHandler) WithGroup(string) slog.Handler { return h }
+
+// logError logs the error ending a run, which --quiet doesn't hide
+func logError(format string, args ...any) {
+	if leveledLogs {
+		slog.Error(fmt.Sprintf(format, args...))
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// logPhase logs how long a phase took, shown with --verbose
+func logPhase(name string, d time.Duration, err error) {
+	if err != nil {
+		slog.Debug(fmt.Sprintf("Phase %s failed after %s", name, d.Round(time.Millisecond)), "phase", name, "duration_ms", d.Milliseconds(), "error", err.Error())
+		return
+	}
+	slog.Debug(fmt.Sprintf("Phase %s took %s", name, d.Round(time.Millisecond)), "phase", name, "duration_ms", d.Milliseconds())
+}
+
+// debugLines logs each line written to it at debug level; it serves as the
+// stderr of subprocesses, whose output --verbose shows
+type debugLines struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	// last is the last line written, which usually says why a command failed
+	last string
+}
+
+func (w *debugLines) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(data)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			return len(data), nil
+		}
+		line = line[:len(line)-1]
+		if strings.TrimSpace(line) != "" {
+			w.last = strings.TrimSpace(line)
+		}
+		slog.Debug(line, "source", "subprocess")
+	}
+}
+
+// runLogged runs cmd with its stderr going to subprocessStderr. Unless
+// --verbose showed the output, a failure carries its last line.
+func runLogged(cmd *exec.Cmd) error {
+	stderr := subprocessStderr()
+	cmd.Stderr = stderr
+	return subprocessError(cmd.Run(), stderr)
+}
+
+// subprocessError adds the last line a failed subprocess wrote to stderr, if
+// it went to the debug log and that isn't shown, to its error
+func subprocessError(err error, stderr io.Writer) error {
+	w, ok := stderr.(*debugLines)
+	if err == nil || !ok || logLevel.Level() <= slog.LevelDebug {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.last == "" {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, w.last)
+}
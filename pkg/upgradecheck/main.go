@@ -0,0 +1,1658 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/scip/bindings/go/scip"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/mod/semver"
+)
+
+// Main runs the go-upgrade-checker command line: the subcommands, or a check
+// configured by flags with its report rendered to stdout. It is all the
+// binary's main function does.
+func Main() {
+	var projectPath string
+	var module string
+	var oldVersion string
+	var newVersion string
+	var policy Policy
+	var view string
+	var format string
+	var outputFile string
+	var githubActionsFlag bool
+	var githubComment bool
+	var allowRetracted bool
+	var cacheDir string
+	var cacheMaxMB int64
+	var cacheSignKey string
+	var cacheVerifyKey string
+	var noCache bool
+	var oldRepoURL string
+	var newRepoURL string
+	var vcsName string
+	var githubStatusPrefix string
+	var platformList string
+	var deep bool
+	var useBazel bool
+	var bazelRepo string
+	var buildPackage string
+	var packageList string
+	var ignoreDirList string
+	var deletingList string
+	var annotationsPath string
+	var flamegraphPath string
+	var configPath string
+	var baselinePath string
+	var writeBaselinePath string
+	var excludeList string
+	var ignoreSymbolList string
+	var maxSeverity string
+	var checkAllDeps bool
+	var followReexports bool
+	var progressFormat string
+	var engineName string
+	var backendName string
+	var projectIndexList, oldIndexPath, newIndexPath string
+	var verbose, quiet bool
+	var logFormat string
+	var deepThreshold int
+	var recordPath string
+	var minConfidence Confidence
+	var groupByOwner bool
+	var createIssues string
+	var includePrerelease bool
+	var issueLabels string
+	var replayPath string
+	var timeout time.Duration
+	var checkpointDir string
+	var resume bool
+	var commitHints bool
+	var stableAPIFlag bool
+	failThreshold := failOn{severity: SeverityBreaking}
+	envOverrides := make(envFlag)
+	var sets upgradeSets
+	mode := ModeCheck
+	var diff goModDiff
+	var sshHosts gitHosts
+	var vcsHosts gitHosts
+	gitTokens := make(gitTokenFlag)
+
+	handleSignals()
+	defer cleanups.run()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cache":
+			runCacheCommand(os.Args[2:])
+			return
+		case "doctor":
+			runDoctor(os.Args[2:])
+			return
+		case "report":
+			runReportCommand(os.Args[2:])
+			return
+		case "readiness":
+			runReadiness(os.Args[2:])
+			return
+		case "removal-impact":
+			runRemovalImpact(os.Args[2:])
+			return
+		case "outdated":
+			runOutdated(os.Args[2:])
+			return
+		case "verify-migration":
+			runVerifyMigration(os.Args[2:])
+			return
+		case "index":
+			runIndexCommand(os.Args[2:])
+			return
+		case "version":
+			runVersion(os.Args[2:])
+			return
+		case "update":
+			runUpdate(os.Args[2:])
+			return
+		case "help":
+			printCommands(os.Stdout)
+			return
+		case ModeCheck, ModeCheckAll, ModeDiff:
+			// The other modes check upgrades with the flags of a check
+			mode = os.Args[1]
+			os.Args = append(os.Args[:1:1], os.Args[2:]...)
+		}
+	}
+
+	flag.StringVar(&projectPath, "project-path", "", "Path to your Go project; separate several paths with commas to check each service of a monorepo")
+	flag.IntVar(&policy.CriticalFiles, "critical-files", 50, "Escalate a finding to critical when the symbol is used in at least this many files (0 disables)")
+	flag.IntVar(&policy.CriticalPackages, "critical-packages", 10, "Escalate a finding to critical when the symbol is used in more than this many packages (0 disables)")
+	flag.StringVar(&view, "view", ViewInline, "Text rendering of changed signatures: inline or side-by-side")
+	flag.StringVar(&format, "format", FormatText, "Output format: text, markdown, json or sarif (json reports can be re-rendered with \"report render\")")
+	flag.StringVar(&format, "output-format", FormatText, "Alias of --format")
+	flag.StringVar(&outputFile, "output-file", "", "Write the report to this file instead of stdout, e.g. for a bot to post as a pull request comment")
+	flag.StringVar(&sourceURL, "source-url", "", "Base URL linking files in markdown reports, e.g. https://github.com/org/repo/blob/main (defaults to the commit under GitHub Actions or GitLab CI)")
+	flag.BoolVar(&allowRetracted, "allow-retracted", false, "Warn instead of failing when the new version has been retracted")
+	flag.StringVar(&vcsName, "vcs", VCSAuto, "Version control system of the repositories cloned: auto, git, hg or svn (auto recognizes svn:// URLs and the go-import meta tags of vanity paths)")
+	flag.StringVar(&cacheDir, "cache-dir", defaultCacheDir(), "Directory for cached dependency indexes (empty disables caching)")
+	flag.Int64Var(&cacheMaxMB, "cache-max-mb", 2048, "Maximum cache size in MiB before least recently used entries are evicted (0 means unbounded)")
+	flag.StringVar(&cacheSignKey, "cache-sign-key", "", "Sign the cache entries stored with this Ed25519 private key (see \"cache keygen\")")
+	flag.StringVar(&cacheVerifyKey, "cache-verify-key", "", "Only use cache entries signed with the private key of this Ed25519 public key")
+	flag.BoolVar(&noCache, "no-cache", false, "Neither read nor write the index cache, e.g. to rule it out when debugging")
+	flag.BoolVar(&githubActionsFlag, "github-actions", false, "In GitHub Actions, annotate the usages of each finding with ::error, ::warning or ::notice workflow commands")
+	flag.BoolVar(&githubComment, "github-comment", false, "With --github-actions, post the markdown report as a pull request comment, updating it on reruns (requires GITHUB_TOKEN)")
+	flag.StringVar(&platformList, "platforms", "", "Index the project for each goos/goarch[:tag1+tag2] in this comma separated list and combine the usages, e.g. linux/amd64,windows/amd64,darwin/arm64:cgo")
+	flag.BoolVar(&deep, "deep", false, "Also compare the bodies of used functions between versions and flag large rewrites as behavioral risks (slower)")
+	flag.IntVar(&deepThreshold, "deep-threshold", 50, "With --deep, the percentage of changed body lines from which a function is flagged")
+	flag.BoolVar(&useBazel, "bazel", false, "Report the impact per Bazel target depending on the module, using bazel query")
+	flag.StringVar(&bazelRepo, "bazel-repo", "", "With --bazel, the external repository of the module (defaults to the gazelle name, e.g. com_github_pkg_errors)")
+	flag.StringVar(&packageList, "packages", "", "Only index and report on these project packages, e.g. ./cmd/api/...,./internal/billing/...")
+	flag.StringVar(&deletingList, "deleting-packages", "", "Report findings only used in these project packages, scheduled for deletion, one severity lower, e.g. ./internal/legacy/...")
+	flag.StringVar(&excludeList, "exclude-packages", "", "Don't report usages in these project packages, e.g. ./internal/legacy/...,./tools/...")
+	flag.StringVar(&ignoreSymbolList, "ignore-symbols", "", "Comma separated symbols not to report, as reported or prefixed by their import path, with path.Match wildcards, e.g. github.com/org/sdk/api.Client.Debug*")
+	flag.StringVar(&maxSeverity, "max-severity", "", "Report findings at most at this severity: info, warning, breaking or critical")
+	flag.StringVar(&baselinePath, "baseline", "", "Report the findings listed in this file, written by --write-baseline, as accepted; they don't fail the check")
+	flag.StringVar(&writeBaselinePath, "write-baseline", "", "Write the findings of this run to this baseline file as accepted, keeping the entries of other modules")
+	flag.StringVar(&configPath, "config", "", "Configuration file setting defaults of these flags (defaults to .upgradecheck.yaml in the project or a parent directory up to the repository root)")
+	flag.StringVar(&ignoreDirList, "ignore-dirs", defaultIgnoredDirs, "Comma separated directory names, matched at any depth, whose usages are ignored (empty ignores none)")
+	flag.BoolVar(&verbose, "verbose", false, "Also log the output of git, go and scip-go and how long each phase took")
+	flag.BoolVar(&quiet, "quiet", false, "Only print the report, and the error ending the run if any")
+	flag.StringVar(&logFormat, "log-format", LogText, "Log format on stderr: text, or json for one JSON object per line, e.g. for CI log processors")
+	flag.StringVar(&progressFormat, "progress-format", ProgressText, "Progress output on stderr: text, or ndjson for one JSON event per line (phases, findings, logs)")
+	flag.StringVar(&buildPackage, "build-impact", "", "Build this package (e.g. ./cmd/api) against both versions and report binary size and build time deltas")
+	flag.StringVar(&engineName, "engine", EngineSCIP, "Compare module versions with scip-go indexes (scip) or with the export data the go toolchain compiles (exportdata), which is faster but only compares signatures")
+	flag.StringVar(&backendName, "backend", "", "Build indexes with scip-go (scip) or with go/packages and go/types (gopackages); empty uses scip-go when it is on the PATH and go/packages otherwise")
+	flag.StringVar(&projectIndexList, "project-index", "", "Comma separated pre-built SCIP indexes of the projects, one per --project-path in the same order, to use instead of indexing them")
+	flag.BoolVar(&inMemoryIndexes, "in-memory", false, "Stream generated indexes from scip-go into memory instead of writing them to temp directories")
+	flag.TextVar(&minConfidence, "min-confidence", ConfidenceHeuristic, "Only report findings of at least this confidence: exact, high or heuristic")
+	flag.BoolVar(&groupByOwner, "group-by-owner", false, "Group the text and markdown findings by the CODEOWNERS owners of the files using them")
+	flag.BoolVar(&includePrerelease, "include-prerelease", false, "Also suggest pre-release versions (e.g. v2.0.0-rc.1) when the new version is retracted or excluded")
+	flag.BoolVar(&followReexports, "follow-reexports", false, "Count references to project declarations re-exporting a module symbol (type Client = dep.Client) as usages of that symbol")
+	flag.BoolVar(&commitHints, "commit-hints", false, "Note the dependency's commits between the two versions marked as breaking (\"feat!:\", \"BREAKING CHANGE:\") on the findings they relate to; clones the dependency's repository")
+	flag.BoolVar(&stableAPIFlag, "stable-api", false, "Also report the symbols you use that the new version's API stability files (api/*.txt, as in the Go distribution) don't list, whether or not they changed")
+	flag.Var(&failThreshold, "fail-on", "Exit with status 1 when a finding has at least this severity: info, warning, breaking, critical, or none to only fail on analysis errors (status 2)")
+	flag.Var(envOverrides, "env", "Set KEY=VALUE in the environment of git, scip-go and go subprocesses (repeatable), e.g. --env GOFLAGS=-mod=mod")
+	flag.Var(&vcsHosts, "vcs-hosts", "Comma separated hosts, with path.Match wildcards, that repositories may be cloned from; cloning from others fails, like fetches GOVCS disallows (empty allows any)")
+	flag.Var(&sshHosts, "git-ssh", "Comma separated hosts, e.g. github.com, whose repositories git fetches over SSH instead of https, with your SSH keys or GIT_SSH_COMMAND")
+	flag.Var(gitTokens, "git-token", "HOST=ENVVAR: send the access token in environment variable ENVVAR when git fetches from https://HOST (repeatable), e.g. --git-token github.com=GITHUB_TOKEN")
+	// Flags selecting the upgrade or acting on its report only apply to
+	// single checks
+	if mode == ModeCheck {
+		flag.StringVar(&module, "module", "", "Module path of the dependency you want to check")
+		flag.StringVar(&oldVersion, "old-version", "", "Old version of the dependency (defaults to the version required by the project's go.mod)")
+		flag.StringVar(&newVersion, "new-version", "", "New version of the dependency (defaults to the latest release)")
+		flag.StringVar(&oldRepoURL, "old-repo-url", "", "Repository to fetch the old version from, e.g. your fork (defaults to https://<module>.git)")
+		flag.StringVar(&newRepoURL, "new-repo-url", "", "Repository to fetch the new version from (defaults to https://<module>.git)")
+		flag.StringVar(&githubStatusPrefix, "github-status", "", "Publish commit statuses <prefix>/breaking and <prefix>/risky on GITHUB_SHA, e.g. --github-status=upgrade-check (requires GITHUB_TOKEN)")
+		flag.StringVar(&createIssues, "create-issues", "", "Open or update a tracking issue with the report when the upgrade is breaking: github, gitlab or jira (configured from the CI environment)")
+		flag.StringVar(&issueLabels, "issue-labels", "dependencies,upgrade-check", "Comma separated labels for tracking issues")
+		flag.BoolVar(&checkAllDeps, "all", false, "Same as the check-all command")
+		flag.StringVar(&annotationsPath, "annotations", "", "Write the findings as JSON annotations on the go.mod line requiring the module to this file, for review tools")
+		flag.StringVar(&flamegraphPath, "flamegraph", "", "Write a flamegraph of the project packages weighted by their usages affected by the upgrade to this file: speedscope JSON when it ends in .json, SVG otherwise")
+		flag.StringVar(&recordPath, "record", "", "Save every input of the analysis (indexes, go.mod files, version metadata, project sources) to this tar archive for --replay")
+		flag.StringVar(&oldIndexPath, "old-index", "", "Pre-built SCIP index of the old version of the module to use instead of fetching and indexing it")
+		flag.StringVar(&newIndexPath, "new-index", "", "Pre-built SCIP index of the new version of the module to use instead of fetching and indexing it (--new-version defaults to the version it records)")
+		flag.StringVar(&replayPath, "replay", "", "Rerun the comparison and reporting of a session archive written by --record, without network access or toolchain")
+		flag.DurationVar(&timeout, "timeout", 0, "Stop the check after this long, e.g. 45m, saving the completed phases to --checkpoint for --resume (0 means no limit)")
+		flag.StringVar(&checkpointDir, "checkpoint", ".upgrade-check-checkpoint", "Directory for the checkpoint of a check run with --timeout")
+		flag.BoolVar(&resume, "resume", false, "Continue the check saved in --checkpoint by an earlier run that timed out")
+		flag.Var(&sets, "upgrade-set", "Comma separated module path patterns that must move together, e.g. 'k8s.io/*' (repeatable): checking one member also checks the others at the versions its new version requires, in one report")
+	}
+	if mode == ModeDiff {
+		flag.StringVar(&diff.Base, "base", defaultDiffBase(), "Without go.mod files, the branch whose merge base with HEAD has the old go.mod")
+	}
+	flag.Usage = modeFlagUsage(mode)
+	flag.Parse()
+	if flag.NArg() > 0 && mode != ModeDiff {
+		fatalf("Unexpected argument %q; run go-upgrade-checker help for the commands", flag.Arg(0))
+	}
+	if mode == ModeCheckAll {
+		checkAllDeps = true
+	}
+
+	if configPath == "" {
+		var err error
+		if configPath, err = findConfig(strings.TrimSpace(strings.Split(projectPath, ",")[0])); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	var cfg *fileConfig
+	if configPath != "" {
+		var err error
+		cfg, err = loadConfig(configPath)
+		if err == nil {
+			err = cfg.apply(flag.CommandLine, envOverrides)
+		}
+		if err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	toolEnv = buildToolEnv(os.Environ(), envOverrides)
+	tokens, err := gitTokens.tokens()
+	if err == nil {
+		toolEnv, err = withGitAuth(toolEnv, sshHosts, tokens)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if noCache {
+		cacheDir = ""
+	}
+
+	if err := parseLogFormat(logFormat); err != nil {
+		fatalf("%v", err)
+	}
+	switch {
+	case verbose && quiet:
+		fatalf("--verbose and --quiet are mutually exclusive")
+	case verbose:
+		logLevel.Set(slog.LevelDebug)
+	case quiet:
+		logLevel.Set(slog.LevelError)
+	}
+	switch progressFormat {
+	case ProgressText:
+		setupLogging(os.Stderr, logFormat, logFormat == LogText)
+	case ProgressNDJSON:
+		enableProgress(os.Stderr)
+		setupLogging(&lineWriter{eventType: "log"}, logFormat, false)
+	default:
+		fatalf("Unknown progress format %q: must be %s or %s", progressFormat, ProgressText, ProgressNDJSON)
+	}
+	if moduleEngine, err = parseEngine(engineName); err != nil {
+		fatalf("%v", err)
+	}
+	if indexBackend, err = parseBackend(backendName); err != nil {
+		fatalf("%v", err)
+	}
+	if githubActionsFlag {
+		actionsMode = newGitHubActions(githubComment)
+	} else if githubComment {
+		fatalf("--github-comment needs --github-actions")
+	}
+	if outputFile != "" {
+		// Everything rendering reports writes to stdout
+		f, err := os.Create(outputFile)
+		if err != nil {
+			fatalf("Failed to create output file: %v", err)
+		}
+		os.Stdout = f
+	}
+	partial.output(format, view, groupByOwner)
+
+	if mode == ModeDiff {
+		switch flag.NArg() {
+		case 0:
+		case 2:
+			diff.OldGoMod, diff.NewGoMod = flag.Arg(0), flag.Arg(1)
+		default:
+			fatalf("usage: go-upgrade-checker diff [flags] [old/go.mod new/go.mod]")
+		}
+		if !validFormat(format) {
+			fatalf("Unknown format %q: must be one of %s", format, formatNames())
+		}
+		batch, err := checkGoModDiff(diff, projectPath, batchArgs(flag.CommandLine, envOverrides))
+		if err != nil {
+			fatalf("%v", err)
+		}
+		saveBaseline(writeBaselinePath, batch.Modules...)
+		finishBatch(batch, format, view, groupByOwner, failThreshold)
+		return
+	}
+
+	if checkAllDeps {
+		if module != "" || replayPath != "" {
+			fatalf("check-all checks every dependency and can't be combined with --module or --replay")
+		}
+		if timeout > 0 || resume {
+			fatalf("--timeout and --resume can't be combined with check-all")
+		}
+		if !validFormat(format) {
+			fatalf("Unknown format %q: must be one of %s", format, formatNames())
+		}
+		batch, err := checkAll(projectPath, includePrerelease, batchArgs(flag.CommandLine, envOverrides))
+		if err != nil {
+			fatalf("%v", err)
+		}
+		saveBaseline(writeBaselinePath, batch.Modules...)
+		finishBatch(batch, format, view, groupByOwner, failThreshold)
+		return
+	}
+
+	// Upgrades of a module in an upgrade set are checked with those of the
+	// other members it moves along
+	if set := sets.match(module); set != nil && module != "" && replayPath == "" {
+		if timeout > 0 || resume {
+			fatalf("--timeout and --resume can't be combined with --upgrade-set")
+		}
+		if oldIndexPath != "" || newIndexPath != "" {
+			fatalf("--old-index and --new-index can't be combined with --upgrade-set")
+		}
+		if !validFormat(format) {
+			fatalf("Unknown format %q: must be one of %s", format, formatNames())
+		}
+		batch, err := checkUpgradeSet(projectPath, module, oldVersion, newVersion, includePrerelease, set, batchArgs(flag.CommandLine, envOverrides))
+		if err != nil {
+			fatalf("%v", err)
+		}
+		saveBaseline(writeBaselinePath, batch.Modules...)
+		finishBatch(batch, format, view, groupByOwner, failThreshold)
+		return
+	}
+
+	if timeout > 0 {
+		timeBox(timeout)
+	}
+
+	platforms, err := parsePlatforms(platformList)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	scope, err := parsePackageScope(packageList)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	policy.Deleting, err = parsePackageScope(deletingList)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	ignored, err := parseIgnoredDirs(ignoreDirList)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	excluded, err := parsePackageScope(excludeList)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	ignoredSymbols, err := parseSymbolPatterns(ignoreSymbolList)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if sev, ok := cfg.severityFor(module); ok && maxSeverity == "" {
+		maxSeverity = sev.String()
+	}
+	if maxSeverity != "" {
+		policy.MaxSeverity = new(Severity)
+		if err := policy.MaxSeverity.UnmarshalText([]byte(maxSeverity)); err != nil {
+			fatalf("Invalid --max-severity: %v", err)
+		}
+	}
+
+	if view != ViewInline && view != ViewSideBySide {
+		fatalf("Unknown view %q: must be %s or %s", view, ViewInline, ViewSideBySide)
+	}
+	if !validFormat(format) {
+		fatalf("Unknown format %q: must be one of %s", format, formatNames())
+	}
+
+	// Issue trackers are configured up front so a missing token fails fast
+	var tracker issueTracker
+	if createIssues != "" {
+		tracker, err = newIssueTracker(createIssues)
+		if err != nil {
+			fatalf("Failed to configure issue tracker: %v", err)
+		}
+	}
+
+	shutdownTracing, err := setupTracing(runContext)
+	if err != nil {
+		fatalf("Failed to set up tracing: %v", err)
+	}
+	flushTelemetry = func() { shutdownTracing(context.Background()) }
+	defer flushTelemetry()
+
+	report, err := runCheck(runContext, checkOptions{
+		projectPath:       projectPath,
+		module:            module,
+		oldVersion:        oldVersion,
+		newVersion:        newVersion,
+		policy:            policy,
+		allowRetracted:    allowRetracted,
+		includePrerelease: includePrerelease,
+		cacheDir:          cacheDir,
+		cacheMaxMB:        cacheMaxMB,
+		cacheSignKey:      cacheSignKey,
+		cacheVerifyKey:    cacheVerifyKey,
+		oldRepoURL:        oldRepoURL,
+		newRepoURL:        newRepoURL,
+		vcs:               vcsName,
+		platforms:         platforms,
+		scope:             scope,
+		ignored:           ignored,
+		excluded:          excluded,
+		vcsHosts:          vcsHosts,
+		ignoredSymbols:    ignoredSymbols,
+		minConfidence:     minConfidence,
+		deep:              deep,
+		deepThreshold:     deepThreshold,
+		useBazel:          useBazel,
+		bazelRepo:         bazelRepo,
+		buildPackage:      buildPackage,
+		followReexports:   followReexports,
+		commitHints:       commitHints,
+		stableAPI:         stableAPIFlag,
+		recordPath:        recordPath,
+		replayPath:        replayPath,
+		projectIndexes:    projectIndexList,
+		oldIndex:          oldIndexPath,
+		newIndex:          newIndexPath,
+		checkpoint:        timeout > 0 || resume,
+		checkpointDir:     checkpointDir,
+		resume:            resume,
+	})
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if baselinePath != "" {
+		accepted, err := readBaseline(baselinePath)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		accepted.accept(report)
+	}
+	partial.finish()
+	saveBaseline(writeBaselinePath, report)
+
+	if err := renderReport(report, format, view, groupByOwner); err != nil {
+		fatalf("%v", err)
+	}
+	if err := appendJobSummary(report); err != nil {
+		log.Printf("Warning: could not write the job summary: %v", err)
+	}
+	if err := actionsMode.publish(report); err != nil {
+		fatalf("%v", err)
+	}
+
+	if annotationsPath != "" {
+		annotations, err := goModAnnotations(report.Services, report.Module)
+		if err == nil {
+			err = writeGoModAnnotations(annotationsPath, annotations)
+		}
+		if err != nil {
+			fatalf("Failed to write go.mod annotations: %v", err)
+		}
+	}
+
+	if flamegraphPath != "" {
+		if err := writeFlamegraph(flamegraphPath, report); err != nil {
+			fatalf("Failed to write the flamegraph: %v", err)
+		}
+	}
+
+	if tracker != nil {
+		if issue := breakingIssue(report, parseLabels(issueLabels)); issue != nil {
+			url, created, err := fileTrackingIssue(tracker, *issue)
+			if err != nil {
+				fatalf("Failed to file tracking issue: %v", err)
+			}
+			if created {
+				log.Printf("Opened tracking issue %s", url)
+			} else {
+				log.Printf("Updated tracking issue %s", url)
+			}
+		}
+	}
+
+	if githubStatusPrefix != "" {
+		repo, err := githubRepoFromEnv()
+		if err == nil {
+			err = repo.publishStatuses(severityStatuses(githubStatusPrefix, report.Module, report.NewVersion, report.Services))
+		}
+		if err != nil {
+			fatalf("Failed to publish GitHub commit statuses: %v", err)
+		}
+	}
+
+	runCheckpoint.Remove()
+
+	if failThreshold.fails(report) {
+		exit(ExitFindings)
+	}
+}
+
+// checkOptions configures runCheck, from the flags of the command line or the
+// Options of Check
+type checkOptions struct {
+	// projectPath separates the paths of several services with commas
+	projectPath       string
+	module            string
+	oldVersion        string
+	newVersion        string
+	policy            Policy
+	allowRetracted    bool
+	includePrerelease bool
+	cacheDir          string
+	cacheMaxMB        int64
+	// cacheSignKey and cacheVerifyKey are the paths of the keys signing and
+	// verifying cache entries
+	cacheSignKey   string
+	cacheVerifyKey string
+	oldRepoURL     string
+	newRepoURL     string
+	// vcs is the version control system of the repositories, detected when
+	// empty or auto
+	vcs string
+	// vcsHosts are the hosts repositories may be cloned from, any when empty
+	vcsHosts  []string
+	platforms []platform
+	scope     packageScope
+	ignored   ignoredDirs
+	// excluded are project packages whose usages aren't reported, and
+	// ignoredSymbols the path.Match patterns of symbols that aren't
+	excluded        packageScope
+	ignoredSymbols  []string
+	minConfidence   Confidence
+	deep            bool
+	deepThreshold   int
+	useBazel        bool
+	bazelRepo       string
+	buildPackage    string
+	followReexports bool
+	commitHints     bool
+	stableAPI       bool
+	recordPath      string
+	replayPath      string
+	// projectIndexes separates pre-built indexes of the projects with commas,
+	// and oldIndex and newIndex are pre-built indexes of the module versions
+	projectIndexes string
+	oldIndex       string
+	newIndex       string
+	// checkpoint saves the completed phases to checkpointDir, or continues
+	// from them with resume
+	checkpoint    bool
+	checkpointDir string
+	resume        bool
+}
+
+// runCheck compares the two versions of the module and returns the findings
+// of each service. The versions default to the one the projects' go.mod
+// requires and the latest release.
+func runCheck(ctx context.Context, opts checkOptions) (*Report, error) {
+	projectPath, module, oldVersion, newVersion := opts.projectPath, opts.module, opts.oldVersion, opts.newVersion
+	policy, platforms, scope, ignored := opts.policy, opts.platforms, opts.scope, opts.ignored
+	deep, useBazel, bazelRepo, buildPackage, commitHints := opts.deep, opts.useBazel, opts.bazelRepo, opts.buildPackage, opts.commitHints
+	checkStableAPI := opts.stableAPI
+	oldRepoURL, newRepoURL := opts.oldRepoURL, opts.newRepoURL
+	var err error
+
+	// Replays take the module and versions from the session
+	var replayed *replayedSession
+	if opts.replayPath != "" {
+		if opts.recordPath != "" {
+			return nil, errors.New("--record and --replay can't be combined")
+		}
+		if opts.checkpoint {
+			return nil, errors.New("--timeout and --resume can't be combined with --replay")
+		}
+		if opts.projectIndexes != "" || opts.oldIndex != "" || opts.newIndex != "" {
+			return nil, errors.New("--project-index, --old-index and --new-index can't be combined with --replay")
+		}
+		replayed, err = loadSession(opts.replayPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session: %w", err)
+		}
+		defer replayed.Close()
+		module, oldVersion, newVersion = replayed.Module, replayed.OldVersion, replayed.NewVersion
+		if deep || useBazel || buildPackage != "" || commitHints || checkStableAPI {
+			log.Printf("Warning: --deep, --bazel, --build-impact, --commit-hints and --stable-api need the repositories and toolchain and are ignored when replaying")
+			deep, useBazel, buildPackage, commitHints, checkStableAPI = false, false, "", false, false
+		}
+	}
+
+	// Without explicit versions, the project's own go.mod says what it builds
+	// against and the proxy what it could upgrade to
+	if replayed == nil && oldVersion == "" {
+		for _, path := range strings.Split(projectPath, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			pinned, err := pinnedVersion(path, module)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the version of %s from %s: %w; pass --old-version", module, path, err)
+			}
+			if oldVersion != "" && pinned != oldVersion {
+				return nil, fmt.Errorf("projects build against different versions of %s (%s and %s); pass --old-version", module, oldVersion, pinned)
+			}
+			oldVersion = pinned
+		}
+		log.Printf("Checking upgrade from %s, the version in go.mod", oldVersion)
+	}
+	// A pre-built index of the new version may say which one it is
+	if replayed == nil && newVersion == "" && opts.newIndex != "" {
+		if newVersion, err = indexedVersion(opts.newIndex, module); err != nil {
+			return nil, fmt.Errorf("invalid --new-index: %w", err)
+		}
+		log.Printf("Checking upgrade to %s, the version --new-index indexes", newVersion)
+	}
+	if replayed == nil && newVersion == "" {
+		newVersion, err = latestRelease(module, opts.includePrerelease)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the latest version of %s: %w; pass --new-version", module, err)
+		}
+		log.Printf("Checking upgrade to %s, the latest version", newVersion)
+		if next, latest, ok := newerMajorVersion(modulePathAt(module, newVersion), opts.includePrerelease); ok {
+			log.Printf("Note: %s is also available as the new major version %s; pass --new-version %s to check the upgrade to it", module, next, latest)
+		}
+	}
+
+	// The versions of an upgrade across major versions are published under
+	// different module paths, e.g. example.com/mod and example.com/mod/v2, and
+	// the path given may be either. Moving off a +incompatible version can
+	// move the module to a /vN path, too.
+	newModule := upgradedModulePath(module, oldVersion, newVersion)
+	if replayed == nil {
+		module, newModule, newVersion = majorVersionPaths(module, oldVersion, newVersion)
+	} else {
+		module = modulePathAt(module, oldVersion)
+	}
+
+	ctx, checkSpan := startSpan(ctx, "check",
+		attribute.String("module", module),
+		attribute.String("old_version", oldVersion),
+		attribute.String("new_version", newVersion),
+	)
+	defer checkSpan.End()
+
+	if newModule != module {
+		log.Printf("Warning: %s is published as module %s; every import of %s has to change along with the upgrade", newVersion, newModule, module)
+		if buildPackage != "" {
+			log.Printf("Warning: --build-impact needs both versions under the same module path and is skipped")
+			buildPackage = ""
+		}
+	}
+
+	var retracted *retraction
+	if replayed != nil {
+		retracted = replayed.Retracted
+	} else {
+		retracted, err = checkRetracted(newModule, newVersion, opts.includePrerelease)
+		if err != nil {
+			log.Printf("Warning: could not check whether %s@%s is retracted: %v", newModule, newVersion, err)
+		}
+	}
+	if retracted != nil {
+		msg := fmt.Sprintf("%s@%s has been retracted by the module author", newModule, retracted.Version)
+		if retracted.Rationale != "" {
+			msg += ": " + retracted.Rationale
+		}
+		if retracted.Suggested != "" {
+			msg += fmt.Sprintf(" (nearest non-retracted version: %s)", retracted.Suggested)
+		}
+		if !opts.allowRetracted {
+			return nil, fmt.Errorf("%s. Pass --allow-retracted to check it anyway.", msg)
+		}
+		log.Printf("WARNING: %s", msg)
+	}
+
+	var services []*serviceReport
+	if replayed != nil {
+		for i, s := range replayed.Services {
+			services = append(services, &serviceReport{
+				Name:      s.Name,
+				Path:      s.Path,
+				indexPath: replayed.path(sessionServiceIndex(i)),
+				dir:       replayed.path(sessionServiceSrc(i)),
+			})
+		}
+	} else {
+		for _, path := range strings.Split(projectPath, ",") {
+			path = strings.TrimSpace(path)
+			if path != "" {
+				services = append(services, &serviceReport{Name: filepath.Base(path), Path: path, dir: path})
+			}
+		}
+	}
+
+	for _, service := range services {
+		excluded, err := checkExcluded(service.dir, newModule, newVersion, opts.includePrerelease)
+		if err != nil {
+			log.Printf("Warning: could not check exclude directives of %s: %v", service.Path, err)
+		}
+		if excluded != nil {
+			msg := fmt.Sprintf("%s@%s is excluded by %s, so the build would never select it", newModule, excluded.Version, excluded.GoMod)
+			if excluded.Suggested != "" {
+				msg += fmt.Sprintf(" (next non-excluded version: %s)", excluded.Suggested)
+			}
+			return nil, fmt.Errorf("%s. Remove the exclude directive or pick another --new-version.", msg)
+		}
+	}
+
+	if opts.checkpoint {
+		var projects []string
+		for _, service := range services {
+			projects = append(projects, service.Path)
+		}
+		runCheckpoint, err = openCheckpoint(opts.checkpointDir, opts.resume, module, oldVersion, newVersion, projects)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	projectIndexes, err := parseProjectIndexes(opts.projectIndexes, projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if projectIndexes != nil && (len(platforms) > 0 || len(scope) > 0) {
+		log.Printf("Warning: --platforms and --packages are ignored for projects indexed with --project-index")
+	}
+	for i, service := range services {
+		if projectIndexes != nil {
+			service.indexPath = projectIndexes[i]
+			if err := checkProjectIndex(service.indexPath, service.dir, module, oldVersion); err != nil {
+				return nil, err
+			}
+		} else if replayed == nil {
+			service.indexPath = runCheckpoint.projectIndex(service.Path)
+		}
+		if replayed == nil && service.indexPath == "" {
+			_, span := startSpan(ctx, "index project", attribute.String("project", service.Path))
+			service.indexPath, err = generateProjectIndex(service.Path, platforms, scope)
+			endSpan(span, err)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate SCIP index for %s: %w", service.Path, err)
+			}
+			defer releaseIndex(service.indexPath)
+			if err := runCheckpoint.saveProjectIndex(service.Path, service.indexPath); err != nil {
+				return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+			}
+			runCheckpoint.checkDeadline()
+		}
+
+		siblings, err := siblingModules(service.indexPath, module)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		for sibling, loc := range siblings {
+			log.Printf("Warning: %s uses %s (e.g. at %s), a separate module of the same repository; it needs its own version bump and is not covered by this check", service.Path, sibling, loc)
+		}
+	}
+
+	var cache *indexCache
+	if opts.cacheDir != "" {
+		cache = &indexCache{Dir: opts.cacheDir, MaxBytes: opts.cacheMaxMB << 20}
+		if opts.cacheSignKey != "" {
+			if cache.SignKey, err = loadSigningKey(opts.cacheSignKey); err != nil {
+				return nil, err
+			}
+		}
+		if opts.cacheVerifyKey != "" {
+			if cache.VerifyKey, err = loadVerifyKey(opts.cacheVerifyKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Versions missing from the cache are downloaded from the module proxy, or
+	// cloned when they can't be. The two versions may come from different
+	// repositories, e.g. a fork and upstream.
+	if oldRepoURL == "" {
+		oldRepoURL = defaultRepoURL(module)
+	}
+	if newRepoURL == "" {
+		// gopkg.in serves each major version from a repository of its own
+		newRepoURL = defaultRepoURL(newModule)
+	}
+	var backend vcs
+	if opts.vcs != "" && opts.vcs != VCSAuto {
+		if backend, err = newVCS(opts.vcs); err != nil {
+			return nil, err
+		}
+	}
+	oldRepo := &moduleRepo{URL: oldRepoURL, VCS: backend, AllowedHosts: opts.vcsHosts}
+	defer oldRepo.Close()
+	newRepo := oldRepo
+	if newRepoURL != oldRepoURL {
+		newRepo = &moduleRepo{URL: newRepoURL, VCS: backend, AllowedHosts: opts.vcsHosts}
+		defer newRepo.Close()
+	}
+
+	var oldModuleIndexPath, newModuleIndexPath string
+	var newGoMod []byte
+	if replayed != nil {
+		oldModuleIndexPath = replayed.path(sessionModuleIndex("old"))
+		newModuleIndexPath = replayed.path(sessionModuleIndex("new"))
+		newGoMod = replayed.NewGoMod
+	} else {
+		var cleanupOld, cleanupNew func()
+		if opts.oldIndex != "" {
+			if err := validateModuleIndex("--old-index", opts.oldIndex, module, oldVersion); err != nil {
+				return nil, err
+			}
+			oldModuleIndexPath = opts.oldIndex
+		} else if oldModuleIndexPath = runCheckpoint.moduleIndex("old"); oldModuleIndexPath == "" {
+			oldModuleIndexPath, _, cleanupOld, err = indexModuleVersion(ctx, cache, oldRepo, module, oldVersion)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate index for old version: %w", err)
+			}
+			defer cleanupOld()
+			if err := runCheckpoint.saveModuleIndex("old", oldModuleIndexPath, nil); err != nil {
+				return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+			}
+			runCheckpoint.checkDeadline()
+		}
+
+		if opts.newIndex != "" {
+			if err := validateModuleIndex("--new-index", opts.newIndex, newModule, newVersion); err != nil {
+				return nil, err
+			}
+			newModuleIndexPath = opts.newIndex
+			// The go.mod, which deprecations are read from, isn't in the index
+			if fromProxy(newModule, newVersion) {
+				if newGoMod, err = fetchGoMod(newModule, newVersion); err != nil {
+					log.Printf("Warning: could not fetch the go.mod of %s@%s: %v", newModule, newVersion, err)
+				}
+			}
+		} else if newModuleIndexPath = runCheckpoint.moduleIndex("new"); newModuleIndexPath != "" {
+			newGoMod = runCheckpoint.NewGoMod
+		} else {
+			newModuleIndexPath, newGoMod, cleanupNew, err = indexModuleVersion(ctx, cache, newRepo, newModule, newVersion)
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate index for new version: %w", err)
+			}
+			defer cleanupNew()
+			if err := runCheckpoint.saveModuleIndex("new", newModuleIndexPath, newGoMod); err != nil {
+				return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+			}
+			runCheckpoint.checkDeadline()
+		}
+	}
+
+	if opts.recordPath != "" {
+		recorded := &session{Module: module, OldVersion: oldVersion, NewVersion: newVersion, Retracted: retracted}
+		for _, service := range services {
+			recorded.Services = append(recorded.Services, sessionService{Name: service.Name, Path: service.Path})
+		}
+		if err := recordSession(opts.recordPath, recorded, services, oldModuleIndexPath, newModuleIndexPath, newGoMod); err != nil {
+			return nil, fmt.Errorf("failed to record session: %w", err)
+		}
+	}
+
+	deprecated, err := moduleDeprecation(newGoMod)
+	if err != nil {
+		log.Printf("Warning: could not check whether %s@%s is deprecated: %v", newModule, newVersion, err)
+	}
+
+	indexes, err := loadModuleIndexes(oldModuleIndexPath, newModuleIndexPath)
+	if err != nil {
+		return nil, err
+	}
+	oldSymbols, newSymbols, definedIn := indexes.oldSymbols, indexes.newSymbols, indexes.definedIn
+	// Packages of the module scip-go couldn't index
+	moduleGaps := append(withVersion(gapsOf(oldModuleIndexPath), oldVersion), withVersion(gapsOf(newModuleIndexPath), newVersion)...)
+	sortGaps(moduleGaps)
+
+	// Deep mode and generated protobuf code read the module sources, which the
+	// index cache doesn't cover, so they are downloaded or cloned on first use
+	var oldSource, newSource *versionSource
+	var newDefinedIn map[string]string
+	readSources := sync.OnceValue(func() error {
+		oldSrc, err := newVersionSource(ctx, oldRepo, module, oldVersion)
+		if err != nil {
+			return fmt.Errorf("old version: %w", err)
+		}
+		newSrc, err := newVersionSource(ctx, newRepo, newModule, newVersion)
+		if err != nil {
+			return fmt.Errorf("new version: %w", err)
+		}
+		oldSource, newSource = oldSrc, newSrc
+		newDefinedIn, err = definingDocuments(newModuleIndexPath)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return nil
+	})
+	// Environment variables the old version reads and the new one doesn't
+	var oldEnv, newEnv map[string]string
+	droppedEnv := make(map[string]bool)
+	if deep {
+		if err := readSources(); err != nil {
+			return nil, fmt.Errorf("failed to read sources of %w", err)
+		}
+		oldEnv, err = envReads(oldSource, sourceFiles(definedIn))
+		if err == nil {
+			newEnv, err = envReads(newSource, sourceFiles(newDefinedIn))
+		}
+		if err != nil {
+			log.Printf("Warning: could not read the environment variables %s reads: %v", module, err)
+		}
+		for name := range oldEnv {
+			if _, ok := newEnv[name]; !ok {
+				droppedEnv[name] = true
+			}
+		}
+	}
+
+	var stable stableAPI
+	if checkStableAPI {
+		if err := readSources(); err != nil {
+			return nil, fmt.Errorf("failed to read sources of %w", err)
+		}
+		var found bool
+		stable, found, err = readStableAPI(newSource)
+		if err != nil {
+			log.Printf("Warning: could not read the stable API of %s@%s: %v", newModule, newVersion, err)
+		} else if !found {
+			log.Printf("Warning: %s@%s has no %s declaring its stable API; --stable-api reports nothing", newModule, newVersion, stableAPIFiles)
+		}
+	}
+
+	var commits []breakingCommit
+	if commitHints {
+		commits, err = breakingCommits(ctx, newRepo, module, oldVersion, newModule, newVersion)
+		if err != nil {
+			log.Printf("Warning: could not read the commits between %s and %s: %v", oldVersion, newVersion, err)
+		}
+	}
+
+	// The services analyzed are the partial results of the run, reported if
+	// it is interrupted
+	analyzed := &Report{Module: module, OldVersion: oldVersion, NewVersion: newVersion, Deprecated: deprecated, Unanalyzed: moduleGaps, Services: []*serviceReport{}}
+	partial.update(func() { partial.report, partial.total = analyzed, len(services) })
+	addAnalyzed := func(service *serviceReport) {
+		partial.update(func() { analyzed.Services = append(analyzed.Services, service) })
+	}
+
+	for i, service := range services {
+		progress.emit(progressEvent{Type: "progress", Phase: "analyze", Service: service.Name, Current: i + 1, Total: len(services)})
+		if done := runCheckpoint.analyzed(service.Path); done != nil {
+			service.Findings, service.UsedSymbols, service.Targets, service.Build = done.Findings, done.UsedSymbols, done.Targets, done.Build
+			service.Unanalyzed = done.Unanalyzed
+			addAnalyzed(service)
+			continue
+		}
+		_, span := startSpan(ctx, "analyze", attribute.String("project", service.Path))
+		service.Unanalyzed = gapsOf(service.indexPath)
+
+		aliased, err := aliasedUsages(service.dir, module)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		asserted, err := assertedTypes(service.dir, module)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		providers, err := diProviders(service.dir, module)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		serialized, err := serializedTypes(service.dir, module)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		var reexported usageSites
+		if opts.followReexports {
+			reexported, err = reexportUsages(service.dir, service.indexPath, module)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		// Types only named in assertions count as used even without method
+		// calls, as do symbols used through a facade re-exporting them
+		extra := make(usageSites)
+		for _, sites := range []usageSites{aliased, asserted, reexported} {
+			for name, locs := range sites {
+				for _, loc := range locs {
+					extra.add(name, loc)
+				}
+			}
+		}
+
+		usedSymbols, findings, err := indexes.symbolFindings(service.indexPath, oldModuleIndexPath, module, extra, ignored)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find used symbols in %s: %w", service.Path, err)
+		}
+
+		for name := range usedSymbols {
+			service.UsedSymbols = append(service.UsedSymbols, name)
+		}
+		service.UsedSymbols = canonicalSymbols(service.UsedSymbols, indexes.packages)
+
+		service.Findings = findings
+		annotateAssertions(service.Findings, asserted)
+		validateCallSites(service.dir, service.Findings, diLocations(providers))
+		annotateGenerics(service.dir, service.Findings)
+		annotateDI(service.Findings, providers)
+		annotateConstValues(service.Findings, newSymbols, serialized)
+
+		members, memberFindings := indexes.memberFindings(service.indexPath, module, ignored)
+		service.Findings = append(service.Findings, memberFindings...)
+		assigned, err := assignedVars(service.dir, module)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		service.Findings = append(service.Findings, flagFindings(assigned, indexes)...)
+		if stable != nil {
+			unstable, err := stableAPIFindings(service.indexPath, module, newVersion, stable, ignored)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			service.Findings = append(service.Findings, unstable...)
+		}
+		if deep {
+			rewritten, err := bodyChangeFindings(members, oldSource, newSource, definedIn, newDefinedIn, service.Findings, float64(opts.deepThreshold)/100)
+			if err != nil {
+				log.Printf("Warning: function body comparison incomplete: %v", err)
+			}
+			service.Findings = append(service.Findings, rewritten...)
+			values, err := varValueFindings(members, oldSymbols, oldSource, newSource, definedIn, newDefinedIn, service.Findings)
+			if err != nil {
+				log.Printf("Warning: variable comparison incomplete: %v", err)
+			}
+			service.Findings = append(service.Findings, values...)
+			settings, err := envSettings(service.dir, droppedEnv, ignored)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			service.Findings = append(service.Findings, envFindings(oldEnv, newEnv, settings)...)
+		}
+		service.Findings = opts.excluded.exclude(scope.filter(service.Findings))
+		annotateGenerated(service.Findings, definedIn)
+		if isProtoGenerated(service.Findings, definedIn) {
+			// Field numbers are only known from the sources, which replays lack
+			if replayed == nil {
+				if err := readSources(); err != nil {
+					log.Printf("Warning: could not read generated protobuf code of %v", err)
+				}
+			}
+			annotateProto(service.Findings, &protoSources{old: oldSource, new: newSource, oldDefinedIn: definedIn, newDefined: newDefinedIn})
+		}
+		annotateCommits(service.Findings, commits, definedIn)
+		// Findings are classified as graded, so the policy lowering a breaking
+		// change doesn't list it among the compatible ones
+		classifyFindings(service.Findings)
+		policy.Apply(service.Findings)
+		service.Findings = filterConfidence(service.Findings, opts.minConfidence)
+
+		owners, err := loadCodeowners(service.dir)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		annotateOwners(service.Findings, owners)
+		canonicalFindings(service.Findings, indexes.packages)
+		service.Findings = ignoreSymbols(service.Findings, opts.ignoredSymbols)
+
+		if useBazel {
+			if bazelRepo == "" {
+				bazelRepo = bazelRepoName(module)
+			}
+			service.Targets, err = bazelTargetImpact(service.dir, bazelRepo, service.Findings)
+			if err != nil {
+				log.Printf("Warning: could not determine Bazel targets of %s: %v", service.Path, err)
+			}
+		}
+
+		for j := range service.Findings {
+			progress.emit(progressEvent{Type: "finding", Service: service.Name, Finding: &service.Findings[j]})
+		}
+		span.SetAttributes(attribute.Int("findings", len(service.Findings)))
+		span.End()
+
+		if buildPackage != "" {
+			_, buildSpan := startSpan(ctx, "build impact", attribute.String("project", service.Path))
+			service.Build, err = measureBuildImpact(service.dir, buildPackage, module, oldVersion, newVersion)
+			endSpan(buildSpan, err)
+			if err != nil {
+				log.Printf("Warning: could not measure build impact for %s: %v", service.Path, err)
+			}
+		}
+
+		if err := runCheckpoint.saveAnalyzed(service); err != nil {
+			return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+		addAnalyzed(service)
+		runCheckpoint.checkDeadline()
+	}
+
+	report := &Report{
+		Module:     module,
+		OldVersion: oldVersion,
+		NewVersion: newVersion,
+		Deprecated: deprecated,
+		Unanalyzed: moduleGaps,
+		Services:   services,
+	}
+
+	return report, nil
+}
+
+// defaultRepoURL guesses the git repository of a module from its path
+func defaultRepoURL(module string) string {
+	return fmt.Sprintf("https://%s.git", repoRoot(module))
+}
+
+// moduleRepo is where the dependency's versions come from: the module proxy
+// zips of its versions, or a clone of its repository, created on first use
+type moduleRepo struct {
+	URL string
+	// VCS is the system of the repository, detected on cloning when nil
+	VCS vcs
+	// Remote is where the repository is cloned from, and Root the import
+	// path of its root if known; both are resolved from URL on cloning
+	Remote string
+	Root   string
+	Dir    string
+	// downloads are the extracted proxy zips by module@version, "" for versions
+	// that failed to download, and sums the go.sum hashes of the zips
+	downloads map[string]string
+	sums      map[string]string
+	// AllowedHosts are the path.Match patterns of the hosts the repository
+	// may be cloned from; empty allows any host GOVCS does
+	AllowedHosts []string
+}
+
+// download extracts module@version from its module proxy zip unless already
+// done, which is the authoritative source of what the go command installs. It
+// returns "" when the version has to be cloned instead: it comes from an
+// explicitly given repository such as a fork, can't be served by the proxy,
+// or failed to download.
+func (r *moduleRepo) download(ctx context.Context, modulePath, version string) string {
+	if r.URL != defaultRepoURL(modulePath) || !fromProxy(modulePath, version) {
+		return ""
+	}
+	key := modulePath + "@" + version
+	if dir, ok := r.downloads[key]; ok {
+		return dir
+	}
+	dir, sum, err := downloadModule(ctx, modulePath, version)
+	if err != nil {
+		log.Printf("Warning: %v; cloning %s instead", err, r.URL)
+	}
+	if r.downloads == nil {
+		r.downloads = make(map[string]string)
+		r.sums = make(map[string]string)
+	}
+	r.downloads[key], r.sums[key] = dir, sum
+	return dir
+}
+
+// clone clones the repository into a temp directory unless already done
+func (r *moduleRepo) clone(ctx context.Context) (err error) {
+	if r.Dir != "" {
+		return nil
+	}
+
+	_, span := startSpan(ctx, "clone", attribute.String("url", r.URL))
+	defer func() { endSpan(span, err) }()
+
+	r.resolve(ctx)
+	if err := r.checkOrigin(); err != nil {
+		return err
+	}
+
+	dir, err := cleanups.tempDir("", "repo-clone-*")
+	if err != nil {
+		return err
+	}
+	if err := r.VCS.clone(r.Remote, dir); err != nil {
+		cleanups.remove(dir)
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+
+	r.Dir = dir
+	return nil
+}
+
+// Close removes the clone and downloads, if any were made
+func (r *moduleRepo) Close() {
+	cleanups.remove(r.Dir)
+	for _, dir := range r.downloads {
+		cleanups.remove(dir)
+	}
+}
+
+// indexModuleVersion returns the index of module@version along with its go.mod,
+// which is nil when the module has none. Tagged versions are served from and
+// stored in the cache; the returned cleanup releases a temporary index, or
+// the lease keeping its cache entry from being evicted.
+func indexModuleVersion(ctx context.Context, cache *indexCache, repo *moduleRepo, module, version string) (_ string, _ []byte, _ func(), err error) {
+	ctx, span := startSpan(ctx, "index module version",
+		attribute.String("module", module),
+		attribute.String("version", version),
+	)
+	defer func() { endSpan(span, err) }()
+
+	// Branches and other moving refs are never cached. Forks can reuse upstream
+	// tag names for different code, so their entries are keyed by repository too.
+	key := module + "@" + version
+	if repo.URL != defaultRepoURL(module) {
+		key += " from " + repo.URL
+	}
+	cacheable := cache != nil && semver.IsValid(version)
+	// Indexes of another scip-go version may record symbols differently
+	if cacheable {
+		key += " by " + moduleIndexer()
+	}
+	// The entry stays leased, and so isn't evicted, until the index is
+	// released
+	cached := func(dir string, release func()) (string, []byte, func(), error) {
+		span.SetAttributes(attribute.Bool("cache_hit", true))
+		goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil && !os.IsNotExist(err) {
+			release()
+			return "", nil, nil, fmt.Errorf("failed to read cached go.mod: %w", err)
+		}
+		indexPath := filepath.Join(dir, "index.scip")
+		if err := readGaps(dir, indexPath); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return indexPath, goMod, release, nil
+	}
+	if cacheable {
+		if dir, release, ok := cache.Get(key); ok {
+			return cached(dir, release)
+		}
+		// Only one run sharing the cache builds a missing entry; the others
+		// wait for it and use the stored result
+		unlock, waited, err := cache.LockKey(key)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			defer unlock()
+			if waited {
+				if dir, release, ok := cache.Get(key); ok {
+					return cached(dir, release)
+				}
+			}
+		}
+	}
+
+	var indexPath, moduleDir string
+	var provenance *cacheProvenance
+	if moduleDir = repo.download(ctx, module, version); moduleDir != "" {
+		provenance = &cacheProvenance{Source: proxyURL(), SourceHash: repo.sums[module+"@"+version]}
+		indexPath, err = indexModuleDir(moduleDir, moduleDir, module)
+	} else {
+		if err := repo.clone(ctx); err != nil {
+			return "", nil, nil, err
+		}
+		var rev, subdir string
+		rev, subdir, err = moduleCheckout(repo, module, version)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		moduleDir = filepath.Join(repo.Dir, subdir)
+		provenance = &cacheProvenance{Source: repo.Remote}
+		if provenance.SourceHash, err = repo.VCS.commitID(repo.Dir, rev); err != nil {
+			log.Printf("Warning: could not resolve %s in %s: %v", rev, repo.Remote, err)
+		}
+		indexPath, err = generateIndexForVersion(repo, rev, subdir, module)
+	}
+	if err != nil {
+		return "", nil, nil, err
+	}
+	cleanup := func() { releaseIndex(indexPath) }
+
+	goMod, err := os.ReadFile(filepath.Join(moduleDir, "go.mod"))
+	if err != nil && !os.IsNotExist(err) {
+		cleanup()
+		return "", nil, nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+
+	// The generated index stays in use for this run; the cache only gets a copy
+	if cacheable {
+		provenance.Indexer = moduleIndexer()
+		if err := cacheIndex(cache, key, indexPath, goMod, provenance); err != nil {
+			log.Printf("Warning: failed to cache index for %s: %v", key, err)
+		}
+	}
+
+	return indexPath, goMod, cleanup, nil
+}
+
+// cacheIndex stores an index and the module's go.mod, if any, in the cache
+// along with the provenance of the index
+func cacheIndex(cache *indexCache, key, indexPath string, goMod []byte, provenance *cacheProvenance) error {
+	index, err := openIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+
+	files := map[string]io.Reader{"index.scip": index}
+	if goMod != nil {
+		files["go.mod"] = bytes.NewReader(goMod)
+	}
+	if err := writeGaps(files, indexPath); err != nil {
+		return err
+	}
+	_, err = cache.Put(key, files, provenance)
+	return err
+}
+
+// generateIndexForVersion checks out a specific version and generates the SCIP
+// index of the module in subdir of the repository. Only that module is indexed,
+// not other modules nested in or next to it.
+func generateIndexForVersion(repo *moduleRepo, version, subdir, modulePath string) (string, error) {
+	// Checkout the specific version
+	if err := repo.VCS.checkout(repo.Dir, version); err != nil {
+		return "", fmt.Errorf("failed to checkout version %s: %w", version, err)
+	}
+
+	return indexModuleDir(repo.Dir, filepath.Join(repo.Dir, subdir), modulePath)
+}
+
+// indexModuleDir generates the SCIP index of the module rooted at moduleDir
+// within the repository or extracted module zip at rootDir, with scip-go or
+// from export data depending on moduleEngine. Versions without a
+// go.mod are indexed as modulePath, with their dependencies resolved like the
+// go command does for them.
+func indexModuleDir(rootDir, moduleDir, modulePath string) (string, error) {
+	synthesized, cleanup, err := synthesizeGoMod(moduleDir, modulePath)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	var env []string
+	if synthesized {
+		env = append(env, "GOFLAGS="+strings.TrimSpace(toolGetenv("GOFLAGS")+" -mod=mod"))
+	}
+	if moduleEngine == EngineExportData {
+		return exportDataIndex(moduleDir, modulePath, env)
+	}
+
+	return runScipGoLoadable(moduleDir, env, subprocessStderr(),
+		[]string{"--verbose", "--project-root", moduleDir, "--repository-root", rootDir},
+		"./...", // Index all packages recursively
+	)
+}
+
+// generateScipIndex runs scip-go on a module and returns the path to the index.
+// A non-empty scope limits indexing to those packages. env adds KEY=VALUE pairs
+// to the scip-go environment, e.g. to select GOOS.
+func generateScipIndex(moduleLocation string, scope packageScope, env ...string) (string, error) {
+	if len(scope) > 0 {
+		return runScipGoLoadable(moduleLocation, env, subprocessStderr(), nil, scope...)
+	}
+	return runScipGoLoadable(moduleLocation, env, subprocessStderr(), nil, moduleLocation)
+}
+
+// findUsedSymbols analyzes the user project's SCIP index to find symbols it uses
+// that originate from the specified targetModule, along with the project documents
+// referencing each of them. aliased holds references made through aliased or dot
+// imports (see aliasedUsages); they are only counted when they name a symbol of
+// the module exactly. The returned confidences rate each match: exact for
+// symbols the index references, high for ones found through aliased imports
+// only, heuristic for old module symbols whose name merely contains a used one.
+// Usages in ignored directories are skipped.
+func findUsedSymbols(indexPath, oldModuleIndexPath, moduleName string, aliased usageSites, ignored ignoredDirs) (map[string][]string, usageSites, map[string]Confidence, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	oldModuleIndex, err := loadIndex(oldModuleIndexPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	oldModuleUsedSymbols := indexDefinitions(oldModuleIndex)
+	// Types the module re-exports from other modules are used through their
+	// aliases
+	reexports := externalReexports(oldModuleIndex, oldModuleUsedSymbols)
+
+	// Documents are processed in parallel shards, merged in order
+	type projectUsages struct {
+		symbols map[string][]string
+		sites   usageSites
+		// members are the sites of struct fields, keyed "Type.Field"
+		members  usageSites
+		packages symbolPackages
+	}
+	shards := shardDocuments(index.Documents, func(docs []*scip.Document) projectUsages {
+		u := projectUsages{symbols: make(map[string][]string), sites: make(usageSites), members: make(usageSites), packages: make(symbolPackages)}
+		for _, doc := range docs {
+			if ignored.ignores(doc.RelativePath) {
+				continue
+			}
+			for _, occ := range doc.Occurrences {
+				if inModule(occ.Symbol, moduleName) {
+					val, typ := extractSymbolsFromOccurrence(occ.Symbol)
+					if val != "" {
+						field := val
+						if typ == "type" {
+							if typeName, member, _ := strings.Cut(val, "#"); member != "" {
+								u.members.add(typeName+"."+member, occurrenceLocation(doc.RelativePath, occ))
+							}
+							val = strings.Split(val, "#")[0]
+							if len(strings.Split(val, ".")) > 1 {
+								field = strings.Split(val, ".")[1]
+							}
+							u.symbols[val] = append(u.symbols[val], field)
+						} else {
+							u.symbols[val] = append(u.symbols[val], "")
+						}
+						u.sites.add(val, occurrenceLocation(doc.RelativePath, occ))
+						u.packages.add(val, occ.Symbol)
+					}
+				} else if alias, ok := reexportedType(occ.Symbol, reexports); ok {
+					u.symbols[alias] = append(u.symbols[alias], "")
+					u.sites.add(alias, occurrenceLocation(doc.RelativePath, occ))
+				}
+			}
+		}
+		return u
+	})
+
+	usedSymbols := make(map[string][]string)
+	usedIn := make(usageSites)
+	memberSites := make(usageSites)
+	usedPackages := make(symbolPackages)
+	for _, shard := range shards {
+		for name, pkgs := range shard.packages {
+			for pkg := range pkgs {
+				if usedPackages[name] == nil {
+					usedPackages[name] = make(map[string]bool)
+				}
+				usedPackages[name][pkg] = true
+			}
+		}
+		for name, locs := range shard.members {
+			for _, loc := range locs {
+				memberSites.add(name, loc)
+			}
+		}
+		for name, fields := range shard.symbols {
+			usedSymbols[name] = append(usedSymbols[name], fields...)
+		}
+		for name, locs := range shard.sites {
+			for _, loc := range locs {
+				usedIn.add(name, loc)
+			}
+		}
+	}
+
+	definedIn := definedPackages(oldModuleIndex)
+
+	aliases := unexportedAliases(oldModuleUsedSymbols)
+	resolveAliases(oldModuleUsedSymbols, aliases)
+	qualifyReexports(oldModuleUsedSymbols, reexports)
+	aliasUsages(usedSymbols, usedIn, aliases)
+	definedIn.rename(aliases)
+	usedPackages.rename(aliases)
+
+	// Methods are matched to their receiver type, so a used type pulls in its
+	// methods and not those of other types sharing the name of one
+	methods := make(map[string][]string)
+	for name := range oldModuleUsedSymbols {
+		if typeName, _, ok := strings.Cut(name, "#"); ok {
+			methods[typeName] = append(methods[typeName], name)
+		}
+	}
+
+	indexed := make(map[string]bool)
+	for name := range usedSymbols {
+		indexed[name] = true
+	}
+
+	for name, locs := range aliased {
+		if _, ok := oldModuleUsedSymbols[name]; !ok {
+			continue
+		}
+		for _, loc := range locs {
+			if ignored.ignores(loc.Path) {
+				continue
+			}
+			if _, ok := usedSymbols[name]; !ok {
+				usedSymbols[name] = append(usedSymbols[name], "")
+			}
+			usedIn.add(name, loc)
+		}
+	}
+
+	resultMap := make(map[string][]string)
+	usage := make(usageSites)
+	confidence := make(map[string]Confidence)
+	for k := range usedSymbols {
+		// Used symbols match module symbols of the same package and name: the
+		// symbol itself, or for a type, its methods, which the project may call
+		// through an interface or an embedding the index doesn't attribute
+		var matches []string
+		if _, ok := oldModuleUsedSymbols[k]; ok {
+			matches = append(matches, k)
+		}
+		if !strings.Contains(k, "#") {
+			matches = append(matches, methods[k]...)
+		}
+		for _, j := range matches {
+			if !usedPackages.overlap(k, definedIn, j) {
+				continue
+			}
+			resultMap[j] = oldModuleUsedSymbols[j]
+			for _, loc := range usedIn[k] {
+				usage.add(j, loc)
+			}
+
+			c := ConfidenceHeuristic
+			if j == k && indexed[k] {
+				c = ConfidenceExact
+			} else if j == k {
+				c = ConfidenceHigh
+			}
+			if prev, ok := confidence[j]; !ok || c > prev {
+				confidence[j] = c
+			}
+		}
+	}
+
+	// Fields are reported on their own (see buildFindings), so their sites are
+	// kept apart from those of their type
+	for name, locs := range memberSites {
+		typeName, member, _ := strings.Cut(name, ".")
+		if alias, ok := aliases[typeName]; ok {
+			typeName = alias
+		}
+		if _, ok := resultMap[typeName]; !ok {
+			continue
+		}
+		for _, loc := range locs {
+			usage.add(typeName+"."+member, loc)
+		}
+	}
+
+	return resultMap, usage, confidence, nil
+}
+
+// occurrenceLocation converts the 0-based SCIP range of an occurrence into a
+// 1-based Location
+func occurrenceLocation(path string, occ *scip.Occurrence) Location {
+	loc := Location{Path: path}
+	if len(occ.Range) >= 2 {
+		loc.Line = int(occ.Range[0]) + 1
+		loc.Column = int(occ.Range[1]) + 1
+	}
+	return loc
+}
+
+func extractSymbolDefinition(symbol string) string {
+	parts := strings.Split(symbol, "\n")
+	if len(parts) < 2 {
+		return ""
+	}
+	// Extract the function definition between \n characters
+	symbolDef := parts[1]
+
+	return symbolDef
+}
+
+// extractSymbolsFromOccurrence returns the name of a scip-go symbol within its
+// package and its kind: methods and functions such as "Client#Close" and
+// "NewClient" are "function", types and their fields such as "Client#" and
+// "Client#Timeout" are "type", and everything else "constant or variable".
+// Local and malformed symbols return "".
+func extractSymbolsFromOccurrence(symbol string) (string, string) {
+	name, ok := parseSymbolName(symbol)
+	if !ok {
+		return "", ""
+	}
+	val := name.Member
+	if name.Type != "" {
+		val = name.Type + "#" + name.Member
+	}
+	switch {
+	case name.Method:
+		return val, "function"
+	case name.Type != "":
+		return val, "type"
+	default:
+		return val, "constant or variable"
+	}
+}
+
+func getAvailableSymbols(indexPath string) (map[string][]string, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := indexDefinitions(index)
+	resolveAliases(symbols, unexportedAliases(symbols))
+	qualifyReexports(symbols, externalReexports(index, symbols))
+
+	return symbols, nil
+}
+
+func findChangedSymbols(oldSymbols map[string][]string, newSymbols map[string][]string) (map[string]string, map[string]string) {
+	added := make(map[string]string)
+	removed := make(map[string]string)
+
+	for oldSymbol, oldSymbolDefs := range oldSymbols {
+		newSymbolDefs, exists := newSymbols[oldSymbol]
+		if exists && !cmp.Equal(normalizedSet(oldSymbolDefs), normalizedSet(newSymbolDefs)) {
+			// Types carry one definition per member; report each changed member on
+			// its own so reordering is ignored and the actual change is pinpointed
+			members := typeMembers(oldSymbol, oldSymbolDefs) || typeMembers(oldSymbol, newSymbolDefs)
+			for _, change := range diffMembers(oldSymbolDefs, newSymbolDefs) {
+				key := oldSymbol
+				if members {
+					key = oldSymbol + "." + change.Name
+				}
+				if change.Old != "" {
+					removed[key] = change.Old
+				}
+				if change.New != "" {
+					added[key] = change.New
+				}
+			}
+		}
+
+		// Also mark completely removed functions, methods and types
+		if _, ok := newSymbols[oldSymbol]; !ok {
+			removed[oldSymbol] = "removed"
+		}
+	}
+
+	return added, removed
+}
@@ -0,0 +1,79 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// modulePathAt returns the path a module is published under at a version:
+// versions of major version 2 or higher carry a /vN suffix (.vN on gopkg.in),
+// except +incompatible ones, which predate the module's go.mod. Paths
+// elsewhere than the module proxy knows them, e.g. branch names, are left as
+// they are.
+func modulePathAt(modulePath, version string) string {
+	if !semver.IsValid(version) {
+		return modulePath
+	}
+	prefix, pathMajor, ok := module.SplitPathVersion(modulePath)
+	if !ok {
+		return modulePath
+	}
+	major := semver.Major(version)
+	if strings.HasPrefix(modulePath, "gopkg.in/") {
+		return prefix + "." + major
+	}
+	if isIncompatible(version) || major == "v0" || major == "v1" {
+		return prefix
+	}
+	if pathMajor == "/"+major {
+		return modulePath
+	}
+	return prefix + "/" + major
+}
+
+// majorVersionPaths returns the module paths of both versions of an upgrade,
+// which differ when it crosses a major version. A new version given without
+// +incompatible is looked up as one when the module has no go.mod at it, as
+// the go command does.
+func majorVersionPaths(modulePath, oldVersion, newVersion string) (oldModule, newModule, version string) {
+	oldModule = modulePathAt(modulePath, oldVersion)
+	newModule = modulePathAt(oldModule, newVersion)
+	if newModule == oldModule || isIncompatible(newVersion) || strings.HasPrefix(modulePath, "gopkg.in/") {
+		return oldModule, newModule, newVersion
+	}
+	if _, err := fetchVersionInfo(newModule, newVersion); err != nil {
+		prefix, _, _ := module.SplitPathVersion(newModule)
+		if _, ierr := fetchVersionInfo(prefix, newVersion+incompatibleSuffix); ierr == nil {
+			return oldModule, prefix, newVersion + incompatibleSuffix
+		}
+	}
+	return oldModule, newModule, newVersion
+}
+
+// newerMajorVersion returns the path and latest release of the next major
+// version of a module, if it has one. Upgrades within a major version never
+// cross into it, so its existence is only worth a hint.
+func newerMajorVersion(modulePath string, includePrerelease bool) (string, string, bool) {
+	prefix, pathMajor, ok := module.SplitPathVersion(modulePath)
+	if !ok {
+		return "", "", false
+	}
+	n := 1
+	if pathMajor != "" {
+		if _, err := fmt.Sscanf(strings.TrimLeft(pathMajor, "/."), "v%d", &n); err != nil {
+			return "", "", false
+		}
+	}
+	next := fmt.Sprintf("%s/v%d", prefix, n+1)
+	if strings.HasPrefix(modulePath, "gopkg.in/") {
+		next = fmt.Sprintf("%s.v%d", prefix, n+1)
+	}
+	latest, err := latestRelease(next, includePrerelease)
+	if err != nil {
+		return "", "", false
+	}
+	return next, latest, true
+}
@@ -0,0 +1,159 @@
+package upgradecheck
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// memberChange is a single member of a type whose declaration differs between
+// versions. Old is empty for added members and New is empty for removed ones.
+type memberChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// normalizeDefinition strips comments and collapses whitespace so
+// formatting-only differences don't register as changes
+func normalizeDefinition(def string) string {
+	return strings.Join(strings.Fields(stripComments(def)), " ")
+}
+
+// stripComments removes the comments of Go source, leaving string, raw string
+// and rune literals alone, so a tag such as `doc:"https://x"` is kept whole
+func stripComments(src string) string {
+	var b strings.Builder
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '"' || c == '\'' || c == '`':
+			end := i + 1
+			for end < len(src) && src[end] != c && (c == '`' || src[end] != '\n') {
+				if src[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(src))
+			b.WriteString(src[i:end])
+			i = end - 1
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				return b.String()
+			}
+			i += end - 1
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return b.String()
+			}
+			b.WriteByte(' ')
+			i += end + 3
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isFieldDefinition reports whether a member definition declares a struct field
+func isFieldDefinition(def string) bool {
+	return strings.HasPrefix(def, "struct field ") || strings.HasPrefix(def, "field ")
+}
+
+// fieldType returns the type of a struct field definition such as
+// "struct field Timeout time.Duration", without its tag
+func fieldType(def string) string {
+	def = normalizeDefinition(def)
+	for _, prefix := range []string{"struct field ", "field "} {
+		def = strings.TrimPrefix(def, prefix)
+	}
+	_, typ, _ := strings.Cut(def, " ")
+	if i := strings.Index(typ, " `"); i >= 0 {
+		typ = typ[:i]
+	}
+	return typ
+}
+
+// memberName extracts the declared name from a member definition such as
+// "func (c *Client) Close() error" or "struct field Timeout time.Duration"
+func memberName(def string) string {
+	rest := def
+	for _, prefix := range []string{"struct field ", "field ", "func ", "const ", "var ", "type "} {
+		if strings.HasPrefix(rest, prefix) {
+			rest = strings.TrimPrefix(rest, prefix)
+			break
+		}
+	}
+	if strings.HasPrefix(rest, "(") {
+		if end := matchingParen(rest, 0); end >= 0 {
+			rest = strings.TrimLeft(rest[end+1:], " .")
+		}
+	}
+
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if end == 0 {
+		return def
+	}
+	if end > 0 {
+		rest = rest[:end]
+	}
+	return rest
+}
+
+// typeMembers reports whether defs, filed under key, declare the members of a
+// type rather than the symbol itself. Deciding by the definitions instead of
+// their number keeps a type with a single member keyed like one with several.
+func typeMembers(key string, defs []string) bool {
+	name := key[strings.LastIndexAny(key, "#.")+1:]
+	for _, def := range defs {
+		if isFieldDefinition(def) || memberName(def) != name {
+			return true
+		}
+	}
+	return false
+}
+
+// diffMembers compares the member definitions of a symbol as sets, ignoring
+// order and cosmetic differences, and pairs up changed members by name
+func diffMembers(oldDefs, newDefs []string) []memberChange {
+	oldByName := make(map[string]string)
+	for _, def := range oldDefs {
+		oldByName[memberName(def)] = def
+	}
+	newByName := make(map[string]string)
+	for _, def := range newDefs {
+		newByName[memberName(def)] = def
+	}
+
+	var changes []memberChange
+	for name, oldDef := range oldByName {
+		newDef, ok := newByName[name]
+		if ok && canonicalSignature(oldDef) == canonicalSignature(newDef) {
+			continue
+		}
+		changes = append(changes, memberChange{Name: name, Old: oldDef, New: newDef})
+	}
+	for name, newDef := range newByName {
+		if _, ok := oldByName[name]; !ok {
+			changes = append(changes, memberChange{Name: name, New: newDef})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// normalizedSet returns the canonical signatures of the definitions sorted, for
+// order-insensitive comparison
+func normalizedSet(defs []string) []string {
+	set := make([]string, 0, len(defs))
+	for _, def := range defs {
+		set = append(set, canonicalSignature(def))
+	}
+	sort.Strings(set)
+	return set
+}
@@ -0,0 +1,23 @@
+package upgradecheck
+
+import "testing"
+
+func TestNormalizeDefinition(t *testing.T) {
+	tests := []struct {
+		def, want string
+	}{
+		{"func Do(req *Request)  error // Do sends req", "func Do(req *Request) error"},
+		{"func Do(req *Request) /* since v1.2 */ error", "func Do(req *Request) error"},
+		{"type T struct {\n\tA int // a\n\tB int\n}", "type T struct { A int B int }"},
+		{"struct field URL string `json:\"u\" doc:\"https://x\"` // link", "struct field URL string `json:\"u\" doc:\"https://x\"`"},
+		{`const Sep = "//"`, `const Sep = "//"`},
+		{`const Quote = "\"//" // escaped`, `const Quote = "\"//"`},
+		{"const Slash = '/' // rune", "const Slash = '/'"},
+		{"func Do() /* unterminated", "func Do()"},
+	}
+	for _, tt := range tests {
+		if got := normalizeDefinition(tt.def); got != tt.want {
+			t.Errorf("normalizeDefinition(%q) = %q, want %q", tt.def, got, tt.want)
+		}
+	}
+}
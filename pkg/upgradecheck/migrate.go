@@ -0,0 +1,420 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+	"google.golang.org/protobuf/proto"
+)
+
+// migrationStatus is how far a project got moving off the symbols an upgrade
+// removed, once the upgrade has been applied
+type migrationStatus struct {
+	Project    string            `json:"project"`
+	Module     string            `json:"module"`
+	NewVersion string            `json:"new_version"`
+	Complete   bool              `json:"complete"`
+	Symbols    []migrationSymbol `json:"symbols"`
+	Remaining  int               `json:"remaining"`
+	// OtherErrors counts the build errors unrelated to the removed symbols
+	OtherErrors int `json:"other_errors"`
+}
+
+// migrationSymbol compares the references to one removed symbol the check
+// reported against the old version with those left after the upgrade
+type migrationSymbol struct {
+	Symbol    string     `json:"symbol"`
+	Before    int        `json:"before"`
+	Remaining []Location `json:"remaining"`
+}
+
+// buildError is one compiler error of the project
+type buildError struct {
+	Location
+	Message string
+}
+
+// buildErrorLine matches a compiler error, e.g. "api/client.go:12:3: undefined: dep.Dial"
+var buildErrorLine = regexp.MustCompile(`^(.+\.go):(\d+):(\d+): (.+)$`)
+
+// runVerifyMigration implements the "verify-migration" subcommand: after the
+// upgrade a report was produced for has been applied, it finds the references
+// to removed symbols that remain in the project, and builds it to make sure
+// nothing else broke, so teams can iterate on the migration until it is done
+func runVerifyMigration(args []string) {
+	fs := flag.NewFlagSet("verify-migration", flag.ExitOnError)
+	reportPath := fs.String("report", "", "Report of the upgrade saved with --format=json")
+	projectPath := fs.String("project-path", "", "Path to the upgraded project (defaults to the paths of the services in the report)")
+	format := fs.String("format", FormatText, "Output format: text, markdown or json")
+	oldIndex := fs.String("old-index", "", "Pre-built SCIP index of the old version of the module, used instead of indexing it")
+	cacheDir := fs.String("cache-dir", defaultCacheDir(), "Directory for cached dependency indexes (empty disables caching)")
+	ignoreDirList := fs.String("ignore-dirs", defaultIgnoredDirs, "Comma separated directory names, matched at any depth, whose usages are ignored (empty ignores none)")
+	fs.Parse(args)
+
+	if *reportPath == "" {
+		fmt.Fprintln(os.Stderr, "usage: go-upgrade-checker verify-migration --report <report.json> [--project-path dir] [--format text|markdown|json]")
+		exit(ExitError)
+	}
+	switch *format {
+	case FormatText, FormatMarkdown, FormatJSON:
+	default:
+		fatalf("Unknown format %q: must be %s, %s or %s", *format, FormatText, FormatMarkdown, FormatJSON)
+	}
+	ignored, err := parseIgnoredDirs(*ignoreDirList)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	report, err := readReport(*reportPath)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	toolEnv = buildToolEnv(os.Environ(), nil)
+
+	// Remaining references are found the way the check found them before the
+	// upgrade: as usages of symbols of the old version
+	oldIndexPath := *oldIndex
+	if oldIndexPath != "" {
+		if err := validateModuleIndex("--old-index", oldIndexPath, report.Module, report.OldVersion); err != nil {
+			fatalf("%v", err)
+		}
+	} else {
+		var cache *indexCache
+		if *cacheDir != "" {
+			cache = &indexCache{Dir: *cacheDir}
+		}
+		repo := &moduleRepo{URL: defaultRepoURL(report.Module)}
+		defer repo.Close()
+		var cleanup func()
+		oldIndexPath, _, cleanup, err = indexModuleVersion(runContext, cache, repo, report.Module, report.OldVersion)
+		if err != nil {
+			fatalf("Failed to index %s@%s: %v", report.Module, report.OldVersion, err)
+		}
+		defer cleanup()
+	}
+
+	newModule := upgradedModulePath(report.Module, report.OldVersion, report.NewVersion)
+	services := report.Services
+	if *projectPath != "" {
+		// Checked one project under another path, e.g. in a fresh checkout
+		merged := &serviceReport{Name: *projectPath, Path: *projectPath}
+		for _, service := range report.Services {
+			merged.Findings = append(merged.Findings, service.Findings...)
+		}
+		services = []*serviceReport{merged}
+	}
+
+	var statuses []*migrationStatus
+	complete := true
+	for _, service := range services {
+		if version, err := pinnedVersion(service.Path, newModule); err != nil || version != report.NewVersion {
+			log.Printf("Warning: %s doesn't build against %s@%s yet; apply the upgrade first, e.g. with go get %s@%s", service.Path, newModule, report.NewVersion, newModule, report.NewVersion)
+		}
+		status, err := checkMigration(service, report.Module, newModule, report.OldVersion, oldIndexPath, ignored)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		status.Module, status.NewVersion = newModule, report.NewVersion
+		complete = complete && status.Complete
+		statuses = append(statuses, status)
+	}
+
+	switch *format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Println(string(data))
+	case FormatMarkdown:
+		for _, status := range statuses {
+			printMigrationMarkdown(os.Stdout, status)
+		}
+	default:
+		for _, status := range statuses {
+			printMigrationText(os.Stdout, status)
+		}
+	}
+	if !complete {
+		exit(ExitFindings)
+	}
+}
+
+// checkMigration builds the upgraded project of service and finds the
+// references left to the module symbols its report found removed, matching
+// the usages of the project against the old version of module indexed at
+// oldIndexPath like the check did
+func checkMigration(service *serviceReport, module, newModule, oldVersion, oldIndexPath string, ignored ignoredDirs) (*migrationStatus, error) {
+	errs, err := buildErrors(service.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s: %w", service.Path, err)
+	}
+	indexPath, err := migratedIndex(service.Path, module, newModule, oldVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to index %s: %w", service.Path, err)
+	}
+	defer releaseIndex(indexPath)
+
+	aliased, err := aliasedUsages(service.Path, newModule)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	_, usage, _, err := findUsedSymbols(indexPath, oldIndexPath, module, aliased, ignored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find used symbols in %s: %w", service.Path, err)
+	}
+	oldIndex, err := loadIndex(oldIndexPath)
+	if err != nil {
+		return nil, err
+	}
+	return verifyMigration(service, usage, definedPackages(oldIndex), errs), nil
+}
+
+// migratedIndex indexes the upgraded project at projectPath against oldVersion
+// of module, so the references left to symbols the upgrade removed resolve as
+// they did before it. The project's go.mod is never modified: the old version
+// is pinned in a scratch copy passed with -modfile, replacing newModule when
+// the upgrade changed the module path. Packages that no longer type-check
+// against the old version, having started using the new API, are indexed as
+// far as they do.
+func migratedIndex(projectPath, module, newModule, oldVersion string) (string, error) {
+	goModPath, err := findGoMod(projectPath)
+	if err != nil {
+		return "", err
+	}
+	if goModPath == "" {
+		return "", fmt.Errorf("no go.mod found for %s", projectPath)
+	}
+
+	scratch, err := cleanups.tempDir("", "verify-migration-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create scratch workspace: %w", err)
+	}
+	defer cleanups.remove(scratch)
+
+	modFile := filepath.Join(scratch, "go.mod")
+	if err := copyFile(goModPath, modFile); err != nil {
+		return "", err
+	}
+	goSum := filepath.Join(filepath.Dir(goModPath), "go.sum")
+	if _, err := os.Stat(goSum); err == nil {
+		if err := copyFile(goSum, filepath.Join(scratch, "go.sum")); err != nil {
+			return "", err
+		}
+	}
+
+	pin := command("go", "get", "-modfile="+modFile, module+"@"+oldVersion)
+	if newModule != module {
+		pin = command("go", "mod", "edit", "-modfile="+modFile, "-replace="+newModule+"="+module+"@"+oldVersion)
+	}
+	pin.Dir = projectPath
+	pin.Stderr = subprocessStderr()
+	if err := pin.Run(); err != nil {
+		return "", fmt.Errorf("failed to pin %s@%s: %w", module, oldVersion, err)
+	}
+
+	// scip-go gives up on packages that don't type-check, which those
+	// half-way through the migration don't
+	env := []string{"GOFLAGS=" + strings.TrimSpace(toolGetenv("GOFLAGS")+" -mod=mod -modfile="+modFile)}
+	indexPath, err := indexPackages(projectPath, env, []string{"./..."}, true)
+	if err != nil || newModule == module {
+		return indexPath, err
+	}
+	defer releaseIndex(indexPath)
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return "", err
+	}
+	renamed := proto.Clone(index).(*scip.Index)
+	for _, doc := range renamed.Documents {
+		for _, occ := range doc.Occurrences {
+			occ.Symbol = renameSymbolModule(occ.Symbol, newModule, module)
+		}
+	}
+	return storeIndex(nil, renamed), nil
+}
+
+// renameSymbolModule returns symbol with its module, and the package path
+// prefix naming it, changed from from to to; symbols of other modules are
+// returned as is
+func renameSymbolModule(symbol, from, to string) string {
+	fields := strings.SplitN(symbol, " ", 5)
+	if len(fields) < 5 || fields[1] != "gomod" || fields[2] != from {
+		return symbol
+	}
+	fields[2] = to
+	if pkg, rest, ok := cutDescriptorName(fields[4]); ok && strings.HasPrefix(rest, "/") {
+		if sub, ok := strings.CutPrefix(pkg, from); ok && (sub == "" || sub[0] == '/') {
+			fields[4] = descriptorName(to+sub) + rest
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// buildErrors compiles every package of the project along with its tests and
+// returns the compiler errors, all of them rather than the first ten per
+// package. Packages importing one that fails aren't compiled.
+func buildErrors(projectPath string) ([]buildError, error) {
+	scratch, err := cleanups.tempDir("", "verify-migration-*")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanups.remove(scratch)
+
+	var out bytes.Buffer
+	cmd := command("go", "test", "-c", "-o", scratch, "-gcflags=-e", "./...")
+	cmd.Dir = projectPath
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	runErr := cmd.Run()
+
+	var errs []buildError
+	seen := make(map[buildError]bool)
+	for _, line := range strings.Split(out.String(), "\n") {
+		m := buildErrorLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		e := buildError{Location{Path: filepath.ToSlash(filepath.Clean(m[1])), Line: lineNo, Column: col}, m[4]}
+		// Packages with tests are compiled twice, with and without them
+		if !seen[e] {
+			seen[e] = true
+			errs = append(errs, e)
+		}
+	}
+	if runErr != nil && len(errs) == 0 {
+		return nil, fmt.Errorf("go test -c failed: %w\n%s", runErr, strings.TrimSpace(out.String()))
+	}
+	return errs, nil
+}
+
+// verifyMigration compares the usages of module symbols left in the upgraded
+// project, by symbol key like findUsedSymbols returns them, against the
+// symbols its report found removed. The migration is complete once none of
+// them is used and the project builds.
+func verifyMigration(service *serviceReport, usage usageSites, packages symbolPackages, errs []buildError) *migrationStatus {
+	remaining := make(map[string][]Location)
+	for key, locs := range usage {
+		// Findings are named the same way, see canonicalFindings
+		name, _ := canonicalName(key, packages)
+		remaining[name] = append(remaining[name], locs...)
+	}
+
+	status := &migrationStatus{Project: service.Path, Symbols: []migrationSymbol{}}
+	removed := make(map[string]int)
+	referenced := make(map[Location]bool)
+	for _, f := range service.Findings {
+		if f.Kind != ChangeRemoved {
+			continue
+		}
+		i, ok := removed[f.Symbol]
+		if !ok {
+			i = len(status.Symbols)
+			removed[f.Symbol] = i
+			locs := sortedLocations(remaining[f.Symbol])
+			status.Symbols = append(status.Symbols, migrationSymbol{Symbol: f.Symbol, Remaining: locs})
+			status.Remaining += len(locs)
+			for _, loc := range locs {
+				referenced[Location{Path: loc.Path, Line: loc.Line}] = true
+			}
+		}
+		status.Symbols[i].Before += len(f.Usages)
+	}
+
+	// The compiler reports the remaining references too; errors on other
+	// lines mean the project is broken in other ways
+	for _, e := range errs {
+		if !referenced[Location{Path: e.Path, Line: e.Line}] {
+			status.OtherErrors++
+		}
+	}
+
+	sort.SliceStable(status.Symbols, func(i, j int) bool {
+		return len(status.Symbols[i].Remaining) > len(status.Symbols[j].Remaining)
+	})
+	status.Complete = status.Remaining == 0 && status.OtherErrors == 0
+	return status
+}
+
+// sortedLocations returns locs without duplicates, in file and line order
+func sortedLocations(locs []Location) []Location {
+	seen := make(map[Location]bool)
+	sorted := []Location{}
+	for _, loc := range locs {
+		if !seen[loc] {
+			seen[loc] = true
+			sorted = append(sorted, loc)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return sorted
+}
+
+func printMigrationText(w io.Writer, status *migrationStatus) {
+	fmt.Fprintf(w, "Migration of %s to %s in %s: ", status.Module, status.NewVersion, status.Project)
+	if status.Complete {
+		fmt.Fprintln(w, "complete, no references to removed symbols remain")
+	} else if status.Remaining == 0 {
+		fmt.Fprintln(w, "incomplete, no references to removed symbols remain but the project doesn't build")
+	} else {
+		fmt.Fprintf(w, "%d references remaining\n", status.Remaining)
+	}
+	for _, sym := range status.Symbols {
+		fmt.Fprintf(w, "  %s: %d of %d references remaining\n", sym.Symbol, len(sym.Remaining), sym.Before)
+		for _, loc := range sym.Remaining {
+			fmt.Fprintf(w, "    %s\n", loc)
+		}
+	}
+	if status.OtherErrors > 0 {
+		fmt.Fprintf(w, "  %d other build errors\n", status.OtherErrors)
+	}
+}
+
+func printMigrationMarkdown(w io.Writer, status *migrationStatus) {
+	fmt.Fprintf(w, "# Migration of %s to %s: `%s`\n\n", status.Module, status.NewVersion, status.Project)
+	if status.Complete {
+		fmt.Fprintln(w, "Migration complete: no references to removed symbols remain.")
+	} else if status.Remaining == 0 {
+		fmt.Fprintln(w, "**Migration incomplete:** no references to removed symbols remain, but the project doesn't build.")
+	} else {
+		fmt.Fprintf(w, "**%d references remaining.**\n", status.Remaining)
+	}
+	if len(status.Symbols) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Symbol | Before | Remaining | Sites |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- |")
+		for _, sym := range status.Symbols {
+			sites := make([]string, len(sym.Remaining))
+			for i, loc := range sym.Remaining {
+				sites[i] = "`" + loc.String() + "`"
+			}
+			fmt.Fprintf(w, "| `%s` | %d | %d | %s |\n", sym.Symbol, sym.Before, len(sym.Remaining), strings.Join(sites, ", "))
+		}
+	}
+	if status.OtherErrors > 0 {
+		fmt.Fprintf(w, "\n%d other build errors.\n", status.OtherErrors)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,134 @@
+package upgradecheck
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes files by path relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckMigration(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	// Both versions of the module are local replacements, so nothing is
+	// downloaded
+	setToolEnv(t, append(os.Environ(), "GOPROXY=off", "GOFLAGS=", "GOWORK=off")...)
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"old/go.mod": "module example.com/m\n\ngo 1.21\n",
+		"old/m.go":   "package m\n\nfunc Dial() {}\n\nfunc Keep() {}\n",
+		"new/go.mod": "module example.com/m\n\ngo 1.21\n",
+		"new/m.go":   "package m\n\nfunc DialContext() {}\n\nfunc Keep() {}\n",
+		"project/go.mod": `module example.com/p
+
+go 1.21
+
+require example.com/m v1.1.0
+
+replace example.com/m v1.0.0 => ../old
+
+replace example.com/m v1.1.0 => ../new
+`,
+	})
+	oldIndexPath, err := indexPackages(filepath.Join(root, "old"), nil, []string{"./..."}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseIndex(oldIndexPath)
+
+	project := filepath.Join(root, "project")
+	service := &serviceReport{Path: project, Findings: []Finding{
+		{Symbol: "m.Dial", Package: "example.com/m", Kind: ChangeRemoved, Usages: []Location{{Path: "a/a.go", Line: 5, Column: 4}, {Path: "b/b.go", Line: 5, Column: 4}}},
+	}}
+
+	tests := []struct {
+		name          string
+		files         map[string]string
+		wantRemaining []Location
+		wantOther     int
+		wantComplete  bool
+	}{
+		{
+			// b.go uses the new API, so its package only type-checks
+			// against the new version
+			name: "references remaining",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport \"example.com/m\"\n\nfunc A() { m.Dial() }\n",
+				"b/b.go": "package b\n\nimport \"example.com/m\"\n\nfunc B() { m.Dial(); m.DialContext() }\n",
+			},
+			wantRemaining: []Location{{Path: "a/a.go", Line: 5, Column: 14}, {Path: "b/b.go", Line: 5, Column: 14}},
+		},
+		{
+			name: "other build errors",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport \"example.com/m\"\n\nfunc A() { m.DialContext(); m.Keep(1) }\n",
+				"b/b.go": "package b\n\nimport \"example.com/m\"\n\nfunc B() { m.DialContext() }\n",
+			},
+			wantOther: 1,
+		},
+		{
+			name: "complete",
+			files: map[string]string{
+				"a/a.go": "package a\n\nimport \"example.com/m\"\n\nfunc A() { m.DialContext() }\n",
+				"b/b.go": "package b\n\nimport \"example.com/m\"\n\nfunc B() { m.DialContext(); m.Keep() }\n",
+			},
+			wantComplete: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFiles(t, project, tt.files)
+			status, err := checkMigration(service, "example.com/m", "example.com/m", "v1.0.0", oldIndexPath, nil)
+			if err != nil {
+				t.Fatalf("checkMigration() error: %v", err)
+			}
+			var remaining []Location
+			for _, sym := range status.Symbols {
+				remaining = append(remaining, sym.Remaining...)
+			}
+			if len(remaining) != len(tt.wantRemaining) || status.OtherErrors != tt.wantOther || status.Complete != tt.wantComplete {
+				t.Fatalf("checkMigration() = %v remaining, %d other errors, complete %v; want %v, %d, %v", remaining, status.OtherErrors, status.Complete, tt.wantRemaining, tt.wantOther, tt.wantComplete)
+			}
+			for i := range remaining {
+				if remaining[i] != tt.wantRemaining[i] {
+					t.Errorf("remaining reference %d = %v, want %v", i, remaining[i], tt.wantRemaining[i])
+				}
+			}
+			if status.Symbols[0].Before != 2 {
+				t.Errorf("references before = %d, want 2", status.Symbols[0].Before)
+			}
+		})
+	}
+}
+
+func TestRenameSymbolModule(t *testing.T) {
+	tests := []struct {
+		symbol, want string
+	}{
+		{"scip-go gomod example.com/m/v2 v1.0.0 `example.com/m/v2`/Dial().", "scip-go gomod example.com/m v1.0.0 `example.com/m`/Dial()."},
+		{"scip-go gomod example.com/m/v2 v1.0.0 `example.com/m/v2/api`/Client#Do().", "scip-go gomod example.com/m v1.0.0 `example.com/m/api`/Client#Do()."},
+		{"scip-go gomod example.com/other v1.0.0 `example.com/other`/Dial().", "scip-go gomod example.com/other v1.0.0 `example.com/other`/Dial()."},
+		{"local 1", "local 1"},
+	}
+	for _, tt := range tests {
+		if got := renameSymbolModule(tt.symbol, "example.com/m/v2", "example.com/m"); got != tt.want {
+			t.Errorf("renameSymbolModule(%q) = %q, want %q", tt.symbol, got, tt.want)
+		}
+	}
+}
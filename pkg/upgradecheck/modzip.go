@@ -0,0 +1,81 @@
+package upgradecheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
+	modzip "golang.org/x/mod/zip"
+)
+
+// zipClient downloads module zips, which can take much longer than the other
+// proxy requests
+var zipClient = &http.Client{Timeout: 10 * time.Minute}
+
+// fromProxy reports whether modulePath@version can be downloaded from the
+// module proxy: the version must be one the proxy serves, and GOPROXY,
+// GONOPROXY and GOPRIVATE must let the go command fetch the module through a
+// proxy. Private modules keep being cloned with git, using its credentials.
+func fromProxy(modulePath, version string) bool {
+	return semver.IsValid(version) && !privateModule(modulePath)
+}
+
+// downloadModule fetches the zip of modulePath@version from the module proxy
+// and extracts it into a new temp directory, which then holds the module root
+// exactly as the go command would install it. It also returns the go.sum hash
+// of the zip.
+func downloadModule(ctx context.Context, modulePath, version string) (_, _ string, err error) {
+	_, span := startSpan(ctx, "download", attribute.String("module", modulePath), attribute.String("version", version))
+	defer func() { endSpan(span, err) }()
+
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid module path %s: %w", modulePath, err)
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid version %s: %w", version, err)
+	}
+	url := fmt.Sprintf("%s/%s/@v/%s.zip", proxyURL(), escapedPath, escapedVersion)
+	resp, err := proxyRequest(zipClient, url)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to query module proxy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("module proxy returned %s for %s", resp.Status, url)
+	}
+
+	zipFile, err := cleanups.tempFile("module-*.zip")
+	if err != nil {
+		return "", "", err
+	}
+	defer cleanups.remove(zipFile.Name())
+	if _, err := io.Copy(zipFile, resp.Body); err != nil {
+		zipFile.Close()
+		return "", "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if err := zipFile.Close(); err != nil {
+		return "", "", err
+	}
+	sum, err := dirhash.HashZip(zipFile.Name(), dirhash.Hash1)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to hash module zip for %s@%s: %w", modulePath, version, err)
+	}
+
+	dir, err := cleanups.tempDir("", "module-src-*")
+	if err != nil {
+		return "", "", err
+	}
+	if err := modzip.Unzip(dir, module.Version{Path: modulePath, Version: version}, zipFile.Name()); err != nil {
+		cleanups.remove(dir)
+		return "", "", fmt.Errorf("invalid module zip for %s@%s: %w", modulePath, version, err)
+	}
+	return dir, sum, nil
+}
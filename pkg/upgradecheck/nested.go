@@ -0,0 +1,207 @@
+package upgradecheck
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// repoRootHosts are code hosts whose repositories are always host/owner/name,
+// so any further path elements of a module are a subdirectory of the repository
+var repoRootHosts = map[string]bool{
+	"github.com":    true,
+	"gitlab.com":    true,
+	"bitbucket.org": true,
+}
+
+// repoRoot guesses the repository path hosting a module. Nested modules
+// (repo/api) and major version suffixes (repo/v2) live inside their repository.
+func repoRoot(modulePath string) string {
+	// gopkg.in serves every major version as a repository of its own
+	if strings.HasPrefix(modulePath, "gopkg.in/") {
+		return modulePath
+	}
+	parts := strings.Split(modulePath, "/")
+	if repoRootHosts[parts[0]] && len(parts) > 3 {
+		return strings.Join(parts[:3], "/")
+	}
+	if prefix, _, ok := module.SplitPathVersion(modulePath); ok && prefix != "" {
+		return prefix
+	}
+	return modulePath
+}
+
+// moduleDirAt returns the directory, relative to the repository root, of the
+// go.mod declaring modulePath at the given revision of a clone
+func moduleDirAt(repo *moduleRepo, rev, modulePath string) (string, bool, error) {
+	files, err := repo.VCS.files(repo.Dir, rev)
+	if err != nil {
+		return "", false, err
+	}
+
+	var candidates []string
+	for _, file := range files {
+		if path.Base(file) != "go.mod" || strings.Contains("/"+file, "/vendor/") || strings.Contains("/"+file, "/testdata/") {
+			continue
+		}
+		candidates = append(candidates, file)
+	}
+	// Shallow go.mod files first: the root module is the usual case
+	sort.Slice(candidates, func(i, j int) bool {
+		return strings.Count(candidates[i], "/") < strings.Count(candidates[j], "/")
+	})
+
+	for _, file := range candidates {
+		data, err := repo.VCS.show(repo.Dir, rev, file)
+		if err != nil {
+			continue
+		}
+		if modfile.ModulePath(data) == modulePath {
+			dir := path.Dir(file)
+			if dir == "." {
+				dir = ""
+			}
+			return dir, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// tagPrefix returns the prefix of the module's version tags: its directory
+// within the repository, minus a major version subdirectory (module/v2 in v2/
+// is tagged v2.x.y, module/sub/v2 in sub/v2/ is tagged sub/v2.x.y)
+func tagPrefix(modulePath, subdir string) string {
+	if _, major, ok := module.SplitPathVersion(modulePath); ok && major != "" {
+		if subdir == major[1:] {
+			return ""
+		}
+		subdir = strings.TrimSuffix(subdir, major)
+	}
+	return subdir
+}
+
+// moduleCheckout locates module@version in a clone of a repository that may
+// host several modules. It returns the revision to check out, taking the tag
+// prefix of nested modules into account, and the module's directory relative
+// to the repository root ("" for the root).
+func moduleCheckout(repo *moduleRepo, modulePath, version string) (rev, subdir string, err error) {
+	subdir, found, err := moduleDirAt(repo, repo.VCS.head(), modulePath)
+	if err != nil {
+		return "", "", err
+	}
+	if !found && repo.Root != "" {
+		// Modules no longer on the default branch are assumed to live in the
+		// directory their path names below the repository root, e.g.
+		// service/s3 of github.com/aws/aws-sdk-go-v2/service/s3, for the tag
+		// prefix; the checked out revision tells where they really are
+		if rel, ok := strings.CutPrefix(modulePath, repo.Root+"/"); ok {
+			subdir = rel
+		}
+	}
+
+	rev = resolveRevision(modulePath, version)
+	switch {
+	case module.IsPseudoVersion(version):
+		rev = repo.VCS.commit(rev)
+	case !semver.IsValid(version):
+		// Branches and other revisions are given in the terms of the VCS
+	case isIncompatible(version):
+		// Such versions have no go.mod: the module is the whole repository
+		rev, _ = repo.VCS.tag(repo.Dir, rev)
+		return rev, "", nil
+	default:
+		tag := version
+		if prefix := tagPrefix(modulePath, subdir); prefix != "" {
+			if _, ok := repo.VCS.tag(repo.Dir, prefix+"/"+version); ok {
+				tag = prefix + "/" + version
+			}
+		}
+		rev, _ = repo.VCS.tag(repo.Dir, tag)
+	}
+
+	// The module may live elsewhere in the requested version than on its
+	// default branch
+	if dir, ok, err := moduleDirAt(repo, rev, modulePath); err == nil && ok {
+		subdir = dir
+	}
+	return rev, subdir, nil
+}
+
+// symbolModule returns the module a scip-go symbol belongs to, taken from its
+// package field ("scip-go gomod <module> <version> ..."), or "" when the
+// symbol has no such field
+func symbolModule(symbol string) string {
+	fields := strings.SplitN(symbol, " ", 4)
+	if len(fields) < 4 || fields[1] != "gomod" {
+		return ""
+	}
+	return fields[2]
+}
+
+// inModule reports whether a symbol belongs to exactly the given module and
+// not, say, to a nested module of the same repository
+func inModule(symbol, modulePath string) bool {
+	if m := symbolModule(symbol); m != "" {
+		return m == modulePath
+	}
+	return strings.Contains(symbol, modulePath)
+}
+
+// siblingModules returns the other modules of the same repository the project
+// uses according to its index, with one usage location each. Changes to these
+// need their own version bump and aren't covered by the check.
+func siblingModules(indexPath, modulePath string) (map[string]Location, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	root := repoRoot(modulePath)
+	siblings := make(map[string]Location)
+	for _, doc := range index.Documents {
+		for _, occ := range doc.Occurrences {
+			m := symbolModule(occ.Symbol)
+			if m == "" || m == modulePath {
+				continue
+			}
+			if _, seen := siblings[m]; seen {
+				continue
+			}
+			if repoRoot(m) == root || strings.HasPrefix(m, modulePath+"/") || strings.HasPrefix(modulePath, m+"/") {
+				siblings[m] = occurrenceLocation(doc.RelativePath, occ)
+			}
+		}
+	}
+	return siblings, nil
+}
+
+// nestedModules returns the modules nested below modulePath that the project
+// requires, read from its go.mod. Their packages share the module's import
+// path prefix but aren't part of it.
+func nestedModules(projectPath, modulePath string) []string {
+	goModPath, err := findGoMod(projectPath)
+	if err != nil || goModPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil
+	}
+	file, err := modfile.ParseLax(goModPath, data, nil)
+	if err != nil {
+		return nil
+	}
+
+	var nested []string
+	for _, req := range file.Require {
+		if strings.HasPrefix(req.Mod.Path, modulePath+"/") {
+			nested = append(nested, req.Mod.Path)
+		}
+	}
+	return nested
+}
@@ -0,0 +1,148 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// listedModule is a module as printed by go list -m -json
+type listedModule struct {
+	Path     string
+	Version  string
+	Main     bool
+	Indirect bool
+	Update   *listedModule
+	Replace  *listedModule
+	Error    *struct{ Err string }
+}
+
+// runOutdated implements the "outdated" subcommand: it asks the go command
+// which dependencies have updates and checks each of them like --all, so
+// discovery and impact analysis take one command. Arguments after "--" are
+// passed on to every check.
+func runOutdated(args []string) {
+	fs := flag.NewFlagSet("outdated", flag.ExitOnError)
+	projectPath := fs.String("project-path", ".", "Path to your Go project; separate several paths with commas to check each service of a monorepo")
+	format := fs.String("format", FormatText, "Output format: text, markdown, json or sarif")
+	view := fs.String("view", ViewInline, "Text rendering of changed signatures: inline or side-by-side")
+	indirect := fs.Bool("indirect", false, "Also check indirect dependencies")
+	listOnly := fs.Bool("list", false, "Only list the dependencies with updates, without checking them")
+	failThreshold := failOn{severity: SeverityBreaking}
+	fs.Var(&failThreshold, "fail-on", "Exit with status 1 when a finding has at least this severity: info, warning, breaking, critical, or none to only fail on analysis errors (status 2)")
+	fs.Parse(args)
+
+	if !validFormat(*format) {
+		fatalf("Unknown format %q: must be one of %s", *format, formatNames())
+	}
+	if *view != ViewInline && *view != ViewSideBySide {
+		fatalf("Unknown view %q: must be %s or %s", *view, ViewInline, ViewSideBySide)
+	}
+
+	toolEnv = buildToolEnv(os.Environ(), nil)
+
+	first := strings.TrimSpace(strings.Split(*projectPath, ",")[0])
+	upgrades, err := outdatedModules(first, *indirect)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	if *listOnly {
+		if *format == FormatJSON {
+			data, err := json.MarshalIndent(upgrades, "", "  ")
+			if err != nil {
+				fatalf("%v", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		for _, upgrade := range upgrades {
+			line := fmt.Sprintf("%s %s -> %s", upgrade.Module, upgrade.OldVersion, upgrade.NewVersion)
+			if upgrade.Indirect {
+				line += " (indirect)"
+			}
+			fmt.Println(line)
+		}
+		return
+	}
+
+	partial.output(*format, *view, false)
+	batch := &batchReport{Modules: []*Report{}}
+	checkUpgrades(batch, *projectPath, upgrades, fs.Args())
+	partial.finish()
+	if err := renderBatch(batch, *format, *view, false); err != nil {
+		fatalf("%v", err)
+	}
+	if err := appendJobSummary(batch.Modules...); err != nil {
+		log.Printf("Warning: could not write the job summary: %v", err)
+	}
+	switch {
+	case len(batch.Failed) > 0:
+		exit(ExitError)
+	case failThreshold.fails(batch.Modules...):
+		exit(ExitFindings)
+	}
+}
+
+// outdatedModules returns the dependencies of the project with a newer
+// version according to go list -m -u, which resolves updates the way go get
+// does: honoring GOPROXY, GOPRIVATE and GOFLAGS, skipping retracted versions,
+// and keeping pre-releases for modules already on one. Indirect dependencies
+// are only included when asked for. Replaced modules are skipped, since the
+// replacement, not the update, is what the build uses.
+func outdatedModules(projectPath string, indirect bool) ([]moduleUpgrade, error) {
+	goModPath, err := findGoMod(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if goModPath == "" {
+		return nil, fmt.Errorf("no go.mod found for %s", projectPath)
+	}
+
+	cmd := command("go", "list", "-m", "-u", "-json", "all")
+	cmd.Dir = filepath.Dir(goModPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("go list -m -u all failed: %s", msg)
+		}
+		return nil, fmt.Errorf("go list -m -u all failed: %w", err)
+	}
+
+	var upgrades []moduleUpgrade
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var m listedModule
+		if err := decoder.Decode(&m); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse go list output: %w", err)
+		}
+		switch {
+		case m.Main || m.Update == nil || (m.Indirect && !indirect):
+			continue
+		case m.Error != nil:
+			log.Printf("Warning: skipping %s: %s", m.Path, m.Error.Err)
+			continue
+		case m.Replace != nil:
+			log.Printf("Skipping %s: replaced by %s", m.Path, strings.TrimSpace(m.Replace.Path+" "+m.Replace.Version))
+			continue
+		}
+		upgrades = append(upgrades, moduleUpgrade{
+			Module:     m.Path,
+			OldVersion: m.Version,
+			NewVersion: m.Update.Version,
+			Indirect:   m.Indirect,
+		})
+	}
+	return upgrades, nil
+}
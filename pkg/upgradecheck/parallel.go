@@ -0,0 +1,108 @@
+package upgradecheck
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// shardDocuments splits docs into one contiguous shard per CPU, runs fn on the
+// shards concurrently and returns their results in shard order, so merging them
+// in turn gives the same result as processing the documents serially. Large
+// project indexes hold tens of thousands of documents.
+func shardDocuments[T any](docs []*scip.Document, fn func([]*scip.Document) T) []T {
+	shards := min(runtime.GOMAXPROCS(0), len(docs))
+	if shards <= 1 {
+		return []T{fn(docs)}
+	}
+
+	size := (len(docs) + shards - 1) / shards
+	results := make([]T, shards)
+	var wg sync.WaitGroup
+	for i := range shards {
+		start := min(i*size, len(docs))
+		end := min(start+size, len(docs))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = fn(docs[start:end])
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// symbolDefinitions collects the definitions of the symbols declared in docs,
+// keyed like getAvailableSymbols. Types get one definition per member.
+func symbolDefinitions(docs []*scip.Document) *definitionSet {
+	set := newDefinitionSet()
+	for _, doc := range docs {
+		for _, sym := range doc.Symbols {
+			val, typ := extractSymbolsFromOccurrence(sym.Symbol)
+			if val == "" || len(sym.Documentation) == 0 {
+				continue
+			}
+			def := extractSymbolDefinition(sym.Documentation[0])
+			if def == "" {
+				continue
+			}
+			if typ == "type" && !strings.Contains(val, "#") {
+				continue
+			}
+			set.add(definitionKey(val, typ), def, canonicalSignature(def))
+		}
+	}
+	return set
+}
+
+// indexDefinitions is symbolDefinitions over all documents of an index,
+// processed in parallel. The members of a type are gathered from every
+// document before the versions are compared, wherever the package declares
+// them.
+func indexDefinitions(index *scip.Index) map[string][]string {
+	merged := newDefinitionSet()
+	for _, shard := range shardDocuments(index.Documents, symbolDefinitions) {
+		for key, defs := range shard.defs {
+			for i, def := range defs {
+				merged.add(key, def, shard.canonical[key][i])
+			}
+		}
+	}
+	return merged.defs
+}
+
+// definitionSet files definitions by key, skipping those already there: a
+// member carried by several documents, such as the files of each platform
+// merged into one index, counts once
+type definitionSet struct {
+	defs map[string][]string
+	// canonical holds the canonical signature of each definition in defs, in
+	// the same order, and seen the set of them by key, so each definition is
+	// only parsed once however many are filed under its key
+	canonical map[string][]string
+	seen      map[string]map[string]bool
+}
+
+func newDefinitionSet() *definitionSet {
+	return &definitionSet{
+		defs:      make(map[string][]string),
+		canonical: make(map[string][]string),
+		seen:      make(map[string]map[string]bool),
+	}
+}
+
+// add files def, whose canonical signature is canonical, under key unless an
+// equivalent definition is there already
+func (s *definitionSet) add(key, def, canonical string) {
+	if s.seen[key][canonical] {
+		return
+	}
+	if s.seen[key] == nil {
+		s.seen[key] = make(map[string]bool)
+	}
+	s.seen[key][canonical] = true
+	s.defs[key] = append(s.defs[key], def)
+	s.canonical[key] = append(s.canonical[key], canonical)
+}
@@ -0,0 +1,35 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+func TestIndexDefinitions(t *testing.T) {
+	member := func(def string) *scip.SymbolInformation {
+		return &scip.SymbolInformation{
+			Symbol:        "scip-go gomod example.com/m v1.0.0 `example.com/m`/Client#Do().",
+			Documentation: []string{"```go\n" + def + "\n```"},
+		}
+	}
+	// Each platform's file declares the method, in more documents than there
+	// are shards
+	var docs []*scip.Document
+	for i := range 64 {
+		def := "func (c *Client) Do(req *Request) error"
+		if i%2 == 1 {
+			def = "func (client *Client) Do(r *Request) error"
+		}
+		docs = append(docs, &scip.Document{RelativePath: fmt.Sprintf("do_%d.go", i), Symbols: []*scip.SymbolInformation{member(def)}})
+	}
+	docs = append(docs, &scip.Document{RelativePath: "do_plan9.go", Symbols: []*scip.SymbolInformation{member("func (c *Client) Do(req *Request) (int, error)")}})
+
+	got := indexDefinitions(&scip.Index{Documents: docs})
+	want := map[string][]string{"Client#Do": {"func (c *Client) Do(req *Request) error", "func (c *Client) Do(req *Request) (int, error)"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("indexDefinitions() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package upgradecheck
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// pinnedVersion returns the version of modulePath the project at projectPath
+// builds against, as required by its go.mod and adjusted by a replace
+// directive pointing at another version of the same module
+func pinnedVersion(projectPath, modulePath string) (string, error) {
+	goModPath, err := findGoMod(projectPath)
+	if err != nil {
+		return "", err
+	}
+	if goModPath == "" {
+		return "", fmt.Errorf("no go.mod found for %s", projectPath)
+	}
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return "", err
+	}
+	// Replace directives only apply to the main module, so ParseLax drops them
+	file, err := modfile.Parse(goModPath, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", goModPath, err)
+	}
+
+	var version string
+	for _, req := range file.Require {
+		if req.Mod.Path == modulePath {
+			version = req.Mod.Version
+		}
+	}
+	if version == "" {
+		return "", fmt.Errorf("%s does not require %s", goModPath, modulePath)
+	}
+	for _, rep := range file.Replace {
+		if rep.Old.Path != modulePath || (rep.Old.Version != "" && rep.Old.Version != version) {
+			continue
+		}
+		if rep.New.Path != modulePath || rep.New.Version == "" {
+			return "", fmt.Errorf("%s replaces %s with %s", goModPath, modulePath, strings.TrimSpace(rep.New.Path+" "+rep.New.Version))
+		}
+		version = rep.New.Version
+	}
+
+	if !inGoSum(filepath.Join(filepath.Dir(goModPath), "go.sum"), modulePath, version) {
+		log.Printf("Warning: %s@%s is missing from go.sum; run go mod tidy if the go.mod is out of date", modulePath, version)
+	}
+	return version, nil
+}
+
+// inGoSum reports whether the go.sum at path has a checksum for the go.mod of
+// modulePath@version, which the go command records for every module in the
+// build. A missing go.sum counts as not containing it.
+func inGoSum(path, modulePath, version string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == modulePath && strings.TrimSuffix(fields[1], "/go.mod") == version {
+			return true
+		}
+	}
+	return false
+}
+
+// latestRelease returns the newest version of modulePath published on the
+// module proxy, skipping pre-releases unless includePrerelease is set
+func latestRelease(modulePath string, includePrerelease bool) (string, error) {
+	versions, err := listVersions(modulePath)
+	if err != nil {
+		return "", err
+	}
+	semver.Sort(versions)
+	latest := newestVersion(versions, includePrerelease)
+	if latest == "" {
+		return "", fmt.Errorf("no tagged versions of %s found", modulePath)
+	}
+	return latest, nil
+}
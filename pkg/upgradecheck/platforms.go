@@ -0,0 +1,118 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// platform is a GOOS/GOARCH pair plus build tags the project is indexed under
+type platform struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+func (p platform) String() string {
+	s := p.GOOS + "/" + p.GOARCH
+	if len(p.Tags) > 0 {
+		s += ":" + strings.Join(p.Tags, "+")
+	}
+	return s
+}
+
+// env returns the variables selecting the platform for scip-go. Build tags are
+// appended to any GOFLAGS already in effect.
+func (p platform) env() []string {
+	env := []string{"GOOS=" + p.GOOS, "GOARCH=" + p.GOARCH}
+	if len(p.Tags) > 0 {
+		goflags := strings.TrimSpace(toolGetenv("GOFLAGS") + " -tags=" + strings.Join(p.Tags, ","))
+		env = append(env, "GOFLAGS="+goflags)
+	}
+	return env
+}
+
+// parsePlatforms parses a comma separated list of goos/goarch[:tag1+tag2]
+func parsePlatforms(list string) ([]platform, error) {
+	var platforms []platform
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target, tags, _ := strings.Cut(entry, ":")
+		goos, goarch, ok := strings.Cut(target, "/")
+		if !ok || goos == "" || goarch == "" {
+			return nil, fmt.Errorf("invalid platform %q: expected goos/goarch[:tag1+tag2]", entry)
+		}
+		p := platform{GOOS: goos, GOARCH: goarch}
+		if tags != "" {
+			p.Tags = strings.Split(tags, "+")
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
+
+// generateProjectIndex indexes the project once per platform and merges the
+// results into a single index, so files only built on some platforms
+// (_windows.go, _darwin.go, tagged files) contribute their usages. Without
+// platforms the project is indexed for the host platform. A non-empty scope
+// limits indexing to those packages.
+func generateProjectIndex(projectPath string, platforms []platform, scope packageScope) (string, error) {
+	if len(platforms) == 0 {
+		return generateScipIndex(projectPath, scope)
+	}
+
+	var indexPaths []string
+	defer func() {
+		for _, p := range indexPaths {
+			releaseIndex(p)
+		}
+	}()
+	for _, p := range platforms {
+		indexPath, err := generateScipIndex(projectPath, scope, p.env()...)
+		if err != nil {
+			return "", fmt.Errorf("indexing for %s: %w", p, err)
+		}
+		indexPaths = append(indexPaths, indexPath)
+	}
+
+	merged, err := mergeIndexes(indexPaths)
+	if err != nil {
+		return "", err
+	}
+	mergedPath, err := saveIndex(merged)
+	if err != nil {
+		return "", err
+	}
+	for i, p := range indexPaths {
+		var gaps []packageGap
+		for _, gap := range gapsOf(p) {
+			gap.Error = fmt.Sprintf("on %s: %s", platforms[i], gap.Error)
+			gaps = append(gaps, gap)
+		}
+		recordGaps(mergedPath, gaps)
+	}
+	return mergedPath, nil
+}
+
+// mergeIndexes returns an index holding the documents of all given indexes.
+// Documents indexed under several platforms appear once per platform; usage
+// collection deduplicates identical occurrences.
+func mergeIndexes(indexPaths []string) (*scip.Index, error) {
+	var merged scip.Index
+	for _, indexPath := range indexPaths {
+		index, err := loadIndex(indexPath)
+		if err != nil {
+			return nil, err
+		}
+		if merged.Metadata == nil {
+			merged.Metadata = index.Metadata
+		}
+		merged.Documents = append(merged.Documents, index.Documents...)
+		merged.ExternalSymbols = append(merged.ExternalSymbols, index.ExternalSymbols...)
+	}
+	return &merged, nil
+}
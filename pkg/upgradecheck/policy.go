@@ -0,0 +1,65 @@
+package upgradecheck
+
+import "fmt"
+
+// Policy controls how findings are graded before they are reported
+type Policy struct {
+	// CriticalFiles escalates a breaking finding to critical when the symbol is
+	// referenced from at least this many project files. Zero disables the check.
+	CriticalFiles int
+	// CriticalPackages escalates a breaking finding to critical when the symbol is
+	// referenced from more than this many project packages. Zero disables the check.
+	CriticalPackages int
+	// Deleting lists project packages scheduled for deletion. Findings only used
+	// in them are reported one severity lower, since migrating that code is
+	// wasted effort.
+	Deleting packageScope
+	// MaxSeverity caps the severity of every finding, e.g. for a module whose
+	// breakage the team has accepted; nil leaves findings as graded
+	MaxSeverity *Severity
+}
+
+// Apply grades every finding according to the policy and re-sorts them so the
+// most severe changes come first
+func (p Policy) Apply(findings []Finding) {
+	for i := range findings {
+		findings[i].Severity = p.escalate(findings[i])
+		if p.onlyDeleting(findings[i]) && findings[i].Severity > SeverityInfo {
+			findings[i].Severity--
+			findings[i].Notes = append(findings[i].Notes, "only used in packages scheduled for deletion")
+		}
+		if p.MaxSeverity != nil && findings[i].Severity > *p.MaxSeverity {
+			findings[i].Notes = append(findings[i].Notes, fmt.Sprintf("reported as %s instead of %s by the severity policy", *p.MaxSeverity, findings[i].Severity))
+			findings[i].Severity = *p.MaxSeverity
+		}
+	}
+	sortFindings(findings)
+}
+
+// escalate raises the severity of breaking findings with a large blast radius
+func (p Policy) escalate(f Finding) Severity {
+	if f.Severity < SeverityBreaking {
+		return f.Severity
+	}
+	if p.CriticalFiles > 0 && len(f.Files()) >= p.CriticalFiles {
+		return SeverityCritical
+	}
+	if p.CriticalPackages > 0 && len(f.Packages()) > p.CriticalPackages {
+		return SeverityCritical
+	}
+	return f.Severity
+}
+
+// onlyDeleting reports whether every usage of the finding is in a package
+// scheduled for deletion
+func (p Policy) onlyDeleting(f Finding) bool {
+	if len(p.Deleting) == 0 || len(f.Usages) == 0 {
+		return false
+	}
+	for _, loc := range f.Usages {
+		if !p.Deleting.contains(loc.Path) {
+			return false
+		}
+	}
+	return true
+}
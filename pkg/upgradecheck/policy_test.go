@@ -0,0 +1,140 @@
+package upgradecheck
+
+import (
+	"slices"
+	"testing"
+)
+
+// usagesIn returns one usage in each of files
+func usagesIn(files ...string) []Location {
+	var usages []Location
+	for i, file := range files {
+		usages = append(usages, Location{Path: file, Line: i + 1})
+	}
+	return usages
+}
+
+func TestPolicyApply(t *testing.T) {
+	warning := SeverityWarning
+	tests := []struct {
+		name      string
+		policy    Policy
+		severity  Severity
+		usages    []Location
+		want      Severity
+		wantNotes []string
+	}{
+		{
+			name:     "no policy",
+			severity: SeverityBreaking,
+			usages:   usagesIn("a/a.go", "b/b.go"),
+			want:     SeverityBreaking,
+		},
+		{
+			name:     "at critical files",
+			policy:   Policy{CriticalFiles: 2},
+			severity: SeverityBreaking,
+			usages:   usagesIn("a/a.go", "a/b.go"),
+			want:     SeverityCritical,
+		},
+		{
+			name:     "below critical files",
+			policy:   Policy{CriticalFiles: 3},
+			severity: SeverityBreaking,
+			// Several usages in one file count once
+			usages: append(usagesIn("a/a.go", "a/b.go"), Location{Path: "a/a.go", Line: 9}),
+			want:   SeverityBreaking,
+		},
+		{
+			name:     "above critical packages",
+			policy:   Policy{CriticalPackages: 1},
+			severity: SeverityBreaking,
+			usages:   usagesIn("a/a.go", "b/b.go"),
+			want:     SeverityCritical,
+		},
+		{
+			name:     "at critical packages",
+			policy:   Policy{CriticalPackages: 2},
+			severity: SeverityBreaking,
+			usages:   usagesIn("a/a.go", "a/b.go", "b/b.go"),
+			want:     SeverityBreaking,
+		},
+		{
+			name:     "warning not escalated",
+			policy:   Policy{CriticalFiles: 1, CriticalPackages: 1},
+			severity: SeverityWarning,
+			usages:   usagesIn("a/a.go", "b/b.go"),
+			want:     SeverityWarning,
+		},
+		{
+			name:      "only used in deleted packages",
+			policy:    Policy{Deleting: packageScope{"./legacy/..."}},
+			severity:  SeverityBreaking,
+			usages:    usagesIn("legacy/a.go", "legacy/old/b.go"),
+			want:      SeverityWarning,
+			wantNotes: []string{"only used in packages scheduled for deletion"},
+		},
+		{
+			name:     "also used outside deleted packages",
+			policy:   Policy{Deleting: packageScope{"./legacy/..."}},
+			severity: SeverityBreaking,
+			usages:   usagesIn("legacy/a.go", "b/b.go"),
+			want:     SeverityBreaking,
+		},
+		{
+			name:     "info in deleted packages",
+			policy:   Policy{Deleting: packageScope{"./legacy"}},
+			severity: SeverityInfo,
+			usages:   usagesIn("legacy/a.go"),
+			want:     SeverityInfo,
+		},
+		{
+			name:      "escalated then lowered",
+			policy:    Policy{CriticalFiles: 2, Deleting: packageScope{"./legacy"}},
+			severity:  SeverityBreaking,
+			usages:    usagesIn("legacy/a.go", "legacy/b.go"),
+			want:      SeverityBreaking,
+			wantNotes: []string{"only used in packages scheduled for deletion"},
+		},
+		{
+			name:      "capped",
+			policy:    Policy{CriticalFiles: 1, MaxSeverity: &warning},
+			severity:  SeverityBreaking,
+			usages:    usagesIn("a/a.go"),
+			want:      SeverityWarning,
+			wantNotes: []string{"reported as warning instead of critical by the severity policy"},
+		},
+		{
+			name:     "below the cap",
+			policy:   Policy{MaxSeverity: &warning},
+			severity: SeverityInfo,
+			usages:   usagesIn("a/a.go"),
+			want:     SeverityInfo,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := []Finding{{Symbol: "Client.Do", Kind: ChangeChanged, Severity: tt.severity, Usages: tt.usages}}
+			tt.policy.Apply(findings)
+			if got := findings[0]; got.Severity != tt.want || !slices.Equal(got.Notes, tt.wantNotes) {
+				t.Errorf("Apply() = %s with notes %q, want %s with notes %q", got.Severity, got.Notes, tt.want, tt.wantNotes)
+			}
+		})
+	}
+}
+
+func TestPolicyApplySorts(t *testing.T) {
+	findings := []Finding{
+		{Symbol: "B", Kind: ChangeChanged, Severity: SeverityBreaking, Usages: usagesIn("a/a.go")},
+		{Symbol: "A", Kind: ChangeChanged, Severity: SeverityWarning},
+		{Symbol: "C", Kind: ChangeChanged, Severity: SeverityBreaking, Usages: usagesIn("a/a.go", "b/b.go")},
+	}
+	Policy{CriticalPackages: 1}.Apply(findings)
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Symbol)
+	}
+	if want := []string{"C", "B", "A"}; !slices.Equal(got, want) {
+		t.Errorf("Apply() order = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// parseProjectIndexes splits --project-index, which names one pre-built index
+// per project of --project-path, in the same order
+func parseProjectIndexes(list, projectPath string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var indexes, projects []string
+	for _, path := range strings.Split(list, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			indexes = append(indexes, path)
+		}
+	}
+	for _, path := range strings.Split(projectPath, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			projects = append(projects, path)
+		}
+	}
+	if len(indexes) != len(projects) {
+		return nil, fmt.Errorf("--project-index names %d indexes for %d projects; pass one per --project-path", len(indexes), len(projects))
+	}
+	for _, path := range indexes {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("invalid --project-index: %w", err)
+		}
+	}
+	return indexes, nil
+}
+
+// symbolVersion returns the version field of a scip-go symbol, or ""
+func symbolVersion(symbol string) string {
+	fields := strings.SplitN(symbol, " ", 5)
+	if len(fields) < 5 || fields[1] != "gomod" {
+		return ""
+	}
+	return fields[3]
+}
+
+// indexedModules returns the versions of the modules whose symbols an index
+// defines, or references when references is set, by module path
+func indexedModules(indexPath string, references bool) (map[string]map[string]bool, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	modules := make(map[string]map[string]bool)
+	add := func(symbol string) {
+		if m := symbolModule(symbol); m != "" {
+			if modules[m] == nil {
+				modules[m] = make(map[string]bool)
+			}
+			modules[m][symbolVersion(symbol)] = true
+		}
+	}
+	for _, doc := range index.Documents {
+		if references {
+			for _, occ := range doc.Occurrences {
+				add(occ.Symbol)
+			}
+			continue
+		}
+		for _, sym := range doc.Symbols {
+			add(sym.Symbol)
+		}
+	}
+	return modules, nil
+}
+
+// taggedVersions returns the release versions among the versions an index
+// records; scip-go records "." or pseudo-versions for untagged checkouts,
+// which don't tell which release was indexed
+func taggedVersions(versions map[string]bool) []string {
+	var tagged []string
+	for v := range versions {
+		if semver.IsValid(v) && !module.IsPseudoVersion(v) {
+			tagged = append(tagged, v)
+		}
+	}
+	semver.Sort(tagged)
+	return tagged
+}
+
+// indexedVersion returns the release of modulePath a pre-built index of the
+// module defines symbols of, when it records one, so --new-version can be
+// left out
+func indexedVersion(indexPath, modulePath string) (string, error) {
+	modules, err := indexedModules(indexPath, false)
+	if err != nil {
+		return "", err
+	}
+	tagged := taggedVersions(modules[modulePath])
+	if len(tagged) != 1 {
+		return "", fmt.Errorf("%s doesn't record which version of %s it indexes; pass --new-version", indexPath, modulePath)
+	}
+	return tagged[0], nil
+}
+
+// validateModuleIndex checks that a pre-built index given for modulePath at
+// version defines symbols of that module and, where the index records the
+// version, of that version
+func validateModuleIndex(flagName, indexPath, modulePath, version string) error {
+	modules, err := indexedModules(indexPath, false)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", flagName, err)
+	}
+	versions, ok := modules[modulePath]
+	if !ok {
+		var defined []string
+		for m := range modules {
+			defined = append(defined, m)
+		}
+		sort.Strings(defined)
+		if len(defined) > 3 {
+			defined = append(defined[:3], "...")
+		}
+		if len(defined) == 0 {
+			return fmt.Errorf("%s %s defines no symbols of %s", flagName, indexPath, modulePath)
+		}
+		return fmt.Errorf("%s %s defines no symbols of %s but of %s; pass the index of the module itself", flagName, indexPath, modulePath, strings.Join(defined, ", "))
+	}
+	if tagged := taggedVersions(versions); len(tagged) > 0 && !versions[version] {
+		return fmt.Errorf("%s %s indexes %s@%s, not %s", flagName, indexPath, modulePath, strings.Join(tagged, ", "), version)
+	}
+	return nil
+}
+
+// checkProjectIndex warns about a pre-built project index that doesn't look
+// like the index of the project at dir built against modulePath@version:
+// one not referencing the module, referencing other versions of it, or whose
+// files aren't in dir, which call sites and owners are read from
+func checkProjectIndex(indexPath, dir, modulePath, version string) error {
+	modules, err := indexedModules(indexPath, true)
+	if err != nil {
+		return fmt.Errorf("invalid --project-index: %w", err)
+	}
+	if versions, ok := modules[modulePath]; !ok {
+		log.Printf("Warning: --project-index %s doesn't reference %s, so no usages of it are found", indexPath, modulePath)
+	} else if tagged := taggedVersions(versions); len(tagged) > 0 && !versions[version] {
+		log.Printf("Warning: --project-index %s was built against %s@%s, not %s; usages of symbols that differ between them may be missed", indexPath, modulePath, strings.Join(tagged, ", "), version)
+	}
+
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	for _, doc := range index.Documents {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(doc.RelativePath))); err == nil {
+			return nil
+		}
+	}
+	if len(index.Documents) > 0 {
+		log.Printf("Warning: none of the files of --project-index %s are in %s; pass the index of that project, built from its root", indexPath, dir)
+	}
+	return nil
+}
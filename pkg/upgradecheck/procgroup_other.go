@@ -0,0 +1,12 @@
+//go:build !unix
+
+package upgradecheck
+
+import "os/exec"
+
+// killProcessGroup leaves canceling cmd to kill only cmd itself on this
+// platform
+func killProcessGroup(cmd *exec.Cmd) {}
+
+// terminateOnCancel leaves canceling cmd to kill it on this platform
+func terminateOnCancel(cmd *exec.Cmd) {}
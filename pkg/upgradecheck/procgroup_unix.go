@@ -0,0 +1,26 @@
+//go:build unix
+
+package upgradecheck
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup runs cmd in a process group of its own and makes canceling
+// it kill the whole group, so processes it started, such as the go commands
+// scip-go runs to load packages, don't outlive it
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// terminateOnCancel makes canceling cmd send it SIGTERM instead of killing it,
+// so a check run as a subprocess removes its temporary files before exiting
+func terminateOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+}
@@ -0,0 +1,148 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Progress formats understood by --progress-format
+const (
+	ProgressText   = "text"
+	ProgressNDJSON = "ndjson"
+)
+
+// progressEvent is one line of --progress-format=ndjson output
+type progressEvent struct {
+	Time time.Time `json:"time"`
+	// Type is "phase", "progress", "finding", "log" or "output"
+	Type string `json:"type"`
+	// Phase and Status ("start", "end" or "error") describe phase events
+	Phase      string         `json:"phase,omitempty"`
+	Status     string         `json:"status,omitempty"`
+	DurationMS int64          `json:"duration_ms,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Service    string         `json:"service,omitempty"`
+	Current    int            `json:"current,omitempty"`
+	Total      int            `json:"total,omitempty"`
+	Finding    *Finding       `json:"finding,omitempty"`
+	Message    string         `json:"message,omitempty"`
+}
+
+// progress writes events as they happen. It is nil unless ndjson progress was
+// requested, in which case it owns stderr: log messages and the output of
+// subprocesses are wrapped into events too.
+var progress *progressWriter
+
+type progressWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// enableProgress switches stderr to ndjson events
+func enableProgress(w io.Writer) {
+	progress = &progressWriter{enc: json.NewEncoder(w)}
+}
+
+// emit writes an event; it is a no-op when progress events are disabled
+func (p *progressWriter) emit(e progressEvent) {
+	if p == nil {
+		return
+	}
+	e.Time = time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enc.Encode(e)
+}
+
+// lineWriter turns each line written to it into an event of the given type;
+// it serves as log output and as subprocess stderr
+type lineWriter struct {
+	mu        sync.Mutex
+	eventType string
+	buf       bytes.Buffer
+}
+
+func (w *lineWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(data)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			return len(data), nil
+		}
+		progress.emit(progressEvent{Type: w.eventType, Message: line[:len(line)-1]})
+	}
+}
+
+// subprocessStderr is where git, go, scip-go and bazel write their diagnostics:
+// as progress events, in the debug log shown with --verbose, or along with the
+// log when embedded
+func subprocessStderr() io.Writer {
+	switch {
+	case progress != nil:
+		return &lineWriter{eventType: "output"}
+	case leveledLogs:
+		return &debugLines{}
+	}
+	return log.Writer()
+}
+
+// phaseSpan is a span that additionally reports its phase as progress events
+type phaseSpan struct {
+	trace.Span
+	name  string
+	start time.Time
+	attrs map[string]any
+	err   error
+}
+
+func newPhaseSpan(span trace.Span, name string, attrs []attribute.KeyValue) *phaseSpan {
+	s := &phaseSpan{Span: span, name: name, start: time.Now(), attrs: make(map[string]any)}
+	s.setAttrs(attrs)
+	progress.emit(progressEvent{Type: "phase", Phase: name, Status: "start", Attributes: s.attrs})
+	return s
+}
+
+func (s *phaseSpan) setAttrs(attrs []attribute.KeyValue) {
+	for _, kv := range attrs {
+		s.attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+}
+
+func (s *phaseSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.setAttrs(kv)
+	s.Span.SetAttributes(kv...)
+}
+
+func (s *phaseSpan) RecordError(err error, options ...trace.EventOption) {
+	s.err = err
+	s.Span.RecordError(err, options...)
+}
+
+func (s *phaseSpan) End(options ...trace.SpanEndOption) {
+	e := progressEvent{
+		Type:       "phase",
+		Phase:      s.name,
+		Status:     "end",
+		DurationMS: time.Since(s.start).Milliseconds(),
+		Attributes: s.attrs,
+	}
+	if s.err != nil {
+		e.Status = "error"
+		e.Message = s.err.Error()
+	}
+	progress.emit(e)
+	logPhase(s.name, time.Since(s.start), s.err)
+	s.Span.End(options...)
+}
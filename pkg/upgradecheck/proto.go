@@ -0,0 +1,221 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// protoField is a message field as generated by protoc-gen-go
+type protoField struct {
+	GoName string
+	// Name is the field's name in the .proto file
+	Name   string
+	Number int
+	// Type is the Go type of the field
+	Type string
+}
+
+// protoFields returns the fields of the message generated as Go type typeName
+// in the .pb.go file at path, keyed by Go field name. Oneof wrappers carry no
+// field number and are left out.
+func (s *versionSource) protoFields(path, typeName string) (map[string]protoField, error) {
+	file, err := s.file(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || ts.Name.Name != typeName {
+				continue
+			}
+			fields := make(map[string]protoField)
+			for _, field := range st.Fields.List {
+				if field.Tag == nil || len(field.Names) == 0 {
+					continue
+				}
+				tag, err := strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					continue
+				}
+				// e.g. protobuf:"bytes,3,opt,name=user_id,json=userId,proto3"
+				parts := strings.Split(reflect.StructTag(tag).Get("protobuf"), ",")
+				if len(parts) < 2 {
+					continue
+				}
+				number, err := strconv.Atoi(parts[1])
+				if err != nil {
+					continue
+				}
+				f := protoField{GoName: field.Names[0].Name, Number: number, Type: types.ExprString(field.Type)}
+				for _, part := range parts[2:] {
+					if name, ok := strings.CutPrefix(part, "name="); ok {
+						f.Name = name
+					}
+				}
+				fields[f.GoName] = f
+			}
+			return fields, nil
+		}
+	}
+	return nil, nil
+}
+
+// protoSources reads the generated code of both versions. Either may be nil,
+// e.g. when replaying a session, in which case notes name the proto elements
+// without field numbers.
+type protoSources struct {
+	old, new                 *versionSource
+	oldDefinedIn, newDefined map[string]string
+}
+
+// fields returns the message fields of typeName in one version, nil if unknown
+func (p *protoSources) fields(src *versionSource, definedIn map[string]string, typeName string) map[string]protoField {
+	if src == nil {
+		return nil
+	}
+	file, ok := definedIn[typeName]
+	if !ok {
+		return nil
+	}
+	fields, err := src.protoFields(file, typeName)
+	if err != nil {
+		return nil
+	}
+	return fields
+}
+
+// isProtoGenerated reports whether findings has a finding on code generated
+// by protoc-gen-go or protoc-gen-go-grpc
+func isProtoGenerated(findings []Finding, definedIn map[string]string) bool {
+	for _, f := range findings {
+		if protoGenerator(f.Symbol, definedIn) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// protoGenerator returns the protobuf generator of the file declaring the
+// symbol's type, or ""
+func protoGenerator(symbol string, definedIn map[string]string) string {
+	typeName, _, _ := strings.Cut(symbol, ".")
+	generator, _, ok := generatedBy(definedIn[typeName])
+	if !ok || (generator != "protoc-gen-go" && generator != "protoc-gen-go-grpc") {
+		return ""
+	}
+	return generator
+}
+
+// annotateProto describes findings on generated protobuf code in proto terms:
+// removed and renamed message fields with their field numbers, enum values,
+// and removed or changed service methods. Reusing a field number for another
+// type breaks the wire format, so such findings are escalated to critical.
+func annotateProto(findings []Finding, sources *protoSources) {
+	for i := range findings {
+		f := &findings[i]
+		var note string
+		switch protoGenerator(f.Symbol, sources.oldDefinedIn) {
+		case "protoc-gen-go-grpc":
+			note = grpcNote(f)
+		case "protoc-gen-go":
+			note = sources.messageNote(f)
+		}
+		if note != "" {
+			f.Notes = append(f.Notes, "proto: "+note)
+		}
+	}
+}
+
+// grpcNote describes a change to a generated service client or server
+func grpcNote(f *Finding) string {
+	typeName, method, isMember := strings.Cut(f.Symbol, ".")
+	service := strings.TrimPrefix(typeName, "Unimplemented")
+	service, isClient := strings.CutSuffix(service, "Client")
+	if !isClient {
+		var isServer bool
+		if service, isServer = strings.CutSuffix(service, "Server"); !isServer {
+			return ""
+		}
+	}
+	switch {
+	case !isMember && f.Kind == ChangeRemoved:
+		return fmt.Sprintf("service %s removed", service)
+	case !isMember || strings.HasPrefix(method, "mustEmbed"):
+		return ""
+	case f.Kind == ChangeRemoved:
+		return fmt.Sprintf("rpc %s.%s removed", service, method)
+	case f.Kind == ChangeAdded, f.Kind == ChangeImplementer && f.OldSignature == "":
+		return fmt.Sprintf("rpc %s.%s added", service, method)
+	default:
+		return fmt.Sprintf("rpc %s.%s changed its request or response message", service, method)
+	}
+}
+
+// messageNote describes a change to a generated message or enum
+func (p *protoSources) messageNote(f *Finding) string {
+	typeName, member, isMember := strings.Cut(f.Symbol, ".")
+	if !isMember {
+		// Enum values are constants of the enum type, e.g.
+		// "const Status_STATUS_ACTIVE Status = 1"
+		if sig := strings.Fields(f.OldSignature); len(sig) >= 3 && sig[0] == "const" {
+			if value, ok := strings.CutPrefix(typeName, sig[2]+"_"); ok && f.Kind == ChangeRemoved {
+				return fmt.Sprintf("enum value %s of %s removed", value, sig[2])
+			}
+			return ""
+		}
+		if f.Kind == ChangeRemoved {
+			return fmt.Sprintf("message %s removed", typeName)
+		}
+		return ""
+	}
+
+	oldFields := p.fields(p.old, p.oldDefinedIn, typeName)
+	prefix := ""
+	field, ok := oldFields[member]
+	if !ok {
+		if getter, isGetter := strings.CutPrefix(member, "Get"); isGetter {
+			field, ok = oldFields[getter]
+			prefix = "getter of "
+		}
+	}
+	if !ok {
+		if oldFields == nil && f.Kind == ChangeRemoved {
+			return fmt.Sprintf("field %s of message %s removed", member, typeName)
+		}
+		return ""
+	}
+
+	desc := fmt.Sprintf("%sfield %s = %d of message %s", prefix, field.Name, field.Number, typeName)
+	newFields := p.fields(p.new, p.newDefined, typeName)
+	if newFields == nil {
+		return fmt.Sprintf("%s %s", desc, f.Kind)
+	}
+	var renamed *protoField
+	for _, nf := range newFields {
+		if nf.Number == field.Number {
+			renamed = &nf
+			break
+		}
+	}
+	switch {
+	case renamed == nil:
+		return desc + " removed; check that its number is reserved"
+	case renamed.Type != field.Type:
+		f.Severity = max(f.Severity, SeverityCritical)
+		return fmt.Sprintf("%s now has type %s instead of %s under the same field number, which is not wire compatible", desc, renamed.Type, field.Type)
+	case renamed.GoName != field.GoName:
+		return fmt.Sprintf("%s renamed to %s; wire compatible, but Go code must use %s", desc, renamed.Name, renamed.GoName)
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+package upgradecheck
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// cacheProvenance records how a cached index was made, so teams sharing a
+// cache can tell what its entries were generated from
+type cacheProvenance struct {
+	// Indexer is the engine and version that generated the index, e.g.
+	// "scip-go v0.1.26"
+	Indexer string `json:"indexer"`
+	// Source is where the module version was read from: the module proxy or
+	// the repository cloned
+	Source string `json:"source"`
+	// SourceHash identifies the sources indexed: the go.sum hash ("h1:...")
+	// of the module zip, which can be checked against the checksum database,
+	// or the commit checked out of the repository
+	SourceHash string `json:"source_hash,omitempty"`
+}
+
+// errUnsigned marks cache entries without a signature
+var errUnsigned = errors.New("entry is not signed")
+
+// signedCachePayload is what the signature of a cache entry covers: its key,
+// the checksums of its files and its provenance, but not when it was last used
+func signedCachePayload(meta *cacheMeta) ([]byte, error) {
+	return json.Marshal(struct {
+		Key        string            `json:"key"`
+		Files      map[string]string `json:"files"`
+		Provenance *cacheProvenance  `json:"provenance"`
+	}{meta.Key, meta.Files, meta.Provenance})
+}
+
+// signCacheMeta signs a cache entry with key
+func signCacheMeta(meta *cacheMeta, key ed25519.PrivateKey) error {
+	payload, err := signedCachePayload(meta)
+	if err != nil {
+		return fmt.Errorf("failed to sign cache entry: %w", err)
+	}
+	meta.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	meta.SignedBy = keyID(key.Public().(ed25519.PublicKey))
+	return nil
+}
+
+// verifyCacheSignature checks that a cache entry was signed with the private
+// key of pub
+func verifyCacheSignature(meta *cacheMeta, pub ed25519.PublicKey) error {
+	if meta.Signature == "" {
+		return errUnsigned
+	}
+	sig, err := base64.StdEncoding.DecodeString(meta.Signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	payload, err := signedCachePayload(meta)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, payload, sig) {
+		if meta.SignedBy != keyID(pub) {
+			return fmt.Errorf("signed by key %s, not the trusted key %s", meta.SignedBy, keyID(pub))
+		}
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// keyID is a short fingerprint of a public key for messages and metadata
+func keyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// loadSigningKey reads an Ed25519 private key from a PEM file, as written by
+// cache keygen
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+	signer, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an Ed25519 key", path)
+	}
+	return signer, nil
+}
+
+// loadVerifyKey reads an Ed25519 public key from a PEM file, as written by
+// cache keygen
+func loadVerifyKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse verification key %s: %w", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("verification key %s is not an Ed25519 key", path)
+	}
+	return pub, nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s holds no PEM %s block", path, blockType)
+	}
+	return block.Bytes, nil
+}
+
+// generateSigningKey writes a new Ed25519 key pair to prefix.key, readable by
+// the owner only, and prefix.pub, returning the key ID
+func generateSigningKey(prefix string) (string, error) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key: %w", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	// Existing keys are never overwritten, since entries signed with them
+	// would no longer verify
+	f, err := os.OpenFile(prefix+".key", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to write signing key: %w", err)
+	}
+	err = pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write signing key: %w", err)
+	}
+	if err := os.WriteFile(prefix+".pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write verification key: %w", err)
+	}
+	return keyID(pub), nil
+}
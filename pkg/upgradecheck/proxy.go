@@ -0,0 +1,142 @@
+package upgradecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+const defaultProxy = "https://proxy.golang.org"
+
+var proxyClient = &http.Client{Timeout: 30 * time.Second}
+
+// proxyURL returns the first HTTP(S) entry of GOPROXY, falling back to the
+// public proxy when GOPROXY is unset or only lists direct/off
+func proxyURL() string {
+	for _, entry := range strings.FieldsFunc(toolGetenv("GOPROXY"), func(r rune) bool {
+		return r == ',' || r == '|'
+	}) {
+		if strings.HasPrefix(entry, "http://") || strings.HasPrefix(entry, "https://") {
+			return strings.TrimSuffix(entry, "/")
+		}
+	}
+	return defaultProxy
+}
+
+// proxyGet fetches a path below the module's proxy root, e.g. "@v/list".
+// Private modules are resolved with the go command instead.
+func proxyGet(modulePath, path string) ([]byte, error) {
+	if privateModule(modulePath) {
+		return directGet(modulePath, path)
+	}
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid module path %s: %w", modulePath, err)
+	}
+
+	url := fmt.Sprintf("%s/%s/%s", proxyURL(), escaped, path)
+	resp, err := proxyRequest(proxyClient, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query module proxy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("module proxy returned %s for %s", resp.Status, url)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// listVersions returns the tagged versions the proxy knows for a module
+func listVersions(modulePath string) ([]string, error) {
+	data, err := proxyGet(modulePath, "@v/list")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// suggestable returns the versions that may be suggested in place of version.
+// Pre-releases (v2.0.0-rc.1) only qualify when includePrerelease is set or
+// version is a pre-release itself, i.e. when release candidates are what's
+// being evaluated.
+func suggestable(versions []string, version string, includePrerelease bool) []string {
+	if includePrerelease || semver.Prerelease(version) != "" {
+		return versions
+	}
+	var releases []string
+	for _, v := range versions {
+		if semver.Prerelease(v) == "" {
+			releases = append(releases, v)
+		}
+	}
+	return releases
+}
+
+// fetchGoMod returns the go.mod file of a module at the given version
+func fetchGoMod(modulePath, version string) ([]byte, error) {
+	escaped, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %s: %w", version, err)
+	}
+	return proxyGet(modulePath, "@v/"+escaped+".mod")
+}
+
+// versionInfo is the JSON served by the proxy's @v/<version>.info endpoint
+type versionInfo struct {
+	Version string
+	Time    string
+	Origin  *struct {
+		VCS  string
+		URL  string
+		Hash string
+		Ref  string
+	}
+}
+
+// fetchVersionInfo returns the proxy's metadata for a module version
+func fetchVersionInfo(modulePath, version string) (*versionInfo, error) {
+	escaped, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %s: %w", version, err)
+	}
+	data, err := proxyGet(modulePath, "@v/"+escaped+".info")
+	if err != nil {
+		return nil, err
+	}
+
+	var info versionInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse version info for %s@%s: %w", modulePath, version, err)
+	}
+	return &info, nil
+}
+
+// resolveRevision maps a module version to something git can check out.
+// Pseudo-versions (v0.0.0-20240102150405-abcdef123456) of untagged commits have
+// no tag, so they are resolved to their commit: the full hash from the proxy's
+// info endpoint when available, otherwise the short hash embedded in the
+// version. The +incompatible suffix isn't part of a tag, so it is dropped;
+// other versions are returned unchanged.
+func resolveRevision(modulePath, version string) string {
+	if !module.IsPseudoVersion(version) {
+		return strings.TrimSuffix(version, incompatibleSuffix)
+	}
+
+	if info, err := fetchVersionInfo(modulePath, version); err == nil && info.Origin != nil && info.Origin.Hash != "" {
+		return info.Origin.Hash
+	}
+
+	rev, err := module.PseudoVersionRev(version)
+	if err != nil {
+		return version
+	}
+	return rev
+}
@@ -0,0 +1,163 @@
+package upgradecheck
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// setToolEnv runs the rest of a test with the subprocess environment holding
+// only the given KEY=value pairs
+func setToolEnv(t *testing.T, env ...string) {
+	t.Helper()
+	prev := toolEnv
+	toolEnv = env
+	t.Cleanup(func() { toolEnv = prev })
+}
+
+// serveProxy starts a module proxy serving files by path, e.g.
+// "/example.com/m/@v/list"
+func serveProxy(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, data)
+	}))
+	t.Cleanup(server.Close)
+	setToolEnv(t, "GOPROXY="+server.URL+",direct")
+	return server
+}
+
+func TestProxyURL(t *testing.T) {
+	tests := []struct {
+		goproxy, want string
+	}{
+		{"", defaultProxy},
+		{"direct", defaultProxy},
+		{"off", defaultProxy},
+		{"https://goproxy.example.com/", "https://goproxy.example.com"},
+		{"direct,https://goproxy.example.com", "https://goproxy.example.com"},
+		{"https://a.example.com|https://b.example.com", "https://a.example.com"},
+		{"http://localhost:3000,direct", "http://localhost:3000"},
+	}
+	for _, tt := range tests {
+		setToolEnv(t, "GOPROXY="+tt.goproxy)
+		if got := proxyURL(); got != tt.want {
+			t.Errorf("proxyURL() with GOPROXY=%q = %q, want %q", tt.goproxy, got, tt.want)
+		}
+	}
+}
+
+func TestProxyRequests(t *testing.T) {
+	serveProxy(t, map[string]string{
+		// Upper case letters are escaped in module paths and versions
+		"/github.com/!azure/sdk/@v/list":                            "v1.0.0\nv1.1.0\nv1.2.0-rc.1\n",
+		"/github.com/!azure/sdk/@v/v1.1.0.mod":                      "module github.com/Azure/sdk\n",
+		"/github.com/!azure/sdk/@v/v1.0.0-!r!c.mod":                 "module github.com/Azure/sdk\n\ngo 1.21\n",
+		"/example.com/m/@v/v0.0.0-20240102150405-abcdef123456.info": `{"Version":"v0.0.0-20240102150405-abcdef123456","Origin":{"VCS":"git","Hash":"abcdef1234567890abcdef1234567890abcdef12"}}`,
+	})
+
+	versions, err := listVersions("github.com/Azure/sdk")
+	if err != nil || strings.Join(versions, " ") != "v1.0.0 v1.1.0 v1.2.0-rc.1" {
+		t.Errorf("listVersions() = %q, %v", versions, err)
+	}
+	if goMod, err := fetchGoMod("github.com/Azure/sdk", "v1.1.0"); err != nil || string(goMod) != "module github.com/Azure/sdk\n" {
+		t.Errorf("fetchGoMod(v1.1.0) = %q, %v", goMod, err)
+	}
+	if _, err := fetchGoMod("github.com/Azure/sdk", "v1.0.0-RC"); err != nil {
+		t.Errorf("fetchGoMod(v1.0.0-RC) error: %v", err)
+	}
+	if _, err := fetchGoMod("github.com/Azure/sdk", "v9.0.0"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("fetchGoMod(v9.0.0) error = %v, want a 404", err)
+	}
+
+	tests := []struct {
+		module, version, want string
+	}{
+		{"example.com/m", "v1.2.3", "v1.2.3"},
+		{"example.com/m", "v2.0.0+incompatible", "v2.0.0"},
+		// The proxy knows the full hash of this pseudo-version's commit
+		{"example.com/m", "v0.0.0-20240102150405-abcdef123456", "abcdef1234567890abcdef1234567890abcdef12"},
+		// but not of this one, which falls back to the abbreviated hash
+		{"example.com/m", "v1.2.4-0.20240102150405-123456abcdef", "123456abcdef"},
+	}
+	for _, tt := range tests {
+		if got := resolveRevision(tt.module, tt.version); got != tt.want {
+			t.Errorf("resolveRevision(%s) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestable(t *testing.T) {
+	versions := []string{"v1.0.0", "v1.1.0-rc.1", "v1.1.0", "v2.0.0-beta.1"}
+	tests := []struct {
+		version           string
+		includePrerelease bool
+		want              string
+	}{
+		{"v1.1.0", false, "v1.0.0 v1.1.0"},
+		{"v1.1.0", true, "v1.0.0 v1.1.0-rc.1 v1.1.0 v2.0.0-beta.1"},
+		{"v2.0.0-beta.1", false, "v1.0.0 v1.1.0-rc.1 v1.1.0 v2.0.0-beta.1"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(suggestable(versions, tt.version, tt.includePrerelease), " "); got != tt.want {
+			t.Errorf("suggestable(%s, %v) = %q, want %q", tt.version, tt.includePrerelease, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadModule(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range map[string]string{
+		"example.com/m@v1.0.0/go.mod":   "module example.com/m\n",
+		"example.com/m@v1.0.0/pkg/a.go": "package pkg\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(w, contents)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zipPath := filepath.Join(t.TempDir(), "m.zip")
+	if err := os.WriteFile(zipPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wantSum, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveProxy(t, map[string]string{"/example.com/m/@v/v1.0.0.zip": buf.String()})
+
+	dir, sum, err := downloadModule(context.Background(), "example.com/m", "v1.0.0")
+	if err != nil {
+		t.Fatalf("downloadModule() error: %v", err)
+	}
+	defer cleanups.remove(dir)
+	if sum != wantSum {
+		t.Errorf("downloadModule() sum = %s, want %s", sum, wantSum)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "pkg", "a.go")); err != nil || string(data) != "package pkg\n" {
+		t.Errorf("pkg/a.go = %q, %v", data, err)
+	}
+
+	if _, _, err := downloadModule(context.Background(), "example.com/m", "v2.0.0"); err == nil {
+		t.Error("downloadModule() of a version the proxy lacks succeeded")
+	}
+}
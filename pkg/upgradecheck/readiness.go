@@ -0,0 +1,199 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// readinessEntry is the upgrade readiness of one direct dependency
+type readinessEntry struct {
+	Module  string `json:"module"`
+	Current string `json:"current_version"`
+	Latest  string `json:"latest_version"`
+	// Verdict is "up-to-date", "ok", the most severe finding's severity, or
+	// "error" when the check failed
+	Verdict   string `json:"verdict"`
+	RiskScore int    `json:"risk_score"`
+	Findings  int    `json:"findings"`
+	Error     string `json:"error,omitempty"`
+}
+
+// readinessReport is the dependency health of a project at one point in time
+type readinessReport struct {
+	Project      string           `json:"project"`
+	Generated    time.Time        `json:"generated"`
+	Dependencies []readinessEntry `json:"dependencies"`
+}
+
+// riskWeights is how much each finding adds to a dependency's risk score,
+// which is capped at 100
+var riskWeights = map[Severity]int{
+	SeverityCritical: 25,
+	SeverityBreaking: 10,
+	SeverityWarning:  3,
+	SeverityInfo:     1,
+}
+
+// runReadiness implements the "readiness" subcommand: it checks the upgrade of
+// every direct dependency of the project to its latest release and writes one
+// report, meant to be run nightly as a dependency health dashboard's data source
+func runReadiness(args []string) {
+	fs := flag.NewFlagSet("readiness", flag.ExitOnError)
+	projectPath := fs.String("project-path", ".", "Path to your Go project")
+	jsonPath := fs.String("json", "readiness.json", "Write the JSON report to this file (empty disables it)")
+	htmlPath := fs.String("html", "readiness.html", "Write the HTML report to this file (empty disables it)")
+	includePrerelease := fs.Bool("include-prerelease", false, "Compare against the newest version even when it is a pre-release (e.g. v2.0.0-rc.1)")
+	cacheDir := fs.String("cache-dir", defaultCacheDir(), "Directory for cached dependency indexes (empty disables caching)")
+	fs.Parse(args)
+
+	// The proxy is queried directly, honoring GOPROXY
+	toolEnv = buildToolEnv(os.Environ(), nil)
+
+	goModPath, err := findGoMod(*projectPath)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if goModPath == "" {
+		fatalf("No go.mod found for %s", *projectPath)
+	}
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		fatalf("Failed to read go.mod: %v", err)
+	}
+	file, err := modfile.ParseLax(goModPath, data, nil)
+	if err != nil {
+		fatalf("Failed to parse go.mod: %v", err)
+	}
+
+	report := &readinessReport{Project: *projectPath, Generated: time.Now().UTC()}
+	for _, req := range file.Require {
+		if req.Indirect {
+			continue
+		}
+		entry := checkReadiness(*projectPath, *cacheDir, req.Mod.Path, req.Mod.Version, *includePrerelease)
+		log.Printf("%s %s -> %s: %s", entry.Module, entry.Current, entry.Latest, entry.Verdict)
+		report.Dependencies = append(report.Dependencies, entry)
+	}
+	sort.SliceStable(report.Dependencies, func(i, j int) bool {
+		return report.Dependencies[i].RiskScore > report.Dependencies[j].RiskScore
+	})
+
+	if *jsonPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if err := os.WriteFile(*jsonPath, append(data, '\n'), 0o644); err != nil {
+			fatalf("Failed to write %s: %v", *jsonPath, err)
+		}
+	}
+	if *htmlPath != "" {
+		var buf bytes.Buffer
+		if err := readinessTemplate.Execute(&buf, report); err != nil {
+			fatalf("%v", err)
+		}
+		if err := os.WriteFile(*htmlPath, buf.Bytes(), 0o644); err != nil {
+			fatalf("Failed to write %s: %v", *htmlPath, err)
+		}
+	}
+}
+
+// checkReadiness checks the upgrade of one dependency to its latest release, or
+// latest version of any kind with includePrerelease.
+// Each check runs the tool as a subprocess so one dependency failing to clone
+// or index doesn't end the whole run.
+func checkReadiness(projectPath, cacheDir, modulePath, current string, includePrerelease bool) readinessEntry {
+	entry := readinessEntry{Module: modulePath, Current: current}
+
+	versions, err := listVersions(modulePath)
+	if err != nil {
+		entry.Verdict, entry.Error = "error", err.Error()
+		return entry
+	}
+	semver.Sort(versions)
+	entry.Latest = newestVersion(versions, includePrerelease)
+	if entry.Latest == "" || semver.Compare(entry.Latest, current) <= 0 {
+		entry.Latest, entry.Verdict = current, "up-to-date"
+		return entry
+	}
+
+	report, err := checkInSubprocess(
+		"--project-path", projectPath,
+		"--module", modulePath,
+		"--old-version", current,
+		"--new-version", entry.Latest,
+		"--cache-dir", cacheDir,
+	)
+	if err != nil {
+		entry.Verdict, entry.Error = "error", err.Error()
+		return entry
+	}
+	entry.Verdict = "ok"
+	worst := Severity(-1)
+	for _, service := range report.Services {
+		for _, f := range service.Findings {
+			entry.Findings++
+			entry.RiskScore += riskWeights[f.Severity]
+			if f.Severity > worst {
+				worst = f.Severity
+				entry.Verdict = f.Severity.String()
+			}
+		}
+	}
+	entry.RiskScore = min(entry.RiskScore, 100)
+	return entry
+}
+
+// logTimestamp is the date and time the log package prefixes lines with
+var logTimestamp = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+
+// lastLine returns the last non-empty line of s, typically a fatal error,
+// without its log timestamp, or its message for JSON logs
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+	var record struct{ Msg string }
+	if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &record) == nil && record.Msg != "" {
+		return record.Msg
+	}
+	return logTimestamp.ReplaceAllString(line, "")
+}
+
+var readinessTemplate = template.Must(template.New("readiness").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Upgrade readiness: {{.Project}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+.critical, .error { background: #f8d7da; }
+.breaking { background: #ffe5cc; }
+.warning { background: #fff3cd; }
+.ok, .up-to-date, .info { background: #d4edda; }
+</style>
+</head>
+<body>
+<h1>Upgrade readiness: {{.Project}}</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04 UTC"}}</p>
+<table>
+<tr><th>Module</th><th>Current</th><th>Latest</th><th>Verdict</th><th>Risk score</th><th>Findings</th></tr>
+{{- range .Dependencies}}
+<tr class="{{.Verdict}}"><td>{{.Module}}</td><td>{{.Current}}</td><td>{{.Latest}}</td><td>{{.Verdict}}{{if .Error}}: {{.Error}}{{end}}</td><td>{{.RiskScore}}</td><td>{{.Findings}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,99 @@
+package upgradecheck
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// externalAlias matches an exported alias of a type of another package, e.g.
+// "type Config = yaml.Node"
+var externalAlias = regexp.MustCompile(`^type ([A-Z]\w*) = (\w+)\.([A-Z]\w*)$`)
+
+// majorSuffix matches the major version suffix of an import path that isn't
+// part of the package name, e.g. "/v2" or ".v3"
+var majorSuffix = regexp.MustCompile(`[/.]v\d+$`)
+
+// typeRef names a type by package path and name, regardless of the module
+// version an index has it at
+type typeRef struct {
+	Package string
+	Type    string
+}
+
+// externalReexports resolves the exported aliases of types from other modules,
+// which the index describes among its external symbols, and maps the aliased
+// types to the alias names. The project reaches their fields and methods
+// through the alias, but its index attributes them to the other module.
+func externalReexports(index *scip.Index, symbols map[string][]string) map[typeRef]string {
+	// External types by name, with the packages defining them
+	external := make(map[string][]string)
+	for _, sym := range index.ExternalSymbols {
+		name, ok := parseSymbolName(sym.Symbol)
+		if ok && name.Type != "" && name.Member == "" {
+			external[name.Type] = append(external[name.Type], name.Package)
+		}
+	}
+
+	reexports := make(map[typeRef]string)
+	for alias, defs := range symbols {
+		for _, def := range defs {
+			m := externalAlias.FindStringSubmatch(normalizeDefinition(def))
+			if m == nil || m[1] != alias {
+				continue
+			}
+			if pkg, ok := resolveQualifier(external[m[3]], m[2]); ok {
+				reexports[typeRef{Package: pkg, Type: m[3]}] = alias
+			}
+		}
+	}
+	return reexports
+}
+
+// resolveQualifier picks the package a qualifier refers to among those
+// defining a type of the name. Qualifiers are package names, which usually
+// are the last element of the import path; a single candidate is taken as is,
+// since the alias has to refer to some package defining the type.
+func resolveQualifier(packages []string, qualifier string) (string, bool) {
+	if len(packages) == 1 {
+		return packages[0], true
+	}
+	for _, pkg := range packages {
+		name := path.Base(majorSuffix.ReplaceAllString(pkg, ""))
+		if name == qualifier || strings.TrimPrefix(name, "go-") == qualifier {
+			return pkg, true
+		}
+	}
+	return "", false
+}
+
+// qualifyReexports writes the import path of the aliased type into the
+// definitions of the aliases, so an alias retargeted to another package of the
+// same name, e.g. a new major version, compares as changed
+func qualifyReexports(symbols map[string][]string, reexports map[typeRef]string) {
+	for target, alias := range reexports {
+		defs := symbols[alias]
+		for i, def := range defs {
+			m := externalAlias.FindStringSubmatch(normalizeDefinition(def))
+			if m != nil && m[3] == target.Type {
+				defs[i] = "type " + alias + " = " + target.Package + "." + target.Type
+			}
+		}
+	}
+}
+
+// reexportedType returns the alias the module re-exports the type of a
+// project occurrence as, such as a call of a method of the aliased type
+func reexportedType(symbol string, reexports map[typeRef]string) (string, bool) {
+	if len(reexports) == 0 {
+		return "", false
+	}
+	name, ok := parseSymbolName(symbol)
+	if !ok || name.Type == "" {
+		return "", false
+	}
+	alias, ok := reexports[typeRef{Package: name.Package, Type: name.Type}]
+	return alias, ok
+}
@@ -0,0 +1,247 @@
+package upgradecheck
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// Effort levels of removing a dependency from a package or project, from the
+// effort points of its ties: 2 per distinct symbol to find a replacement for
+// and 1 per call site to rewrite
+const (
+	EffortSmall  = "small"
+	EffortMedium = "medium"
+	EffortLarge  = "large"
+)
+
+// removalImpact lists everything tying a project to a module, for planning
+// its replacement
+type removalImpact struct {
+	Project  string          `json:"project"`
+	Module   string          `json:"module"`
+	Packages []packageImpact `json:"packages"`
+	Symbols  int             `json:"symbols"`
+	Sites    int             `json:"call_sites"`
+	Effort   string          `json:"effort"`
+}
+
+// packageImpact is what one project package uses of the module
+type packageImpact struct {
+	Package string         `json:"package"`
+	Symbols []symbolImpact `json:"symbols"`
+	Sites   int            `json:"call_sites"`
+	Files   int            `json:"files"`
+	Effort  string         `json:"effort"`
+}
+
+// symbolImpact is one module symbol and where a package uses it
+type symbolImpact struct {
+	// Package is the import path of the module package declaring the symbol
+	Package string     `json:"package"`
+	Name    string     `json:"name"`
+	Sites   []Location `json:"sites"`
+}
+
+func (s symbolImpact) String() string {
+	return defaultImportName(s.Package) + "." + s.Name
+}
+
+// symbolPackage matches the package of a scip-go symbol
+var symbolPackage = regexp.MustCompile("`([^`]+)`/")
+
+// runRemovalImpact implements the "removal-impact" subcommand: it lists every
+// symbol and call site tying the project to a module, grouped by project
+// package with effort estimates, to plan replacing the module
+func runRemovalImpact(args []string) {
+	fs := flag.NewFlagSet("removal-impact", flag.ExitOnError)
+	projectPath := fs.String("project-path", ".", "Path to your Go project")
+	module := fs.String("module", "", "Module path of the dependency to remove")
+	format := fs.String("format", FormatText, "Output format: text, markdown or json")
+	ignoreDirList := fs.String("ignore-dirs", defaultIgnoredDirs, "Comma separated directory names, matched at any depth, whose usages are ignored (empty ignores none)")
+	fs.Parse(args)
+
+	if *module == "" {
+		fmt.Fprintln(os.Stderr, "usage: go-upgrade-checker removal-impact --module <module> [--project-path dir] [--format text|markdown|json] [--ignore-dirs list]")
+		exit(ExitError)
+	}
+	switch *format {
+	case FormatText, FormatMarkdown, FormatJSON:
+	default:
+		fatalf("Unknown format %q: must be %s, %s or %s", *format, FormatText, FormatMarkdown, FormatJSON)
+	}
+	ignored, err := parseIgnoredDirs(*ignoreDirList)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	toolEnv = buildToolEnv(os.Environ(), nil)
+
+	indexPath, err := generateProjectIndex(*projectPath, nil, nil)
+	if err != nil {
+		fatalf("Failed to generate SCIP index for %s: %v", *projectPath, err)
+	}
+	defer releaseIndex(indexPath)
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	impact := moduleRemovalImpact(index, *module, ignored)
+	impact.Project = *projectPath
+
+	switch *format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(impact, "", "  ")
+		if err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Println(string(data))
+	case FormatMarkdown:
+		printRemovalMarkdown(os.Stdout, impact)
+	default:
+		printRemovalText(os.Stdout, impact)
+	}
+}
+
+// moduleRemovalImpact collects the references of the project index to symbols
+// of the module, grouped by the project package making them. Packages come
+// most effort first.
+func moduleRemovalImpact(index *scip.Index, module string, ignored ignoredDirs) *removalImpact {
+	type symbolKey struct{ pkg, name string }
+	byPackage := make(map[string]map[symbolKey][]Location)
+	for _, doc := range index.Documents {
+		if ignored.ignores(doc.RelativePath) {
+			continue
+		}
+		for _, occ := range doc.Occurrences {
+			if occ.SymbolRoles&int32(scip.SymbolRole_Definition) != 0 || !inModule(occ.Symbol, module) {
+				continue
+			}
+			val, _ := extractSymbolsFromOccurrence(occ.Symbol)
+			m := symbolPackage.FindStringSubmatch(occ.Symbol)
+			if val == "" || m == nil {
+				continue
+			}
+			key := symbolKey{pkg: m[1], name: strings.TrimSuffix(strings.Replace(val, "#", ".", 1), ".")}
+			dir := path.Dir(doc.RelativePath)
+			if byPackage[dir] == nil {
+				byPackage[dir] = make(map[symbolKey][]Location)
+			}
+			// Documents indexed for several platforms repeat their occurrences
+			if loc := occurrenceLocation(doc.RelativePath, occ); !slices.Contains(byPackage[dir][key], loc) {
+				byPackage[dir][key] = append(byPackage[dir][key], loc)
+			}
+		}
+	}
+
+	impact := &removalImpact{Module: module, Packages: []packageImpact{}}
+	distinct := make(map[symbolKey]bool)
+	for dir, symbols := range byPackage {
+		pkg := packageImpact{Package: dir}
+		files := make(map[string]bool)
+		for key, locs := range symbols {
+			sort.Slice(locs, func(i, j int) bool {
+				if locs[i].Path != locs[j].Path {
+					return locs[i].Path < locs[j].Path
+				}
+				return locs[i].Line < locs[j].Line
+			})
+			for _, loc := range locs {
+				files[loc.Path] = true
+			}
+			pkg.Symbols = append(pkg.Symbols, symbolImpact{Package: key.pkg, Name: key.name, Sites: locs})
+			pkg.Sites += len(locs)
+			distinct[key] = true
+		}
+		sort.Slice(pkg.Symbols, func(i, j int) bool {
+			if len(pkg.Symbols[i].Sites) != len(pkg.Symbols[j].Sites) {
+				return len(pkg.Symbols[i].Sites) > len(pkg.Symbols[j].Sites)
+			}
+			return pkg.Symbols[i].String() < pkg.Symbols[j].String()
+		})
+		pkg.Files = len(files)
+		pkg.Effort = effortLevel(effortPoints(len(pkg.Symbols), pkg.Sites))
+		impact.Packages = append(impact.Packages, pkg)
+		impact.Sites += pkg.Sites
+	}
+	impact.Symbols = len(distinct)
+	impact.Effort = effortLevel(effortPoints(impact.Symbols, impact.Sites))
+
+	sort.Slice(impact.Packages, func(i, j int) bool {
+		a, b := impact.Packages[i], impact.Packages[j]
+		if pa, pb := effortPoints(len(a.Symbols), a.Sites), effortPoints(len(b.Symbols), b.Sites); pa != pb {
+			return pa > pb
+		}
+		return a.Package < b.Package
+	})
+	return impact
+}
+
+func effortPoints(symbols, sites int) int {
+	return 2*symbols + sites
+}
+
+// effortLevel maps effort points to a level: small below 10 points, medium
+// below 40, large from there
+func effortLevel(points int) string {
+	switch {
+	case points < 10:
+		return EffortSmall
+	case points < 40:
+		return EffortMedium
+	default:
+		return EffortLarge
+	}
+}
+
+func printRemovalText(w io.Writer, impact *removalImpact) {
+	fmt.Fprintf(w, "Removal impact of %s on %s\n", impact.Module, impact.Project)
+	if len(impact.Packages) == 0 {
+		fmt.Fprintln(w, "The project doesn't use the module.")
+		return
+	}
+	fmt.Fprintf(w, "%d symbols, %d call sites in %d packages (effort: %s)\n", impact.Symbols, impact.Sites, len(impact.Packages), impact.Effort)
+	for _, pkg := range impact.Packages {
+		fmt.Fprintf(w, "\n%s (effort: %s, %d symbols, %d call sites in %d files)\n", pkg.Package, pkg.Effort, len(pkg.Symbols), pkg.Sites, pkg.Files)
+		for _, sym := range pkg.Symbols {
+			fmt.Fprintf(w, "  %s (%d)\n", sym, len(sym.Sites))
+			for _, loc := range sym.Sites {
+				fmt.Fprintf(w, "    %s\n", loc)
+			}
+		}
+	}
+}
+
+func printRemovalMarkdown(w io.Writer, impact *removalImpact) {
+	fmt.Fprintf(w, "# Removal impact: %s\n\n", impact.Module)
+	if len(impact.Packages) == 0 {
+		fmt.Fprintln(w, "The project doesn't use the module.")
+		return
+	}
+	fmt.Fprintf(w, "%d symbols, %d call sites in %d packages. Estimated effort: **%s**.\n\n", impact.Symbols, impact.Sites, len(impact.Packages), impact.Effort)
+	fmt.Fprintln(w, "| Package | Effort | Symbols | Call sites | Files |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- |")
+	for _, pkg := range impact.Packages {
+		fmt.Fprintf(w, "| `%s` | %s | %d | %d | %d |\n", pkg.Package, pkg.Effort, len(pkg.Symbols), pkg.Sites, pkg.Files)
+	}
+	for _, pkg := range impact.Packages {
+		fmt.Fprintf(w, "\n## `%s`\n\n", pkg.Package)
+		for _, sym := range pkg.Symbols {
+			sites := make([]string, len(sym.Sites))
+			for i, loc := range sym.Sites {
+				sites[i] = "`" + loc.String() + "`"
+			}
+			fmt.Fprintf(w, "- `%s` (%d): %s\n", sym, len(sym.Sites), strings.Join(sites, ", "))
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	reportapi "go-upgrade-checker/report"
+)
+
+// Report formats understood by --format
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+)
+
+// Report is the complete result of a run. It is what --format=json writes, so
+// a saved report can be rendered again later without re-running the analysis.
+type Report struct {
+	Module     string `json:"module"`
+	OldVersion string `json:"old_version"`
+	NewVersion string `json:"new_version"`
+	Deprecated string `json:"deprecated,omitempty"`
+	// Interrupted says why the run ended early, making this a partial report
+	// of the services analyzed before
+	Interrupted string `json:"interrupted,omitempty"`
+	// Unanalyzed are the packages of the module versions scip-go couldn't
+	// index; changes to them are missing from the report
+	Unanalyzed []packageGap     `json:"unanalyzed,omitempty"`
+	Services   []*serviceReport `json:"services"`
+}
+
+// MarshalJSON adds the finding's fingerprint to saved reports for tools that
+// track findings across runs
+func (f Finding) MarshalJSON() ([]byte, error) {
+	type finding Finding
+	return json.Marshal(struct {
+		Fingerprint string `json:"fingerprint"`
+		finding
+	}{f.Fingerprint(), finding(f)})
+}
+
+// validFormat reports whether format is one of the supported report formats,
+// built in or registered with the report package
+func validFormat(format string) bool {
+	switch format {
+	case FormatText, FormatMarkdown, FormatJSON, FormatSARIF:
+		return true
+	}
+	_, ok := reportapi.Lookup(format)
+	return ok
+}
+
+// formatNames lists the supported report formats for error messages
+func formatNames() string {
+	return strings.Join(append([]string{FormatText, FormatMarkdown, FormatJSON, FormatSARIF}, reportapi.Formats()...), ", ")
+}
+
+// publicReport converts the report to the types of the report package, which
+// mirror its JSON form
+func publicReport(report *Report) (*reportapi.Report, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	return reportapi.Read(bytes.NewReader(data))
+}
+
+// renderReport writes the report to stdout in the given format. view selects
+// how the text format renders changed signatures; byOwner groups the findings
+// of the text and markdown formats by CODEOWNERS owner.
+func renderReport(report *Report, format, view string, byOwner bool) error {
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+		fmt.Println(string(data))
+	case FormatMarkdown:
+		printMarkdown(os.Stdout, report, byOwner)
+	case FormatSARIF:
+		return printSARIF(report)
+	case FormatText:
+		fmt.Println()
+		if report.Interrupted != "" {
+			fmt.Printf("PARTIAL REPORT: the check was interrupted (%s); findings of the remaining projects are missing.\n", report.Interrupted)
+			fmt.Println()
+		}
+		if report.Deprecated != "" {
+			fmt.Printf("DEPRECATED: %s@%s is deprecated: %s\n", report.Module, report.NewVersion, report.Deprecated)
+			fmt.Println("Consider migrating to its successor instead of upgrading.")
+			fmt.Println()
+		}
+		printGapsText(os.Stdout, report)
+		printReport(report.Services, view, byOwner)
+	default:
+		renderer, ok := reportapi.Lookup(format)
+		if !ok {
+			return fmt.Errorf("unknown format %q: must be one of %s", format, formatNames())
+		}
+		public, err := publicReport(report)
+		if err != nil {
+			return err
+		}
+		return renderer.Render(os.Stdout, public)
+	}
+	return nil
+}
+
+// readReport loads a report saved with --format=json
+func readReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	for _, service := range report.Services {
+		classifyFindings(service.Findings)
+	}
+	return &report, nil
+}
+
+// printMarkdown writes the report to w as GitHub flavored markdown, e.g. for pull
+// request comments or wiki pages
+func printMarkdown(w io.Writer, report *Report, byOwner bool) {
+	fmt.Fprintf(w, "# Upgrade check: %s %s → %s\n\n", report.Module, report.OldVersion, report.NewVersion)
+	if report.Interrupted != "" {
+		fmt.Fprintf(w, "> **Partial report:** the check was interrupted (%s); findings of the remaining projects are missing.\n\n", report.Interrupted)
+	}
+	if report.Deprecated != "" {
+		fmt.Fprintf(w, "> **Deprecated:** %s. Consider migrating to its successor instead of upgrading.\n\n", report.Deprecated)
+	}
+	printGapsMarkdown(w, report)
+
+	for _, service := range report.Services {
+		if len(report.Services) > 1 {
+			fmt.Fprintf(w, "## Service: %s (`%s`)\n\n", service.Name, service.Path)
+		}
+		root := service.dir
+		if root == "" {
+			root = service.Path
+		}
+		if byOwner && len(service.Findings) > 0 {
+			owners, groups := findingsByOwner(service.Findings)
+			for _, owner := range owners {
+				fmt.Fprintf(w, "### Owner: %s\n\n", markdownCell(owner))
+				printMarkdownFindings(w, root, groups[owner])
+			}
+		} else {
+			printMarkdownFindings(w, root, service.Findings)
+		}
+		printMarkdownTargets(w, service.Targets)
+		printMarkdownBuildImpact(w, service.Build)
+	}
+
+	if len(report.Services) > 1 {
+		fmt.Fprintln(w, "## Rollup")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Service | Verdict | Findings |")
+		fmt.Fprintln(w, "| --- | --- | --- |")
+		for _, service := range report.Services {
+			fmt.Fprintf(w, "| %s | %s | %d |\n", markdownCell(service.Name), service.Verdict(), len(service.Findings))
+		}
+	}
+}
+
+// printMarkdownFindings writes a table of findings per class, each followed by
+// the usages of its symbols in the project at root
+func printMarkdownFindings(w io.Writer, root string, findings []Finding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "No breaking changes detected.")
+		fmt.Fprintln(w)
+		return
+	}
+
+	var notes []string
+	classes, groups := findingsByClass(findings)
+	for _, class := range classes {
+		fmt.Fprintf(w, "**%s**\n\n", classTitle(class))
+		fmt.Fprintln(w, "| Severity | Confidence | Symbol | Change | Old | New | Used in | Owners |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- | --- | --- | --- | --- |")
+		for _, f := range groups[class] {
+			usedIn := ""
+			if len(f.Usages) > 0 {
+				usedIn = fmt.Sprintf("%d files, %d packages", len(f.Files()), len(f.Packages()))
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+				markdownSeverity(f), f.Confidence, markdownCode(f.Symbol), f.Kind,
+				markdownCode(f.OldSignature), markdownCode(f.NewSignature), usedIn,
+				markdownCell(strings.Join(f.Owners, ", ")))
+			for _, note := range f.Notes {
+				notes = append(notes, fmt.Sprintf("- %s: %s", markdownCode(f.Symbol), note))
+			}
+		}
+		fmt.Fprintln(w)
+		printMarkdownUsages(w, root, groups[class])
+	}
+
+	if len(notes) > 0 {
+		fmt.Fprintln(w, "**Notes**")
+		fmt.Fprintln(w)
+		for _, note := range notes {
+			fmt.Fprintln(w, note)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func printMarkdownTargets(w io.Writer, targets []targetImpact) {
+	if len(targets) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "**Bazel targets depending on the module**")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Target | Verdict | Affected symbols |")
+	fmt.Fprintln(w, "| --- | --- | --- |")
+	for _, t := range targets {
+		var symbols []string
+		for _, sym := range t.Symbols {
+			symbols = append(symbols, markdownCode(sym))
+		}
+		fmt.Fprintf(w, "| %s | %s | %s |\n", markdownCode(t.Label), t.Verdict, strings.Join(symbols, ", "))
+	}
+	fmt.Fprintln(w)
+}
+
+func printMarkdownBuildImpact(w io.Writer, impact *buildImpact) {
+	if impact == nil {
+		return
+	}
+	fmt.Fprintf(w, "**Build impact** (%s)\n\n", markdownCode(impact.Package))
+	fmt.Fprintln(w, "| | Old | New | Change |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- |")
+	fmt.Fprintf(w, "| Binary size | %.1f MiB | %.1f MiB | %s |\n",
+		float64(impact.OldSize)/(1<<20), float64(impact.NewSize)/(1<<20),
+		percentChange(float64(impact.OldSize), float64(impact.NewSize)))
+	fmt.Fprintf(w, "| Build time | %.1fs | %.1fs | %s |\n",
+		impact.OldSeconds, impact.NewSeconds, percentChange(impact.OldSeconds, impact.NewSeconds))
+	fmt.Fprintln(w)
+}
+
+// markdownSeverity is the severity cell of a finding, marking accepted ones
+func markdownSeverity(f Finding) string {
+	if f.Accepted {
+		return f.Severity.String() + " (accepted)"
+	}
+	return f.Severity.String()
+}
+
+// markdownCode formats s as inline code usable in a table cell. Signatures can
+// contain backticks (struct tags), so the fence is made longer than any run of
+// backticks inside.
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + markdownCell(s) + fence
+}
+
+// markdownCell escapes characters that would break a table row
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,86 @@
+package upgradecheck
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runReportCommand implements the "report" subcommand for working with
+// reports saved with --format=json
+func runReportCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: go-upgrade-checker report render <report.json> [--format text|markdown|json] [--view inline|side-by-side] [--min-confidence exact|high|heuristic] [--group-by-owner]")
+		fmt.Fprintln(os.Stderr, "       go-upgrade-checker report diff <old.json> <new.json>")
+		exit(2)
+	}
+
+	switch args[0] {
+	case "render":
+		fs := flag.NewFlagSet("report render", flag.ExitOnError)
+		format := fs.String("format", FormatText, "Output format: text, markdown, json or sarif")
+		view := fs.String("view", ViewInline, "Text rendering of changed signatures: inline or side-by-side")
+		byOwner := fs.Bool("group-by-owner", false, "Group the text and markdown findings by CODEOWNERS owner")
+		var minConfidence Confidence
+		fs.TextVar(&minConfidence, "min-confidence", ConfidenceHeuristic, "Only render findings of at least this confidence: exact, high or heuristic")
+		paths := parseInterspersed(fs, args[1:])
+		if len(paths) != 1 {
+			fmt.Fprintln(os.Stderr, "usage: go-upgrade-checker report render <report.json> [--format text|markdown|json] [--view inline|side-by-side] [--min-confidence exact|high|heuristic] [--group-by-owner]")
+			exit(2)
+		}
+		if !validFormat(*format) {
+			fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+			exit(2)
+		}
+		if *view != ViewInline && *view != ViewSideBySide {
+			fmt.Fprintf(os.Stderr, "unknown view %q\n", *view)
+			exit(2)
+		}
+
+		report, err := readReport(paths[0])
+		if err != nil {
+			fatalf("%v", err)
+		}
+		for _, service := range report.Services {
+			service.Findings = filterConfidence(service.Findings, minConfidence)
+		}
+		if err := renderReport(report, *format, *view, *byOwner); err != nil {
+			fatalf("%v", err)
+		}
+	case "diff":
+		fs := flag.NewFlagSet("report diff", flag.ExitOnError)
+		paths := parseInterspersed(fs, args[1:])
+		if len(paths) != 2 {
+			fmt.Fprintln(os.Stderr, "usage: go-upgrade-checker report diff <old.json> <new.json>")
+			exit(2)
+		}
+
+		oldReport, err := readReport(paths[0])
+		if err != nil {
+			fatalf("%v", err)
+		}
+		newReport, err := readReport(paths[1])
+		if err != nil {
+			fatalf("%v", err)
+		}
+		printReportDiff(oldReport, newReport, diffReports(oldReport, newReport))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown report command %q\n", args[0])
+		exit(2)
+	}
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments, e.g. "render findings.json --format markdown", and
+// returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
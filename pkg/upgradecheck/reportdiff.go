@@ -0,0 +1,92 @@
+package upgradecheck
+
+import (
+	"fmt"
+)
+
+// serviceDiff lists the findings of one service that appeared or disappeared
+// between two saved reports
+type serviceDiff struct {
+	Service     string
+	Appeared    []Finding
+	Disappeared []Finding
+	Unchanged   int
+}
+
+// diffReports matches the findings of two reports by fingerprint, service by
+// service. Services present in only one report have all their findings listed
+// as appeared or disappeared.
+func diffReports(oldReport, newReport *Report) []serviceDiff {
+	oldServices := make(map[string]*serviceReport)
+	for _, service := range oldReport.Services {
+		oldServices[service.Name] = service
+	}
+
+	var diffs []serviceDiff
+	seen := make(map[string]bool)
+	for _, service := range newReport.Services {
+		seen[service.Name] = true
+		var oldFindings []Finding
+		if old, ok := oldServices[service.Name]; ok {
+			oldFindings = old.Findings
+		}
+		diffs = append(diffs, diffFindings(service.Name, oldFindings, service.Findings))
+	}
+	for _, service := range oldReport.Services {
+		if !seen[service.Name] {
+			diffs = append(diffs, diffFindings(service.Name, service.Findings, nil))
+		}
+	}
+	return diffs
+}
+
+func diffFindings(service string, oldFindings, newFindings []Finding) serviceDiff {
+	diff := serviceDiff{Service: service}
+
+	oldPrints := make(map[string]bool)
+	for _, f := range oldFindings {
+		oldPrints[f.Fingerprint()] = true
+	}
+	newPrints := make(map[string]bool)
+	for _, f := range newFindings {
+		newPrints[f.Fingerprint()] = true
+		if oldPrints[f.Fingerprint()] {
+			diff.Unchanged++
+		} else {
+			diff.Appeared = append(diff.Appeared, f)
+		}
+	}
+	for _, f := range oldFindings {
+		if !newPrints[f.Fingerprint()] {
+			diff.Disappeared = append(diff.Disappeared, f)
+		}
+	}
+
+	sortFindings(diff.Appeared)
+	sortFindings(diff.Disappeared)
+	return diff
+}
+
+// printReportDiff writes the appeared and disappeared findings of every service
+func printReportDiff(oldReport, newReport *Report, diffs []serviceDiff) {
+	fmt.Printf("%s: %s -> %s (old report) vs %s -> %s (new report)\n",
+		newReport.Module, oldReport.OldVersion, oldReport.NewVersion, newReport.OldVersion, newReport.NewVersion)
+
+	for _, diff := range diffs {
+		fmt.Println()
+		if len(diffs) > 1 {
+			fmt.Printf("Service: %s\n", diff.Service)
+		}
+		if len(diff.Appeared) == 0 && len(diff.Disappeared) == 0 {
+			fmt.Printf("No changes (%d findings in both reports).\n", diff.Unchanged)
+			continue
+		}
+		for _, f := range diff.Appeared {
+			fmt.Printf("+ [%s] %s (%s) %s\n", f.Severity, f.Symbol, f.Kind, f.Fingerprint())
+		}
+		for _, f := range diff.Disappeared {
+			fmt.Printf("- [%s] %s (%s) %s\n", f.Severity, f.Symbol, f.Kind, f.Fingerprint())
+		}
+		fmt.Printf("%d appeared, %d disappeared, %d unchanged\n", len(diff.Appeared), len(diff.Disappeared), diff.Unchanged)
+	}
+}
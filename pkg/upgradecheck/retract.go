@@ -0,0 +1,109 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// retraction describes why a version was withdrawn by the module's maintainer
+type retraction struct {
+	Version   string
+	Rationale string
+	// Suggested is the nearest version that is not retracted, if any
+	Suggested string
+}
+
+// checkRetracted reads the retract directives from the go.mod of the module's
+// latest version and reports whether version is covered by one of them. It
+// returns nil when the version is not retracted or is not a semantic version.
+// includePrerelease allows suggesting pre-releases instead (see suggestable).
+func checkRetracted(modulePath, version string, includePrerelease bool) (*retraction, error) {
+	if !semver.IsValid(version) {
+		return nil, nil
+	}
+
+	versions, err := listVersions(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	semver.Sort(versions)
+	if len(versions) == 0 {
+		return nil, nil
+	}
+
+	latest := latestVersion(versions)
+	data, err := fetchGoMod(modulePath, latest)
+	if err != nil {
+		return nil, err
+	}
+	file, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse go.mod of %s@%s: %w", modulePath, latest, err)
+	}
+
+	isRetracted := func(v string) (bool, string) {
+		for _, r := range file.Retract {
+			if semver.Compare(v, r.Low) >= 0 && semver.Compare(v, r.High) <= 0 {
+				return true, r.Rationale
+			}
+		}
+		return false, ""
+	}
+
+	retracted, rationale := isRetracted(version)
+	if !retracted {
+		return nil, nil
+	}
+
+	result := &retraction{Version: version, Rationale: rationale}
+	result.Suggested = nearestUnretracted(version, suggestable(versions, version, includePrerelease), func(v string) bool {
+		r, _ := isRetracted(v)
+		return r
+	})
+	return result, nil
+}
+
+// newestVersion returns the highest version of a sorted list that isn't
+// +incompatible, skipping pre-releases unless includePrerelease is set. It
+// returns "" when there is none.
+func newestVersion(versions []string, includePrerelease bool) string {
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if strings.HasSuffix(semver.Build(v), "+incompatible") {
+			continue
+		}
+		if includePrerelease || semver.Prerelease(v) == "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// latestVersion picks the version whose go.mod carries the authoritative retract
+// directives: the highest release that is not +incompatible, mirroring the go
+// command. versions must be sorted and non-empty.
+func latestVersion(versions []string) string {
+	if v := newestVersion(versions, false); v != "" {
+		return v
+	}
+	return versions[len(versions)-1]
+}
+
+// nearestUnretracted picks the newest version older than version that is not
+// retracted, falling back to the oldest newer one. versions must be sorted.
+func nearestUnretracted(version string, versions []string, retracted func(string) bool) string {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if semver.Compare(versions[i], version) < 0 && !retracted(versions[i]) {
+			return versions[i]
+		}
+	}
+	for _, v := range versions {
+		if semver.Compare(v, version) > 0 && !retracted(v) {
+			return v
+		}
+	}
+	return ""
+}
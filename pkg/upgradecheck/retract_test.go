@@ -0,0 +1,100 @@
+package upgradecheck
+
+import (
+	"testing"
+
+	"golang.org/x/mod/semver"
+)
+
+func TestNewestVersion(t *testing.T) {
+	tests := []struct {
+		name              string
+		versions          []string
+		includePrerelease bool
+		want              string
+	}{
+		{"release", []string{"v1.0.0", "v1.1.0"}, false, "v1.1.0"},
+		{"newer pre-release skipped", []string{"v1.1.0", "v1.2.0-rc.1"}, false, "v1.1.0"},
+		{"newer pre-release", []string{"v1.1.0", "v1.2.0-rc.1"}, true, "v1.2.0-rc.1"},
+		{"release after its candidates", []string{"v1.2.0-rc.1", "v1.2.0-rc.2", "v1.2.0"}, true, "v1.2.0"},
+		{"numeric identifiers", []string{"v1.2.0-rc.2", "v1.2.0-rc.10"}, true, "v1.2.0-rc.10"},
+		{"beta before rc", []string{"v2.0.0-beta.3", "v2.0.0-rc.1"}, true, "v2.0.0-rc.1"},
+		{"incompatible skipped", []string{"v1.5.0", "v2.0.0+incompatible"}, false, "v1.5.0"},
+		{"only pre-releases", []string{"v0.1.0-alpha", "v0.1.0-beta"}, false, ""},
+		{"none", nil, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := sortedVersions(tt.versions...)
+			if got := newestVersion(versions, tt.includePrerelease); got != tt.want {
+				t.Errorf("newestVersion(%q, %v) = %q, want %q", versions, tt.includePrerelease, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	tests := []struct {
+		versions []string
+		want     string
+	}{
+		{[]string{"v1.0.0", "v1.1.0-rc.1"}, "v1.0.0"},
+		{[]string{"v1.0.0", "v2.0.0+incompatible"}, "v1.0.0"},
+		// Without a release, the go command reads the newest version
+		{[]string{"v0.1.0-alpha", "v0.1.0-beta"}, "v0.1.0-beta"},
+	}
+	for _, tt := range tests {
+		if got := latestVersion(sortedVersions(tt.versions...)); got != tt.want {
+			t.Errorf("latestVersion(%q) = %q, want %q", tt.versions, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRetracted(t *testing.T) {
+	serveProxy(t, map[string]string{
+		"/example.com/m/@v/list": "v1.0.0\nv1.1.0\nv1.2.0-rc.1\nv1.2.0-rc.2\nv1.2.0\nv1.3.0-rc.1\n",
+		"/example.com/m/@v/v1.2.0.mod": `module example.com/m
+
+retract (
+	v1.1.0 // Leaks connections.
+	[v1.2.0-rc.2, v1.2.0] // Broken build.
+)
+`,
+	})
+
+	tests := []struct {
+		version           string
+		includePrerelease bool
+		want              string
+	}{
+		{"v1.0.0", false, ""},
+		{"v1.1.0", false, "v1.1.0: Leaks connections. (use v1.0.0)"},
+		{"v1.2.0", false, "v1.2.0: Broken build. (use v1.0.0)"},
+		{"v1.2.0", true, "v1.2.0: Broken build. (use v1.2.0-rc.1)"},
+		// A release candidate being evaluated may be replaced by another
+		{"v1.2.0-rc.2", false, "v1.2.0-rc.2: Broken build. (use v1.2.0-rc.1)"},
+		{"v1.2.0-rc.1", false, ""},
+		{"master", false, ""},
+	}
+	for _, tt := range tests {
+		r, err := checkRetracted("example.com/m", tt.version, tt.includePrerelease)
+		if err != nil {
+			t.Fatalf("checkRetracted(%s) error: %v", tt.version, err)
+		}
+		got := ""
+		if r != nil {
+			got = r.Version + ": " + r.Rationale + " (use " + r.Suggested + ")"
+		}
+		if got != tt.want {
+			t.Errorf("checkRetracted(%s, %v) = %q, want %q", tt.version, tt.includePrerelease, got, tt.want)
+		}
+	}
+}
+
+// sortedVersions returns versions in semantic version order, as the callers
+// of newestVersion and latestVersion sort them
+func sortedVersions(versions ...string) []string {
+	sorted := append([]string(nil), versions...)
+	semver.Sort(sorted)
+	return sorted
+}
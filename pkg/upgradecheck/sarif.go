@@ -0,0 +1,170 @@
+package upgradecheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// FormatSARIF is the SARIF 2.1.0 output for code scanning integrations such as
+// GitHub code scanning and Azure DevOps
+const FormatSARIF = "sarif"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	// Suppressions mark findings accepted in the baseline, which code
+	// scanning then doesn't alert on
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// sarifRules describes each change kind, the rule of its findings
+var sarifRules = []sarifRule{
+	{ID: ChangeRemoved, ShortDescription: sarifMessage{"Used dependency symbol removed"}},
+	{ID: ChangeChanged, ShortDescription: sarifMessage{"Used dependency symbol changed"}},
+	{ID: ChangeResults, ShortDescription: sarifMessage{"Used dependency function gained results every call site has to receive"}},
+	{ID: ChangeErrorHandling, ShortDescription: sarifMessage{"Used dependency symbol now documented to panic"}},
+	{ID: ChangeAdded, ShortDescription: sarifMessage{"Member added to a used dependency symbol"}},
+	{ID: ChangeBehavior, ShortDescription: sarifMessage{"Possible behavior change of a used dependency symbol"}},
+	{ID: ChangeImplements, ShortDescription: sarifMessage{"Used dependency type no longer implements an interface"}},
+	{ID: ChangeImplementer, ShortDescription: sarifMessage{"Dependency interface implemented by a project type gained or changed a method"}},
+	{ID: ChangeDeprecated, ShortDescription: sarifMessage{"Used dependency symbol deprecated"}},
+	{ID: ChangeUnstableAPI, ShortDescription: sarifMessage{"Used dependency symbol outside the documented stable API"}},
+	{ID: ChangeConfig, ShortDescription: sarifMessage{"Configuration the project sets no longer read by the dependency"}},
+}
+
+// sarifLevel maps severities onto SARIF levels
+func sarifLevel(s Severity) string {
+	switch {
+	case s >= SeverityBreaking:
+		return "error"
+	case s == SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// buildSARIF converts reports into a SARIF log with one result per usage of
+// each finding, located where the project uses the affected symbol. Paths are
+// relative to the repository root, as code scanning expects.
+func buildSARIF(reports ...*Report) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "go-upgrade-checker",
+			InformationURI: "https://github.com/Oloruntobi1/go-upgrade-checker",
+			Rules:          sarifRules,
+		}},
+		Results: []sarifResult{},
+	}
+	for _, report := range reports {
+		for _, service := range report.Services {
+			root := service.dir
+			if root == "" {
+				root = service.Path
+			}
+			for _, f := range service.Findings {
+				result := sarifResult{
+					RuleID:  f.Kind,
+					Level:   sarifLevel(f.Severity),
+					Message: sarifMessage{fmt.Sprintf("%s@%s: %s", report.Module, report.NewVersion, f.Summary())},
+					PartialFingerprints: map[string]string{
+						"upgradeFinding/v1": f.Fingerprint(),
+					},
+				}
+				if f.Accepted {
+					result.Suppressions = []sarifSuppression{{Kind: "external", Justification: "accepted in the baseline"}}
+				}
+				if len(f.Notes) > 0 {
+					result.Message.Text += " (" + strings.Join(f.Notes, "; ") + ")"
+				}
+				if len(f.Usages) == 0 {
+					run.Results = append(run.Results, result)
+					continue
+				}
+				for _, loc := range f.Usages {
+					located := result
+					located.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifact{URI: repoRelative(filepath.Join(root, filepath.FromSlash(loc.Path)))},
+					}}}
+					if loc.Line > 0 {
+						located.Locations[0].PhysicalLocation.Region = &sarifRegion{StartLine: loc.Line, StartColumn: loc.Column}
+					}
+					run.Results = append(run.Results, located)
+				}
+			}
+		}
+	}
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
+
+// printSARIF writes the reports to stdout as one SARIF log
+func printSARIF(reports ...*Report) error {
+	data, err := json.MarshalIndent(buildSARIF(reports...), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode SARIF: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
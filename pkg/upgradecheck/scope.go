@@ -0,0 +1,138 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// packageScope restricts indexing and reporting to some packages of the
+// project, given as go-style patterns relative to the project root such as
+// ./cmd/api or ./internal/billing/...
+type packageScope []string
+
+// parsePackageScope parses a comma separated list of package patterns. An
+// empty list yields an empty scope covering the whole project.
+func parsePackageScope(list string) (packageScope, error) {
+	var scope packageScope
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if pattern != "." && pattern != "./..." && !strings.HasPrefix(pattern, "./") {
+			return nil, fmt.Errorf("invalid package pattern %q: must be relative to the project, e.g. ./cmd/api/...", pattern)
+		}
+		scope = append(scope, pattern)
+	}
+	return scope, nil
+}
+
+// contains reports whether the project file at file (relative to the project
+// root, slash separated) belongs to a package in scope
+func (s packageScope) contains(file string) bool {
+	if len(s) == 0 {
+		return true
+	}
+	dir := path.Dir(file)
+	for _, pattern := range s {
+		pkg, recursive := strings.CutSuffix(pattern, "/...")
+		pkg = path.Clean(pkg)
+		if dir == pkg || (recursive && (pkg == "." || strings.HasPrefix(dir, pkg+"/"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter narrows the usages of every finding to the packages in scope and drops
+// findings only used outside of it
+func (s packageScope) filter(findings []Finding) []Finding {
+	if len(s) == 0 {
+		return findings
+	}
+	var kept []Finding
+	for _, f := range findings {
+		if len(f.Usages) == 0 {
+			kept = append(kept, f)
+			continue
+		}
+		var usages []Location
+		for _, loc := range f.Usages {
+			if s.contains(loc.Path) {
+				usages = append(usages, loc)
+			}
+		}
+		if len(usages) == 0 {
+			continue
+		}
+		f.Usages = usages
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// defaultIgnoredDirs are sample and fixture trees: breakage confined to them
+// shouldn't fail the check of a production upgrade
+const defaultIgnoredDirs = "example,examples,testdata"
+
+// ignoredDirs names project directories, matched at any depth, whose usages of
+// the dependency are not collected
+type ignoredDirs map[string]bool
+
+// parseIgnoredDirs parses a comma separated list of directory names
+func parseIgnoredDirs(list string) (ignoredDirs, error) {
+	dirs := make(ignoredDirs)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid ignored directory %q: must be a directory name, e.g. testdata", name)
+		}
+		dirs[name] = true
+	}
+	return dirs, nil
+}
+
+// ignores reports whether the project file at file (relative to the project
+// root, slash separated) lies in an ignored directory
+func (d ignoredDirs) ignores(file string) bool {
+	if len(d) == 0 {
+		return false
+	}
+	for _, dir := range strings.Split(path.Dir(file), "/") {
+		if d[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// exclude drops the usages of every finding in the packages in scope, and
+// findings only used there. An empty scope excludes nothing.
+func (s packageScope) exclude(findings []Finding) []Finding {
+	if len(s) == 0 {
+		return findings
+	}
+	var kept []Finding
+	for _, f := range findings {
+		if len(f.Usages) == 0 {
+			kept = append(kept, f)
+			continue
+		}
+		var usages []Location
+		for _, loc := range f.Usages {
+			if !s.contains(loc.Path) {
+				usages = append(usages, loc)
+			}
+		}
+		if len(usages) == 0 {
+			continue
+		}
+		f.Usages = usages
+		kept = append(kept, f)
+	}
+	return kept
+}
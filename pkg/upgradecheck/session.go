@@ -0,0 +1,234 @@
+package upgradecheck
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sessionFile is the manifest of a recorded session
+const sessionFile = "session.json"
+
+// session describes the inputs of a recorded run (--record). Together with the
+// indexes, the dependency's go.mod and the project sources stored next to it in
+// the archive, it lets --replay rerun the comparison and reporting stages
+// without network access, git or scip-go.
+type session struct {
+	Module     string `json:"module"`
+	OldVersion string `json:"old_version"`
+	NewVersion string `json:"new_version"`
+	// Retracted is the retraction found for the new version, if any
+	Retracted *retraction      `json:"retracted,omitempty"`
+	Services  []sessionService `json:"services"`
+}
+
+type sessionService struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Archive layout of the recorded files
+func sessionModuleIndex(which string) string { return which + "/index.scip" }
+func sessionModuleGoMod(which string) string { return which + "/go.mod" }
+func sessionServiceIndex(i int) string       { return fmt.Sprintf("services/%d/index.scip", i) }
+func sessionServiceSrc(i int) string         { return fmt.Sprintf("services/%d/src", i) }
+
+// recordSession writes the session manifest and every input the analysis reads
+// to a tar archive at archivePath
+func recordSession(archivePath string, s *session, services []*serviceReport, oldIndexPath, newIndexPath string, newGoMod []byte) (err error) {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create session archive: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	tw := tar.NewWriter(f)
+
+	manifest, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, sessionFile, manifest); err != nil {
+		return err
+	}
+
+	if err := writeTarIndex(tw, sessionModuleIndex("old"), oldIndexPath); err != nil {
+		return err
+	}
+	if err := writeTarIndex(tw, sessionModuleIndex("new"), newIndexPath); err != nil {
+		return err
+	}
+	if newGoMod != nil {
+		if err := writeTarFile(tw, sessionModuleGoMod("new"), newGoMod); err != nil {
+			return err
+		}
+	}
+
+	for i, service := range services {
+		if err := writeTarIndex(tw, sessionServiceIndex(i), service.indexPath); err != nil {
+			return err
+		}
+		if err := recordSources(tw, sessionServiceSrc(i), service.Path); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// recordSources stores the project files read besides the index: its Go
+// sources, parsed for imports and call sites, and its go.mod. A go.mod found in
+// a parent directory is stored at the root of the recorded sources.
+func recordSources(tw *tar.Writer, prefix, projectPath string) error {
+	goModPath, err := findGoMod(projectPath)
+	if err != nil {
+		return err
+	}
+	if goModPath != "" {
+		data, err := os.ReadFile(goModPath)
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, prefix+"/go.mod", data); err != nil {
+			return err
+		}
+	}
+
+	return filepath.WalkDir(projectPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p != projectPath && (name == "vendor" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		rel, err := filepath.Rel(projectPath, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return writeTarFile(tw, prefix+"/"+filepath.ToSlash(rel), data)
+	})
+}
+
+func writeTarIndex(tw *tar.Writer, name, indexPath string) error {
+	r, err := openIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read index %s: %w", indexPath, err)
+	}
+	return writeTarFile(tw, name, data)
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write %s to session archive: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to session archive: %w", name, err)
+	}
+	return nil
+}
+
+// replayedSession is a recorded session extracted to a temp directory
+type replayedSession struct {
+	session
+	Dir string
+	// NewGoMod is the recorded go.mod of the new version, nil if it had none
+	NewGoMod []byte
+}
+
+// loadSession extracts a session archive written by recordSession
+func loadSession(archivePath string) (*replayedSession, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session archive: %w", err)
+	}
+	defer f.Close()
+
+	dir, err := cleanups.tempDir("", "replay-*")
+	if err != nil {
+		return nil, err
+	}
+	replayed := &replayedSession{Dir: dir}
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			replayed.Close()
+			return nil, fmt.Errorf("failed to read session archive: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || path.IsAbs(name) || strings.HasPrefix(name, "../") {
+			continue
+		}
+		var data bytes.Buffer
+		if _, err := io.Copy(&data, tr); err != nil {
+			replayed.Close()
+			return nil, fmt.Errorf("failed to read %s from session archive: %w", name, err)
+		}
+		switch name {
+		case sessionFile:
+			if err := json.Unmarshal(data.Bytes(), &replayed.session); err != nil {
+				replayed.Close()
+				return nil, fmt.Errorf("invalid session manifest: %w", err)
+			}
+		case sessionModuleGoMod("new"):
+			replayed.NewGoMod = data.Bytes()
+		}
+
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			replayed.Close()
+			return nil, err
+		}
+		if err := os.WriteFile(dst, data.Bytes(), 0o644); err != nil {
+			replayed.Close()
+			return nil, err
+		}
+	}
+
+	if replayed.Module == "" {
+		replayed.Close()
+		return nil, fmt.Errorf("%s is not a session archive: %s missing", archivePath, sessionFile)
+	}
+	return replayed, nil
+}
+
+// path returns the location of a recorded file in the extracted session
+func (r *replayedSession) path(name string) string {
+	return filepath.Join(r.Dir, filepath.FromSlash(name))
+}
+
+// Close removes the extracted session
+func (r *replayedSession) Close() {
+	cleanups.remove(r.Dir)
+}
@@ -0,0 +1,99 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+// canonicalSignature parses a declaration from an index, e.g.
+// "func (c *Client) Do(req *Request) (*Response, error)", and prints it in a
+// form that only changes when the declaration does: gofmt formatting, without
+// comments, parameter and result names or the receiver name, which callers
+// can't depend on, and with type parameters named by position. Declarations
+// that don't parse are compared by their normalized text instead.
+func canonicalSignature(def string) string {
+	def = normalizeDefinition(def)
+	for _, prefix := range []string{"struct field ", "field "} {
+		field, ok := strings.CutPrefix(def, prefix)
+		if !ok {
+			continue
+		}
+		name, typ, ok := strings.Cut(field, " ")
+		if !ok {
+			return def
+		}
+		// Struct tags follow the type; they are kept since they change encoding
+		var tag string
+		if i := strings.Index(typ, " `"); i >= 0 {
+			typ, tag = typ[:i], typ[i:]
+		}
+		expr, err := parser.ParseExpr(typ)
+		if err != nil {
+			return def
+		}
+		return prefix + name + " " + printCanonical(expr) + tag
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+def, parser.SkipObjectResolution)
+	if err != nil || len(file.Decls) != 1 {
+		return def
+	}
+	decl := file.Decls[0]
+	renameTypeParams(decl)
+	if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil {
+		for _, field := range fn.Recv.List {
+			field.Names = nil
+		}
+	}
+	return printCanonical(decl)
+}
+
+// printCanonical prints node on one line after dropping the parameter and
+// result names of every function type in it
+func printCanonical(node ast.Node) string {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if fn, ok := n.(*ast.FuncType); ok {
+			fn.Params = unnamedFields(fn.Params)
+			fn.Results = unnamedFields(fn.Results)
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// unnamedFields returns the fields of a parameter list with one unnamed field
+// per name, so "a, b int" and "x int, y int" both become "int, int"
+func unnamedFields(list *ast.FieldList) *ast.FieldList {
+	if list == nil {
+		return nil
+	}
+	unnamed := &ast.FieldList{}
+	for _, field := range list.List {
+		for range max(len(field.Names), 1) {
+			unnamed.List = append(unnamed.List, &ast.Field{Type: field.Type})
+		}
+	}
+	return unnamed
+}
+
+// methodSignature returns the canonical signature of a method without its
+// receiver, so an interface method such as "func (Reader).Read(p []byte) (n
+// int, err error)" compares equal to a method implementing it, such as
+// "func (f *File) Read(b []byte) (int, error)"
+func methodSignature(def string) string {
+	def = normalizeDefinition(def)
+	if strings.HasPrefix(def, "func (") {
+		if end := matchingParen(def, len("func ")); end >= 0 {
+			def = "func " + strings.TrimLeft(def[end+1:], " .")
+		}
+	}
+	return canonicalSignature(def)
+}
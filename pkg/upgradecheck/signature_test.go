@@ -0,0 +1,67 @@
+package upgradecheck
+
+import "testing"
+
+func TestCanonicalSignature(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{"parameter names", "func Do(req *Request) error", "func Do(r *Request) error", true},
+		{"grouped parameters", "func Copy(dst, src string) (n int, err error)", "func Copy(a string, b string) (int, error)", true},
+		{"receiver name", "func (c *Client) Close() error", "func (client *Client) Close() error", true},
+		{"whitespace", "func Do( req  *Request )error", "func Do(req *Request) error", true},
+		{"comments", "func Do(req *Request) error // Do sends req", "func Do(req *Request) error", true},
+		{"type parameter names", "func Map[T, U any](s []T, f func(T) U) []U", "func Map[A, B any](s []A, f func(A) B) []B", true},
+		{"func-typed parameter names", "func Walk(fn func(path string) error) error", "func Walk(fn func(p string) error) error", true},
+		{"field", "struct field Timeout time.Duration", "struct field Timeout  time.Duration // how long", true},
+
+		{"parameter type", "func Do(req *Request) error", "func Do(req Request) error", false},
+		{"added result", "func Close()", "func Close() error", false},
+		{"pointer receiver", "func (c *Client) Close() error", "func (c Client) Close() error", false},
+		{"type parameter order", "func Pair[K comparable, V any](k K, v V)", "func Pair[K comparable, V any](v V, k K)", false},
+		{"constraint", "func Max[T any](a, b T) T", "func Max[T cmp.Ordered](a, b T) T", false},
+		{"field type", "struct field Timeout time.Duration", "struct field Timeout int64", false},
+		{"struct tag", "struct field URL string `json:\"url\"`", "struct field URL string `json:\"uri\"`", false},
+		{"// in struct tag", "struct field URL string `doc:\"https://a\"`", "struct field URL string `doc:\"https://b\"`", false},
+		{"unparsable", "func Do(", "func Do(req", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := canonicalSignature(tt.a), canonicalSignature(tt.b)
+			if (a == b) != tt.equal {
+				t.Errorf("canonicalSignature(%q) = %q\ncanonicalSignature(%q) = %q\nequal = %v, want %v", tt.a, a, tt.b, b, a == b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestMethodSignature(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"func (Reader).Read(p []byte) (n int, err error)", "func (f *File) Read(b []byte) (int, error)", true},
+		{"func (Reader).Read(p []byte) (n int, err error)", "func (f *File) Read(b []byte) int", false},
+	}
+	for _, tt := range tests {
+		if a, b := methodSignature(tt.a), methodSignature(tt.b); (a == b) != tt.equal {
+			t.Errorf("methodSignature(%q) = %q, methodSignature(%q) = %q; equal = %v, want %v", tt.a, a, tt.b, b, a == b, tt.equal)
+		}
+	}
+}
+
+func TestClassifyResultChanges(t *testing.T) {
+	findings := []Finding{
+		{Symbol: "Close", Kind: ChangeChanged, OldSignature: "func Close()", NewSignature: "func Close() error"},
+		{Symbol: "Do", Kind: ChangeChanged, OldSignature: "func Do(req *Request)", NewSignature: "func Do(req *Request, opts Options)"},
+		{Symbol: "Stop", Kind: ChangeChanged, OldSignature: "func Stop() error", NewSignature: "func Stop()"},
+	}
+	classifyResultChanges(findings)
+	for i, want := range []string{ChangeResults, ChangeChanged, ChangeChanged} {
+		if findings[i].Kind != want {
+			t.Errorf("%s kind = %s, want %s", findings[i].Symbol, findings[i].Kind, want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package upgradecheck
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeUnstableAPI marks findings for symbols the project uses that the new
+// version's API stability files don't list. They are reported whether or not
+// the upgrade changes them.
+const ChangeUnstableAPI = "unstable-api"
+
+// stableAPIFiles is where modules following the Go project's convention
+// declare their stable API, one file per release, e.g. api/go1.21.txt
+const stableAPIFiles = "api/*.txt"
+
+// stableAPI is the set of symbols stability files declare, keyed by import
+// path and then like extractSymbolsFromOccurrence: "Func", "Type",
+// "Type#Method" and "Type#Field"
+type stableAPI map[string]map[string]bool
+
+func (s stableAPI) add(pkg, key string) {
+	if s[pkg] == nil {
+		s[pkg] = make(map[string]bool)
+	}
+	s[pkg][key] = true
+}
+
+// parseStableAPI reads files in the format of the Go distribution's api
+// directory, one declaration per line:
+//
+//	pkg net/http, func Get(string) (*Response, error)
+//	pkg net/http, method (*Client) Do(*Request) (*Response, error)
+//	pkg net/http, type Client struct, Timeout time.Duration
+//	pkg io, type Reader interface, Read([]uint8) (int, error)
+//	pkg syscall (linux-386), const AF_INET = 2
+//
+// Comments and lines it doesn't recognize are skipped.
+func parseStableAPI(files map[string][]byte) stableAPI {
+	api := make(stableAPI)
+	for _, data := range files {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			rest, ok := strings.CutPrefix(line, "pkg ")
+			if !ok {
+				continue
+			}
+			pkg, decl, ok := strings.Cut(rest, ", ")
+			if !ok {
+				continue
+			}
+			// Platform specific declarations name the platform, "(linux-386)"
+			pkg, _, _ = strings.Cut(pkg, " ")
+			if key := stableAPIKey(decl); key != "" {
+				api.add(pkg, key)
+			}
+		}
+	}
+	return api
+}
+
+// stableAPIKey returns the key of one declaration of a stability file
+func stableAPIKey(decl string) string {
+	kind, rest, _ := strings.Cut(decl, " ")
+	switch kind {
+	case "func", "const", "var":
+		return declName(rest)
+	case "method":
+		recv, method, ok := strings.Cut(strings.TrimPrefix(rest, "("), ") ")
+		if !ok {
+			return ""
+		}
+		recv = declName(strings.TrimPrefix(recv, "*"))
+		return recv + "#" + declName(method)
+	case "type":
+		name := declName(rest)
+		// Members follow the kind of the type: "struct, Field T" or
+		// "interface, Method()"
+		for _, typeKind := range []string{"struct, ", "interface, "} {
+			if _, member, ok := strings.Cut(rest, " "+typeKind); ok {
+				if embedded, ok := strings.CutPrefix(member, "embedded "); ok {
+					// Embedded fields are named after their type
+					embedded = strings.TrimPrefix(embedded, "*")
+					if i := strings.LastIndex(embedded, "."); i >= 0 {
+						embedded = embedded[i+1:]
+					}
+					return name + "#" + declName(embedded)
+				}
+				return name + "#" + declName(member)
+			}
+		}
+		return name
+	}
+	return ""
+}
+
+// declName returns the identifier a declaration starts with, without type
+// parameters, parameters or the rest of the declaration
+func declName(decl string) string {
+	if i := strings.IndexAny(decl, " [(="); i >= 0 {
+		return decl[:i]
+	}
+	return decl
+}
+
+// readStableAPI returns the stable API the stability files of a version
+// declare, and false if it has none
+func readStableAPI(src *versionSource) (stableAPI, bool, error) {
+	paths, err := src.glob(stableAPIFiles)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(paths) == 0 {
+		return nil, false, nil
+	}
+	files := make(map[string][]byte)
+	for _, path := range paths {
+		data, err := src.readFile(path)
+		if err != nil {
+			return nil, false, err
+		}
+		files[path] = data
+	}
+	return parseStableAPI(files), true, nil
+}
+
+// stableAPIFindings reports the module symbols the project at indexPath
+// references outside of ignored directories that the stable API doesn't
+// include. Members of types the API lists count as stable only when listed
+// themselves.
+func stableAPIFindings(indexPath, module, version string, api stableAPI, ignored ignoredDirs) ([]Finding, error) {
+	index, err := loadIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	type unstable struct{ pkg, key string }
+	used := make(map[unstable][]Location)
+	for _, doc := range index.Documents {
+		if ignored.ignores(doc.RelativePath) {
+			continue
+		}
+		for _, occ := range doc.Occurrences {
+			if !inModule(occ.Symbol, module) {
+				continue
+			}
+			name, ok := parseSymbolName(occ.Symbol)
+			if !ok {
+				continue
+			}
+			key := name.Member
+			switch {
+			case name.Type != "" && name.Member != "":
+				key = name.Type + "#" + name.Member
+			case name.Type != "":
+				key = name.Type
+			}
+			if api[name.Package][key] {
+				continue
+			}
+			sym := unstable{name.Package, key}
+			used[sym] = append(used[sym], occurrenceLocation(doc.RelativePath, occ))
+		}
+	}
+
+	syms := make([]unstable, 0, len(used))
+	for sym := range used {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool {
+		if syms[i].pkg != syms[j].pkg {
+			return syms[i].pkg < syms[j].pkg
+		}
+		return syms[i].key < syms[j].key
+	})
+
+	var findings []Finding
+	for _, sym := range syms {
+		name := strings.Replace(sym.key, "#", ".", 1)
+		findings = append(findings, Finding{
+			Symbol:     defaultImportName(sym.pkg) + "." + name,
+			Package:    sym.pkg,
+			Kind:       ChangeUnstableAPI,
+			Severity:   SeverityWarning,
+			Confidence: ConfidenceHigh,
+			Usages:     used[sym],
+			Notes:      []string{fmt.Sprintf("%s@%s doesn't list %s in %s; it may change in any release", module, version, name, stableAPIFiles)},
+		})
+	}
+	return findings, nil
+}
@@ -0,0 +1,159 @@
+package upgradecheck
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// svnVCS reads Subversion repositories with the standard trunk, tags and
+// branches layout. The working copy is of one of those directories, switched
+// between them; revisions are the directory relative to the repository root,
+// optionally pinned to a revision number, e.g. "tags/v1.2.0" or "trunk@1234".
+type svnVCS struct{}
+
+func svn(dir string, args ...string) ([]byte, error) {
+	return runVCS("svn", dir, append([]string{"--non-interactive"}, args...)...)
+}
+
+// svnURL returns the repository-relative URL of a path at a revision
+func svnURL(rev, path string) string {
+	dir, pegRev, _ := strings.Cut(rev, "@")
+	url := "^/" + dir
+	if path != "" {
+		url += "/" + path
+	}
+	if pegRev != "" {
+		url += "@" + pegRev
+	}
+	return url
+}
+
+func (svnVCS) clone(url, dir string) error {
+	cmd := command("svn", "--non-interactive", "checkout", "--", strings.TrimSuffix(url, "/")+"/trunk", dir)
+	return runLogged(cmd)
+}
+
+func (svnVCS) head() string { return "trunk" }
+
+func (svnVCS) tag(dir, name string) (string, bool) {
+	rev := "tags/" + name
+	_, err := svn(dir, "info", svnURL(rev, ""))
+	return rev, err == nil
+}
+
+// commit maps the zero-padded revision number of a pseudo-version to trunk at
+// that revision
+func (svnVCS) commit(id string) string {
+	if n, err := strconv.Atoi(strings.TrimLeft(id, "0")); err == nil {
+		return fmt.Sprintf("trunk@%d", n)
+	}
+	return "trunk@" + id
+}
+
+func (svnVCS) checkout(dir, rev string) error {
+	cmd := command("svn", "--non-interactive", "switch", "--ignore-ancestry", svnURL(rev, ""), ".")
+	cmd.Dir = dir
+	return runLogged(cmd)
+}
+
+func (s svnVCS) commitID(dir, rev string) (string, error) {
+	n, err := s.revision(dir, rev)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("r%d", n), nil
+}
+
+func (svnVCS) files(dir, rev string) ([]string, error) {
+	out, err := svn(dir, "list", "--recursive", svnURL(rev, ""))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if !strings.HasSuffix(file, "/") {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func (svnVCS) show(dir, rev, path string) ([]byte, error) {
+	return svn(dir, "cat", svnURL(rev, path))
+}
+
+// svnLog is the output of svn log --xml --verbose
+type svnLog struct {
+	Entries []struct {
+		Revision string   `xml:"revision,attr"`
+		Message  string   `xml:"msg"`
+		Paths    []string `xml:"paths>path"`
+	} `xml:"logentry"`
+}
+
+func (s svnVCS) log(dir, oldRev, newRev, subdir string) ([]vcsCommit, error) {
+	oldNum, err := s.revision(dir, oldRev)
+	if err != nil {
+		return nil, err
+	}
+	newNum, err := s.revision(dir, newRev)
+	if err != nil {
+		return nil, err
+	}
+	if newNum <= oldNum {
+		return nil, nil
+	}
+
+	// The history of the new directory leads back through the copy a tag was
+	// made from to trunk
+	out, err := svn(dir, "log", "--xml", "--verbose", "--revision", fmt.Sprintf("%d:%d", oldNum+1, newNum), svnURL(newRev, subdir))
+	if err != nil {
+		return nil, err
+	}
+	var history svnLog
+	if err := xml.Unmarshal(out, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse svn log: %w", err)
+	}
+
+	var commits []vcsCommit
+	for _, entry := range history.Entries {
+		c := vcsCommit{Hash: entry.Revision, Message: entry.Message}
+		for _, path := range entry.Paths {
+			if file, ok := svnRelative(path, subdir); ok {
+				c.Files = append(c.Files, file)
+			}
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// revision returns the number of the last commit changing the directory of
+// rev, as of its pinned revision
+func (svnVCS) revision(dir, rev string) (int, error) {
+	out, err := svn(dir, "info", "--show-item", "last-changed-revision", svnURL(rev, ""))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+// svnRelative returns a path of svn log, such as /trunk/api/client.go or
+// /tags/v1.2.0/api/client.go, relative to subdir of its trunk, tag or branch
+func svnRelative(path, subdir string) (string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	switch {
+	case len(parts) >= 2 && parts[0] == "trunk":
+		path = strings.Join(parts[1:], "/")
+	case len(parts) == 3 && (parts[0] == "tags" || parts[0] == "branches"):
+		path = parts[2]
+	default:
+		return "", false
+	}
+	if subdir == "" {
+		return path, true
+	}
+	return strings.CutPrefix(path, subdir+"/")
+}
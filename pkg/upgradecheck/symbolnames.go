@@ -0,0 +1,79 @@
+package upgradecheck
+
+import (
+	"sort"
+	"strings"
+)
+
+// Findings are reported under canonical Go names, e.g. "api.Client.Do" for
+// the method the analyses key "Client#Do", with the import path of the package
+// alongside. The analyses keep their own keys; canonicalFindings renames the
+// findings once they are done.
+
+// canonicalName returns the Go name of a symbol key and the import path of its
+// package, e.g. "api.Client.Do" and "example.com/m/api" for "Client#Do".
+// Members are looked up by their type. Names not defined in exactly one
+// package of the module are only rid of the "#", as are environment
+// variables, "$NAME", kept as they are.
+func canonicalName(key string, packages symbolPackages) (string, string) {
+	if strings.HasPrefix(key, "$") {
+		return key, ""
+	}
+	name := strings.Replace(key, "#", ".", 1)
+	parent, member, isMember := strings.Cut(name, ".")
+	candidates := []string{key}
+	if isMember {
+		candidates = append(candidates, parent+"#"+member, parent)
+	}
+	for _, candidate := range candidates {
+		pkgs := packages[candidate]
+		if len(pkgs) == 0 {
+			continue
+		}
+		if len(pkgs) > 1 {
+			break
+		}
+		for pkg := range pkgs {
+			if pkg == "" {
+				return name, ""
+			}
+			return defaultImportName(pkg) + "." + name, pkg
+		}
+	}
+	return name, ""
+}
+
+// canonicalFindings renames findings to their canonical names and re-sorts
+// them. Findings already renamed, e.g. read back from a checkpoint, are left
+// alone.
+func canonicalFindings(findings []Finding, packages symbolPackages) {
+	for i := range findings {
+		f := &findings[i]
+		if f.Package != "" || strings.HasPrefix(f.Symbol, "$") {
+			continue
+		}
+		f.Symbol, f.Package = canonicalName(f.Symbol, packages)
+	}
+	sortFindings(findings)
+}
+
+// canonicalSymbols returns the canonical names of symbol keys, sorted
+func canonicalSymbols(keys []string, packages symbolPackages) []string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		name, _ := canonicalName(key, packages)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Name returns the symbol of the finding without its package, e.g.
+// "Client.Do" for "api.Client.Do", as compiler errors name it after the
+// package qualifier
+func (f Finding) Name() string {
+	if f.Package == "" {
+		return strings.Replace(f.Symbol, "#", ".", 1)
+	}
+	return strings.TrimPrefix(f.Symbol, defaultImportName(f.Package)+".")
+}
@@ -0,0 +1,181 @@
+package upgradecheck
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// symbolName is a scip-go symbol split into its package, type and member, e.g.
+// "scip-go gomod example.com/m v1.0.0 `example.com/m/api`/Client#Close()." is
+// the method Close of type Client in package example.com/m/api. Package-level
+// functions, variables and constants have no Type, and types no Member.
+type symbolName struct {
+	Package string
+	Type    string
+	Member  string
+	// Method is set when Member is a method rather than a field or term
+	Method bool
+}
+
+// parseSymbolName parses the descriptors of a scip-go symbol following the
+// SCIP grammar: namespaces end in "/", types in "#", terms in "." and methods
+// and functions in "()." with an optional disambiguator inside the
+// parentheses. Names with characters other than letters, digits, "_", "+", "-"
+// and "$" are escaped in backticks. Local and malformed symbols return false.
+func parseSymbolName(symbol string) (symbolName, bool) {
+	fields := strings.SplitN(symbol, " ", 5)
+	if len(fields) < 5 {
+		return symbolName{}, false
+	}
+
+	var name symbolName
+	var namespaces []string
+	for desc := fields[4]; desc != ""; {
+		ident, rest, ok := cutDescriptorName(desc)
+		// Type parameters, "[T]", and parameters, "(x)", have no name in front
+		// and aren't symbols a module exports
+		if !ok || rest == "" || ident == "" {
+			return symbolName{}, false
+		}
+		switch rest[0] {
+		case '/':
+			namespaces = append(namespaces, ident)
+			rest = rest[1:]
+		case '#':
+			if name.Type != "" || name.Member != "" {
+				return symbolName{}, false
+			}
+			name.Type = ident
+			rest = rest[1:]
+		case '.':
+			// Fields of anonymous struct types nest under the outer member
+			if name.Member == "" {
+				name.Member = ident
+			}
+			rest = rest[1:]
+		case '(':
+			end := strings.Index(rest, ").")
+			if end < 0 {
+				return symbolName{}, false
+			}
+			if name.Member == "" {
+				name.Member, name.Method = ident, true
+			}
+			rest = rest[end+2:]
+		default:
+			return symbolName{}, false
+		}
+		desc = rest
+	}
+	if name.Type == "" && name.Member == "" {
+		return symbolName{}, false
+	}
+	name.Package = strings.Join(namespaces, "/")
+	return name, true
+}
+
+// cutDescriptorName splits the name off the front of a descriptor, unescaping
+// backticked names. It returns false for an unterminated backtick.
+func cutDescriptorName(desc string) (name, rest string, ok bool) {
+	if !strings.HasPrefix(desc, "`") {
+		end := strings.IndexFunc(desc, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_+-$", r)
+		})
+		if end < 0 {
+			return desc, "", true
+		}
+		return desc[:end], desc[end:], true
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(desc); i++ {
+		if desc[i] != '`' {
+			b.WriteByte(desc[i])
+			continue
+		}
+		if i+1 < len(desc) && desc[i+1] == '`' {
+			b.WriteByte('`')
+			i++
+			continue
+		}
+		return b.String(), desc[i+1:], true
+	}
+	return "", "", false
+}
+
+// definitionKey returns the key getAvailableSymbols files a symbol under, given
+// the result of extractSymbolsFromOccurrence: types carry their fields, while
+// methods and package-level symbols have keys of their own, e.g. "Client",
+// "Client#Close" and "NewClient"
+func definitionKey(val, typ string) string {
+	if typ == "type" {
+		return strings.Split(val, "#")[0]
+	}
+	return val
+}
+
+// symbolPackages records the packages the symbols filed under each key belong
+// to, so symbols of the same name in different packages of a module aren't
+// taken for one another
+type symbolPackages map[string]map[string]bool
+
+// add records the package of symbol under key
+func (p symbolPackages) add(key, symbol string) {
+	name, ok := parseSymbolName(symbol)
+	if !ok {
+		return
+	}
+	if p[key] == nil {
+		p[key] = make(map[string]bool)
+	}
+	p[key][name.Package] = true
+}
+
+// rename moves the packages recorded under aliased keys to their aliases, as
+// resolveAliases and aliasUsages do for definitions and usages
+func (p symbolPackages) rename(aliases map[string]string) {
+	for key := range p {
+		_, renamed, ok := aliasedKey(key, aliases)
+		if !ok {
+			continue
+		}
+		for pkg := range p[key] {
+			if p[renamed] == nil {
+				p[renamed] = make(map[string]bool)
+			}
+			p[renamed][pkg] = true
+		}
+		delete(p, key)
+	}
+}
+
+// overlap reports whether the symbols under key a in p and key b in other may
+// be the same: they share a package, or the package of either is unknown, as
+// for usages found in the sources rather than the index
+func (p symbolPackages) overlap(a string, other symbolPackages, b string) bool {
+	if len(p[a]) == 0 || len(other[b]) == 0 {
+		return true
+	}
+	for pkg := range p[a] {
+		if other[b][pkg] {
+			return true
+		}
+	}
+	return false
+}
+
+// definedPackages returns the packages of the symbols an index defines, keyed
+// like getAvailableSymbols
+func definedPackages(index *scip.Index) symbolPackages {
+	packages := make(symbolPackages)
+	for _, doc := range index.Documents {
+		for _, sym := range doc.Symbols {
+			if val, typ := extractSymbolsFromOccurrence(sym.Symbol); val != "" {
+				packages.add(definitionKey(val, typ), sym.Symbol)
+			}
+		}
+	}
+	return packages
+}
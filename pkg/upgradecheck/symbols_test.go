@@ -0,0 +1,98 @@
+package upgradecheck
+
+import "testing"
+
+func TestParseSymbolName(t *testing.T) {
+	const prefix = "scip-go gomod example.com/m v1.0.0 "
+	tests := []struct {
+		symbol string
+		want   symbolName
+		wantOK bool
+	}{
+		{prefix + "`example.com/m/api`/Client#Close().", symbolName{Package: "example.com/m/api", Type: "Client", Member: "Close", Method: true}, true},
+		{prefix + "`example.com/m`/NewClient().", symbolName{Package: "example.com/m", Member: "NewClient", Method: true}, true},
+		{prefix + "`example.com/m`/Client#", symbolName{Package: "example.com/m", Type: "Client"}, true},
+		{prefix + "`example.com/m`/Client#Timeout.", symbolName{Package: "example.com/m", Type: "Client", Member: "Timeout"}, true},
+		{prefix + "`example.com/m`/DefaultTimeout.", symbolName{Package: "example.com/m", Member: "DefaultTimeout"}, true},
+		// Doubled backticks escape a backtick in the name
+		{prefix + "`example.com/m/a``b`/Client#", symbolName{Package: "example.com/m/a`b", Type: "Client"}, true},
+		{prefix + "`example.com/m`/`odd``name`().", symbolName{Package: "example.com/m", Member: "odd`name", Method: true}, true},
+		// Disambiguators inside the parentheses are skipped
+		{prefix + "`example.com/m`/init(a8f2).", symbolName{Package: "example.com/m", Member: "init", Method: true}, true},
+		// Fields of anonymous structs stay under the outer member
+		{prefix + "`example.com/m`/Config#Retry.Max.", symbolName{Package: "example.com/m", Type: "Config", Member: "Retry"}, true},
+		{prefix + "`example.com/m`/Options.Dial().", symbolName{Package: "example.com/m", Member: "Options"}, true},
+		// Type parameters and parameters aren't exported symbols
+		{prefix + "`example.com/m`/Map().[T]", symbolName{}, false},
+		{prefix + "`example.com/m`/List#[T]", symbolName{}, false},
+		{prefix + "`example.com/m`/Map().(f)", symbolName{}, false},
+		{prefix + "`example.com/m/Client#", symbolName{}, false},
+		{prefix + "`example.com/m`/Client#Close(", symbolName{}, false},
+		{prefix + "`example.com/m`/", symbolName{}, false},
+		{"local 1", symbolName{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSymbolName(tt.symbol)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseSymbolName(%q) = %+v, %v, want %+v, %v", tt.symbol, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCutDescriptorName(t *testing.T) {
+	tests := []struct {
+		desc, wantName, wantRest string
+		wantOK                   bool
+	}{
+		{"Client#Close().", "Client", "#Close().", true},
+		{"Close", "Close", "", true},
+		{"a_b+c-d$e.", "a_b+c-d$e", ".", true},
+		{"`example.com/m`/Client#", "example.com/m", "/Client#", true},
+		{"`a``b`/", "a`b", "/", true},
+		{"`a````b`", "a``b", "", true},
+		{"``/", "", "/", true},
+		{"[T]", "", "[T]", true},
+		{"`example.com/m/Client#", "", "", false},
+		{"`a``", "", "", false},
+	}
+	for _, tt := range tests {
+		name, rest, ok := cutDescriptorName(tt.desc)
+		if name != tt.wantName || rest != tt.wantRest || ok != tt.wantOK {
+			t.Errorf("cutDescriptorName(%q) = %q, %q, %v, want %q, %q, %v", tt.desc, name, rest, ok, tt.wantName, tt.wantRest, tt.wantOK)
+		}
+	}
+}
+
+func TestSymbolPackagesOverlap(t *testing.T) {
+	const prefix = "scip-go gomod example.com/m v1.0.0 "
+	defined := make(symbolPackages)
+	defined.add("Client", prefix+"`example.com/m/api`/Client#")
+	defined.add("Client", prefix+"`example.com/m/internal`/Client#")
+	defined.add("Open", prefix+"`example.com/m`/Open().")
+	// Local symbols have no package and record nothing
+	defined.add("x", "local 1")
+
+	used := make(symbolPackages)
+	used.add("Client", prefix+"`example.com/m/internal`/Client#")
+	used.add("Open", prefix+"`example.com/m/v2`/Open().")
+
+	tests := []struct {
+		a     string
+		other symbolPackages
+		b     string
+		want  bool
+	}{
+		{"Client", used, "Client", true},
+		{"Open", used, "Open", false},
+		{"Open", defined, "Open", true},
+		// An unknown package overlaps everything
+		{"Open", symbolPackages{}, "Open", true},
+		{"x", used, "Open", true},
+		{"Missing", used, "Client", true},
+	}
+	for _, tt := range tests {
+		if got := defined.overlap(tt.a, tt.other, tt.b); got != tt.want {
+			t.Errorf("overlap(%q, %v, %q) = %v, want %v", tt.a, tt.other, tt.b, got, tt.want)
+		}
+	}
+}
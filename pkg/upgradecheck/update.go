@@ -0,0 +1,299 @@
+package upgradecheck
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// updateRepository is the GitHub repository whose releases the update command
+// installs
+const updateRepository = "Oloruntobi1/go-upgrade-checker"
+
+// checksumsAsset is the release asset listing the SHA-256 of the binaries,
+// one "<hex>  <asset>" line each like sha256sum writes
+const checksumsAsset = "checksums.txt"
+
+// updateClient downloads release binaries, which takes longer than API calls
+var updateClient = &http.Client{Timeout: 10 * time.Minute}
+
+// release is a GitHub release of the tool
+type release struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+	Assets     []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL returns the download URL of the asset called name, or ""
+func (r *release) assetURL(name string) string {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL
+		}
+	}
+	return ""
+}
+
+// binaryAsset is the name of the release binary for this platform, e.g.
+// go-upgrade-checker_linux_amd64
+func binaryAsset() string {
+	name := fmt.Sprintf("go-upgrade-checker_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// runUpdate implements the "update" subcommand, which replaces the running
+// binary with the latest release, or the release pinned by update-version in
+// the configuration file, after verifying its checksum
+func runUpdate(args []string) {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	version := fs.String("version", "", "Install this release instead of the latest, e.g. v1.4.2, or the latest of a major or minor version, e.g. v1 or v1.4 (overrides update-version in the config)")
+	checkOnly := fs.Bool("check", false, "Only print whether another release would be installed; exits 1 if so")
+	prerelease := fs.Bool("include-prerelease", false, "Also consider pre-releases")
+	configPath := fs.String("config", "", "Configuration file pinning the release with update-version (defaults to .upgradecheck.yaml in the current directory or a parent directory up to the repository root)")
+	repo := fs.String("repo", updateRepository, "GitHub repository (owner/name) to download releases from")
+	apiURL := fs.String("api-url", "https://api.github.com", "GitHub API URL, e.g. of GitHub Enterprise Server")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: go-upgrade-checker update [--check] [--version v]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	pin := *version
+	if pin == "" {
+		path := *configPath
+		if path == "" {
+			var err error
+			if path, err = findConfig("."); err != nil {
+				fatalf("%v", err)
+			}
+		}
+		if path != "" {
+			cfg, err := loadConfig(path)
+			if err != nil {
+				fatalf("%v", err)
+			}
+			if pin = cfg.UpdateVersion; pin != "" {
+				fmt.Printf("Pinned to %s by %s\n", pin, path)
+			}
+		}
+	}
+	if pin != "" && !semver.IsValid(pin) {
+		fatalf("Invalid version %q: use a semantic version such as v1.4.2, v1.4 or v1", pin)
+	}
+
+	releases, err := listReleases(*apiURL, *repo)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	target := selectRelease(releases, pin, *prerelease)
+	if target == nil {
+		if pin != "" {
+			fatalf("No release of %s matches %s", *repo, pin)
+		}
+		fatalf("%s has no releases", *repo)
+	}
+
+	current := checkerVersion()
+	if target.TagName == current {
+		fmt.Printf("go-upgrade-checker %s is up to date\n", current)
+		return
+	}
+	if *checkOnly {
+		fmt.Printf("go-upgrade-checker %s would be replaced by %s\n", current, target.TagName)
+		exit(ExitFindings)
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fatalf("Failed to locate the running binary: %v", err)
+	}
+	if err := installRelease(target, exe); err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("Updated go-upgrade-checker %s to %s (%s)\n", current, target.TagName, exe)
+}
+
+// listReleases returns the releases of repo, newest first, authenticating
+// with GITHUB_TOKEN when it is set to avoid the anonymous rate limit
+func listReleases(apiURL, repo string) ([]release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=100", strings.TrimSuffix(apiURL, "/"), repo)
+	req, err := http.NewRequestWithContext(runContext, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := githubClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("listing releases of %s returned %s: %s", repo, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var releases []release
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to parse releases: %w", err)
+	}
+	return releases, nil
+}
+
+// selectRelease returns the highest published release matching pin, which is
+// an exact version or a major (v1) or minor (v1.4) version, or any release
+// when pin is empty. Pre-releases only qualify when pinned exactly or with
+// includePrerelease.
+func selectRelease(releases []release, pin string, includePrerelease bool) *release {
+	var best *release
+	for i := range releases {
+		r := &releases[i]
+		if r.Draft || !semver.IsValid(r.TagName) {
+			continue
+		}
+		if pin != "" && !matchesPin(r.TagName, pin) {
+			continue
+		}
+		if r.Prerelease && !includePrerelease && r.TagName != pin {
+			continue
+		}
+		if best == nil || semver.Compare(r.TagName, best.TagName) > 0 {
+			best = r
+		}
+	}
+	return best
+}
+
+// matchesPin reports whether version is pin or, for a major (v1) or minor
+// (v1.4) pin, a version of it
+func matchesPin(version, pin string) bool {
+	switch pin {
+	case semver.Major(pin):
+		return semver.Major(version) == pin
+	case semver.MajorMinor(pin):
+		return semver.MajorMinor(version) == pin
+	}
+	return version == pin
+}
+
+// installRelease downloads the binary of r for this platform, verifies it
+// against the checksums published with the release and replaces exe with it
+func installRelease(r *release, exe string) error {
+	name := binaryAsset()
+	binaryURL := r.assetURL(name)
+	if binaryURL == "" {
+		return fmt.Errorf("release %s has no binary for %s/%s (%s)", r.TagName, runtime.GOOS, runtime.GOARCH, name)
+	}
+	checksumsURL := r.assetURL(checksumsAsset)
+	if checksumsURL == "" {
+		return fmt.Errorf("release %s has no %s to verify the binary with", r.TagName, checksumsAsset)
+	}
+
+	var checksums bytes.Buffer
+	if err := download(checksumsURL, &checksums); err != nil {
+		return err
+	}
+	want, err := assetChecksum(&checksums, name)
+	if err != nil {
+		return fmt.Errorf("%s of %s: %w", checksumsAsset, r.TagName, err)
+	}
+
+	// The new binary is written next to the old one so the rename replacing
+	// it stays on one file system
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".go-upgrade-checker-*")
+	if err != nil {
+		return fmt.Errorf("failed to write next to %s: %w", exe, err)
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	err = download(binaryURL, io.MultiWriter(tmp, hash))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch for %s of %s: got %s, want %s", name, r.TagName, got, want)
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+
+	// Windows doesn't replace a running executable, but lets it be renamed
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("failed to move %s aside: %w", exe, err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	return nil
+}
+
+// download writes the body of url to w
+func download(url string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(runContext, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := updateClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading %s returned %s", url, resp.Status)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return nil
+}
+
+// assetChecksum returns the hex SHA-256 of the asset called name in a
+// checksums file
+func assetChecksum(r io.Reader, name string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sha256sum marks binary mode with "*" before the name
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			if sum, err := hex.DecodeString(fields[0]); err != nil || len(sum) != sha256.Size {
+				return "", fmt.Errorf("invalid checksum %q for %s", fields[0], name)
+			}
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum for %s", name)
+}
@@ -0,0 +1,146 @@
+package upgradecheck
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelectRelease(t *testing.T) {
+	releases := []release{
+		{TagName: "v1.3.0"},
+		{TagName: "v1.4.1"},
+		{TagName: "v1.4.2"},
+		{TagName: "v1.5.0-rc.1", Prerelease: true},
+		{TagName: "v2.0.0"},
+		{TagName: "v2.1.0", Draft: true},
+		{TagName: "nightly"},
+	}
+	tests := []struct {
+		pin        string
+		prerelease bool
+		want       string
+	}{
+		{"", false, "v2.0.0"},
+		{"v1", false, "v1.4.2"},
+		{"v1", true, "v1.5.0-rc.1"},
+		{"v1.4", false, "v1.4.2"},
+		{"v1.4.1", false, "v1.4.1"},
+		{"v1.5.0-rc.1", false, "v1.5.0-rc.1"},
+		{"v1.5", false, ""},
+		{"v2.1.0", false, ""},
+		{"v3", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%v", tt.pin, tt.prerelease), func(t *testing.T) {
+			got := ""
+			if r := selectRelease(releases, tt.pin, tt.prerelease); r != nil {
+				got = r.TagName
+			}
+			if got != tt.want {
+				t.Errorf("selectRelease(%q, %v) = %q, want %q", tt.pin, tt.prerelease, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssetChecksum(t *testing.T) {
+	sum := strings.Repeat("ab", sha256.Size)
+	tests := []struct {
+		name      string
+		checksums string
+		want      string
+		wantErr   string
+	}{
+		{"text mode", sum + "  tool_linux_amd64\n", sum, ""},
+		{"binary mode", sum + " *tool_linux_amd64\n", sum, ""},
+		{"upper case", strings.ToUpper(sum) + "  tool_linux_amd64\n", sum, ""},
+		{"other assets", sum + "  tool_darwin_arm64\n" + sum + "  tool_linux_amd64.sig\n", "", "no checksum"},
+		{"short", "abcd  tool_linux_amd64\n", "", "invalid checksum"},
+		{"not hex", strings.Repeat("zz", sha256.Size) + "  tool_linux_amd64\n", "", "invalid checksum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := assetChecksum(strings.NewReader(tt.checksums), "tool_linux_amd64")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("assetChecksum() = %q, %v; want an error mentioning %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("assetChecksum() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallRelease(t *testing.T) {
+	binary := []byte("new binary")
+	sum := sha256.Sum256(binary)
+	good := hex.EncodeToString(sum[:]) + "  " + binaryAsset() + "\n"
+	bad := strings.Repeat("00", sha256.Size) + "  " + binaryAsset() + "\n"
+
+	tests := []struct {
+		name      string
+		checksums string
+		assets    []string
+		wantErr   string
+	}{
+		{"verified", good, []string{binaryAsset(), checksumsAsset}, ""},
+		{"checksum mismatch", bad, []string{binaryAsset(), checksumsAsset}, "checksum mismatch"},
+		{"no checksums", good, []string{binaryAsset()}, "no " + checksumsAsset},
+		{"no binary", good, []string{checksumsAsset}, "has no binary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/" + checksumsAsset:
+					fmt.Fprint(w, tt.checksums)
+				case "/" + binaryAsset():
+					w.Write(binary)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			r := &release{TagName: "v1.4.2"}
+			for _, name := range tt.assets {
+				r.Assets = append(r.Assets, struct {
+					Name string `json:"name"`
+					URL  string `json:"browser_download_url"`
+				}{name, server.URL + "/" + name})
+			}
+			exe := filepath.Join(t.TempDir(), "go-upgrade-checker")
+			if err := os.WriteFile(exe, []byte("old binary"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			err := installRelease(r, exe)
+			want := "new binary"
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("installRelease() error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				want = "old binary"
+			} else if err != nil {
+				t.Fatalf("installRelease() error = %v", err)
+			}
+			if data, _ := os.ReadFile(exe); string(data) != want {
+				t.Errorf("binary = %q, want %q", data, want)
+			}
+			// Nothing is left behind next to the binary
+			if entries, _ := os.ReadDir(filepath.Dir(exe)); len(entries) != 1 {
+				t.Errorf("%d files next to the binary, want 1", len(entries))
+			}
+		})
+	}
+}
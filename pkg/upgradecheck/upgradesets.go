@@ -0,0 +1,130 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// upgradeSets collects repeated --upgrade-set flags. Each set is a list of
+// module path patterns, such as "k8s.io/*", whose modules must move together:
+// upgrading one of them pulls in the versions of the others it requires.
+type upgradeSets [][]string
+
+func (s *upgradeSets) String() string {
+	var sets []string
+	for _, set := range *s {
+		sets = append(sets, strings.Join(set, ","))
+	}
+	return strings.Join(sets, " ")
+}
+
+func (s *upgradeSets) Set(value string) error {
+	var set []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid module pattern %q: %w", pattern, err)
+		}
+		set = append(set, pattern)
+	}
+	if len(set) == 0 {
+		return fmt.Errorf("empty upgrade set")
+	}
+	*s = append(*s, set)
+	return nil
+}
+
+// match returns the patterns of the first set the module belongs to, or nil
+func (s upgradeSets) match(module string) []string {
+	for _, set := range s {
+		if inUpgradeSet(set, module) {
+			return set
+		}
+	}
+	return nil
+}
+
+// inUpgradeSet reports whether a module path matches one of the patterns,
+// which follow path.Match: "*" stands for one path element or part of it
+func inUpgradeSet(patterns []string, module string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, module); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeSetMembers returns the upgrade of module from oldVersion to
+// newVersion followed by those of the other members of its set that the new
+// version requires at a newer version than the project: the versions the
+// build would move them to anyway. Members the new version doesn't require,
+// or the project doesn't use, are left out.
+func upgradeSetMembers(projectPath, module, oldVersion, newVersion string, patterns []string) ([]moduleUpgrade, error) {
+	upgrades := []moduleUpgrade{{Module: module, OldVersion: oldVersion, NewVersion: newVersion}}
+
+	data, err := fetchGoMod(module, newVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the go.mod of %s@%s: %w", module, newVersion, err)
+	}
+	file, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the go.mod of %s@%s: %w", module, newVersion, err)
+	}
+
+	for _, req := range file.Require {
+		member := req.Mod.Path
+		if member == module || !inUpgradeSet(patterns, member) {
+			continue
+		}
+		current, err := pinnedVersion(projectPath, member)
+		if err != nil {
+			log.Printf("%s is not used by the project, skipping it", member)
+			continue
+		}
+		if semver.Compare(req.Mod.Version, current) <= 0 {
+			log.Printf("%s %s already satisfies %s@%s", member, current, module, newVersion)
+			continue
+		}
+		upgrades = append(upgrades, moduleUpgrade{Module: member, OldVersion: current, NewVersion: req.Mod.Version})
+	}
+	return upgrades, nil
+}
+
+// checkUpgradeSet checks the upgrade of module together with the coordinated
+// upgrades of its set, each in a separate run of the tool like --all. The
+// versions default as for a single check. args are passed on to every check.
+func checkUpgradeSet(projectPath, module, oldVersion, newVersion string, includePrerelease bool, patterns []string, args []string) (*batchReport, error) {
+	first := strings.TrimSpace(strings.Split(projectPath, ",")[0])
+	var err error
+	if oldVersion == "" {
+		if oldVersion, err = pinnedVersion(first, module); err != nil {
+			return nil, fmt.Errorf("failed to read the version of %s from %s: %w; pass --old-version", module, first, err)
+		}
+	}
+	if newVersion == "" {
+		if newVersion, err = latestRelease(module, includePrerelease); err != nil {
+			return nil, fmt.Errorf("failed to find the latest version of %s: %w; pass --new-version", module, err)
+		}
+	}
+
+	upgrades, err := upgradeSetMembers(first, module, oldVersion, newVersion, patterns)
+	if err != nil {
+		return nil, err
+	}
+	for _, upgrade := range upgrades[1:] {
+		log.Printf("%s@%s moves %s along to %s", module, newVersion, upgrade.Module, upgrade.NewVersion)
+	}
+
+	batch := &batchReport{Modules: []*Report{}}
+	checkUpgrades(batch, projectPath, upgrades, args)
+	return batch, nil
+}
@@ -0,0 +1,277 @@
+package upgradecheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Version control systems hosting module repositories, the values of --vcs
+const (
+	VCSAuto       = "auto"
+	VCSGit        = "git"
+	VCSMercurial  = "hg"
+	VCSSubversion = "svn"
+)
+
+// vcs reads the versions of a module from a clone of its repository. Revisions
+// are whatever the backend checks out, e.g. a commit hash or tag for git.
+type vcs interface {
+	// clone clones the repository at url into dir, an empty directory
+	clone(url, dir string) error
+	// head is the revision of the default branch
+	head() string
+	// tag returns the revision of a tag, and whether the tag exists
+	tag(dir, name string) (string, bool)
+	// commit returns the revision of the commit a pseudo-version names, e.g.
+	// its abbreviated hash
+	commit(id string) string
+	// checkout updates the working copy to rev
+	checkout(dir, rev string) error
+	// commitID returns the immutable ID of the commit rev refers to
+	commitID(dir, rev string) (string, error)
+	// files lists the files at rev, relative to the working copy root
+	files(dir, rev string) ([]string, error)
+	// show returns the contents of a file at rev
+	show(dir, rev, path string) ([]byte, error)
+	// log returns the commits after oldRev up to newRev, oldest first, with
+	// the files they change in subdir, relative to it
+	log(dir, oldRev, newRev, subdir string) ([]vcsCommit, error)
+}
+
+// vcsCommit is a commit read from a repository's history
+type vcsCommit struct {
+	Hash    string
+	Message string
+	Files   []string
+}
+
+// newVCS returns the backend of a system
+func newVCS(name string) (vcs, error) {
+	switch name {
+	case VCSGit:
+		return gitVCS{}, nil
+	case VCSMercurial:
+		return hgVCS{}, nil
+	case VCSSubversion:
+		return svnVCS{}, nil
+	}
+	return nil, fmt.Errorf("unknown version control system %q: must be %s, %s, %s or %s", name, VCSAuto, VCSGit, VCSMercurial, VCSSubversion)
+}
+
+// goImport matches the go-import meta tags vanity import paths serve to the go
+// command: <meta name="go-import" content="prefix vcs repo-url">
+var goImport = regexp.MustCompile(`<meta\s+name="go-import"\s+content="([^"]*)"`)
+
+// resolve determines the system hosting the repository, unless given, where
+// to clone it from and the import path of its root. Subversion URLs are
+// recognized by their scheme. The default https://<repo-root>.git guess of
+// other hosts than GitHub, GitLab and Bitbucket is checked against the
+// go-import meta tag of the path, which the go command resolves modules with:
+// vanity paths such as cloud.google.com/go/storage name a repository hosted
+// elsewhere, with the module in a subdirectory. Everything else is git.
+func (r *moduleRepo) resolve(ctx context.Context) {
+	r.Remote = r.URL
+	defer func() {
+		if r.VCS == nil {
+			r.VCS = gitVCS{}
+		}
+	}()
+	if strings.HasPrefix(r.URL, "svn://") || strings.HasPrefix(r.URL, "svn+ssh://") {
+		if r.VCS == nil {
+			r.VCS = svnVCS{}
+		}
+		return
+	}
+	root, ok := strings.CutPrefix(r.URL, "https://")
+	root, isGit := strings.CutSuffix(root, ".git")
+	if !ok || !isGit {
+		return
+	}
+	r.Root = root
+	if host, _, _ := strings.Cut(root, "/"); repoRootHosts[host] {
+		return
+	}
+
+	prefix, system, repoURL, ok := goImportMeta(ctx, root)
+	if !ok {
+		return
+	}
+	if !validRepoURL(system, repoURL) {
+		log.Printf("Warning: ignoring the go-import repository %q of %s: not a %s URL the go command would clone", repoURL, root, system)
+		return
+	}
+	r.Root, r.Remote = prefix, repoURL
+	if r.VCS == nil {
+		r.VCS, _ = newVCS(system)
+	}
+}
+
+// repoSchemes are the URL schemes the go command clones each system's
+// repositories over by default; others, such as file or ext transports, and
+// plain http aren't taken from a go-import meta tag
+var repoSchemes = map[string][]string{
+	VCSGit:        {"https", "ssh", "git+ssh"},
+	VCSMercurial:  {"https", "ssh"},
+	VCSSubversion: {"https", "svn", "svn+ssh"},
+}
+
+// validRepoURL reports whether repoURL, read from a go-import meta tag, is a
+// URL of a system's repository that is safe to pass to its command
+func validRepoURL(system, repoURL string) bool {
+	if strings.HasPrefix(repoURL, "-") {
+		return false
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return slices.Contains(repoSchemes[system], u.Scheme)
+}
+
+// goImportMeta looks up the go-import meta tag that importPath serves to the
+// go command, returning the import path of the repository root, its version
+// control system and URL
+func goImportMeta(ctx context.Context, importPath string) (prefix, system, url string, ok bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+importPath+"?go-get=1", nil)
+	if err != nil {
+		return "", "", "", false
+	}
+	resp, err := proxyClient.Do(req)
+	if err != nil {
+		return "", "", "", false
+	}
+	defer resp.Body.Close()
+	page, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return "", "", "", false
+	}
+	for _, m := range goImport.FindAllSubmatch(page, -1) {
+		fields := strings.Fields(html.UnescapeString(string(m[1])))
+		// Module proxies also serve a "mod" entry, which isn't a repository
+		if len(fields) != 3 || fields[1] == "mod" {
+			continue
+		}
+		if fields[0] == importPath || strings.HasPrefix(importPath, fields[0]+"/") {
+			return fields[0], fields[1], fields[2], true
+		}
+	}
+	return "", "", "", false
+}
+
+// runVCS runs a command of a version control system in the working copy and
+// returns its stdout
+func runVCS(name, dir string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	cmd := command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", name, args[0], err)
+	}
+	return out.Bytes(), nil
+}
+
+// git runs a git command in the repository and returns its stdout
+func git(repoDir string, args ...string) ([]byte, error) {
+	return runVCS("git", repoDir, args...)
+}
+
+// gitAllowedProtocols are the transports git may clone over unless
+// GIT_ALLOW_PROTOCOL says otherwise: the schemes the go command fetches git
+// repositories with, but not ext::, which runs arbitrary commands, or file
+const gitAllowedProtocols = "git:https:http:git+ssh:ssh"
+
+// gitVCS is the default backend
+type gitVCS struct{}
+
+func (gitVCS) clone(url, dir string) error {
+	cmd := command("git", "clone", "--", url, dir)
+	if toolGetenv("GIT_ALLOW_PROTOCOL") == "" {
+		cmd.Env = append(cmd.Env[:len(cmd.Env):len(cmd.Env)], "GIT_ALLOW_PROTOCOL="+gitAllowedProtocols)
+	}
+	return runLogged(cmd)
+}
+
+func (gitVCS) head() string { return "HEAD" }
+
+func (gitVCS) tag(dir, name string) (string, bool) {
+	_, err := git(dir, "rev-parse", "--verify", "--quiet", "refs/tags/"+name)
+	return name, err == nil
+}
+
+func (gitVCS) commit(id string) string { return id }
+
+func (gitVCS) checkout(dir, rev string) error {
+	// git checkout doesn't take --end-of-options, so a revision that would
+	// parse as an option is refused; "--" keeps it from naming paths
+	if strings.HasPrefix(rev, "-") {
+		return fmt.Errorf("invalid revision %q", rev)
+	}
+	cmd := command("git", "checkout", rev, "--")
+	cmd.Dir = dir
+	return runLogged(cmd)
+}
+
+func (gitVCS) commitID(dir, rev string) (string, error) {
+	out, err := git(dir, "rev-parse", "--verify", rev+"^{commit}")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (gitVCS) files(dir, rev string) ([]string, error) {
+	out, err := git(dir, "ls-tree", "-r", "--name-only", rev)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
+func (gitVCS) show(dir, rev, path string) ([]byte, error) {
+	return git(dir, "show", rev+":"+path)
+}
+
+func (gitVCS) log(dir, oldRev, newRev, subdir string) ([]vcsCommit, error) {
+	// Records start with \x1e, and the message is delimited by \x00
+	args := []string{"log", "--reverse", "--name-only", "--format=%x1e%H%x00%B%x00"}
+	if subdir != "" {
+		args = append(args, "--relative="+subdir)
+	}
+	args = append(args, oldRev+".."+newRev)
+	if subdir != "" {
+		args = append(args, "--", subdir)
+	}
+	out, err := git(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLogRecords(out), nil
+}
+
+// parseLogRecords parses a log whose records start with \x1e and hold the
+// hash, message and newline separated files, delimited by \x00
+func parseLogRecords(out []byte) []vcsCommit {
+	var commits []vcsCommit
+	for _, record := range strings.Split(string(out), "\x1e") {
+		hash, rest, ok := strings.Cut(record, "\x00")
+		if !ok {
+			continue
+		}
+		message, files, _ := strings.Cut(rest, "\x00")
+		c := vcsCommit{Hash: strings.TrimSpace(hash), Message: message}
+		for _, file := range strings.Split(files, "\n") {
+			if file = strings.TrimSpace(file); file != "" {
+				c.Files = append(c.Files, file)
+			}
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
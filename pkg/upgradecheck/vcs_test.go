@@ -0,0 +1,293 @@
+package upgradecheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// gitTestEnv runs the rest of a test with git configured to commit without a
+// user's configuration, plus the given overrides
+func gitTestEnv(t *testing.T, overrides map[string]string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	env := map[string]string{
+		"HOME":                t.TempDir(),
+		"GIT_CONFIG_NOSYSTEM": "1",
+		"GIT_AUTHOR_NAME":     "Test",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "Test",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+	}
+	for k, v := range overrides {
+		env[k] = v
+	}
+	prev := toolEnv
+	toolEnv = buildToolEnv(os.Environ(), env)
+	t.Cleanup(func() { toolEnv = prev })
+}
+
+// gitTestRepo creates a repository with a v1.0.0 tag and a later commit
+// changing a file of pkg
+func gitTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		if _, err := git(dir, args...); err != nil {
+			t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+		}
+	}
+	write := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q", "-b", "main")
+	write("go.mod", "module example.com/m\n")
+	write("pkg/a.go", "package pkg\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "Initial commit")
+	run("tag", "v1.0.0")
+	write("pkg/a.go", "package pkg\n\nfunc A() {}\n")
+	write("README", "m\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "Add A\n\nBREAKING CHANGE: none really")
+	return dir
+}
+
+func TestNewVCS(t *testing.T) {
+	tests := []struct {
+		name string
+		want vcs
+	}{
+		{VCSGit, gitVCS{}},
+		{VCSMercurial, hgVCS{}},
+		{VCSSubversion, svnVCS{}},
+		{"bzr", nil},
+		{VCSAuto, nil},
+	}
+	for _, tt := range tests {
+		got, err := newVCS(tt.name)
+		if got != tt.want || (err != nil) != (tt.want == nil) {
+			t.Errorf("newVCS(%q) = %T, %v; want %T", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestGitVCS(t *testing.T) {
+	// Local repositories are only cloned when GIT_ALLOW_PROTOCOL allows it
+	gitTestEnv(t, map[string]string{"GIT_ALLOW_PROTOCOL": "file"})
+	origin := gitTestRepo(t)
+	dir := filepath.Join(t.TempDir(), "clone")
+
+	var v gitVCS
+	if err := v.clone(origin, dir); err != nil {
+		t.Fatalf("clone() error: %v", err)
+	}
+	if rev, ok := v.tag(dir, "v1.0.0"); !ok || rev != "v1.0.0" {
+		t.Errorf("tag(v1.0.0) = %q, %v", rev, ok)
+	}
+	if _, ok := v.tag(dir, "v2.0.0"); ok {
+		t.Error("tag(v2.0.0) found a missing tag")
+	}
+
+	files, err := v.files(dir, "v1.0.0")
+	if err != nil || !slices.Equal(files, []string{"go.mod", "pkg/a.go"}) {
+		t.Errorf("files(v1.0.0) = %q, %v", files, err)
+	}
+	if data, err := v.show(dir, v.head(), "pkg/a.go"); err != nil || !strings.Contains(string(data), "func A") {
+		t.Errorf("show(HEAD, pkg/a.go) = %q, %v", data, err)
+	}
+
+	commits, err := v.log(dir, "v1.0.0", v.head(), "pkg")
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("log(v1.0.0, HEAD, pkg) = %v, %v; want one commit", commits, err)
+	}
+	if !strings.HasPrefix(commits[0].Message, "Add A\n") || !slices.Equal(commits[0].Files, []string{"a.go"}) {
+		t.Errorf("log() commit = %q with files %q; want \"Add A\" changing a.go", commits[0].Message, commits[0].Files)
+	}
+	id, err := v.commitID(dir, v.head())
+	if err != nil || id != commits[0].Hash {
+		t.Errorf("commitID(HEAD) = %q, %v; want %q", id, err, commits[0].Hash)
+	}
+
+	if err := v.checkout(dir, "v1.0.0"); err != nil {
+		t.Fatalf("checkout(v1.0.0) error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "pkg", "a.go")); string(data) != "package pkg\n" {
+		t.Errorf("pkg/a.go at v1.0.0 = %q", data)
+	}
+}
+
+func TestGitVCSRejectsOptions(t *testing.T) {
+	gitTestEnv(t, nil)
+	origin := gitTestRepo(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+
+	var v gitVCS
+	tests := []struct {
+		name, url string
+	}{
+		{"ext transport", "ext::sh -c touch% " + marker},
+		{"option as URL", "--upload-pack=touch " + marker},
+		// The file transport is only allowed through GIT_ALLOW_PROTOCOL
+		{"local repository", origin},
+		{"file URL", "file://" + origin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.clone(tt.url, filepath.Join(t.TempDir(), "clone")); err == nil {
+				t.Errorf("clone(%q) succeeded", tt.url)
+			}
+			if _, err := os.Stat(marker); err == nil {
+				t.Fatalf("clone(%q) ran a command", tt.url)
+			}
+		})
+	}
+
+	for _, rev := range []string{"-b", "--orphan=x", "-"} {
+		if err := v.checkout(origin, rev); err == nil || !strings.Contains(err.Error(), "invalid revision") {
+			t.Errorf("checkout(%q) error = %v, want an invalid revision", rev, err)
+		}
+	}
+}
+
+func TestParseLogRecords(t *testing.T) {
+	out := "\x1eaaa\x00Fix a\n\nDetails\n\x00\na.go\nb/c.go\n\x1ebbb\x00Empty\x00\n"
+	want := []vcsCommit{
+		{Hash: "aaa", Message: "Fix a\n\nDetails\n", Files: []string{"a.go", "b/c.go"}},
+		{Hash: "bbb", Message: "Empty"},
+	}
+	got := parseLogRecords([]byte(out))
+	if len(got) != len(want) {
+		t.Fatalf("parseLogRecords() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Hash != want[i].Hash || got[i].Message != want[i].Message || !slices.Equal(got[i].Files, want[i].Files) {
+			t.Errorf("commit %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestValidRepoURL(t *testing.T) {
+	tests := []struct {
+		system, url string
+		want        bool
+	}{
+		{VCSGit, "https://github.com/googleapis/google-cloud-go", true},
+		{VCSGit, "ssh://git@git.example.com/m.git", true},
+		{VCSGit, "git+ssh://git@git.example.com/m.git", true},
+		{VCSGit, "http://git.example.com/m.git", false},
+		{VCSGit, "git://git.example.com/m.git", false},
+		{VCSGit, "file:///srv/git/m.git", false},
+		{VCSGit, "ext::sh -c touch% /tmp/pwned", false},
+		{VCSGit, "-uhttps://git.example.com/m.git", false},
+		{VCSGit, "--upload-pack=touch /tmp/pwned", false},
+		{VCSGit, "/srv/git/m.git", false},
+		{VCSGit, "git@github.com:org/m.git", false},
+		{VCSGit, "svn://svn.example.com/m", false},
+		{VCSMercurial, "https://hg.example.com/m", true},
+		{VCSMercurial, "ssh://hg@hg.example.com/m", true},
+		{VCSMercurial, "git+ssh://hg.example.com/m", false},
+		{VCSSubversion, "svn://svn.example.com/m", true},
+		{VCSSubversion, "svn+ssh://svn.example.com/m", true},
+		{VCSSubversion, "https://svn.example.com/m", true},
+		{VCSSubversion, "ssh://svn.example.com/m", false},
+		{"bzr", "https://bzr.example.com/m", false},
+	}
+	for _, tt := range tests {
+		if got := validRepoURL(tt.system, tt.url); got != tt.want {
+			t.Errorf("validRepoURL(%s, %q) = %v, want %v", tt.system, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestResolveGoImport(t *testing.T) {
+	var meta string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><head>%s</head></html>`, meta)
+	}))
+	defer server.Close()
+	prevClient := proxyClient
+	proxyClient = server.Client()
+	defer func() { proxyClient = prevClient }()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	tests := []struct {
+		name       string
+		meta       string
+		wantRemote string
+		wantRoot   string
+		wantVCS    vcs
+	}{
+		{
+			name:       "git",
+			meta:       `<meta name="go-import" content="HOST/vanity git https://git.example.com/vanity">`,
+			wantRemote: "https://git.example.com/vanity",
+			wantRoot:   "HOST/vanity",
+			wantVCS:    gitVCS{},
+		},
+		{
+			name:       "module proxy entry skipped",
+			meta:       `<meta name="go-import" content="HOST/vanity mod https://proxy.example.com"><meta name="go-import" content="HOST/vanity hg ssh://hg@hg.example.com/vanity">`,
+			wantRemote: "ssh://hg@hg.example.com/vanity",
+			wantRoot:   "HOST/vanity",
+			wantVCS:    hgVCS{},
+		},
+		{
+			name:       "option",
+			meta:       `<meta name="go-import" content="HOST/vanity git --upload-pack=touch%20/tmp/pwned">`,
+			wantRemote: "https://HOST/vanity/m.git",
+			wantRoot:   "HOST/vanity/m",
+			wantVCS:    gitVCS{},
+		},
+		{
+			name:       "ext transport",
+			meta:       `<meta name="go-import" content="HOST/vanity git ext::sh%20-c%20id">`,
+			wantRemote: "https://HOST/vanity/m.git",
+			wantRoot:   "HOST/vanity/m",
+			wantVCS:    gitVCS{},
+		},
+		{
+			name:       "file URL",
+			meta:       `<meta name="go-import" content="HOST/vanity git file:///etc">`,
+			wantRemote: "https://HOST/vanity/m.git",
+			wantRoot:   "HOST/vanity/m",
+			wantVCS:    gitVCS{},
+		},
+		{
+			name:       "other path",
+			meta:       `<meta name="go-import" content="HOST/other git https://git.example.com/other">`,
+			wantRemote: "https://HOST/vanity/m.git",
+			wantRoot:   "HOST/vanity/m",
+			wantVCS:    gitVCS{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta = strings.ReplaceAll(tt.meta, "HOST", host)
+			r := &moduleRepo{URL: "https://" + host + "/vanity/m.git"}
+			r.resolve(context.Background())
+			wantRemote := strings.ReplaceAll(tt.wantRemote, "HOST", host)
+			wantRoot := strings.ReplaceAll(tt.wantRoot, "HOST", host)
+			if r.Remote != wantRemote || r.Root != wantRoot || r.VCS != tt.wantVCS {
+				t.Errorf("resolve() = %s at %s with %T; want %s at %s with %T", r.Remote, r.Root, r.VCS, wantRemote, wantRoot, tt.wantVCS)
+			}
+		})
+	}
+}
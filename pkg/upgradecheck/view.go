@@ -0,0 +1,211 @@
+package upgradecheck
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Report views understood by --view
+const (
+	ViewInline     = "inline"
+	ViewSideBySide = "side-by-side"
+)
+
+// splitSignature breaks a Go declaration into the text before its parameter list,
+// the individual parameters, and the text after the parameter list. Declarations
+// without a parameter list are returned whole as the head.
+func splitSignature(sig string) (head string, params []string, tail string) {
+	start := paramListStart(sig, 0)
+	if start < 0 {
+		return sig, nil, ""
+	}
+	// Skip over a method receiver so the real parameter list is used
+	if strings.HasPrefix(sig, "func (") {
+		end := matchingParen(sig, start)
+		if end < 0 {
+			return sig, nil, ""
+		}
+		start = paramListStart(sig, end+1)
+		if start < 0 {
+			return sig, nil, ""
+		}
+	}
+	end := matchingParen(sig, start)
+	if end < 0 {
+		return sig, nil, ""
+	}
+
+	return sig[:start+1], splitTopLevel(sig[start+1 : end]), sig[end:]
+}
+
+// paramListStart returns the index of the first parenthesis in s from index
+// from that isn't inside brackets, skipping the type parameter list of a
+// generic function such as "func Map[T interface{ Key() string }](xs []T)"
+func paramListStart(s string, from int) int {
+	depth := 0
+	for i := from; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '(':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open
+func matchingParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits a comma separated list, ignoring commas nested inside
+// brackets, braces or parentheses
+func splitTopLevel(list string) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	for i, r := range list {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(list[last:i]))
+				last = i + 1
+			}
+		}
+	}
+	if rest := strings.TrimSpace(list[last:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// sideBySideRows lays out the old and new declarations as aligned rows, one
+// parameter per row, with a marker describing how each row changed
+func sideBySideRows(oldSig, newSig string) [][3]string {
+	oldHead, oldParams, oldTail := splitSignature(oldSig)
+	newHead, newParams, newTail := splitSignature(newSig)
+
+	var rows [][3]string
+	add := func(oldCol, newCol string) {
+		marker := " "
+		switch {
+		case oldCol == newCol:
+		case oldCol == "":
+			marker = "+"
+		case newCol == "":
+			marker = "-"
+		default:
+			marker = "~"
+		}
+		rows = append(rows, [3]string{marker, oldCol, newCol})
+	}
+
+	// Nothing to align when neither side has parameters
+	if len(oldParams) == 0 && len(newParams) == 0 {
+		add(oldSig, newSig)
+		return rows
+	}
+
+	add(oldHead, newHead)
+	for i := 0; i < len(oldParams) || i < len(newParams); i++ {
+		var o, n string
+		if i < len(oldParams) {
+			o = "    " + oldParams[i] + ","
+		}
+		if i < len(newParams) {
+			n = "    " + newParams[i] + ","
+		}
+		add(o, n)
+	}
+	if oldTail != "" || newTail != "" {
+		add(oldTail, newTail)
+	}
+	return rows
+}
+
+// printSideBySide writes the text report with old and new declarations in two
+// aligned columns, locating usages of breaking findings under root like
+// printFindings
+func printSideBySide(findings []Finding, root string) {
+	if len(findings) == 0 {
+		fmt.Println("No breaking changes detected.")
+		return
+	}
+
+	classes, groups := findingsByClass(findings)
+	for i, class := range classes {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(classTitle(class) + ":")
+		printSideBySideFindings(groups[class], root)
+	}
+	fmt.Println()
+	printSummary(findings)
+}
+
+func printSideBySideFindings(findings []Finding, root string) {
+	for _, f := range findings {
+		fmt.Printf("\n- [%s] %s (%s)\n", f.grade(), f.Symbol, f.Kind)
+
+		var rows [][3]string
+		switch f.Kind {
+		case ChangeRemoved:
+			rows = [][3]string{{"-", f.OldSignature, "removed"}}
+		case ChangeAdded:
+			rows = [][3]string{{"+", "", f.NewSignature}}
+		case ChangeImplementer:
+			if f.OldSignature == "" {
+				rows = [][3]string{{"+", "", f.NewSignature}}
+			} else {
+				rows = sideBySideRows(f.OldSignature, f.NewSignature)
+			}
+		case ChangeBehavior, ChangeImplements, ChangeDeprecated, ChangeConfig, ChangeErrorHandling, ChangeUnstableAPI:
+			// Documentation or method sets are compared rather than declarations;
+			// the notes explain it
+		default:
+			rows = sideBySideRows(f.OldSignature, f.NewSignature)
+		}
+
+		width := len("OLD")
+		for _, row := range rows {
+			if len(row[1]) > width {
+				width = len(row[1])
+			}
+		}
+		if len(rows) > 0 {
+			fmt.Printf("    %-*s | %s\n", width, "OLD", "NEW")
+		}
+		for _, row := range rows {
+			fmt.Printf("  %s %-*s | %s\n", row[0], width, row[1], row[2])
+		}
+		for _, note := range f.Notes {
+			fmt.Println("    note: " + note)
+		}
+		if len(f.Owners) > 0 {
+			fmt.Println("    owners: " + strings.Join(f.Owners, ", "))
+		}
+		printUsages(f, root)
+	}
+}
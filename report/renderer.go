@@ -0,0 +1,60 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Renderer writes a report in one output format
+type Renderer interface {
+	Render(w io.Writer, r *Report) error
+}
+
+// RendererFunc adapts a function to a Renderer
+type RendererFunc func(w io.Writer, r *Report) error
+
+func (f RendererFunc) Render(w io.Writer, r *Report) error {
+	return f(w, r)
+}
+
+var (
+	mu        sync.RWMutex
+	renderers = make(map[string]Renderer)
+)
+
+// Register makes a renderer available under a format name, typically from the
+// init function of the package implementing it. It panics if the name is
+// empty or already registered.
+func Register(format string, r Renderer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if format == "" || r == nil {
+		panic("report: Register needs a format name and a renderer")
+	}
+	if _, dup := renderers[format]; dup {
+		panic(fmt.Sprintf("report: format %q registered twice", format))
+	}
+	renderers[format] = r
+}
+
+// Lookup returns the renderer registered for a format
+func Lookup(format string) (Renderer, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	r, ok := renderers[format]
+	return r, ok
+}
+
+// Formats returns the registered format names, sorted
+func Formats() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	formats := make([]string, 0, len(renderers))
+	for format := range renderers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
@@ -0,0 +1,117 @@
+// Package report is the stable Go API to the reports of go-upgrade-checker:
+// the JSON report written by --format=json, and a registry of renderers that
+// turn it into custom output formats, e.g. Confluence wiki markup or internal
+// ticket templates.
+//
+// The types mirror the JSON report, whose fields are only ever added to.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Report is the complete result of a run
+type Report struct {
+	Module     string `json:"module"`
+	OldVersion string `json:"old_version"`
+	NewVersion string `json:"new_version"`
+	// Deprecated is the deprecation notice of the new version, if any
+	Deprecated string `json:"deprecated,omitempty"`
+	// Interrupted says why the run ended early, making this a partial report
+	// of the services analyzed before
+	Interrupted string `json:"interrupted,omitempty"`
+	// Unanalyzed are the packages of the module versions the indexer couldn't
+	// index; changes to them are missing from the report
+	Unanalyzed []PackageGap `json:"unanalyzed,omitempty"`
+	Services   []*Service   `json:"services"`
+}
+
+// Service holds the findings for one project analyzed in a run. Monorepos
+// check several projects, one per service.
+type Service struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Findings []Finding `json:"findings"`
+	// UsedSymbols are the module symbols the service uses, changed or not
+	UsedSymbols []string `json:"used_symbols,omitempty"`
+	// Targets is the per-target impact in Bazel workspaces
+	Targets []TargetImpact `json:"targets,omitempty"`
+	// Build compares building a package against both versions
+	Build *BuildImpact `json:"build,omitempty"`
+	// Unanalyzed are the packages of the project the indexer couldn't index;
+	// their usages are missing from the findings
+	Unanalyzed []PackageGap `json:"unanalyzed,omitempty"`
+}
+
+// PackageGap is a package left out of the analysis because it couldn't be
+// indexed, e.g. for a build error or a missing C toolchain
+type PackageGap struct {
+	Package string `json:"package"`
+	// Version is the module version of the package; it is empty for packages
+	// of the project
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error"`
+}
+
+// Finding describes a single change to a module symbol the project uses
+type Finding struct {
+	// Fingerprint identifies the change across runs
+	Fingerprint string `json:"fingerprint"`
+	// Symbol is the Go name of the symbol, e.g. "api.Client.Do"
+	Symbol string `json:"symbol"`
+	// Package is the import path of the package defining the symbol, if known
+	Package string `json:"package,omitempty"`
+	// Kind is e.g. "removed", "changed", "results", "added", "behavior",
+	// "error-handling", "implements", "deprecated", "unstable-api" or "config"
+	Kind string `json:"kind"`
+	// Class is "breaking", "configuration", "behavioral", "deprecated",
+	// "unstable" or "compatible"
+	Class string `json:"class,omitempty"`
+	// Severity is "info", "warning", "breaking" or "critical"
+	Severity string `json:"severity"`
+	// Confidence is "heuristic", "high" or "exact"
+	Confidence   string     `json:"confidence"`
+	OldSignature string     `json:"old_signature,omitempty"`
+	NewSignature string     `json:"new_signature,omitempty"`
+	Usages       []Location `json:"usages,omitempty"`
+	Notes        []string   `json:"notes,omitempty"`
+	Owners       []string   `json:"owners,omitempty"`
+	// Accepted findings are listed in the baseline of the run and don't fail
+	// it
+	Accepted bool `json:"accepted,omitempty"`
+}
+
+// Location is a position in a project file, relative to the project root.
+// Line and Column are 1-based; both are zero when only the file is known.
+type Location struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// TargetImpact is the verdict for one Bazel target depending on the module
+type TargetImpact struct {
+	Label   string   `json:"label"`
+	Verdict string   `json:"verdict"`
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// BuildImpact compares building a package against both versions
+type BuildImpact struct {
+	Package    string  `json:"package"`
+	OldSize    int64   `json:"old_size_bytes"`
+	NewSize    int64   `json:"new_size_bytes"`
+	OldSeconds float64 `json:"old_build_seconds"`
+	NewSeconds float64 `json:"new_build_seconds"`
+}
+
+// Read decodes a JSON report
+func Read(r io.Reader) (*Report, error) {
+	var report Report
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid report: %w", err)
+	}
+	return &report, nil
+}